@@ -17,7 +17,7 @@ import (
 
 type allowListCmdConfig struct {
 	Name    string // allowlist name
-	Network string // RPC network space ("cfx" or "eth")
+	Network string // RPC network space ("cfx", "eth" or EVM chain name/ID)
 	Rules   string // allowlist rules config json
 }
 
@@ -57,7 +57,7 @@ func init() {
 func hookAllowListCmdFlags(cmd *cobra.Command, hookName, hookRules bool) {
 	{ // RPC network space
 		cmd.Flags().StringVarP(
-			&alCfg.Network, "network", "n", "cfx", "RPC network space ('cfx' or 'eth')",
+			&alCfg.Network, "network", "n", "cfx", "RPC network space ('cfx', 'eth' or EVM chain name/ID)",
 		)
 		cmd.MarkFlagRequired("network")
 	}
@@ -217,25 +217,28 @@ func validateAllowListCmdConfig(validateName, validateRules bool) (*acl.AllowLis
 		return nil, errors.WithMessage(err, "invalid allowlist contract addresses")
 	}
 
+	if q := al.FilterQuota; q != nil && (q.MaxFilters < 0 || q.MaxLogs < 0 || q.MaxCriteria < 0) {
+		return nil, errors.New("The virtual filter quota must not be negative")
+	}
+
 	return al, nil
 }
 
 func validateAllowListContractAddresses(al *acl.AllowList) error {
-	if strings.EqualFold(alCfg.Network, "eth") {
-		for _, caddr := range al.ContractAddresses {
-			if !common.IsHexAddress(caddr) {
-				return errors.Errorf("%v is not a hex address", caddr)
-			}
-		}
-		return nil
-	}
-
 	if strings.EqualFold(alCfg.Network, "cfx") {
 		for _, ctAddr := range al.ContractAddresses {
 			if _, err := cfxaddress.NewFromBase32(ctAddr); err != nil {
 				return errors.WithMessagef(err, "%v is not a valid base32 string", ctAddr)
 			}
 		}
+		return nil
+	}
+
+	// evm space or EVM chains
+	for _, caddr := range al.ContractAddresses {
+		if !common.IsHexAddress(caddr) {
+			return errors.Errorf("%v is not a hex address", caddr)
+		}
 	}
 
 	return nil
@@ -0,0 +1,59 @@
+package bigcontract
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// network space ("cfx" or "eth")
+	network string
+
+	reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Dry run big contract policy to report contracts to be promoted or demoted",
+		Run:   report,
+	}
+)
+
+func init() {
+	Cmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVarP(
+		&network, "network", "n", "cfx", "network space ('cfx' or 'eth')",
+	)
+}
+
+func report(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	dbs, err := storeCtx.GetMysqlStore(network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return
+	}
+
+	report, err := dbs.EvaluateBigContractPolicy()
+	if err != nil {
+		logrus.WithError(err).Info("Failed to evaluate big contract policy")
+		return
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logrus.WithError(err).Info("Failed to marshal big contract policy report")
+		return
+	}
+
+	fmt.Println(string(data))
+}
@@ -0,0 +1,15 @@
+package bigcontract
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "bigcontract",
+		Short: "Big contract utility toolset",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+)
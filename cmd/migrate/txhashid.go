@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// network space ("cfx", "eth" or the EVM chain name)
+	network string
+
+	txHashIdCmd = &cobra.Command{
+		Use:   "txhashid",
+		Short: "Add tx hash id column along with index to the event log tables created before",
+		Run:   migrateTxHashId,
+	}
+)
+
+func init() {
+	Cmd.AddCommand(txHashIdCmd)
+
+	txHashIdCmd.Flags().StringVarP(
+		&network, "network", "n", "cfx", "network space ('cfx', 'eth' or the EVM chain name)",
+	)
+}
+
+func migrateTxHashId(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	dbs, err := storeCtx.GetMysqlStore(network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get MySQL store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("Mysql store is unavailable")
+		return
+	}
+
+	if err := dbs.MigrateLogTxHashId(); err != nil {
+		logrus.WithError(err).Info("Failed to migrate tx hash id of event log tables")
+		return
+	}
+
+	logrus.Info("Event log tables migrated with tx hash id")
+}
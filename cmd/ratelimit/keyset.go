@@ -13,7 +13,7 @@ import (
 )
 
 type keysetCmdConfig struct {
-	Network   string         // RPC network space ("cfx" or "eth")
+	Network   string         // RPC network space ("cfx", "eth" or EVM chain name/ID)
 	Strategy  string         // rate limit strategy
 	AllowList string         // acl allow list
 	LimitKey  string         // rate limit key
@@ -287,7 +287,7 @@ func validateKeysetCmdConfig(validateStrategy, validateLimitKey, validateLimitTy
 func hookKeysetCmdFlags(keysetCmd *cobra.Command, hookNetwork, hookStrategy, hookLimitKey, hookLimitType bool) {
 	if hookNetwork { // RPC network space
 		keysetCmd.Flags().StringVarP(
-			&keysetCfg.Network, "network", "n", "cfx", "RPC network space ('cfx' or 'eth')",
+			&keysetCfg.Network, "network", "n", "cfx", "RPC network space ('cfx', 'eth' or EVM chain name/ID)",
 		)
 		keysetCmd.MarkFlagRequired("network")
 	}
@@ -14,7 +14,7 @@ import (
 
 type strategyCmdConfig struct {
 	Name    string // strategy name
-	Network string // RPC network space ("cfx" or "eth")
+	Network string // RPC network space ("cfx", "eth" or EVM chain name/ID)
 	Rules   string // strategy rules config json
 }
 
@@ -54,7 +54,7 @@ func init() {
 func hookStrategyCmdFlags(stratCmd *cobra.Command, hookName, hookRules bool) {
 	{ // RPC network space
 		stratCmd.Flags().StringVarP(
-			&stratCfg.Network, "network", "n", "cfx", "RPC network space ('cfx' or 'eth')",
+			&stratCfg.Network, "network", "n", "cfx", "RPC network space ('cfx', 'eth' or EVM chain name/ID)",
 		)
 		stratCmd.MarkFlagRequired("network")
 	}
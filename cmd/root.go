@@ -7,10 +7,13 @@ import (
 	"sync"
 
 	"github.com/Conflux-Chain/confura/cmd/acl"
+	"github.com/Conflux-Chain/confura/cmd/bigcontract"
+	"github.com/Conflux-Chain/confura/cmd/migrate"
 	"github.com/Conflux-Chain/confura/cmd/noderoute"
 	"github.com/Conflux-Chain/confura/cmd/ratelimit"
 	"github.com/Conflux-Chain/confura/cmd/test"
 	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/cmd/webhook"
 	"github.com/Conflux-Chain/confura/config"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -60,6 +63,9 @@ func init() {
 	rootCmd.AddCommand(ratelimit.Cmd)
 	rootCmd.AddCommand(noderoute.Cmd)
 	rootCmd.AddCommand(acl.Cmd)
+	rootCmd.AddCommand(bigcontract.Cmd)
+	rootCmd.AddCommand(webhook.Cmd)
+	rootCmd.AddCommand(migrate.Cmd)
 }
 
 func start(cmd *cobra.Command, args []string) {
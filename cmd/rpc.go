@@ -12,9 +12,12 @@ import (
 	"github.com/Conflux-Chain/confura/cmd/util"
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/rpc"
+	"github.com/Conflux-Chain/confura/rpc/cache"
 	"github.com/Conflux-Chain/confura/rpc/handler"
+	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/store/redis"
 	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/chain"
 	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/Conflux-Chain/confura/util/relay"
 	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
@@ -131,8 +134,13 @@ func startNativeSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx
 
 		// initialize logs api handler
 		option.LogApiHandler = handler.NewCfxLogsApiHandler(storeCtx.CfxDB, prunedHandler)
+		// initialize finality handler
+		option.FinalityHandler = handler.NewFinalityHandler(storeCtx.CfxDB)
 	}
 
+	// initialize response cache
+	option.ResponseCache = cache.MustNewResponseCacheFromViper(ctx, "cfx", "sync.sink")
+
 	// initialize RPC server
 	exposedModules := viper.GetStringSlice("rpc.exposedModules")
 	server := rpc.MustNewNativeSpaceServer(rateReg, clientProvider, gasHandler, exposedModules, option)
@@ -178,6 +186,8 @@ func startEvmSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx ut
 		option.StoreHandler = handler.NewEthStoreHandler(storeCtx.EthDB, nil)
 		// initialize logs api handler
 		option.LogApiHandler = handler.NewEthLogsApiHandler(storeCtx.EthDB)
+		// initialize finality handler
+		option.FinalityHandler = handler.NewFinalityHandler(storeCtx.EthDB)
 
 		rateKeyLoader := rate.NewKeyLoader(storeCtx.EthDB.LoadRateLimitKeyInfos)
 		rateReg = rate.NewRegistry(rateKeyLoader, acl.NewEthValidator)
@@ -186,10 +196,35 @@ func startEvmSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx ut
 		go rateReg.AutoReload(15*time.Second, storeCtx.EthDB.LoadRateLimitConfigs)
 	}
 
+	// initialize response cache
+	option.ResponseCache = cache.MustNewResponseCacheFromViper(ctx, "eth", "sync.eth.sink")
+
 	// initialize RPC server
 	exposedModules := viper.GetStringSlice("ethrpc.exposedModules")
 	server := rpc.MustNewEvmSpaceServer(rateReg, clientProvider, gasHandler, exposedModules, option)
 
+	// route requests to EVM chains by path `/v1/<chainId>` or host names
+	if chains := chain.Configs(); len(chains) > 0 {
+		routes, hosts := make(map[string]*rpcutil.Server), make(map[string]*rpcutil.Server)
+
+		for _, cc := range chains {
+			chainServer := newEvmChainRpcServer(ctx, cc, storeCtx.ChainDBs[cc.ChainId], exposedModules)
+
+			routes[cc.Route()] = chainServer
+			for _, host := range cc.Hosts {
+				hosts[host] = chainServer
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"chain":   cc.Name,
+				"chainId": cc.ChainId,
+				"hosts":   cc.Hosts,
+			}).Info("EVM chain RPC server routed")
+		}
+
+		server = rpcutil.NewRoutedServer(server.String(), server, routes, hosts)
+	}
+
 	// serve HTTP endpoint
 	httpEndpoint := viper.GetString("ethrpc.endpoint")
 	go server.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
@@ -206,6 +241,54 @@ func startEvmSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx ut
 	}
 }
 
+// newEvmChainRpcServer creates RPC server of EVM chain, of which the fullnode groups, db store, txn
+// relay, response cache and rate limits are all scoped by the chain.
+//
+// Note virtual filters are not supported for EVM chains, since the virtual filter service serves
+// evm space only, and filter APIs are delegated to the chain fullnodes directly.
+func newEvmChainRpcServer(
+	ctx context.Context, cc *chain.Config, db *mysql.MysqlStore, exposedModules []string,
+) *rpcutil.Server {
+	var rateReg *rate.Registry
+
+	if viper.IsSet(cc.ViperKey("virtualFilters")) {
+		logrus.WithField("chain", cc.Name).Fatal("Virtual filters not supported for EVM chain")
+	}
+
+	router := node.NewChainRouter(cc.ViperKey("node"))
+	clientProvider := node.NewEthClientProvider(db, router)
+
+	relayer := relay.MustNewEthTxnRelayerFromViperKey(cc.ViperKey("relay"))
+	groupConf := node.MustNewChainUrlConfigFromViper(cc.ViperKey("node"))
+
+	option := rpc.EthAPIOption{
+		TxnHandler: handler.MustNewEthChainTxnHandler(relayer, cc.ViperKey("relay"), groupConf),
+	}
+
+	// initialize gas station handler
+	gasHandler := handler.MustNewEthGasStationHandlerFromViper(clientProvider)
+
+	if db != nil {
+		// initialize store handler
+		option.StoreHandler = handler.NewEthStoreHandler(db, nil)
+		// initialize logs api handler
+		option.LogApiHandler = handler.NewEthLogsApiHandler(db)
+		// initialize finality handler
+		option.FinalityHandler = handler.NewFinalityHandler(db)
+
+		rateKeyLoader := rate.NewKeyLoader(db.LoadRateLimitKeyInfos)
+		rateReg = rate.NewRegistry(rateKeyLoader, acl.NewEthValidator)
+
+		// periodically reload rate limit settings from db
+		go rateReg.AutoReload(15*time.Second, db.LoadRateLimitConfigs)
+	}
+
+	// initialize response cache, which is invalidated by chain reorg events of the chain syncer
+	option.ResponseCache = cache.MustNewResponseCacheFromViper(ctx, "chain"+cc.Route(), cc.ViperKey("sync.sink"))
+
+	return rpc.MustNewEvmChainServer(cc.ChainId, rateReg, clientProvider, gasHandler, exposedModules, option)
+}
+
 // startNativeSpaceBridgeRpcServer starts core space bridge RPC server
 func startNativeSpaceBridgeRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext) {
 	// Initialize ratelimit registry
@@ -5,7 +5,9 @@ import (
 	"sync"
 
 	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/store"
 	cisync "github.com/Conflux-Chain/confura/sync"
+	"github.com/Conflux-Chain/confura/util/chain"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -13,8 +15,9 @@ import (
 var (
 	// sync boot options
 	syncOpt struct {
-		dbSyncEnabled  bool
-		ethSyncEnabled bool
+		dbSyncEnabled    bool
+		ethSyncEnabled   bool
+		chainSyncEnabled bool
 	}
 
 	syncCmd = &cobra.Command{
@@ -35,11 +38,16 @@ func init() {
 		&syncOpt.ethSyncEnabled, "eth", false, "start ETH sync server",
 	)
 
+	// boot flag for EVM chains sync
+	syncCmd.Flags().BoolVar(
+		&syncOpt.chainSyncEnabled, "chains", false, "start sync servers of all configured EVM chains",
+	)
+
 	rootCmd.AddCommand(syncCmd)
 }
 
 func startSyncService(*cobra.Command, []string) {
-	if !syncOpt.dbSyncEnabled && !syncOpt.ethSyncEnabled {
+	if !syncOpt.dbSyncEnabled && !syncOpt.ethSyncEnabled && !syncOpt.chainSyncEnabled {
 		logrus.Fatal("No Sync server specified")
 	}
 
@@ -60,12 +68,16 @@ func startSyncService(*cobra.Command, []string) {
 		startSyncEthDatabase(ctx, &wg, syncCtx)
 	}
 
+	if syncOpt.chainSyncEnabled { // start EVM chains sync
+		startSyncChainDatabases(ctx, &wg, syncCtx)
+	}
+
 	util.GracefulShutdown(&wg, cancel)
 }
 
 // startSyncServiceAdaptively adaptively starts kinds of sync server per to store instances.
 func startSyncServiceAdaptively(ctx context.Context, wg *sync.WaitGroup, syncCtx util.SyncContext) {
-	if syncCtx.CfxDB == nil && syncCtx.EthDB == nil {
+	if syncCtx.CfxDB == nil && syncCtx.EthDB == nil && len(syncCtx.ChainDBs) == 0 {
 		logrus.Fatal("No data sync configured")
 	}
 
@@ -76,6 +88,10 @@ func startSyncServiceAdaptively(ctx context.Context, wg *sync.WaitGroup, syncCtx
 	if syncCtx.EthDB != nil { // start ETH sync
 		startSyncEthDatabase(ctx, wg, syncCtx)
 	}
+
+	if len(syncCtx.ChainDBs) > 0 { // start EVM chains sync
+		startSyncChainDatabases(ctx, wg, syncCtx)
+	}
 }
 
 func startSyncCfxDatabase(ctx context.Context, wg *sync.WaitGroup, syncCtx util.SyncContext) *cisync.DatabaseSyncer {
@@ -87,6 +103,20 @@ func startSyncCfxDatabase(ctx context.Context, wg *sync.WaitGroup, syncCtx util.
 	// start core space db prune
 	go syncCtx.CfxDB.Prune()
 
+	// start core space db prune by retention policy
+	if rc := &store.StoreConfig().Retention; rc.Enabled() {
+		pruner := cisync.NewCfxRetentionPruner(syncCtx.SyncCfx, syncCtx.CfxDB, rc)
+		go pruner.Prune(ctx, wg)
+	}
+
+	// start core space data integrity audit
+	if auditor := cisync.MustNewCfxAuditor(syncCtx.SyncCfx, syncCtx.CfxDB); auditor != nil {
+		go auditor.Audit(ctx, wg)
+	}
+
+	// start core space big contract policy
+	syncCtx.CfxDB.ScheduleBigContractPolicy()
+
 	return syncer
 }
 
@@ -98,4 +128,42 @@ func startSyncEthDatabase(ctx context.Context, wg *sync.WaitGroup, syncCtx util.
 
 	// start evm space db prune
 	go syncCtx.EthDB.Prune()
+
+	// start evm space db prune by retention policy
+	if rc := &store.EthStoreConfig().Retention; rc.Enabled() {
+		pruner := cisync.NewEthRetentionPruner(syncCtx.SyncEth, syncCtx.EthDB, rc)
+		go pruner.Prune(ctx, wg)
+	}
+
+	// start evm space data integrity audit
+	if auditor := cisync.MustNewEthAuditor(syncCtx.SyncEth, syncCtx.EthDB); auditor != nil {
+		go auditor.Audit(ctx, wg)
+	}
+
+	// start evm space big contract policy
+	syncCtx.EthDB.ScheduleBigContractPolicy()
+}
+
+func startSyncChainDatabases(ctx context.Context, wg *sync.WaitGroup, syncCtx util.SyncContext) {
+	if len(syncCtx.ChainDBs) == 0 {
+		logrus.Fatal("No EVM chain db store configured")
+	}
+
+	for _, cc := range chain.Configs() {
+		db, ok := syncCtx.ChainDBs[cc.ChainId]
+		if !ok {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"chain":   cc.Name,
+			"chainId": cc.ChainId,
+		}).Info("Start to sync EVM chain blockchain data into database")
+
+		syncer := cisync.MustNewChainEthSyncer(syncCtx.SyncChains[cc.ChainId], db, cc)
+		go syncer.Sync(ctx, wg)
+
+		// start EVM chain db prune
+		go db.Prune()
+	}
 }
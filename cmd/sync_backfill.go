@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	cisync "github.com/Conflux-Chain/confura/sync"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// backfill options
+	backfillOpt struct {
+		network string
+		from    uint64
+		to      uint64
+		types   []string
+	}
+
+	backfillCmd = &cobra.Command{
+		Use:   "backfill",
+		Short: "Re-index chain data of the specified data types for a historic epoch (or block) range",
+		Run:   backfill,
+	}
+)
+
+func init() {
+	backfillCmd.Flags().StringVarP(
+		&backfillOpt.network, "network", "n", "cfx", "network space ('cfx' or 'eth')",
+	)
+	backfillCmd.Flags().Uint64Var(
+		&backfillOpt.from, "from", 0, "epoch (or block for evm space) to backfill from",
+	)
+	backfillCmd.Flags().Uint64Var(
+		&backfillOpt.to, "to", 0, "epoch (or block for evm space) to backfill to",
+	)
+	backfillCmd.Flags().StringSliceVar(
+		&backfillOpt.types, "types", []string{"block", "transaction", "log"},
+		"data types to backfill ('block', 'transaction' or 'log')",
+	)
+
+	backfillCmd.MarkFlagRequired("from")
+	backfillCmd.MarkFlagRequired("to")
+
+	syncCmd.AddCommand(backfillCmd)
+}
+
+func backfill(*cobra.Command, []string) {
+	dataTypes, err := cisync.ParseBackfillDataTypes(backfillOpt.types)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid data types to backfill")
+	}
+
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	dbs, err := storeCtx.GetMysqlStore(backfillOpt.network)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to get MySQL store by network")
+	}
+
+	if dbs == nil {
+		logrus.Fatal("Mysql store is unavailable")
+	}
+
+	syncCtx := util.MustInitSyncContext(storeCtx)
+	defer syncCtx.Close()
+
+	var backfiller *cisync.Backfiller
+	if strings.EqualFold(backfillOpt.network, "eth") {
+		backfiller = cisync.MustNewEthBackfiller(
+			syncCtx.SyncEth, dbs, backfillOpt.from, backfillOpt.to, dataTypes,
+		)
+	} else {
+		backfiller = cisync.MustNewCfxBackfiller(
+			syncCtx.SyncCfx, dbs, backfillOpt.from, backfillOpt.to, dataTypes,
+		)
+	}
+
+	// backfill is interrupted on termination signal, and could be resumed later
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	if err := backfiller.Backfill(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			logrus.WithError(err).Warn("Backfill canceled, which could be resumed by the same command")
+			return
+		}
+
+		logrus.WithError(err).Error("Failed to backfill")
+		return
+	}
+
+	logrus.Info("Backfill done")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/store/redis"
+	"github.com/Conflux-Chain/confura/util/chain"
 	"github.com/Conflux-Chain/confura/util/rpc"
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	"github.com/openweb3/web3go"
@@ -17,6 +18,9 @@ type StoreContext struct {
 	CfxDB    *mysql.MysqlStore
 	EthDB    *mysql.MysqlStore
 	CfxCache *redis.RedisStore
+
+	// db stores of EVM chains besides the evm space, keyed by chain ID
+	ChainDBs map[uint64]*mysql.MysqlStore
 }
 
 func MustInitStoreContext() StoreContext {
@@ -25,17 +29,30 @@ func MustInitStoreContext() StoreContext {
 	// prepare core space db store
 	if config := mysql.MustNewConfigFromViper(); config.Enabled {
 		ctx.CfxDB = config.MustOpenOrCreate(mysql.StoreOption{
-			Disabler: store.StoreConfig(),
+			Disabler:  store.StoreConfig(),
+			Retention: &store.StoreConfig().Retention,
 		})
 	}
 
 	// prepare evm space db store
 	if ethConfig := mysql.MustNewEthStoreConfigFromViper(); ethConfig.Enabled {
 		ctx.EthDB = ethConfig.MustOpenOrCreate(mysql.StoreOption{
-			Disabler: store.EthStoreConfig(),
+			Disabler:  store.EthStoreConfig(),
+			Retention: &store.EthStoreConfig().Retention,
 		})
 	}
 
+	// prepare db stores of EVM chains, which share the store settings of evm space
+	ctx.ChainDBs = make(map[uint64]*mysql.MysqlStore)
+	for _, cc := range chain.Configs() {
+		if config := mysql.MustNewChainStoreConfigFromViper(cc.ViperKey("mysql")); config.Enabled {
+			ctx.ChainDBs[cc.ChainId] = config.MustOpenOrCreate(mysql.StoreOption{
+				Disabler:  store.EthStoreConfig(),
+				Retention: &store.EthStoreConfig().Retention,
+			})
+		}
+	}
+
 	// prepare redis store
 	if redis, ok := redis.MustNewRedisStoreFromViper(store.StoreConfig()); ok {
 		ctx.CfxCache = redis
@@ -56,18 +73,26 @@ func (ctx *StoreContext) Close() {
 	if ctx.CfxCache != nil {
 		ctx.CfxCache.Close()
 	}
+
+	for _, db := range ctx.ChainDBs {
+		db.Close()
+	}
 }
 
-// GetMysqlStore returns mysql store by network space
+// GetMysqlStore returns mysql store by network space, or by chain name or ID of EVM chains.
 func (ctx *StoreContext) GetMysqlStore(network string) (store *mysql.MysqlStore, err error) {
 	switch {
 	case strings.EqualFold(network, "eth"):
 		return ctx.EthDB, nil
 	case strings.EqualFold(network, "cfx"):
 		return ctx.CfxDB, nil
-	default:
-		return nil, errors.New("invalid network space (only `cfx` and `eth` acceptable)")
 	}
+
+	if cc, ok := chain.GetConfig(strings.ToLower(network)); ok {
+		return ctx.ChainDBs[cc.ChainId], nil
+	}
+
+	return nil, errors.New("invalid network space (only `cfx`, `eth` or configured EVM chain acceptable)")
 }
 
 // SyncContext context to hold sdk clients for blockchain interoperation.
@@ -76,6 +101,9 @@ type SyncContext struct {
 
 	SyncCfx *sdk.Client
 	SyncEth *web3go.Client
+
+	// sdk clients of EVM chains besides the evm space, keyed by chain ID
+	SyncChains map[uint64]*web3go.Client
 }
 
 func MustInitSyncContext(storeCtx StoreContext) SyncContext {
@@ -89,6 +117,13 @@ func MustInitSyncContext(storeCtx StoreContext) SyncContext {
 		sc.SyncEth = rpc.MustNewEthClientFromViper(rpc.WithClientHookMetrics(true))
 	}
 
+	sc.SyncChains = make(map[uint64]*web3go.Client)
+	for _, cc := range chain.Configs() {
+		if storeCtx.ChainDBs[cc.ChainId] != nil {
+			sc.SyncChains[cc.ChainId] = rpc.MustNewEthClient(cc.Http, rpc.WithClientHookMetrics(true))
+		}
+	}
+
 	return sc
 }
 
@@ -103,4 +138,8 @@ func (ctx *SyncContext) Close() {
 	if ctx.SyncEth != nil {
 		ctx.SyncEth.Close()
 	}
+
+	for _, w3c := range ctx.SyncChains {
+		w3c.Close()
+	}
 }
@@ -0,0 +1,120 @@
+package vfadmin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	adminRequestTimeout = 10 * time.Second
+)
+
+type adminCmdConfig struct {
+	Url    string // admin RPC url of the virtual filter service instance
+	Fid    string // virtual filter id
+	Worker string // filter worker key
+}
+
+var (
+	adminCfg adminCmdConfig
+
+	listFiltersCmd = &cobra.Command{
+		Use:   "filters",
+		Short: "List active virtual filters",
+		Run: func(cmd *cobra.Command, args []string) {
+			callAdmin("admin_listFilters")
+		},
+	}
+
+	listWorkersCmd = &cobra.Command{
+		Use:   "workers",
+		Short: "List filter worker sessions along with cursor lags",
+		Run: func(cmd *cobra.Command, args []string) {
+			callAdmin("admin_listWorkers")
+		},
+	}
+
+	dumpChainCmd = &cobra.Command{
+		Use:   "chain",
+		Short: "Dump filter chain nodes of log filter worker",
+		Run: func(cmd *cobra.Command, args []string) {
+			callAdmin("admin_dumpFilterChain", adminCfg.Worker)
+		},
+	}
+
+	uninstallCmd = &cobra.Command{
+		Use:   "uninstall",
+		Short: "Force to uninstall virtual filter",
+		Run: func(cmd *cobra.Command, args []string) {
+			callAdmin("admin_uninstallFilter", rpc.ID(adminCfg.Fid))
+		},
+	}
+
+	resetWorkerCmd = &cobra.Command{
+		Use:   "reset",
+		Short: "Force to re-establish filter worker session",
+		Run: func(cmd *cobra.Command, args []string) {
+			callAdmin("admin_resetWorker", adminCfg.Worker)
+		},
+	}
+)
+
+func init() {
+	Cmd.PersistentFlags().StringVarP(
+		&adminCfg.Url, "url", "u", "http://127.0.0.1:48546", "admin RPC url of the virtual filter service",
+	)
+
+	Cmd.AddCommand(listFiltersCmd)
+	Cmd.AddCommand(listWorkersCmd)
+
+	Cmd.AddCommand(dumpChainCmd)
+	hookWorkerCmdFlag(dumpChainCmd)
+
+	Cmd.AddCommand(uninstallCmd)
+	uninstallCmd.Flags().StringVarP(&adminCfg.Fid, "fid", "f", "", "virtual filter id")
+	uninstallCmd.MarkFlagRequired("fid")
+
+	Cmd.AddCommand(resetWorkerCmd)
+	hookWorkerCmdFlag(resetWorkerCmd)
+}
+
+func hookWorkerCmdFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&adminCfg.Worker, "worker", "w", "", "filter worker key, eg., node name for log filter worker",
+	)
+	cmd.MarkFlagRequired("worker")
+}
+
+// callAdmin calls the admin RPC method of virtual filter service, and prints the result as JSON.
+func callAdmin(method string, args ...interface{}) {
+	p, err := providers.NewProviderWithOption(adminCfg.Url, providers.Option{RequestTimeout: adminRequestTimeout})
+	if err != nil {
+		logrus.WithField("url", adminCfg.Url).WithError(err).Info("Failed to create RPC provider")
+		return
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminRequestTimeout)
+	defer cancel()
+
+	var result json.RawMessage
+	if err := p.CallContext(ctx, &result, method, args...); err != nil {
+		logrus.WithField("method", method).WithError(err).Info("Failed to call virtual filter admin API")
+		return
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		logrus.WithError(err).Info("Failed to marshal admin API result")
+		return
+	}
+
+	fmt.Println(string(data))
+}
@@ -0,0 +1,13 @@
+package vfadmin
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Virtual filter service admin toolset",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/cmd/vfadmin"
 	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
 	"github.com/Conflux-Chain/confura/virtualfilter"
 )
@@ -37,6 +38,7 @@ func init() {
 		&vfOpt.ethEnabled, "eth", false, "start evm space virtual filter server",
 	)
 
+	virtualFilterCmd.AddCommand(vfadmin.Cmd)
 	rootCmd.AddCommand(virtualFilterCmd)
 }
 
@@ -67,7 +69,7 @@ func startEvmSpaceVirtualFilterServer(ctx context.Context, wg *sync.WaitGroup, s
 	// serve HTTP endpoint
 	vfServer, httpEndpoint := virtualfilter.MustNewEvmSpaceServerFromViper(
 		util.GracefulShutdownContext{Ctx: ctx, Wg: wg},
-		storeCtx.EthDB.VirtualFilterLogStore,
+		storeCtx.EthDB,
 	)
 
 	go vfServer.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
@@ -78,7 +80,7 @@ func startCoreSpaceVirtualFilterServer(ctx context.Context, wg *sync.WaitGroup,
 	// serve HTTP endpoint
 	vfServer, httpEndpoint := virtualfilter.MustNewCoreSpaceServerFromViper(
 		util.GracefulShutdownContext{Ctx: ctx, Wg: wg},
-		storeCtx.CfxDB.VirtualFilterLogStore,
+		storeCtx.CfxDB,
 	)
 
 	go vfServer.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/sync/webhook"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type hookCmdConfig struct {
+	Network string // network space ("cfx" or "eth")
+	Name    string // webhook name
+	Url     string // callback url
+	Filter  string // log filter json
+	Secret  string // secret to sign payload
+	Limit   int    // max number of dead letters to list
+}
+
+var (
+	hookCfg hookCmdConfig
+
+	addHookCmd = &cobra.Command{
+		Use:   "add",
+		Short: "Add or update webhook",
+		Run:   addHook,
+	}
+
+	delHookCmd = &cobra.Command{
+		Use:   "rm",
+		Short: "Remove existing webhook",
+		Run:   delHook,
+	}
+
+	listHooksCmd = &cobra.Command{
+		Use:   "ls",
+		Short: "List all registered webhooks",
+		Run:   listHooks,
+	}
+
+	listDeadLettersCmd = &cobra.Command{
+		Use:   "dl",
+		Short: "List the latest dead letters of failed deliveries",
+		Run:   listDeadLetters,
+	}
+)
+
+func init() {
+	Cmd.AddCommand(addHookCmd)
+	hookHookCmdFlags(addHookCmd, true, true)
+
+	Cmd.AddCommand(delHookCmd)
+	hookHookCmdFlags(delHookCmd, true, false)
+
+	Cmd.AddCommand(listHooksCmd)
+	hookHookCmdFlags(listHooksCmd, false, false)
+
+	Cmd.AddCommand(listDeadLettersCmd)
+	hookHookCmdFlags(listDeadLettersCmd, false, false)
+	listDeadLettersCmd.Flags().StringVarP(&hookCfg.Name, "name", "a", "", "webhook name, or all webhooks if empty")
+	listDeadLettersCmd.Flags().IntVarP(&hookCfg.Limit, "limit", "l", 20, "max number of dead letters to list")
+}
+
+func hookHookCmdFlags(cmd *cobra.Command, hookName, hookTarget bool) {
+	{ // network space
+		cmd.Flags().StringVarP(
+			&hookCfg.Network, "network", "n", "cfx", "network space ('cfx' or 'eth')",
+		)
+		cmd.MarkFlagRequired("network")
+	}
+
+	if hookName { // webhook name
+		cmd.Flags().StringVarP(&hookCfg.Name, "name", "a", "", "webhook name")
+		cmd.MarkFlagRequired("name")
+	}
+
+	if hookTarget { // callback url, log filter and secret
+		cmd.Flags().StringVarP(&hookCfg.Url, "url", "u", "", "callback url")
+		cmd.MarkFlagRequired("url")
+
+		cmd.Flags().StringVarP(&hookCfg.Filter, "filter", "f", "{}", "log filter json of the network space")
+
+		cmd.Flags().StringVarP(
+			&hookCfg.Secret, "secret", "s", "", "secret to sign payload, randomly generated if empty",
+		)
+	}
+}
+
+func addHook(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	hook, err := webhook.NewWebhook(
+		hookCfg.Network, hookCfg.Name, hookCfg.Url, json.RawMessage(hookCfg.Filter), hookCfg.Secret,
+	)
+	if err != nil {
+		logrus.WithField("config", hookCfg).WithError(err).Info("Invalid command config")
+		return
+	}
+
+	dbs, err := storeCtx.GetMysqlStore(hookCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return
+	}
+
+	hooks, err := dbs.LoadWebhooks(hook.Name)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load webhook")
+		return
+	}
+
+	op := "add a new webhook"
+	if _, ok := hooks[hook.Name]; ok {
+		op = "update an existed webhook"
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":   hook.Name,
+		"url":    hook.Url,
+		"filter": string(hook.Filter),
+	}).Info("Press the Enter Key to ", op)
+	fmt.Scanln() // wait for Enter Key
+
+	if err := dbs.StoreWebhook(hook); err != nil {
+		logrus.WithError(err).Info("Failed to ", op)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":   hook.Name,
+		"secret": hook.Secret,
+	}).Info("Succeeded to ", op)
+}
+
+func delHook(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	if len(hookCfg.Name) == 0 {
+		logrus.WithError(errors.New("name must not be empty")).Info("Invalid command config")
+		return
+	}
+
+	dbs, err := storeCtx.GetMysqlStore(hookCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return
+	}
+
+	logrus.WithField("name", hookCfg.Name).Info("Press the Enter Key to delete the webhook!")
+	fmt.Scanln() // wait for Enter Key
+
+	removed, err := dbs.DelWebhook(hookCfg.Name)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to delete the webhook")
+		return
+	}
+
+	if removed {
+		logrus.WithField("name", hookCfg.Name).Info("Webhook deleted")
+	} else {
+		logrus.WithField("name", hookCfg.Name).Info("Webhook not existed")
+	}
+}
+
+func listHooks(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	dbs, err := storeCtx.GetMysqlStore(hookCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return
+	}
+
+	hooks, err := dbs.LoadWebhooks()
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load webhooks")
+		return
+	}
+
+	if len(hooks) == 0 {
+		logrus.Info("No webhook found")
+		return
+	}
+
+	logrus.WithField("total", len(hooks)).Info("Webhooks loaded:")
+
+	for _, hook := range hooks {
+		logrus.WithFields(logrus.Fields{
+			"id":     hook.ID,
+			"url":    hook.Url,
+			"filter": string(hook.Filter),
+		}).Info("Webhook ", hook.Name)
+	}
+}
+
+func listDeadLetters(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	dbs, err := storeCtx.GetMysqlStore(hookCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return
+	}
+
+	letters, err := dbs.GetWebhookDeadLetters(hookCfg.Name, hookCfg.Limit)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load dead letters")
+		return
+	}
+
+	if len(letters) == 0 {
+		logrus.Info("No dead letter found")
+		return
+	}
+
+	logrus.WithField("total", len(letters)).Info("Dead letters loaded:")
+
+	for _, letter := range letters {
+		logrus.WithFields(logrus.Fields{
+			"webhook":    letter.Webhook,
+			"deliveryId": letter.DeliveryID,
+			"attempts":   letter.Attempts,
+			"error":      letter.Error,
+			"createdAt":  letter.CreatedAt,
+		}).Info("Dead letter #", letter.ID)
+	}
+}
@@ -0,0 +1,13 @@
+package webhook
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Webhook utility toolset",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
@@ -132,3 +132,26 @@ func CfxUrlConfig() map[Group]UrlConfig {
 func EthUrlConfig() map[Group]UrlConfig {
 	return ethUrlCfg
 }
+
+// chainConfig holds the fullnode groups of EVM chain besides the evm space.
+type chainConfig struct {
+	URLs          []string
+	FullStateURLs []string
+	WSURLs        []string
+	LogNodes      []string
+	FilterNodes   []string
+}
+
+// MustNewChainUrlConfigFromViper loads the fullnode groups of EVM chain from the specified viper key.
+func MustNewChainUrlConfigFromViper(key string) map[Group]UrlConfig {
+	var conf chainConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	return map[Group]UrlConfig{
+		GroupEthHttp:      {Nodes: conf.URLs},
+		GroupEthFullState: {Nodes: conf.FullStateURLs},
+		GroupEthWs:        {Nodes: conf.WSURLs},
+		GroupEthLogs:      {Nodes: conf.LogNodes},
+		GroupEthFilter:    {Nodes: conf.FilterNodes},
+	}
+}
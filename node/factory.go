@@ -43,6 +43,13 @@ func EthFactory() *factory {
 	return ethFactory
 }
 
+// NewChainRouter creates the node router of EVM chain with fullnode groups configured under the
+// specified viper key, which routes RPC requests locally without sharing route states with other
+// chains.
+func NewChainRouter(key string) Router {
+	return MustNewRouter("", "", MustNewChainUrlConfigFromViper(key))
+}
+
 // factory creates router and RPC server.
 type factory struct {
 	nodeRpcUrl     string
@@ -1,35 +1,59 @@
 package cache
 
 import (
+	"context"
 	"time"
 
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mcuadros/go-defaults"
 )
 
-var CfxDefault = NewCfx()
+var CfxDefault = NewCfx(newCfxCacheConfig())
+
+type CfxCacheConfig struct {
+	// epoch increase every 1 second and different nodes have different epoch number
+	StatusExpiration        time.Duration `default:"1s"`
+	PriceExpiration         time.Duration `default:"3s"`
+	ClientVersionExpiration time.Duration `default:"1m"`
+	// optional second-level cache shared by RPC proxy instances
+	Shared SharedCacheConfig
+}
+
+// newCfxCacheConfig returns a CfxCacheConfig with default values.
+func newCfxCacheConfig() CfxCacheConfig {
+	var cfg CfxCacheConfig
+	defaults.SetDefaults(&cfg)
+	return cfg
+}
 
 // CfxCache memory cache for some core space RPC methods
 type CfxCache struct {
 	*StatusCache
 
+	cfg          CfxCacheConfig
+	sharedCache  *sharedCache
 	priceCache   *expiryCache
 	versionCache *expiryCache
 }
 
-func NewCfx() *CfxCache {
+func NewCfx(cfg CfxCacheConfig) *CfxCache {
+	shared := mustNewSharedCache("cfx", cfg.Shared)
+
 	return &CfxCache{
-		StatusCache: NewStatusCache(),
+		StatusCache: newStatusCache(cfg.StatusExpiration, shared),
 
-		priceCache:   newExpiryCache(3 * time.Second),
-		versionCache: newExpiryCache(time.Minute),
+		cfg:          cfg,
+		sharedCache:  shared,
+		priceCache:   newExpiryCache(cfg.PriceExpiration),
+		versionCache: newExpiryCache(cfg.ClientVersionExpiration),
 	}
 }
 
-func (cache *CfxCache) GetGasPrice(cfx sdk.ClientOperator) (*hexutil.Big, error) {
-	val, err := cache.priceCache.getOrUpdate(func() (interface{}, error) {
-		return cfx.GetGasPrice()
-	})
+func (cache *CfxCache) GetGasPrice(ctx context.Context, cfx sdk.ClientOperator) (*hexutil.Big, error) {
+	val, err := cache.priceCache.getOrUpdate(sharedLoader(
+		ctx, cache.sharedCache, "cfx_gasPrice", "", cache.cfg.PriceExpiration, cfx.GetGasPrice,
+	))
 
 	if err != nil {
 		return nil, err
@@ -38,10 +62,10 @@ func (cache *CfxCache) GetGasPrice(cfx sdk.ClientOperator) (*hexutil.Big, error)
 	return val.(*hexutil.Big), nil
 }
 
-func (cache *CfxCache) GetClientVersion(cfx sdk.ClientOperator) (string, error) {
-	val, err := cache.versionCache.getOrUpdate(func() (interface{}, error) {
-		return cfx.GetClientVersion()
-	})
+func (cache *CfxCache) GetClientVersion(ctx context.Context, cfx sdk.ClientOperator) (string, error) {
+	val, err := cache.versionCache.getOrUpdate(sharedLoader(
+		ctx, cache.sharedCache, "cfx_clientVersion", "", cache.cfg.ClientVersionExpiration, cfx.GetClientVersion,
+	))
 
 	if err != nil {
 		return "", err
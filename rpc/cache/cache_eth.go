@@ -1,14 +1,18 @@
 package cache
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"math/big"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	"github.com/Conflux-Chain/go-conflux-util/viper"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/mcuadros/go-defaults"
@@ -18,7 +22,12 @@ import (
 )
 
 var (
-	EthDefault *EthCache = newEthCache(newEthCacheConfig())
+	EthDefault *EthCache = newEthCache(newEthCacheConfig(), "eth")
+
+	// caches of EVM chains served at `/v1/<chainId>` by chain ID
+	ethChainCachesMu sync.Mutex
+	ethChainCaches   = make(map[uint64]*EthCache)
+	ethChainConfig   = newEthCacheConfig()
 )
 
 type EthCacheConfig struct {
@@ -29,6 +38,8 @@ type EthCacheConfig struct {
 	PriceExpiration         time.Duration `default:"3s"`
 	CallCacheExpiration     time.Duration `default:"1s"`
 	CallCacheSize           int           `default:"128"`
+	// optional second-level cache shared by RPC proxy instances
+	Shared SharedCacheConfig
 }
 
 // newEthCacheConfig returns a EthCacheConfig with default values.
@@ -42,11 +53,46 @@ func MustInitFromViper() {
 	config := newEthCacheConfig()
 	viper.MustUnmarshalKey("requestControl.ethCache", &config)
 
-	EthDefault = newEthCache(config)
+	EthDefault = newEthCache(config, "eth")
+
+	ethChainCachesMu.Lock()
+	ethChainConfig = config
+	ethChainCaches = make(map[uint64]*EthCache)
+	ethChainCachesMu.Unlock()
+
+	cfxConfig := newCfxCacheConfig()
+	viper.MustUnmarshalKey("requestControl.cfxCache", &cfxConfig)
+
+	CfxDefault = NewCfx(cfxConfig)
+}
+
+// EthFromContext returns the cache of the EVM chain which the RPC request is routed to, so that
+// chain specific values (e.g., chain ID) are never shared among chains. Otherwise, the default
+// evm space cache is returned.
+func EthFromContext(ctx context.Context) *EthCache {
+	chainId, ok := handlers.GetChainIdFromContext(ctx)
+	if !ok {
+		return EthDefault
+	}
+
+	ethChainCachesMu.Lock()
+	defer ethChainCachesMu.Unlock()
+
+	if cache, ok := ethChainCaches[chainId]; ok {
+		return cache
+	}
+
+	cache := newEthCache(ethChainConfig, "chain"+strconv.FormatUint(chainId, 10))
+	ethChainCaches[chainId] = cache
+
+	return cache
 }
 
 // EthCache memory cache for some evm space RPC methods
 type EthCache struct {
+	cfg         EthCacheConfig
+	sharedCache *sharedCache
+
 	netVersionCache    *expiryCache
 	clientVersionCache *expiryCache
 	chainIdCache       *expiryCache
@@ -55,8 +101,12 @@ type EthCache struct {
 	callCache          *keyExpiryLruCaches
 }
 
-func newEthCache(cfg EthCacheConfig) *EthCache {
+// newEthCache creates evm space cache, of which the shared cache keys are isolated by space, e.g.,
+// `eth` or `chain<id>` for EVM chains.
+func newEthCache(cfg EthCacheConfig, space string) *EthCache {
 	return &EthCache{
+		cfg:                cfg,
+		sharedCache:        mustNewSharedCache(space, cfg.Shared),
 		netVersionCache:    newExpiryCache(cfg.NetVersionExpiration),
 		clientVersionCache: newExpiryCache(cfg.ClientVersionExpiration),
 		chainIdCache:       newExpiryCache(cfg.ChainIdExpiration),
@@ -66,10 +116,10 @@ func newEthCache(cfg EthCacheConfig) *EthCache {
 	}
 }
 
-func (cache *EthCache) GetNetVersion(client *web3go.Client) (string, error) {
-	val, err := cache.netVersionCache.getOrUpdate(func() (interface{}, error) {
-		return client.Eth.NetVersion()
-	})
+func (cache *EthCache) GetNetVersion(ctx context.Context, client *web3go.Client) (string, error) {
+	val, err := cache.netVersionCache.getOrUpdate(sharedLoader(
+		ctx, cache.sharedCache, "net_version", "", cache.cfg.NetVersionExpiration, client.Eth.NetVersion,
+	))
 
 	if err != nil {
 		return "", err
@@ -78,10 +128,10 @@ func (cache *EthCache) GetNetVersion(client *web3go.Client) (string, error) {
 	return val.(string), nil
 }
 
-func (cache *EthCache) GetClientVersion(client *web3go.Client) (string, error) {
-	val, err := cache.clientVersionCache.getOrUpdate(func() (interface{}, error) {
-		return client.Eth.ClientVersion()
-	})
+func (cache *EthCache) GetClientVersion(ctx context.Context, client *web3go.Client) (string, error) {
+	val, err := cache.clientVersionCache.getOrUpdate(sharedLoader(
+		ctx, cache.sharedCache, "web3_clientVersion", "", cache.cfg.ClientVersionExpiration, client.Eth.ClientVersion,
+	))
 
 	if err != nil {
 		return "", err
@@ -90,10 +140,10 @@ func (cache *EthCache) GetClientVersion(client *web3go.Client) (string, error) {
 	return val.(string), nil
 }
 
-func (cache *EthCache) GetChainId(client *web3go.Client) (*hexutil.Uint64, error) {
-	val, err := cache.chainIdCache.getOrUpdate(func() (interface{}, error) {
-		return client.Eth.ChainId()
-	})
+func (cache *EthCache) GetChainId(ctx context.Context, client *web3go.Client) (*hexutil.Uint64, error) {
+	val, err := cache.chainIdCache.getOrUpdate(sharedLoader(
+		ctx, cache.sharedCache, "eth_chainId", "", cache.cfg.ChainIdExpiration, client.Eth.ChainId,
+	))
 
 	if err != nil {
 		return nil, err
@@ -102,10 +152,10 @@ func (cache *EthCache) GetChainId(client *web3go.Client) (*hexutil.Uint64, error
 	return (*hexutil.Uint64)(val.(*uint64)), nil
 }
 
-func (cache *EthCache) GetGasPrice(client *web3go.Client) (*hexutil.Big, error) {
-	val, err := cache.priceCache.getOrUpdate(func() (interface{}, error) {
-		return client.Eth.GasPrice()
-	})
+func (cache *EthCache) GetGasPrice(ctx context.Context, client *web3go.Client) (*hexutil.Big, error) {
+	val, err := cache.priceCache.getOrUpdate(sharedLoader(
+		ctx, cache.sharedCache, "eth_gasPrice", "", cache.cfg.PriceExpiration, client.Eth.GasPrice,
+	))
 
 	if err != nil {
 		return nil, err
@@ -114,12 +164,12 @@ func (cache *EthCache) GetGasPrice(client *web3go.Client) (*hexutil.Big, error)
 	return (*hexutil.Big)(val.(*big.Int)), nil
 }
 
-func (cache *EthCache) GetBlockNumber(client *node.Web3goClient) (*hexutil.Big, error) {
+func (cache *EthCache) GetBlockNumber(ctx context.Context, client *node.Web3goClient) (*hexutil.Big, error) {
 	nodeName := rpc.Url2NodeName(client.URL)
 
-	val, err := cache.blockNumberCache.getOrUpdate(nodeName, func() (interface{}, error) {
-		return client.Eth.BlockNumber()
-	})
+	val, err := cache.blockNumberCache.getOrUpdate(nodeName, sharedLoader(
+		ctx, cache.sharedCache, "eth_blockNumber", nodeName, cache.cfg.BlockNumberExpiration, client.Eth.BlockNumber,
+	))
 
 	if err != nil {
 		return nil, err
@@ -128,7 +178,7 @@ func (cache *EthCache) GetBlockNumber(client *node.Web3goClient) (*hexutil.Big,
 	return (*hexutil.Big)(val.(*big.Int)), nil
 }
 
-func (cache *EthCache) Call(client *node.Web3goClient, callRequest types.CallRequest, blockNum *types.BlockNumberOrHash) ([]byte, error) {
+func (cache *EthCache) Call(ctx context.Context, client *node.Web3goClient, callRequest types.CallRequest, blockNum *types.BlockNumberOrHash) ([]byte, error) {
 	nodeName := rpc.Url2NodeName(client.URL)
 
 	cacheKey, err := generateCallCacheKey(nodeName, callRequest, blockNum)
@@ -143,9 +193,11 @@ func (cache *EthCache) Call(client *node.Web3goClient, callRequest types.CallReq
 		return client.Eth.Call(callRequest, blockNum)
 	}
 
-	val, err := cache.callCache.getOrUpdate(cacheKey, func() (interface{}, error) {
-		return client.Eth.Call(callRequest, blockNum)
-	})
+	val, err := cache.callCache.getOrUpdate(cacheKey, sharedLoader(
+		ctx, cache.sharedCache, "eth_call", cacheKey, cache.cfg.CallCacheExpiration, func() ([]byte, error) {
+			return client.Eth.Call(callRequest, blockNum)
+		},
+	))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/web3go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEthChainClient creates client of the EVM chain, which replies the chain ID only.
+func newTestEthChainClient(t *testing.T, chainId uint64) *web3go.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0", "id": req.ID, "result": hexutil.EncodeUint64(chainId),
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := web3go.NewClient(server.URL)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return client
+}
+
+func TestEthCacheIsolatedByChain(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	conf := newEthCacheConfig()
+	conf.Shared.RedisUrl = "redis://" + mr.Addr()
+
+	ethChainConfig, ethChainCaches = conf, make(map[uint64]*EthCache)
+	t.Cleanup(func() {
+		ethChainConfig, ethChainCaches = newEthCacheConfig(), make(map[uint64]*EthCache)
+	})
+
+	assert.Same(t, EthDefault, EthFromContext(context.Background()))
+
+	for _, chainId := range []uint64{1030, 8453} {
+		ctx := context.WithValue(context.Background(), handlers.CtxKeyChainId, chainId)
+		assert.Same(t, EthFromContext(ctx), EthFromContext(ctx))
+
+		val, err := EthFromContext(ctx).GetChainId(ctx, newTestEthChainClient(t, chainId))
+		require.NoError(t, err)
+		assert.Equal(t, chainId, uint64(*val))
+	}
+
+	// shared cache keys are isolated by chain
+	assert.True(t, mr.Exists("confura:cache:chain1030:eth_chainId:"))
+	assert.True(t, mr.Exists("confura:cache:chain8453:eth_chainId:"))
+}
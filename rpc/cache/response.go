@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Conflux-Chain/confura/sync/sink"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/mcuadros/go-defaults"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// interval to re-subscribe the commit stream on failure
+	responseStreamRetryInterval = 5 * time.Second
+)
+
+type ResponseCacheConfig struct {
+	Enabled bool
+	// max number of cached responses referring to finalized or pinned block (or epoch)
+	FinalizedSize int `default:"10000"`
+	// max number of cached responses referring to head-relative block (or epoch)
+	HeadSize int `default:"1000"`
+	// expiration of cached responses referring to head-relative block (or epoch)
+	HeadExpiration time.Duration `default:"1s"`
+	// max bytes of a single response to cache
+	MaxResponseBytes int `default:"1048576"`
+}
+
+// responseEntry cached response of finalized or pinned block (or epoch).
+type responseEntry struct {
+	result json.RawMessage
+	epoch  uint64
+	pinned bool // referred by block hash, which is never reverted
+}
+
+// ResponseCache caches JSON-RPC responses by the finality of referenced block (or epoch for core
+// space). Responses of finalized data are cached without expiration but bounded by size, while
+// responses relative to the chain head are cached for a short while. Besides, cached responses are
+// invalidated once chain reorg detected by the syncer.
+type ResponseCache struct {
+	conf      ResponseCacheConfig
+	finalized *lru.Cache // cache key => *responseEntry
+	head      *util.ExpirableLruCache
+}
+
+// MustNewResponseCacheFromViper creates response cache from the `requestControl.responseCache` config,
+// or nil if disabled. Chain reorg is detected by the change data capture sink of the specified viper key.
+func MustNewResponseCacheFromViper(ctx context.Context, space, sinkViperKey string) *ResponseCache {
+	var conf ResponseCacheConfig
+	defaults.SetDefaults(&conf)
+	viper.MustUnmarshalKey("requestControl.responseCache", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	c := NewResponseCache(conf)
+
+	if sub := sink.MustNewSubscriberFromViper(sinkViperKey); sub != nil {
+		go c.run(ctx, space, sub)
+	} else {
+		logrus.WithField("sink", sinkViperKey).Warn("Response cache enabled without chain reorg detection")
+	}
+
+	return c
+}
+
+func NewResponseCache(conf ResponseCacheConfig) *ResponseCache {
+	finalized, _ := lru.New(max(conf.FinalizedSize, 1))
+
+	return &ResponseCache{
+		conf:      conf,
+		finalized: finalized,
+		head:      util.NewExpirableLruCache(conf.HeadSize, conf.HeadExpiration),
+	}
+}
+
+// Get returns the cached response result of the specified cache key.
+func (c *ResponseCache) Get(key string) (json.RawMessage, bool) {
+	if v, ok := c.finalized.Get(key); ok {
+		return v.(*responseEntry).result, true
+	}
+
+	if v, ok := c.head.Get(key); ok {
+		return v.(json.RawMessage), true
+	}
+
+	return nil, false
+}
+
+// AddFinalized caches response result of the finalized block (or epoch) without expiration.
+func (c *ResponseCache) AddFinalized(key string, result json.RawMessage, epoch uint64) {
+	if len(result) <= c.conf.MaxResponseBytes {
+		c.finalized.Add(key, &responseEntry{result: result, epoch: epoch})
+	}
+}
+
+// AddPinned caches response result of the block referred by hash without expiration.
+func (c *ResponseCache) AddPinned(key string, result json.RawMessage) {
+	if len(result) <= c.conf.MaxResponseBytes {
+		c.finalized.Add(key, &responseEntry{result: result, pinned: true})
+	}
+}
+
+// AddHead caches response result relative to the chain head for a short while.
+func (c *ResponseCache) AddHead(key string, result json.RawMessage) {
+	if len(result) <= c.conf.MaxResponseBytes {
+		c.head.Add(key, result)
+	}
+}
+
+// Revert invalidates the cached responses of reverted epochs (or blocks) since the specified epoch
+// (inclusive), along with all the responses relative to the chain head.
+func (c *ResponseCache) Revert(from uint64) {
+	c.head.Purge()
+
+	for _, key := range c.finalized.Keys() {
+		if v, ok := c.finalized.Peek(key); ok {
+			if entry := v.(*responseEntry); !entry.pinned && entry.epoch >= from {
+				c.finalized.Remove(key)
+			}
+		}
+	}
+}
+
+// run subscribes the commit stream of syncer to invalidate cached responses upon chain reorg until
+// the context is done, and re-subscribes on failure.
+func (c *ResponseCache) run(ctx context.Context, space string, sub sink.Subscriber) {
+	defer sub.Close()
+
+	for {
+		err := sub.Subscribe(ctx, space, func(event *sink.Event) {
+			if event.Type == sink.EventRevert {
+				c.Revert(event.Epoch)
+			}
+		})
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		logrus.WithField("space", space).WithError(err).Info("Response cache failed to subscribe commit stream")
+
+		// revert events may be missed during re-subscription
+		c.head.Purge()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(responseStreamRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCacheRevert(t *testing.T) {
+	cache := NewResponseCache(ResponseCacheConfig{
+		FinalizedSize: 10, HeadSize: 10, HeadExpiration: time.Minute, MaxResponseBytes: 8,
+	})
+
+	cache.AddFinalized("f1", json.RawMessage(`"0x1"`), 1)
+	cache.AddFinalized("f5", json.RawMessage(`"0x5"`), 5)
+	cache.AddPinned("p", json.RawMessage(`"0x6"`))
+	cache.AddHead("h", json.RawMessage(`"0x7"`))
+
+	// response too large to cache
+	cache.AddFinalized("large", json.RawMessage(`"0x123456789"`), 1)
+	_, ok := cache.Get("large")
+	assert.False(t, ok)
+
+	result, ok := cache.Get("f5")
+	assert.True(t, ok)
+	assert.Equal(t, json.RawMessage(`"0x5"`), result)
+
+	// reverted epochs and head-relative responses invalidated
+	cache.Revert(3)
+
+	_, ok = cache.Get("f1")
+	assert.True(t, ok)
+	_, ok = cache.Get("p")
+	assert.True(t, ok)
+
+	_, ok = cache.Get("f5")
+	assert.False(t, ok)
+	_, ok = cache.Get("h")
+	assert.False(t, ok)
+}
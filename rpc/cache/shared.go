@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/redis"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// releases the load lock only if still held by the instance
+	sharedLockReleaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// SharedCacheConfig second-level cache shared by RPC proxy instances behind the memory caches.
+type SharedCacheConfig struct {
+	// redis url of the shared cache, which is disabled if empty
+	RedisUrl string
+	// prefix of cache keys to isolate from other deployments
+	KeyPrefix string `default:"confura:cache"`
+	// max duration for one instance to load the missing key, while the others wait for it
+	LockExpiration time.Duration `default:"3s"`
+	// timeout of each redis operation, after which it falls through to load value directly
+	Timeout time.Duration `default:"100ms"`
+	// per-method expirations of the shared cache by method name (case insensitive), which
+	// defaults to the expiration of the memory cache
+	Expirations map[string]time.Duration
+}
+
+// sharedCache second-level cache backed by redis, so that only one RPC proxy instance in the fleet
+// requests full node for the missing key, and the others reuse the result.
+type sharedCache struct {
+	conf   SharedCacheConfig
+	space  string
+	client *goredis.Client
+}
+
+// mustNewSharedCache creates shared cache for the space, or nil if disabled.
+func mustNewSharedCache(space string, conf SharedCacheConfig) *sharedCache {
+	if len(conf.RedisUrl) == 0 {
+		return nil
+	}
+
+	return &sharedCache{
+		conf:   conf,
+		space:  space,
+		client: redis.MustNewRedisClient(conf.RedisUrl),
+	}
+}
+
+// expiration returns the expiration of the shared cache for the RPC method.
+func (c *sharedCache) expiration(method string, fallback time.Duration) time.Duration {
+	if ttl, ok := c.conf.Expirations[strings.ToLower(method)]; ok && ttl > 0 {
+		return ttl
+	}
+
+	return fallback
+}
+
+func (c *sharedCache) cacheKey(method string, key ...string) string {
+	return strings.Join(append([]string{c.conf.KeyPrefix, c.space, method}, key...), ":")
+}
+
+// withTimeout derives context from the request context with the timeout of redis operation.
+func (c *sharedCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.conf.Timeout)
+}
+
+// get loads value from the shared cache, and returns false if missing. Note value failed to
+// unmarshal is regarded as missing too.
+func (c *sharedCache) get(ctx context.Context, key string, valPtr interface{}) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err == goredis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, valPtr); err != nil {
+		logrus.WithField("key", key).WithError(err).Debug("Shared cache failed to unmarshal value")
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (c *sharedCache) set(ctx context.Context, key string, val interface{}, ttl time.Duration) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(val)
+	if err == nil {
+		err = c.client.Set(ctx, key, data, ttl).Err()
+	}
+
+	if err != nil {
+		logrus.WithField("key", key).WithError(err).Debug("Shared cache failed to set value")
+	}
+}
+
+// lock tries to acquire the lock to load the missing key.
+func (c *sharedCache) lock(ctx context.Context, lockKey, lockVal string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.SetNX(ctx, lockKey, lockVal, c.conf.LockExpiration).Result()
+}
+
+// unlock releases the lock only if still held, and then notifies the waiters, so that waiters
+// could load value by themselves on error.
+func (c *sharedCache) unlock(ctx context.Context, lockKey, lockVal string) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if err := sharedLockReleaseScript.Run(ctx, c.client, []string{lockKey}, lockVal).Err(); err != nil {
+		logrus.WithField("key", lockKey).WithError(err).Debug("Shared cache failed to release lock")
+	}
+
+	if err := c.client.Publish(ctx, lockKey, lockVal).Err(); err != nil {
+		logrus.WithField("key", lockKey).WithError(err).Debug("Shared cache failed to notify waiters")
+	}
+}
+
+// wait waits for the value loaded by other instance which holds the lock, and returns false if
+// the lock released or expired without value loaded.
+//
+// Waiters subscribe to the lock key channel, on which the lock holder publishes after loaded, and
+// then double check the value and lock in case of the notification published before subscribed.
+func (c *sharedCache) wait(ctx context.Context, key, lockKey string, valPtr interface{}) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.conf.LockExpiration)
+	defer cancel()
+
+	sub := c.client.Subscribe(ctx, lockKey)
+	defer sub.Close()
+
+	// wait for the subscription confirmed
+	if _, err := sub.Receive(ctx); err != nil {
+		return false, err
+	}
+
+	if ok, err := c.get(ctx, key, valPtr); ok || err != nil {
+		return ok, err
+	}
+
+	existsCtx, existsCancel := c.withTimeout(ctx)
+	n, err := c.client.Exists(existsCtx, lockKey).Result()
+	existsCancel()
+
+	if err != nil || n == 0 { // lock released without value loaded
+		return false, err
+	}
+
+	select {
+	case <-sub.Channel():
+		return c.get(ctx, key, valPtr)
+	case <-ctx.Done(): // lock expired or request canceled
+		return false, nil
+	}
+}
+
+// getOrLoadShared gets value from the shared cache, or loads value by the specified load function
+// and then caches it. Only one instance in the fleet loads the missing key at a time, while the
+// others wait for the loaded value. Note it falls through to load value directly if the shared
+// cache is disabled or unavailable, e.g., any redis operation failed or timed out.
+func getOrLoadShared[T any](
+	ctx context.Context, c *sharedCache, method, key string, ttl time.Duration, load func() (T, error),
+) (T, error) {
+	if c == nil {
+		return load()
+	}
+
+	cacheKey := c.cacheKey(method, key)
+	logger := logrus.WithField("key", cacheKey)
+
+	var val T
+	ok, err := c.get(ctx, cacheKey, &val)
+	if ok {
+		metrics.Registry.RPC.SharedCacheHit(method).Mark(true)
+		return val, nil
+	}
+
+	lockKey := cacheKey + ":lock"
+	lockVal := uuid.NewString()
+
+	var locked bool
+	if err == nil {
+		locked, err = c.lock(ctx, lockKey, lockVal)
+	}
+
+	if err == nil && !locked {
+		if ok, err = c.wait(ctx, cacheKey, lockKey, &val); ok {
+			metrics.Registry.RPC.SharedCacheHit(method).Mark(true)
+			return val, nil
+		}
+	}
+
+	if err != nil {
+		logger.WithError(err).Debug("Shared cache unavailable, fall through to load value directly")
+	}
+
+	metrics.Registry.RPC.SharedCacheHit(method).Mark(false)
+
+	val, err = load()
+
+	// value loaded is still cached and lock released even though the request canceled
+	ctx = context.WithoutCancel(ctx)
+
+	if err == nil {
+		c.set(ctx, cacheKey, val, c.expiration(method, ttl))
+	}
+
+	if locked {
+		c.unlock(ctx, lockKey, lockVal)
+	}
+
+	return val, err
+}
+
+// sharedLoader returns the update function of memory cache, which loads value from the shared cache.
+func sharedLoader[T any](
+	ctx context.Context, c *sharedCache, method, key string, ttl time.Duration, load func() (T, error),
+) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return getOrLoadShared(ctx, c, method, key, ttl, load)
+	}
+}
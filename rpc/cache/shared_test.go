@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mcuadros/go-defaults"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSharedCaches(t *testing.T, num int) []*sharedCache {
+	mr := miniredis.RunT(t)
+
+	var conf SharedCacheConfig
+	defaults.SetDefaults(&conf)
+	conf.RedisUrl = "redis://" + mr.Addr()
+	conf.Expirations = map[string]time.Duration{"eth_call": time.Minute}
+
+	var caches []*sharedCache
+	for i := 0; i < num; i++ {
+		caches = append(caches, mustNewSharedCache("eth", conf))
+	}
+
+	return caches
+}
+
+func TestSharedCacheCoalescing(t *testing.T) {
+	ctx := context.Background()
+	caches := newTestSharedCaches(t, 4)
+
+	var loads int32
+	load := func() ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(100 * time.Millisecond)
+		return []byte{0x1, 0x2}, nil
+	}
+
+	// only one instance loads the missing key, while the others wait for it
+	var wg sync.WaitGroup
+	for _, c := range caches {
+		wg.Add(1)
+
+		go func(c *sharedCache) {
+			defer wg.Done()
+
+			val, err := getOrLoadShared(ctx, c, "eth_call", "key", time.Second, load)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte{0x1, 0x2}, val)
+		}(c)
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// per-method expiration configured
+	ttl := caches[0].client.TTL(caches[0].client.Context(), caches[0].cacheKey("eth_call", "key")).Val()
+	assert.Greater(t, ttl, time.Second)
+}
+
+func TestSharedCacheLoadError(t *testing.T) {
+	ctx := context.Background()
+	c := newTestSharedCaches(t, 1)[0]
+
+	errLoad := errors.New("load error")
+	_, err := getOrLoadShared(ctx, c, "eth_gasPrice", "", time.Second, func() (string, error) {
+		return "", errLoad
+	})
+	require.ErrorIs(t, err, errLoad)
+
+	// neither value cached nor lock held on error
+	val, err := getOrLoadShared(ctx, c, "eth_gasPrice", "", time.Second, func() (string, error) {
+		return "0x1", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0x1", val)
+
+	// disabled shared cache loads value directly
+	val, err = getOrLoadShared(ctx, nil, "eth_gasPrice", "", time.Second, func() (string, error) {
+		return "0x2", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0x2", val)
+}
+
+func TestSharedCacheReleaseLock(t *testing.T) {
+	c := newTestSharedCaches(t, 1)[0]
+	ctx := c.client.Context()
+	lockKey := c.cacheKey("eth_gasPrice", "") + ":lock"
+
+	_, err := getOrLoadShared(ctx, c, "eth_gasPrice", "", time.Second, func() (string, error) {
+		return "0x1", nil
+	})
+	require.NoError(t, err)
+	assert.Zero(t, c.client.Exists(ctx, lockKey).Val())
+
+	// lock expired and then acquired by other instance during loading
+	_, err = getOrLoadShared(ctx, c, "eth_blockNumber", "", time.Second, func() (string, error) {
+		lockKey = c.cacheKey("eth_blockNumber", "") + ":lock"
+		c.client.Set(ctx, lockKey, "other", time.Minute)
+		return "0x2", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "other", c.client.Get(ctx, lockKey).Val())
+}
+
+func TestSharedCacheWaitNotified(t *testing.T) {
+	ctx := context.Background()
+	caches := newTestSharedCaches(t, 2)
+	for _, c := range caches {
+		c.conf.LockExpiration = time.Minute
+	}
+
+	loading := make(chan struct{})
+	go getOrLoadShared(ctx, caches[0], "eth_gasPrice", "", time.Second, func() (string, error) {
+		close(loading)
+		time.Sleep(100 * time.Millisecond)
+		return "0x1", nil
+	})
+
+	<-loading
+
+	// waiter notified once loaded rather than waiting until the lock expired
+	start := time.Now()
+	val, err := getOrLoadShared(ctx, caches[1], "eth_gasPrice", "", time.Second, func() (string, error) {
+		return "0x2", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0x1", val)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// waiter notified to load value by itself if failed to load by the lock holder
+	errLoad := errors.New("load error")
+	loading = make(chan struct{})
+	go getOrLoadShared(ctx, caches[0], "eth_chainId", "", time.Second, func() (string, error) {
+		close(loading)
+		time.Sleep(100 * time.Millisecond)
+		return "", errLoad
+	})
+
+	<-loading
+
+	start = time.Now()
+	val, err = getOrLoadShared(ctx, caches[1], "eth_chainId", "", time.Second, func() (string, error) {
+		return "0x2", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0x2", val)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestSharedCacheFallThrough(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	var conf SharedCacheConfig
+	defaults.SetDefaults(&conf)
+	conf.RedisUrl = "redis://" + mr.Addr()
+	c := mustNewSharedCache("eth", conf)
+
+	load := func() (string, error) { return "0x1", nil }
+
+	// shared cache unavailable
+	mr.SetError("unavailable")
+	val, err := getOrLoadShared(context.Background(), c, "eth_gasPrice", "", time.Second, load)
+	require.NoError(t, err)
+	assert.Equal(t, "0x1", val)
+
+	// request canceled
+	mr.SetError("")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	val, err = getOrLoadShared(ctx, c, "eth_gasPrice", "", time.Second, load)
+	require.NoError(t, err)
+	assert.Equal(t, "0x1", val)
+}
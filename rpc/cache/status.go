@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"time"
 
 	"github.com/Conflux-Chain/confura/util/rpc"
@@ -11,22 +12,25 @@ import (
 
 // StatusCache memory cache for core space status related RPC method suites.
 type StatusCache struct {
-	inner *nodeExpiryCaches
+	inner       *nodeExpiryCaches
+	expiration  time.Duration
+	sharedCache *sharedCache
 }
 
-func NewStatusCache() *StatusCache {
+func newStatusCache(expiration time.Duration, shared *sharedCache) *StatusCache {
 	return &StatusCache{
-		// epoch increase every 1 second and different nodes have different epoch number
-		inner: newNodeExpiryCaches(time.Second),
+		inner:       newNodeExpiryCaches(expiration),
+		expiration:  expiration,
+		sharedCache: shared,
 	}
 }
 
-func (c *StatusCache) GetStatus(cfx sdk.ClientOperator) (types.Status, error) {
+func (c *StatusCache) GetStatus(ctx context.Context, cfx sdk.ClientOperator) (types.Status, error) {
 	nodeName := rpc.Url2NodeName(cfx.GetNodeURL())
 
-	val, err := c.inner.getOrUpdate(nodeName, func() (interface{}, error) {
-		return cfx.GetStatus()
-	})
+	val, err := c.inner.getOrUpdate(nodeName, sharedLoader(
+		ctx, c.sharedCache, "cfx_getStatus", nodeName, c.expiration, cfx.GetStatus,
+	))
 
 	if err != nil {
 		return types.Status{}, err
@@ -35,12 +39,12 @@ func (c *StatusCache) GetStatus(cfx sdk.ClientOperator) (types.Status, error) {
 	return val.(types.Status), nil
 }
 
-func (c *StatusCache) GetEpochNumber(cfx sdk.ClientOperator, epoch *types.Epoch) (*hexutil.Big, error) {
+func (c *StatusCache) GetEpochNumber(ctx context.Context, cfx sdk.ClientOperator, epoch *types.Epoch) (*hexutil.Big, error) {
 	if types.EpochEarliest.Equals(epoch) {
 		return types.NewBigInt(0), nil
 	}
 
-	status, err := c.GetStatus(cfx)
+	status, err := c.GetStatus(ctx, cfx)
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +78,8 @@ func (c *StatusCache) GetEpochNumber(cfx sdk.ClientOperator, epoch *types.Epoch)
 	return cfx.GetEpochNumber(epoch)
 }
 
-func (c *StatusCache) GetBestBlockHash(cfx sdk.ClientOperator) (types.Hash, error) {
-	status, err := c.GetStatus(cfx)
+func (c *StatusCache) GetBestBlockHash(ctx context.Context, cfx sdk.ClientOperator) (types.Hash, error) {
+	status, err := c.GetStatus(ctx, cfx)
 	if err != nil {
 		return "", err
 	}
@@ -6,6 +6,8 @@ import (
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/rpc/cache"
 	"github.com/Conflux-Chain/confura/rpc/handler"
+	"github.com/Conflux-Chain/confura/store"
+	citypes "github.com/Conflux-Chain/confura/types"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
 	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
@@ -25,6 +27,7 @@ var (
 	emptyEpochs             = []*types.Epoch{}
 	emptyEpochOrBlockHashes = []*types.EpochOrBlockHash{}
 	emptyLogs               = []types.Log{}
+	emptyFinalityLogs       = []citypes.CfxLog{}
 )
 
 type CfxAPIOption struct {
@@ -32,6 +35,8 @@ type CfxAPIOption struct {
 	LogApiHandler       *handler.CfxLogsApiHandler
 	TxnHandler          *handler.CfxTxnHandler
 	VirtualFilterClient *vfclient.CfxClient
+	FinalityHandler     *handler.FinalityHandler
+	ResponseCache       *cache.ResponseCache
 }
 
 // cfxAPI provides main proxy API for core space.
@@ -75,13 +80,13 @@ func toEpochOrBlockHashSlice(epoch *types.EpochOrBlockHash) []*types.EpochOrBloc
 
 func (api *cfxAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
 	cfx := GetCfxClientFromContext(ctx)
-	return cache.CfxDefault.GetGasPrice(cfx)
+	return cache.CfxDefault.GetGasPrice(ctx, cfx)
 }
 
 func (api *cfxAPI) EpochNumber(ctx context.Context, epoch *types.Epoch) (*hexutil.Big, error) {
 	cfx := GetCfxClientFromContext(ctx)
 	api.inputEpochMetric.Update(epoch, "cfx_epochNumber", cfx)
-	return cache.CfxDefault.GetEpochNumber(cfx, epoch)
+	return cache.CfxDefault.GetEpochNumber(ctx, cfx, epoch)
 }
 
 func (api *cfxAPI) GetBalance(ctx context.Context, address types.Address, epoch *types.EpochOrBlockHash) (*hexutil.Big, error) {
@@ -243,7 +248,7 @@ func (api *cfxAPI) GetBlockByBlockNumber(
 
 func (api *cfxAPI) GetBestBlockHash(ctx context.Context) (types.Hash, error) {
 	cfx := GetCfxClientFromContext(ctx)
-	return cache.CfxDefault.GetBestBlockHash(cfx)
+	return cache.CfxDefault.GetBestBlockHash(ctx, cfx)
 }
 
 func (api *cfxAPI) GetNextNonce(ctx context.Context, address types.Address, epoch *types.EpochOrBlockHash) (*hexutil.Big, error) {
@@ -269,9 +274,26 @@ func (api *cfxAPI) Call(ctx context.Context, request types.CallRequest, epoch *t
 	return api.stateHandler.Call(ctx, cfx, request, epoch)
 }
 
-func (api *cfxAPI) GetLogs(ctx context.Context, fq types.LogFilter) ([]types.Log, error) {
+// GetLogs returns event logs matched with the log filter, along with the extension field `final`
+// of each log if the finality of indexed data is available.
+func (api *cfxAPI) GetLogs(ctx context.Context, fq types.LogFilter) ([]citypes.CfxLog, error) {
 	cfx := GetCfxClientFromContext(ctx)
-	return api.getLogs(ctx, cfx, fq, rpcMethodCfxGetLogs)
+
+	logs, err := api.getLogs(ctx, cfx, fq, rpcMethodCfxGetLogs)
+	if err != nil {
+		return emptyFinalityLogs, err
+	}
+
+	res := make([]citypes.CfxLog, len(logs))
+	for i := range logs {
+		res[i].Log = logs[i]
+
+		if api.FinalityHandler != nil && logs[i].EpochNumber != nil {
+			res[i].Final = api.FinalityHandler.IsFinal(logs[i].EpochNumber.ToInt().Uint64())
+		}
+	}
+
+	return res, nil
 }
 
 // getLogs helper method to get logs from store or fullnode.
@@ -306,6 +328,21 @@ func (api *cfxAPI) getLogs(
 	return cfx.GetLogs(fq)
 }
 
+// GetLogsByTransactions returns event logs of the specified transactions, which is an extension
+// method for indexers to get event logs in batch instead of fetching receipts one by one.
+func (api *cfxAPI) GetLogsByTransactions(ctx context.Context, txHashes []types.Hash) ([]types.Log, error) {
+	if len(txHashes) > store.MaxLogTxHashesSize {
+		return emptyLogs, ErrExceedLogTxHashLimit(len(txHashes))
+	}
+
+	if api.LogApiHandler == nil {
+		return emptyLogs, ErrLogsByTxHashesUnsupported
+	}
+
+	logs, err := api.LogApiHandler.GetLogsByTxHashes(ctx, txHashes)
+	return uniformCfxLogs(logs), err
+}
+
 func (api *cfxAPI) GetTransactionByHash(ctx context.Context, txHash types.Hash) (*types.Transaction, error) {
 	logger := logrus.WithFields(logrus.Fields{"txHash": txHash})
 
@@ -416,7 +453,7 @@ func (api *cfxAPI) GetConfirmationRiskByHash(ctx context.Context, blockHash type
 
 func (api *cfxAPI) GetStatus(ctx context.Context) (types.Status, error) {
 	cfx := GetCfxClientFromContext(ctx)
-	return cache.CfxDefault.GetStatus(cfx)
+	return cache.CfxDefault.GetStatus(ctx, cfx)
 }
 
 func (api *cfxAPI) GetBlockRewardInfo(ctx context.Context, epoch types.Epoch) ([]types.RewardInfo, error) {
@@ -427,7 +464,7 @@ func (api *cfxAPI) GetBlockRewardInfo(ctx context.Context, epoch types.Epoch) ([
 
 func (api *cfxAPI) ClientVersion(ctx context.Context) (string, error) {
 	cfx := GetCfxClientFromContext(ctx)
-	return cache.CfxDefault.GetClientVersion(cfx)
+	return cache.CfxDefault.GetClientVersion(ctx, cfx)
 }
 
 func (api *cfxAPI) GetSupplyInfo(ctx context.Context, epoch *types.Epoch) (types.TokenSupplyInfo, error) {
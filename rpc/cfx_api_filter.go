@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"time"
 
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/util/metrics"
@@ -35,7 +36,17 @@ func (api *cfxAPI) NewFilter(ctx context.Context, filterCrit types.LogFilter) (*
 	metrics.UpdateCfxRpcLogFilter(rpcMethodCfxNewFilter, cfx, &filterCrit)
 
 	if api.VirtualFilterClient != nil {
-		fid, err := api.VirtualFilterClient.NewFilter(cfx.GetNodeURL(), &filterCrit)
+		critSize := len(filterCrit.Address)
+		for i := range filterCrit.Topics {
+			critSize += len(filterCrit.Topics[i])
+		}
+
+		quota, err := virtualFilterQuota(ctx, critSize)
+		if err != nil {
+			return nil, err
+		}
+
+		fid, err := api.VirtualFilterClient.NewFilter(cfx.GetNodeURL(), &filterCrit, quota)
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -48,7 +59,12 @@ func (api *cfxAPI) NewBlockFilter(ctx context.Context) (*rpc.ID, error) {
 	cfx := GetCfxClientFromContext(ctx)
 
 	if api.VirtualFilterClient != nil {
-		fid, err := api.VirtualFilterClient.NewBlockFilter(cfx.GetNodeURL())
+		quota, err := virtualFilterQuota(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fid, err := api.VirtualFilterClient.NewBlockFilter(cfx.GetNodeURL(), quota)
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -64,7 +80,12 @@ func (api *cfxAPI) NewPendingTransactionFilter(ctx context.Context) (*rpc.ID, er
 	cfx := GetCfxClientFromContext(ctx)
 
 	if api.VirtualFilterClient != nil {
-		fid, err := api.VirtualFilterClient.NewPendingTransactionFilter(cfx.GetNodeURL())
+		quota, err := virtualFilterQuota(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fid, err := api.VirtualFilterClient.NewPendingTransactionFilter(cfx.GetNodeURL(), quota)
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -87,9 +108,17 @@ func (api *cfxAPI) UninstallFilter(ctx context.Context, fid rpc.ID) (bool, error
 //
 // For pending transaction and block filters the result is []types.Hash.
 // (pending)Log filters return []types.CfxFilterLog.
-func (api *cfxAPI) GetFilterChanges(ctx context.Context, fid rpc.ID) (interface{}, error) {
+//
+// The optional `waitMillis` is an extension to wait up to the duration in milliseconds until any
+// change arrives if no change yet, which is only supported by virtual filters.
+func (api *cfxAPI) GetFilterChanges(ctx context.Context, fid rpc.ID, waitMillis *uint64) (interface{}, error) {
 	if api.VirtualFilterClient != nil {
-		res, err := api.VirtualFilterClient.GetFilterChanges(fid)
+		var wait time.Duration
+		if waitMillis != nil {
+			wait = time.Duration(*waitMillis) * time.Millisecond
+		}
+
+		res, err := api.VirtualFilterClient.GetFilterChanges(fid, wait)
 		return res, errVirtualFilterProxyErrorOrNil(err)
 	}
 
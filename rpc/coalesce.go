@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"golang.org/x/sync/singleflight"
+)
+
+type coalescingConfig struct {
+	Enabled bool
+	// RPC methods excluded from coalescing besides the non-idempotent methods, e.g., `eth_sendRawTransaction`
+	ExcludedMethods []string
+}
+
+// requestCoalescer shares one upstream request among identical concurrent in-flight RPC requests,
+// which are keyed by RPC method, normalized parameters and the target full node group.
+type requestCoalescer struct {
+	group    singleflight.Group
+	excluded map[string]bool
+}
+
+// mustNewRequestCoalescerFromViper creates request coalescer from the `requestControl.coalescing`
+// config, or nil if disabled.
+func mustNewRequestCoalescerFromViper() *requestCoalescer {
+	var conf coalescingConfig
+	viper.MustUnmarshalKey("requestControl.coalescing", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	return newRequestCoalescer(conf.ExcludedMethods...)
+}
+
+func newRequestCoalescer(excludedMethods ...string) *requestCoalescer {
+	excluded := make(map[string]bool)
+	for _, method := range excludedMethods {
+		excluded[method] = true
+	}
+
+	return &requestCoalescer{excluded: excluded}
+}
+
+// coalescible returns whether the RPC method is idempotent and could be coalesced.
+func (c *requestCoalescer) coalescible(method string) bool {
+	switch {
+	case c.excluded[method]:
+		return false
+	case isEthFilterRpcMethod(method), isCfxFilterRpcMethod(method):
+		return false
+	case strings.HasPrefix(method, "eth_send"), strings.HasPrefix(method, "cfx_send"):
+		return false
+	case strings.HasSuffix(method, "_subscribe"), strings.HasSuffix(method, "_unsubscribe"):
+		return false
+	}
+
+	return true
+}
+
+// middleware coalesces the identical concurrent RPC requests routed to the same full node group.
+func (c *requestCoalescer) middleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		grp, ok := ctx.Value(ctxKeyClientGroup).(node.Group)
+		if !ok || !c.coalescible(msg.Method) {
+			return next(ctx, msg)
+		}
+
+		key := fmt.Sprintf("%v:%v", grp, callMsgKey(msg))
+		if chainId, ok := ctx.Value(handlers.CtxKeyChainId).(uint64); ok {
+			key = fmt.Sprintf("%v:%v", chainId, key)
+		}
+
+		val, _, shared := c.group.Do(key, func() (interface{}, error) {
+			// the shared request should not be cancelled along with the first requester
+			return next(context.WithoutCancel(ctx), msg), nil
+		})
+
+		metrics.Registry.RPC.Percentage(msg.Method, "coalesced").Mark(shared)
+
+		resp, ok := val.(*rpc.JsonRpcMessage)
+		if !ok || resp == nil {
+			return resp
+		}
+
+		// response of the shared request is replied with the ID of each request
+		res := *resp
+		res.ID = msg.ID
+
+		return &res
+	}
+}
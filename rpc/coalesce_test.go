@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCoalescer(t *testing.T) {
+	coalescer := newRequestCoalescer("eth_gasPrice")
+
+	var calls int32
+	handle := coalescer.middleware(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return &rpc.JsonRpcMessage{Version: msg.Version, ID: msg.ID, Result: json.RawMessage(`"0x1"`)}
+	})
+
+	request := func(grp node.Group, id int, method, params string) {
+		ctx := context.WithValue(context.Background(), ctxKeyClientGroup, grp)
+		msg := &rpc.JsonRpcMessage{
+			Version: "2.0", ID: json.RawMessage(fmt.Sprint(id)), Method: method, Params: json.RawMessage(params),
+		}
+
+		resp := handle(ctx, msg)
+		assert.Equal(t, msg.ID, resp.ID)
+		assert.Equal(t, json.RawMessage(`"0x1"`), resp.Result)
+	}
+
+	testCases := []struct {
+		grp      node.Group
+		method   string
+		params   string
+		expected int32
+	}{
+		// identical requests coalesced regardless of insignificant spaces
+		{node.GroupEthHttp, "eth_getBlockByNumber", `["0x1", false]`, 1},
+		// requests routed to different groups
+		{"", "eth_getBlockByNumber", `["0x1",false]`, 2},
+		// non-idempotent or excluded methods
+		{node.GroupEthHttp, "eth_sendRawTransaction", `["0x1"]`, 4},
+		{node.GroupEthHttp, "eth_gasPrice", `[]`, 4},
+	}
+
+	for _, tc := range testCases {
+		atomic.StoreInt32(&calls, 0)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+
+			grp := tc.grp
+			if len(grp) == 0 { // alternate groups
+				grp = []node.Group{node.GroupEthHttp, node.GroupEthLogs}[i%2]
+			}
+
+			go func(i int) {
+				defer wg.Done()
+				request(grp, i, tc.method, tc.params)
+			}(i)
+		}
+
+		wg.Wait()
+		assert.Equal(t, tc.expected, atomic.LoadInt32(&calls), tc.method)
+	}
+}
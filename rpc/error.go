@@ -28,6 +28,10 @@ var (
 		"(1) a block number range through `fromBlock` and `toBlock`",
 		"(2) a set of block hashes through `blockHash`",
 	)
+
+	ErrLogsByTxHashesUnsupported = errors.New(
+		"querying event logs by transaction hashes is not supported without event log store",
+	)
 )
 
 func ErrExceedLogFilterBlockHashLimit(size int) error {
@@ -54,3 +58,16 @@ func ErrExceedLogFilterTopicLimit(size int) error {
 		store.MaxLogFilterTopicCount, size,
 	)
 }
+
+func ErrExceedLogTxHashLimit(size int) error {
+	return errors.Errorf(
+		"transaction hashes can contain up to %v hashes; %v were provided.",
+		store.MaxLogTxHashesSize, size,
+	)
+}
+
+func ErrExceedFilterCriteriaQuota(quota, size int) error {
+	return errors.Errorf(
+		"filter criteria can contain up to %v addresses and topics in total; %v were provided.", quota, size,
+	)
+}
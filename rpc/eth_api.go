@@ -8,6 +8,7 @@ import (
 	"github.com/Conflux-Chain/confura/rpc/cache"
 	"github.com/Conflux-Chain/confura/rpc/handler"
 	"github.com/Conflux-Chain/confura/store"
+	citypes "github.com/Conflux-Chain/confura/types"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
 	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
@@ -31,7 +32,8 @@ const (
 )
 
 var (
-	ethEmptyLogs = []web3Types.Log{}
+	ethEmptyLogs         = []web3Types.Log{}
+	ethEmptyFinalityLogs = []citypes.EthLog{}
 
 	errTooManyRewardPercentiles = errors.Errorf(
 		"the number of reward percentiles exceeds the maximum allowed (%v)",
@@ -48,6 +50,8 @@ type EthAPIOption struct {
 	LogApiHandler       *handler.EthLogsApiHandler
 	TxnHandler          *handler.EthTxnHandler
 	VirtualFilterClient *vfclient.EthClient
+	FinalityHandler     *handler.FinalityHandler
+	ResponseCache       *cache.ResponseCache
 }
 
 // ethAPI provides Ethereum relative API within evm space according to:
@@ -123,13 +127,13 @@ func (api *ethAPI) GetBlockByHash(
 // ChainId returns the chainID value for transaction replay protection.
 func (api *ethAPI) ChainId(ctx context.Context) (*hexutil.Uint64, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetChainId(w3c.Client)
+	return cache.EthFromContext(ctx).GetChainId(ctx, w3c.Client)
 }
 
 // BlockNumber returns the block number of the chain head.
 func (api *ethAPI) BlockNumber(ctx context.Context) (*hexutil.Big, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetBlockNumber(w3c)
+	return cache.EthFromContext(ctx).GetBlockNumber(ctx, w3c)
 }
 
 // GetBalance returns the amount of wei for the given address in the state of the
@@ -213,7 +217,7 @@ func (api *ethAPI) ProtocolVersion(ctx context.Context) (string, error) {
 // GasPrice returns the current gas price in wei.
 func (api *ethAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetGasPrice(w3c.Client)
+	return cache.EthFromContext(ctx).GetGasPrice(ctx, w3c.Client)
 }
 
 // GetStorageAt returns the value from a storage position at a given address.
@@ -336,10 +340,26 @@ func (api *ethAPI) GetTransactionReceipt(ctx context.Context, txHash common.Hash
 	return receipt, err
 }
 
-// GetLogs returns an array of all logs matching a given filter object.
-func (api *ethAPI) GetLogs(ctx context.Context, fq web3Types.FilterQuery) ([]web3Types.Log, error) {
+// GetLogs returns event logs matched with the log filter, along with the extension field `final`
+// of each log if the finality of indexed data is available.
+func (api *ethAPI) GetLogs(ctx context.Context, fq web3Types.FilterQuery) ([]citypes.EthLog, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return api.getLogs(ctx, w3c, &fq, rpcMethodEthGetLogs)
+
+	logs, err := api.getLogs(ctx, w3c, &fq, rpcMethodEthGetLogs)
+	if err != nil {
+		return ethEmptyFinalityLogs, err
+	}
+
+	res := make([]citypes.EthLog, len(logs))
+	for i := range logs {
+		res[i].Log = logs[i]
+
+		if api.FinalityHandler != nil {
+			res[i].Final = api.FinalityHandler.IsFinal(logs[i].BlockNumber)
+		}
+	}
+
+	return res, nil
 }
 
 // getLogs helper method to get logs from store or fullnode.
@@ -379,6 +399,21 @@ func (api *ethAPI) getLogs(
 	return w3c.Eth.Logs(*fq)
 }
 
+// GetLogsByTransactions returns event logs of the specified transactions, which is an extension
+// method for indexers to get event logs in batch instead of fetching receipts one by one.
+func (api *ethAPI) GetLogsByTransactions(ctx context.Context, txHashes []common.Hash) ([]web3Types.Log, error) {
+	if len(txHashes) > store.MaxLogTxHashesSize {
+		return ethEmptyLogs, ErrExceedLogTxHashLimit(len(txHashes))
+	}
+
+	if api.LogApiHandler == nil {
+		return ethEmptyLogs, ErrLogsByTxHashesUnsupported
+	}
+
+	logs, err := api.LogApiHandler.GetLogsByTxHashes(ctx, txHashes)
+	return uniformEthLogs(logs), err
+}
+
 // GetBlockTransactionCountByHash returns the total number of transactions in the given block.
 func (api *ethAPI) GetBlockTransactionCountByHash(ctx context.Context, blockHash common.Hash) (*hexutil.Big, error) {
 	w3c := GetEthClientFromContext(ctx)
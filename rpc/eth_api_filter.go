@@ -2,9 +2,12 @@ package rpc
 
 import (
 	"context"
+	"time"
 
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rate"
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
 	"github.com/openweb3/go-rpc-provider"
 	web3Types "github.com/openweb3/web3go/types"
 	"github.com/pkg/errors"
@@ -31,6 +34,25 @@ func errVirtualFilterProxyErrorOrNil(err error) error {
 	return errors.WithMessage(err, "virtual filter proxy error")
 }
 
+// virtualFilterQuota returns the virtual filter quota of the requester if configured by allowlist,
+// and also checks the number of addresses and topics of log filter criteria against the quota.
+func virtualFilterQuota(ctx context.Context, critSize int) (*vfclient.FilterQuota, error) {
+	owner, quota, ok := rate.FilterQuotaFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	if quota.MaxCriteria > 0 && critSize > quota.MaxCriteria {
+		return nil, ErrExceedFilterCriteriaQuota(quota.MaxCriteria, critSize)
+	}
+
+	return &vfclient.FilterQuota{
+		Owner:      owner,
+		MaxFilters: quota.MaxFilters,
+		MaxLogs:    quota.MaxLogs,
+	}, nil
+}
+
 // NewFilter creates a new filter and returns the filter id. It can be
 // used to retrieve logs when the state changes. This method cannot be
 // used to fetch logs that are already stored in the state.
@@ -47,7 +69,17 @@ func (api *ethAPI) NewFilter(ctx context.Context, fq web3Types.FilterQuery) (*rp
 	metrics.UpdateEthRpcLogFilter(rpcMethodEthNewFilter, w3c.Eth, &fq)
 
 	if api.VirtualFilterClient != nil {
-		fid, err := api.VirtualFilterClient.NewFilter(w3c.URL, &fq)
+		critSize := len(fq.Addresses)
+		for i := range fq.Topics {
+			critSize += len(fq.Topics[i])
+		}
+
+		quota, err := virtualFilterQuota(ctx, critSize)
+		if err != nil {
+			return nil, err
+		}
+
+		fid, err := api.VirtualFilterClient.NewFilter(w3c.URL, &fq, quota)
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -60,7 +92,12 @@ func (api *ethAPI) NewBlockFilter(ctx context.Context) (*rpc.ID, error) {
 	w3c := GetEthClientFromContext(ctx)
 
 	if api.VirtualFilterClient != nil {
-		fid, err := api.VirtualFilterClient.NewBlockFilter(w3c.URL)
+		quota, err := virtualFilterQuota(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fid, err := api.VirtualFilterClient.NewBlockFilter(w3c.URL, quota)
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -76,7 +113,12 @@ func (api *ethAPI) NewPendingTransactionFilter(ctx context.Context) (*rpc.ID, er
 	w3c := GetEthClientFromContext(ctx)
 
 	if api.VirtualFilterClient != nil {
-		fid, err := api.VirtualFilterClient.NewPendingTransactionFilter(w3c.URL)
+		quota, err := virtualFilterQuota(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fid, err := api.VirtualFilterClient.NewPendingTransactionFilter(w3c.URL, quota)
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -99,9 +141,17 @@ func (api *ethAPI) UninstallFilter(ctx context.Context, fid rpc.ID) (bool, error
 //
 // For pending transaction and block filters the result is []common.Hash.
 // (pending) Log filters return []Log.
-func (api *ethAPI) GetFilterChanges(ctx context.Context, fid rpc.ID) (interface{}, error) {
+//
+// The optional `waitMillis` is an extension to wait up to the duration in milliseconds until any
+// change arrives if no change yet, which is only supported by virtual filters.
+func (api *ethAPI) GetFilterChanges(ctx context.Context, fid rpc.ID, waitMillis *uint64) (interface{}, error) {
 	if api.VirtualFilterClient != nil {
-		res, err := api.VirtualFilterClient.GetFilterChanges(fid)
+		var wait time.Duration
+		if waitMillis != nil {
+			wait = time.Duration(*waitMillis) * time.Millisecond
+		}
+
+		res, err := api.VirtualFilterClient.GetFilterChanges(fid, wait)
 		return res, errVirtualFilterProxyErrorOrNil(err)
 	}
 
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"time"
 
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/util/metrics"
@@ -144,6 +145,32 @@ func (api *ethAPI) Logs(ctx context.Context, filter types.FilterQuery) (*rpc.Sub
 	return rpcSub, nil
 }
 
+// FilterChanges creates a subscription that streams the changes of an existing virtual filter, e.g.,
+// created by `eth_newFilter`, so that clients could move from polling to pubsub notification.
+func (api *ethAPI) FilterChanges(ctx context.Context, fid rpc.ID) (*rpc.Subscription, error) {
+	if api.VirtualFilterClient == nil {
+		return &rpc.Subscription{}, errFilterChangesSubscriptionUnsupported
+	}
+
+	return subscribeFilterChanges(ctx, "eth", fid, func(wait time.Duration) ([]interface{}, error) {
+		fc, err := api.VirtualFilterClient.GetFilterChanges(fid, wait)
+		if err != nil || fc == nil {
+			return nil, err
+		}
+
+		changes := make([]interface{}, 0, len(fc.Logs)+len(fc.Hashes))
+		for i := range fc.Logs {
+			changes = append(changes, &fc.Logs[i])
+		}
+
+		for i := range fc.Hashes {
+			changes = append(changes, fc.Hashes[i])
+		}
+
+		return changes, nil
+	})
+}
+
 type epubsubContext struct {
 	notifier  *rpc.Notifier
 	rpcClient *rpc.Client
@@ -224,6 +224,29 @@ func (handler *CfxLogsApiHandler) getLogsReorgGuard(
 	return logs, len(dbFilters) > 0, nil
 }
 
+// GetLogsByTxHashes returns event logs of the specified transactions from store.
+//
+// Note only event logs that have already been synchronized into store will be returned.
+func (handler *CfxLogsApiHandler) GetLogsByTxHashes(ctx context.Context, txHashes []types.Hash) ([]types.Log, error) {
+	hashes := make([]string, 0, len(txHashes))
+	for i := range txHashes {
+		hashes = append(hashes, txHashes[i].String())
+	}
+
+	dbLogs, err := getLogsByTxHashesReorgGuard(ctx, handler.ms, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]types.Log, 0, len(dbLogs))
+	for _, v := range dbLogs {
+		log, _ := v.ToCfxLog()
+		logs = append(logs, *log)
+	}
+
+	return logs, nil
+}
+
 func (handler *CfxLogsApiHandler) splitLogFilter(
 	cfx sdk.ClientOperator,
 	filter *types.LogFilter,
@@ -538,6 +561,52 @@ func newSuggestedBodyBytesOversizedError[T types.Log | store.Log](cfx sdk.Client
 	return errResponseBodySizeTooLarge
 }
 
+// getLogsByTxHashesReorgGuard gets event logs of the specified transactions from store,
+// and queries again if reorg happened during the query to ensure data consistence.
+func getLogsByTxHashesReorgGuard(ctx context.Context, ms *mysql.MysqlStore, txHashes []string) ([]*store.Log, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.TimeoutGetLogs)
+	defer cancel()
+
+	// record the reorg version before query to ensure data consistence
+	lastReorgVersion, err := ms.GetReorgVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		logs, err := ms.GetLogsByTxHashes(ctx, txHashes)
+		if err != nil {
+			return nil, err
+		}
+
+		// check the reorg version after query
+		reorgVersion, err := ms.GetReorgVersion()
+		if err != nil {
+			return nil, err
+		}
+
+		if reorgVersion == lastReorgVersion {
+			var accumulator int
+			for _, log := range logs {
+				accumulator += len(log.Extra)
+				if uint64(accumulator) > maxGetLogsResponseBytes {
+					return nil, errResponseBodySizeTooLarge
+				}
+			}
+
+			return logs, nil
+		}
+
+		// when reorg occurred, check timeout before retry.
+		if err := checkTimeout(ctx); err != nil {
+			return nil, err
+		}
+
+		// reorg version changed during data query and try again.
+		lastReorgVersion = reorgVersion
+	}
+}
+
 // checkTimeout checks if operation is timed out.
 func checkTimeout(ctx context.Context) error {
 	select {
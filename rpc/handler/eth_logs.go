@@ -9,7 +9,9 @@ import (
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	citypes "github.com/Conflux-Chain/confura/types"
+	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/openweb3/web3go/client"
 	"github.com/openweb3/web3go/types"
 )
@@ -69,16 +71,19 @@ func (handler *EthLogsApiHandler) getLogsReorgGuard(
 	filter *types.FilterQuery,
 	delegatedRpcMethod string,
 ) ([]types.Log, bool, error) {
-	// Try to query event logs from database and fullnode.
-	dbFilter, fnFilter, err := handler.splitLogFilter(eth, filter)
+	// Try to query event logs from database, unsafe head tier and fullnode.
+	dbFilter, headFilter, fnFilter, err := handler.splitLogFilter(eth, filter)
 	if err != nil {
 		return nil, false, err
 	}
 
+	storeHit := dbFilter != nil || headFilter != nil
+
 	if len(delegatedRpcMethod) > 0 {
 		metrics.Registry.RPC.Percentage(delegatedRpcMethod, "filter/split/alldatabase").Mark(fnFilter == nil)
-		metrics.Registry.RPC.Percentage(delegatedRpcMethod, "filter/split/allfullnode").Mark(dbFilter == nil)
-		metrics.Registry.RPC.Percentage(delegatedRpcMethod, "filter/split/partial").Mark(dbFilter != nil && fnFilter != nil)
+		metrics.Registry.RPC.Percentage(delegatedRpcMethod, "filter/split/allfullnode").Mark(!storeHit)
+		metrics.Registry.RPC.Percentage(delegatedRpcMethod, "filter/split/partial").Mark(storeHit && fnFilter != nil)
+		metrics.Registry.RPC.Percentage(delegatedRpcMethod, "filter/split/unsafehead").Mark(headFilter != nil)
 
 		if blockRange, valid := calculateEthBlockRange(fnFilter); valid {
 			numBlocks := blockRange.To - blockRange.From + 1
@@ -89,13 +94,15 @@ func (handler *EthLogsApiHandler) getLogsReorgGuard(
 	var logs []types.Log
 	var accumulator int
 
-	// query data from database
-	if dbFilter != nil {
-		// add db query timeout
+	// add db query timeout
+	if storeHit {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, store.TimeoutGetLogs)
 		defer cancel()
+	}
 
+	// query data from database
+	if dbFilter != nil {
 		dbLogs, err := handler.ms.GetLogs(ctx, *dbFilter)
 		if err != nil {
 			// TODO ErrPrunedAlready
@@ -112,6 +119,23 @@ func (handler *EthLogsApiHandler) getLogsReorgGuard(
 		}
 	}
 
+	// query data from unsafe head tier
+	if headFilter != nil {
+		headLogs, err := handler.ms.Head().GetLogs(ctx, *headFilter)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, v := range headLogs {
+			if accumulator, err = handler.accumulateBodySizeOfLogs(filter, accumulator, v); err != nil {
+				return nil, false, err
+			}
+
+			cfxLog, ext := v.ToCfxLog()
+			logs = append(logs, *ethbridge.ConvertLog(cfxLog, ext))
+		}
+	}
+
 	// query data from fullnode
 	if fnFilter != nil {
 		// check timeout before fullnode delegation
@@ -141,92 +165,158 @@ func (handler *EthLogsApiHandler) getLogsReorgGuard(
 		return nil, false, store.ErrFilterResultSetTooLarge
 	}
 
-	return logs, dbFilter != nil, nil
+	return logs, storeHit, nil
 }
 
+// GetLogsByTxHashes returns event logs of the specified transactions from store.
+//
+// Note only event logs that have already been synchronized into store will be returned.
+func (handler *EthLogsApiHandler) GetLogsByTxHashes(ctx context.Context, txHashes []common.Hash) ([]types.Log, error) {
+	hashes := make([]string, 0, len(txHashes))
+	for i := range txHashes {
+		hashes = append(hashes, txHashes[i].Hex())
+	}
+
+	dbLogs, err := getLogsByTxHashesReorgGuard(ctx, handler.ms, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]types.Log, 0, len(dbLogs))
+	for _, v := range dbLogs {
+		cfxLog, ext := v.ToCfxLog()
+		logs = append(logs, *ethbridge.ConvertLog(cfxLog, ext))
+	}
+
+	return logs, nil
+}
+
+// splitLogFilter splits the log filter into db filter, unsafe head filter and fullnode filter, any of
+// which would be nil if not necessary.
 func (handler *EthLogsApiHandler) splitLogFilter(
 	eth *client.RpcEthClient,
 	filter *types.FilterQuery,
-) (*store.LogFilter, *types.FilterQuery, error) {
+) (*store.LogFilter, *store.LogFilter, *types.FilterQuery, error) {
 	maxBlock, ok, err := handler.ms.MaxEpoch()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	if !ok {
-		return nil, filter, nil
+		return nil, nil, filter, nil
+	}
+
+	// unsafe head blocks beyond the max safe block in database
+	maxHeadBlock := maxBlock
+	if hs := handler.ms.Head(); hs != nil {
+		_, headTo, ok, err := hs.BlockRange()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if ok && headTo > maxBlock {
+			maxHeadBlock = headTo
+		}
 	}
 
 	if filter.BlockHash != nil {
-		return handler.splitLogFilterByBlockHash(eth, filter, maxBlock)
+		return handler.splitLogFilterByBlockHash(eth, filter, maxBlock, maxHeadBlock)
 	}
 
-	return handler.splitLogFilterByBlockRange(eth, filter, maxBlock)
+	return handler.splitLogFilterByBlockRange(eth, filter, maxBlock, maxHeadBlock)
 }
 
 func (handler *EthLogsApiHandler) splitLogFilterByBlockHash(
 	eth *client.RpcEthClient,
 	filter *types.FilterQuery,
-	maxBlock uint64,
-) (*store.LogFilter, *types.FilterQuery, error) {
+	maxBlock, maxHeadBlock uint64,
+) (*store.LogFilter, *store.LogFilter, *types.FilterQuery, error) {
 	block, err := eth.BlockByHash(*filter.BlockHash, false)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	if block == nil || block.Number == nil {
-		return nil, nil, errors.New("unknown block")
+		return nil, nil, nil, errors.New("unknown block")
 	}
 
 	bn := block.Number.Uint64()
 
-	if bn > maxBlock {
-		return nil, filter, nil
+	if bn > maxHeadBlock {
+		return nil, nil, filter, nil
 	}
 
 	networkId, err := handler.GetNetworkId(eth)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	dbFilter := store.ParseEthLogFilter(bn, bn, filter, networkId)
-	return &dbFilter, nil, err
+	if bn <= maxBlock {
+		dbFilter := store.ParseEthLogFilter(bn, bn, filter, networkId)
+		return &dbFilter, nil, nil, nil
+	}
+
+	// the unsafe head block must be the same one
+	headHash, _, ok, err := handler.ms.Head().Block(bn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if !ok || headHash != block.Hash.Hex() {
+		return nil, nil, filter, nil
+	}
+
+	headFilter := store.ParseEthLogFilter(bn, bn, filter, networkId)
+	return nil, &headFilter, nil, nil
 }
 
 func (handler *EthLogsApiHandler) splitLogFilterByBlockRange(
 	eth *client.RpcEthClient,
 	filter *types.FilterQuery,
-	maxBlock uint64,
-) (*store.LogFilter, *types.FilterQuery, error) {
+	maxBlock, maxHeadBlock uint64,
+) (*store.LogFilter, *store.LogFilter, *types.FilterQuery, error) {
 	if filter.FromBlock == nil || *filter.FromBlock < 0 {
-		return nil, filter, nil
+		return nil, nil, filter, nil
 	}
 
 	if filter.ToBlock == nil || *filter.ToBlock < 0 {
-		return nil, filter, nil
+		return nil, nil, filter, nil
 	}
 
 	blockFrom, blockTo := uint64(*filter.FromBlock), uint64(*filter.ToBlock)
 
-	// no data in database
-	if blockFrom > maxBlock {
-		return nil, filter, nil
+	// no data in database or unsafe head tier
+	if blockFrom > maxHeadBlock {
+		return nil, nil, filter, nil
 	}
 
 	networkId, err := handler.GetNetworkId(eth)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	var dbFilter, headFilter *store.LogFilter
+
+	// data in database
+	if blockFrom <= maxBlock {
+		f := store.ParseEthLogFilter(blockFrom, util.MinUint64(blockTo, maxBlock), filter, networkId)
+		dbFilter = &f
+	}
+
+	// data in unsafe head tier
+	if blockTo > maxBlock && maxHeadBlock > maxBlock {
+		headFrom := util.MaxUint64(blockFrom, maxBlock+1)
+		f := store.ParseEthLogFilter(headFrom, util.MinUint64(blockTo, maxHeadBlock), filter, networkId)
+		headFilter = &f
 	}
 
-	// all data in database
-	if blockTo <= maxBlock {
-		dbFilter := store.ParseEthLogFilter(blockFrom, blockTo, filter, networkId)
-		return &dbFilter, nil, nil
+	// all data in database or unsafe head tier
+	if blockTo <= maxHeadBlock {
+		return dbFilter, headFilter, nil, nil
 	}
 
-	// otherwise, partial data in databse
-	dbFilter := store.ParseEthLogFilter(blockFrom, maxBlock, filter, networkId)
-	fnBlockFrom := types.BlockNumber(maxBlock + 1)
+	// otherwise, partial data in databse or unsafe head tier
+	fnBlockFrom := types.BlockNumber(maxHeadBlock + 1)
 	fnFilter := types.FilterQuery{
 		FromBlock: &fnBlockFrom,
 		ToBlock:   filter.ToBlock,
@@ -234,7 +324,7 @@ func (handler *EthLogsApiHandler) splitLogFilterByBlockRange(
 		Topics:    filter.Topics,
 	}
 
-	return &dbFilter, &fnFilter, nil
+	return dbFilter, headFilter, &fnFilter, nil
 }
 
 func (handler *EthLogsApiHandler) GetNetworkId(eth *client.RpcEthClient) (uint32, error) {
@@ -22,6 +22,8 @@ type EthTxnHandler struct {
 	nclient  *rpc.Client         // node RPC client
 	clients  *util.ConcurrentMap // sdk clients: node name => RPC client
 	relayTxn bool                // whether to relay to other group nodes while sending txn
+	// fullnode groups to replicate txn sending if no node RPC, which defaults to evm space
+	groupConf map[node.Group]node.UrlConfig
 }
 
 func MustNewEthTxnHandler(relayer relay.TxnRelayer) *EthTxnHandler {
@@ -48,6 +50,22 @@ func MustNewEthTxnHandler(relayer relay.TxnRelayer) *EthTxnHandler {
 	}
 }
 
+// MustNewEthChainTxnHandler creates txn handler for EVM chain, which relays txn with configurations
+// of the specified viper key, and replicates txn sending to the fullnodes of chain groups.
+func MustNewEthChainTxnHandler(
+	relayer relay.TxnRelayer, relayKey string, groupConf map[node.Group]node.UrlConfig,
+) *EthTxnHandler {
+	cfg := struct{ RelayTxn bool }{}
+	viper.MustUnmarshalKey(relayKey, &cfg)
+
+	return &EthTxnHandler{
+		relayer:   relayer,
+		clients:   &util.ConcurrentMap{},
+		relayTxn:  cfg.RelayTxn,
+		groupConf: groupConf,
+	}
+}
+
 func (h *EthTxnHandler) SendRawTxn(w3c *node.Web3goClient, group node.Group, signedTx hexutil.Bytes) (common.Hash, error) {
 	txHash, err := w3c.Eth.SendRawTransaction(signedTx)
 	if err != nil {
@@ -84,7 +102,12 @@ func (h *EthTxnHandler) replicateRawTxnSendingByGroup(w3c *node.Web3goClient, gr
 	}
 
 	// otherwise get group nodes from local config
-	if conf, ok := node.EthUrlConfig()[group]; ok {
+	groupConf := h.groupConf
+	if groupConf == nil {
+		groupConf = node.EthUrlConfig()
+	}
+
+	if conf, ok := groupConf[group]; ok {
 		h.replicateRawTxnSendingToNodes(w3c, conf.Nodes, signedTx)
 	}
 }
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// expiration of the cached finalized epoch
+	finalityCacheExpiration = time.Second
+)
+
+// FinalityHandler tells whether the indexed data is final by the finalized epoch (or block for
+// evm space) recorded by syncer in db store, which is cached for a short while.
+type FinalityHandler struct {
+	ms *mysql.MysqlStore
+
+	mu       sync.Mutex
+	epoch    uint64
+	ok       bool
+	expireAt time.Time
+}
+
+func NewFinalityHandler(ms *mysql.MysqlStore) *FinalityHandler {
+	return &FinalityHandler{ms: ms}
+}
+
+// Finalized returns the finalized epoch, or false if unknown.
+func (h *FinalityHandler) Finalized() (uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Now().Before(h.expireAt) {
+		return h.epoch, h.ok
+	}
+
+	epoch, ok, err := h.ms.GetFinalizedEpoch()
+	if err != nil {
+		logrus.WithError(err).Debug("Finality handler failed to get finalized epoch from db")
+	}
+
+	h.epoch, h.ok = epoch, ok && err == nil
+	h.expireAt = time.Now().Add(finalityCacheExpiration)
+
+	return h.epoch, h.ok
+}
+
+// IsFinal returns whether the data of the specified epoch is final, or nil if unknown.
+func (h *FinalityHandler) IsFinal(epoch uint64) *bool {
+	finalized, ok := h.Finalized()
+	if !ok {
+		return nil
+	}
+
+	final := epoch <= finalized
+	return &final
+}
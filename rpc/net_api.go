@@ -13,7 +13,7 @@ type netAPI struct{}
 // Version returns the current network id.
 func (api *netAPI) Version(ctx context.Context) (string, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetNetVersion(w3c.Client)
+	return cache.EthFromContext(ctx).GetNetVersion(ctx, w3c.Client)
 }
 
 // Listening returns true if client is actively listening for network connections.
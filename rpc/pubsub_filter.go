@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// max duration to long poll virtual filter changes for each round of subscription
+	filterChangesPollingWait = 10 * time.Second
+	// interval to retry polling virtual filter changes on failure
+	filterChangesRetryInterval = 1 * time.Second
+)
+
+var (
+	errFilterChangesSubscriptionUnsupported = errors.New(
+		"filter changes subscription is only supported by virtual filters",
+	)
+)
+
+// filterChangesPoller polls the changes of virtual filter, and waits up to the specified duration
+// until any change arrives if no change yet.
+type filterChangesPoller func(wait time.Duration) ([]interface{}, error)
+
+// subscribeFilterChanges creates a subscription to stream the changes of an existing virtual filter,
+// which are long polled with the same cursor semantics as `getFilterChanges`.
+func subscribeFilterChanges(
+	ctx context.Context, space string, fid rpc.ID, poll filterChangesPoller,
+) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcClient, supported := rpcClientFromContext(ctx)
+	if !supported {
+		logrus.Error("FilterChanges pubsub context error: failed to get rpc client from context")
+		return &rpc.Subscription{}, errSubscriptionProxyError
+	}
+
+	// poll once at first to make sure the virtual filter exists
+	changes, err := poll(0)
+	if err != nil {
+		return &rpc.Subscription{}, errVirtualFilterProxyErrorOrNil(err)
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	logger := logrus.WithFields(logrus.Fields{"rpcSubID": rpcSub.ID, "fid": fid})
+
+	counter := metrics.Registry.PubSub.Sessions(space, "filter_changes", "virtual_filter")
+	counter.Inc(1)
+
+	go func() {
+		defer counter.Dec(1)
+
+		for {
+			for _, change := range changes {
+				notifier.Notify(rpcSub.ID, change)
+			}
+
+			select {
+			case err = <-rpcSub.Err(): // client connection closed or error
+				logger.WithError(err).Debug("FilterChanges pubsub subscription error")
+				return
+			case <-notifier.Closed():
+				logger.Debug("FilterChanges pubsub connection closed")
+				return
+			default:
+			}
+
+			changes, err = poll(filterChangesPollingWait)
+			if err == nil {
+				continue
+			}
+
+			if vfclient.IsFilterNotFoundError(err) {
+				// virtual filter expired or uninstalled, close the connection to notify client
+				logger.WithError(err).Debug("FilterChanges pubsub virtual filter not found")
+				rpcClient.Close()
+				return
+			}
+
+			logger.WithError(err).Info("Failed to poll virtual filter changes for pubsub")
+
+			changes = nil
+			time.Sleep(filterChangesRetryInterval)
+		}
+	}()
+
+	return rpcSub, nil
+}
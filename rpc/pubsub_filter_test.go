@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPollResult struct {
+	changes []interface{}
+	err     error
+}
+
+// newTestFilterChangesPoller creates poller which replies the results in order, and then replies
+// no change since all results polled.
+func newTestFilterChangesPoller(results ...testPollResult) filterChangesPoller {
+	return func(wait time.Duration) ([]interface{}, error) {
+		if len(results) == 0 {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		}
+
+		res := results[0]
+		results = results[1:]
+
+		return res.changes, res.err
+	}
+}
+
+type testFilterChangesApi struct {
+	poll filterChangesPoller
+}
+
+func (api *testFilterChangesApi) FilterChanges(ctx context.Context, fid rpc.ID) (*rpc.Subscription, error) {
+	return subscribeFilterChanges(ctx, "eth", fid, api.poll)
+}
+
+func newTestFilterChangesClient(t *testing.T, poll filterChangesPoller) *rpc.Client {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &testFilterChangesApi{poll}))
+	t.Cleanup(server.Stop)
+
+	client := rpc.DialInProc(server)
+	t.Cleanup(client.Close)
+
+	return client
+}
+
+func receiveFilterChanges(t *testing.T, ch <-chan string, num int) (res []string) {
+	for i := 0; i < num; i++ {
+		select {
+		case change := <-ch:
+			res = append(res, change)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout to receive filter changes")
+		}
+	}
+
+	return res
+}
+
+func TestSubscribeFilterChangesNotFound(t *testing.T) {
+	client := newTestFilterChangesClient(t, newTestFilterChangesPoller(
+		testPollResult{err: vfclient.ErrFilterNotFound},
+	))
+
+	ch := make(chan string)
+	_, err := client.Subscribe(context.Background(), "eth", ch, "filterChanges", "0x1")
+	assert.ErrorContains(t, err, "filter not found")
+}
+
+func TestSubscribeFilterChangesStreaming(t *testing.T) {
+	client := newTestFilterChangesClient(t, newTestFilterChangesPoller(
+		testPollResult{changes: []interface{}{"0x01"}},
+		testPollResult{},
+		testPollResult{changes: []interface{}{"0x02", "0x03"}},
+	))
+
+	ch := make(chan string)
+	sub, err := client.Subscribe(context.Background(), "eth", ch, "filterChanges", "0x1")
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	assert.Equal(t, []string{"0x01", "0x02", "0x03"}, receiveFilterChanges(t, ch, 3))
+}
+
+func TestSubscribeFilterChangesFilterRemoved(t *testing.T) {
+	client := newTestFilterChangesClient(t, newTestFilterChangesPoller(
+		testPollResult{changes: []interface{}{"0x01"}},
+		testPollResult{err: errors.New("virtual filter service unavailable")},
+		testPollResult{changes: []interface{}{"0x02"}},
+		testPollResult{err: vfclient.ErrFilterNotFound},
+	))
+
+	ch := make(chan string)
+	sub, err := client.Subscribe(context.Background(), "eth", ch, "filterChanges", "0x1")
+	require.NoError(t, err)
+
+	// changes streamed after retry on transient error
+	assert.Equal(t, []string{"0x01", "0x02"}, receiveFilterChanges(t, ch, 2))
+
+	// connection closed once the virtual filter is removed
+	select {
+	case err := <-sub.Err():
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout to close subscription")
+	}
+}
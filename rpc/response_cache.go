@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/rpc/cache"
+	"github.com/Conflux-Chain/confura/rpc/handler"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+const (
+	ctxKeyResponseCache = handlers.CtxKey("Infura-RPC-Response-Cache")
+
+	// length of hex encoded block hash with `0x` prefix
+	blockHashHexLength = 66
+)
+
+// responseCacheSpec specifies how to tell the block (or epoch) referred by RPC method.
+type responseCacheSpec struct {
+	// index of the block (or epoch) parameter, or -1 if referred by the response result
+	blockParam int
+	// field of the response result to tell the block (or epoch) number, e.g., `blockNumber`
+	resultField string
+}
+
+var (
+	ethResponseCacheSpecs = map[string]responseCacheSpec{
+		"eth_getBlockByNumber":                 {blockParam: 0},
+		"eth_getBlockByHash":                   {blockParam: -1, resultField: "number"},
+		"eth_getBlockReceipts":                 {blockParam: 0},
+		"eth_getBlockTransactionCountByNumber": {blockParam: 0},
+		"eth_getTransactionByHash":             {blockParam: -1, resultField: "blockNumber"},
+		"eth_getTransactionReceipt":            {blockParam: -1, resultField: "blockNumber"},
+		"eth_getBalance":                       {blockParam: 1},
+		"eth_getCode":                          {blockParam: 1},
+		"eth_getStorageAt":                     {blockParam: 2},
+		"eth_getTransactionCount":              {blockParam: 1},
+		"eth_call":                             {blockParam: 1},
+	}
+
+	cfxResponseCacheSpecs = map[string]responseCacheSpec{
+		"cfx_getBlockByEpochNumber": {blockParam: 0},
+		"cfx_getBlockByHash":        {blockParam: -1, resultField: "epochNumber"},
+		"cfx_getBlockByBlockNumber": {blockParam: -1, resultField: "epochNumber"},
+		"cfx_getBlocksByEpoch":      {blockParam: 0},
+		"cfx_getEpochReceipts":      {blockParam: 0},
+		"cfx_getTransactionReceipt": {blockParam: -1, resultField: "epochNumber"},
+		"cfx_getBalance":            {blockParam: 1},
+		"cfx_getCode":               {blockParam: 1},
+		"cfx_getStorageAt":          {blockParam: 2},
+		"cfx_getNextNonce":          {blockParam: 1},
+		"cfx_call":                  {blockParam: 1},
+	}
+)
+
+// blockRef block (or epoch) referred by RPC request or response.
+type blockRef struct {
+	number    uint64
+	hasNumber bool // referred by block (or epoch) number
+	pinned    bool // referred by block hash, of which the data is immutable
+	pending   bool // referred by `pending` tag, of which the data is never cached
+}
+
+// parseBlockRef parses the block (or epoch) reference, which is head-relative if neither number
+// nor block hash specified, e.g., `latest` or `latest_state`.
+func parseBlockRef(raw json.RawMessage) blockRef {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return parseBlockRefString(str)
+	}
+
+	var obj struct {
+		BlockHash        *string `json:"blockHash"`
+		BlockNumber      *string `json:"blockNumber"`
+		EpochNumber      *string `json:"epochNumber"`
+		RequireCanonical bool    `json:"requireCanonical"`
+		RequirePivot     bool    `json:"requirePivot"`
+	}
+
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return blockRef{}
+	}
+
+	switch {
+	case obj.BlockHash != nil:
+		return blockRef{pinned: !obj.RequireCanonical && !obj.RequirePivot}
+	case obj.BlockNumber != nil:
+		return parseBlockRefString(*obj.BlockNumber)
+	case obj.EpochNumber != nil:
+		return parseBlockRefString(*obj.EpochNumber)
+	}
+
+	return blockRef{}
+}
+
+func parseBlockRefString(str string) blockRef {
+	switch str {
+	case "earliest":
+		return blockRef{hasNumber: true}
+	case "pending":
+		return blockRef{pending: true}
+	}
+
+	if len(str) == blockHashHexLength && strings.HasPrefix(str, "0x") {
+		return blockRef{pinned: true}
+	}
+
+	if num, err := hexutil.DecodeUint64(str); err == nil {
+		return blockRef{number: num, hasNumber: true}
+	}
+
+	return blockRef{}
+}
+
+// responseCache caches responses of historical queries by the finality of the referred block (or
+// epoch), so that requests of finalized data are served without requesting full node at all.
+type responseCache struct {
+	cache    *cache.ResponseCache
+	specs    map[string]responseCacheSpec
+	finality *handler.FinalityHandler // nil if finality unknown
+}
+
+// httpMiddleware injects the response cache into context for the static RPC call middleware.
+func (rc *responseCache) httpMiddleware(next http.Handler) http.Handler {
+	if rc.cache == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxKeyResponseCache, rc)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// handle serves the RPC request from cache if hit, otherwise caches the response by the finality
+// of the referred block (or epoch).
+func (rc *responseCache) handle(
+	ctx context.Context, msg *rpc.JsonRpcMessage, next rpc.HandleCallMsgFunc,
+) *rpc.JsonRpcMessage {
+	spec, ok := rc.specs[msg.Method]
+	if !ok {
+		return next(ctx, msg)
+	}
+
+	var params []json.RawMessage
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return next(ctx, msg)
+		}
+	}
+
+	var ref blockRef
+	if spec.blockParam >= 0 && spec.blockParam < len(params) {
+		ref = parseBlockRef(params[spec.blockParam])
+	}
+
+	if ref.pending { // e.g., pending nonce or balance changes as transactions arrive
+		return next(ctx, msg)
+	}
+
+	key := callMsgKey(msg)
+
+	result, hit := rc.cache.Get(key)
+	metrics.Registry.RPC.ResponseCacheHit(msg.Method).Mark(hit)
+
+	if hit {
+		return &rpc.JsonRpcMessage{Version: msg.Version, ID: msg.ID, Result: result}
+	}
+
+	resp := next(ctx, msg)
+	if resp == nil || resp.Error != nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+		return resp
+	}
+
+	if spec.blockParam < 0 {
+		// not packed yet if block (or epoch) number is absent, e.g., pending transaction
+		ref.pending = true
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(resp.Result, &fields); err == nil {
+			if raw, ok := fields[spec.resultField]; ok && string(raw) != "null" {
+				ref = parseBlockRef(raw)
+			}
+		}
+	}
+
+	switch {
+	case ref.pending:
+		// never cache pending data
+	case ref.pinned:
+		rc.cache.AddPinned(key, resp.Result)
+	case ref.hasNumber && rc.isFinalized(ref.number):
+		rc.cache.AddFinalized(key, resp.Result, ref.number)
+	default:
+		rc.cache.AddHead(key, resp.Result)
+	}
+
+	return resp
+}
+
+func (rc *responseCache) isFinalized(number uint64) bool {
+	if rc.finality == nil {
+		return false
+	}
+
+	finalized, ok := rc.finality.Finalized()
+	return ok && number <= finalized
+}
+
+// callMsgKey generates key of RPC call by method and parameters without insignificant spaces.
+func callMsgKey(msg *rpc.JsonRpcMessage) string {
+	var buf bytes.Buffer
+	buf.WriteString(msg.Method)
+
+	if err := json.Compact(&buf, msg.Params); err != nil {
+		buf.Write(msg.Params)
+	}
+
+	return buf.String()
+}
+
+// responseCacheMiddleware serves RPC requests from the response cache injected into context if any.
+func responseCacheMiddleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if rc, ok := ctx.Value(ctxKeyResponseCache).(*responseCache); ok {
+			return rc.handle(ctx, msg, next)
+		}
+
+		return next(ctx, msg)
+	}
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/rpc/cache"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBlockRef(t *testing.T) {
+	hash := `"0x2a8b5c7d4e6f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b"`
+
+	testCases := []struct {
+		raw      string
+		expected blockRef
+	}{
+		{`"latest"`, blockRef{}},
+		{`"latest_state"`, blockRef{}},
+		{`"earliest"`, blockRef{hasNumber: true}},
+		{`"pending"`, blockRef{pending: true}},
+		{`{"blockNumber":"pending"}`, blockRef{pending: true}},
+		{`"0x10"`, blockRef{number: 16, hasNumber: true}},
+		{hash, blockRef{pinned: true}},
+		{`{"blockHash":` + hash + `}`, blockRef{pinned: true}},
+		{`{"blockHash":` + hash + `,"requireCanonical":true}`, blockRef{}},
+		{`{"blockNumber":"0x20"}`, blockRef{number: 32, hasNumber: true}},
+		{`null`, blockRef{}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, parseBlockRef(json.RawMessage(tc.raw)), tc.raw)
+	}
+}
+
+func TestResponseCacheHandle(t *testing.T) {
+	rc := &responseCache{
+		cache: cache.NewResponseCache(cache.ResponseCacheConfig{
+			FinalizedSize: 10, HeadSize: 10, HeadExpiration: time.Minute, MaxResponseBytes: 1024,
+		}),
+		specs: ethResponseCacheSpecs,
+	}
+
+	var calls int
+	result := `{"blockNumber":"0x1"}`
+	next := func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		calls++
+		return &rpc.JsonRpcMessage{Version: msg.Version, ID: msg.ID, Result: json.RawMessage(result)}
+	}
+
+	newMsg := func(id, method, params string) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Version: "2.0", ID: json.RawMessage(id), Method: method, Params: json.RawMessage(params)}
+	}
+
+	// identical requests regardless of insignificant spaces served from cache
+	rc.handle(context.Background(), newMsg("1", "eth_getBlockByNumber", `["latest", false]`), next)
+	resp := rc.handle(context.Background(), newMsg("2", "eth_getBlockByNumber", `["latest",false]`), next)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, json.RawMessage("2"), resp.ID)
+
+	// head-relative responses invalidated upon chain reorg
+	rc.cache.Revert(1)
+	rc.handle(context.Background(), newMsg("3", "eth_getBlockByNumber", `["latest",false]`), next)
+	assert.Equal(t, 2, calls)
+
+	// methods not cacheable
+	rc.handle(context.Background(), newMsg("4", "eth_blockNumber", `[]`), next)
+	rc.handle(context.Background(), newMsg("5", "eth_blockNumber", `[]`), next)
+	assert.Equal(t, 4, calls)
+
+	// requests of pending data never cached
+	for i := 0; i < 2; i++ {
+		rc.handle(context.Background(), newMsg("6", "eth_getTransactionCount", `["0x8c9f9e2e9c8e2e1e2c0d8e9f2e5c6b7a8d9e0f11","pending"]`), next)
+		rc.handle(context.Background(), newMsg("7", "eth_call", `[{"to":"0x8c9f9e2e9c8e2e1e2c0d8e9f2e5c6b7a8d9e0f11"},"pending"]`), next)
+	}
+	assert.Equal(t, 8, calls)
+
+	// responses of pending transaction never cached
+	result = `{"blockNumber":null}`
+	for i := 0; i < 2; i++ {
+		rc.handle(context.Background(), newMsg("8", "eth_getTransactionByHash", `["0x0a"]`), next)
+	}
+	assert.Equal(t, 10, calls)
+}
@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"fmt"
+
 	infuraNode "github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/rpc/handler"
 	"github.com/Conflux-Chain/confura/util/rate"
@@ -11,6 +13,7 @@ import (
 const (
 	nativeSpaceRpcServerName = "core_space_rpc"
 	evmSpaceRpcServerName    = "evm_space_rpc"
+	evmChainRpcServerName    = "evm_chain_rpc"
 
 	nativeSpaceBridgeRpcServerName = "core_space_bridge_rpc"
 
@@ -40,7 +43,14 @@ func MustNewNativeSpaceServer(
 
 	middleware := httpMiddleware(registry, clientProvider)
 
-	return rpc.MustNewServer(nativeSpaceRpcServerName, exposedApis, middleware)
+	var opt CfxAPIOption
+	if len(option) > 0 {
+		opt = option[0]
+	}
+
+	rc := &responseCache{cache: opt.ResponseCache, specs: cfxResponseCacheSpecs, finality: opt.FinalityHandler}
+
+	return rpc.MustNewServer(nativeSpaceRpcServerName, exposedApis, middleware, rc.httpMiddleware)
 }
 
 // MustNewEvmSpaceServer new evm space RPC server by specifying router, and exposed modules.
@@ -68,7 +78,42 @@ func MustNewEvmSpaceServer(
 
 	middleware := httpMiddleware(registry, clientProvider)
 
-	return rpc.MustNewServer(evmSpaceRpcServerName, exposedApis, middleware)
+	var opt EthAPIOption
+	if len(option) > 0 {
+		opt = option[0]
+	}
+
+	rc := &responseCache{cache: opt.ResponseCache, specs: ethResponseCacheSpecs, finality: opt.FinalityHandler}
+
+	return rpc.MustNewServer(evmSpaceRpcServerName, exposedApis, middleware, rc.httpMiddleware)
+}
+
+// MustNewEvmChainServer new RPC server of EVM chain besides the evm space, which serves the same
+// RPC APIs as the evm space server with requests tagged by the chain ID.
+func MustNewEvmChainServer(
+	chainId uint64,
+	registry *rate.Registry,
+	clientProvider *infuraNode.EthClientProvider,
+	gasHandler *handler.EthGasStationHandler,
+	exposedModules []string,
+	option ...EthAPIOption,
+) *rpc.Server {
+	allApis, err := evmSpaceApis(clientProvider, gasHandler, option...)
+	if err != nil {
+		logrus.WithError(err).WithField("chainId", chainId).Fatal("Failed to new EVM chain RPC server")
+	}
+
+	exposedApis, err := filterExposedApis(allApis, exposedModules)
+	if err != nil {
+		logrus.WithError(err).WithField("chainId", chainId).Fatal(
+			"Failed to new EVM chain RPC server with bad exposed modules",
+		)
+	}
+
+	name := fmt.Sprintf("%v_%v", evmChainRpcServerName, chainId)
+	middleware := httpMiddleware(registry, clientProvider)
+
+	return rpc.MustNewServer(name, exposedApis, middleware, chainMiddleware(chainId))
 }
 
 type CfxBridgeServerConfig struct {
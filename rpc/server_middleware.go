@@ -57,9 +57,17 @@ func MustInit() {
 	rpc.HookHandleBatch(middlewares.LogBatch)
 	rpc.HookHandleCallMsg(middlewares.Log)
 
+	// response cache
+	rpc.HookHandleCallMsg(responseCacheMiddleware)
+
 	// cfx/eth client
 	rpc.HookHandleCallMsg(clientMiddleware)
 
+	// request coalescing
+	if coalescer := mustNewRequestCoalescerFromViper(); coalescer != nil {
+		rpc.HookHandleCallMsg(coalescer.middleware)
+	}
+
 	// uniform human-readable error message
 	rpc.HookHandleCallMsg(middlewares.UniformError)
 
@@ -96,6 +104,16 @@ func httpMiddleware(registry *rate.Registry, clientProvider interface{}) handler
 	}
 }
 
+// Inject chain ID into context to tag RPC requests of EVM chain, e.g. metrics
+func chainMiddleware(chainId uint64) handlers.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), handlers.CtxKeyChainId, chainId)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func clientMiddleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
 		var client interface{}
@@ -13,7 +13,7 @@ type web3API struct{}
 // ClientVersion returns the current client version.
 func (api *web3API) ClientVersion(ctx context.Context) (string, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetClientVersion(w3c.Client)
+	return cache.EthFromContext(ctx).GetClientVersion(ctx, w3c.Client)
 }
 
 // Sha3 returns Keccak-256 (not the standardized SHA3-256) hash of the given data.
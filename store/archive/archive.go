@@ -0,0 +1,364 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// directory of partition manifest files, which are keyed by `<entity>/<partition>/<archive time>.json`
+	manifestDir = "manifest"
+)
+
+// Manifest index of all the archived entity partitions.
+type Manifest struct {
+	Partitions []*PartitionMeta `json:"partitions"`
+}
+
+// PartitionMeta metadata of archived entity partition.
+type PartitionMeta struct {
+	Entity     string      `json:"entity"`
+	Partition  uint32      `json:"partition"`
+	BnMin      uint64      `json:"bnMin"`
+	BnMax      uint64      `json:"bnMax"`
+	Files      []*FileMeta `json:"files"`
+	ArchivedAt time.Time   `json:"archivedAt"`
+}
+
+// FileMeta metadata of archive file.
+type FileMeta struct {
+	Key   string `json:"key"`
+	BnMin uint64 `json:"bnMin"`
+	BnMax uint64 `json:"bnMax"`
+	Count int    `json:"count"`
+}
+
+// Archive archives event logs of pruned partitions into compressed columnar files on object
+// storage, and serves historical event logs from the archive files.
+type Archive struct {
+	config  *Config
+	storage Storage
+
+	mu               sync.Mutex
+	manifest         *Manifest
+	manifestLoadedAt time.Time
+	// loaded partition manifest files: key => partition metadata
+	partitionMetas map[string]*PartitionMeta
+
+	// cache of decoded archive files: key => []*store.Log
+	cache *lru.Cache
+}
+
+// MustNew creates an instance of Archive or exits on any error.
+func MustNew(config *Config) *Archive {
+	storage, err := NewStorage(config)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create archive storage")
+	}
+
+	cache, _ := lru.New(max(config.CacheSize, 1))
+	return &Archive{
+		config:         config,
+		storage:        storage,
+		cache:          cache,
+		partitionMetas: make(map[string]*PartitionMeta),
+	}
+}
+
+// ChunkSize returns the max number of event logs per archive file.
+func (a *Archive) ChunkSize() int {
+	return max(a.config.ChunkSize, 1)
+}
+
+// Put saves event logs of the entity partition as the archive file with the specified sequence
+// number, which will not be visible until committed into manifest.
+func (a *Archive) Put(
+	ctx context.Context, entity string, partition uint32, seq int, logs []*store.Log,
+) (*FileMeta, error) {
+	if len(logs) == 0 {
+		return nil, errors.New("no event logs to archive")
+	}
+
+	data, err := encodeLogs(logs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encode event logs")
+	}
+
+	file := &FileMeta{BnMin: logs[0].BlockNumber, BnMax: logs[0].BlockNumber, Count: len(logs)}
+	for _, log := range logs {
+		file.BnMin = min(file.BnMin, log.BlockNumber)
+		file.BnMax = max(file.BnMax, log.BlockNumber)
+	}
+
+	file.Key = a.key(entity, fmt.Sprint(partition), fmt.Sprintf("%v-%v-%v.logs.zst", seq, file.BnMin, file.BnMax))
+
+	if err := a.storage.Put(ctx, file.Key, data); err != nil {
+		return nil, errors.WithMessage(err, "failed to save archive file")
+	}
+
+	return file, nil
+}
+
+// Commit commits the archive files of the entity partition into manifest, which replaces any
+// archive files previously committed for the same entity partition.
+//
+// Each commit is saved as a new partition manifest file rather than read-modify-write of a shared
+// manifest file, so that concurrent commits from different processes never overwrite each other.
+func (a *Archive) Commit(ctx context.Context, meta *PartitionMeta) error {
+	meta.ArchivedAt = time.Now()
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal partition manifest")
+	}
+
+	key := a.key(
+		manifestDir, meta.Entity, fmt.Sprint(meta.Partition), fmt.Sprintf("%020d.json", meta.ArchivedAt.UnixNano()),
+	)
+	if err := a.storage.Put(ctx, key, data); err != nil {
+		return errors.WithMessage(err, "failed to save partition manifest")
+	}
+
+	// reload manifest on next access
+	a.mu.Lock()
+	a.manifest = nil
+	a.mu.Unlock()
+
+	return nil
+}
+
+// GetLogs returns archived event logs of the entity for the log filter, or `store.ErrAlreadyPruned`
+// if the block range of the log filter is not fully covered by archived partitions.
+//
+// Note contract addresses of the log filter are ignored, since event logs are archived by entity.
+func (a *Archive) GetLogs(ctx context.Context, entity string, filter store.LogFilter) ([]*store.Log, error) {
+	partitions, err := a.searchPartitions(ctx, entity, filter.BlockFrom, filter.BlockTo)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := newTopicsMatcher(filter.Topics)
+
+	var result []*store.Log
+	for _, partition := range partitions {
+		for _, file := range partition.Files {
+			if file.BnMax < filter.BlockFrom || file.BnMin > filter.BlockTo {
+				continue
+			}
+
+			// check timeout before loading archive file
+			select {
+			case <-ctx.Done():
+				return nil, store.ErrGetLogsTimeout
+			default:
+			}
+
+			logs, err := a.loadFile(ctx, file.Key)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to load archive file %v", file.Key)
+			}
+
+			for _, log := range logs {
+				if log.BlockNumber < filter.BlockFrom || log.BlockNumber > filter.BlockTo {
+					continue
+				}
+
+				if matcher.match(log) {
+					result = append(result, log)
+				}
+			}
+
+			if len(result) > int(store.MaxLogLimit) {
+				return nil, store.ErrFilterResultSetTooLarge
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// searchPartitions searches the archived entity partitions which cover the specified block range,
+// or returns `store.ErrAlreadyPruned` if the block range is not fully covered by archived partitions,
+// e.g., gaps between archived partitions or beyond the last archived partition.
+func (a *Archive) searchPartitions(ctx context.Context, entity string, bnFrom, bnTo uint64) ([]*PartitionMeta, error) {
+	manifest, err := a.getManifest(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get manifest")
+	}
+
+	var partitions []*PartitionMeta
+	for _, p := range manifest.Partitions {
+		if p.Entity == entity {
+			partitions = append(partitions, p)
+		}
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].BnMin < partitions[j].BnMin
+	})
+
+	// block number from which not covered by the archived partitions yet
+	next := bnFrom
+
+	var result []*PartitionMeta
+	for _, p := range partitions {
+		if p.BnMax < next {
+			continue
+		}
+
+		if p.BnMin > next { // gap between archived partitions
+			break
+		}
+
+		result = append(result, p)
+
+		if p.BnMax >= bnTo {
+			return result, nil
+		}
+
+		next = p.BnMax + 1
+	}
+
+	return nil, errors.WithMessagef(
+		store.ErrAlreadyPruned, "block range [%v, %v] not archived since block %v", bnFrom, bnTo, next,
+	)
+}
+
+// getManifest returns the manifest, which will be reloaded from storage periodically.
+func (a *Archive) getManifest(ctx context.Context) (*Manifest, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.manifest != nil && time.Since(a.manifestLoadedAt) < a.config.ManifestRefreshInterval {
+		return a.manifest, nil
+	}
+
+	manifest, err := a.loadManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.manifest, a.manifestLoadedAt = manifest, time.Now()
+	return manifest, nil
+}
+
+// loadManifest loads the latest manifest file of each entity partition from storage. Note, caller
+// must hold the lock.
+func (a *Archive) loadManifest(ctx context.Context) (*Manifest, error) {
+	keys, err := a.storage.List(ctx, a.key(manifestDir)+"/")
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list partition manifests")
+	}
+
+	// latest manifest file of each entity partition: entity partition dir => key
+	latestKeys := make(map[string]string)
+	for _, key := range keys {
+		dir, file := path.Split(key)
+		if path.Ext(file) != ".json" {
+			continue
+		}
+
+		// file names are zero padded archive time, which could be compared lexicographically
+		if latest, ok := latestKeys[dir]; !ok || key > latest {
+			latestKeys[dir] = key
+		}
+	}
+
+	manifest := &Manifest{}
+	partitionMetas := make(map[string]*PartitionMeta, len(latestKeys))
+
+	for _, key := range latestKeys {
+		// partition manifest files are immutable once saved
+		meta, ok := a.partitionMetas[key]
+		if !ok {
+			data, err := a.storage.Get(ctx, key)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to get partition manifest %v", key)
+			}
+
+			meta = &PartitionMeta{}
+			if err := json.Unmarshal(data, meta); err != nil {
+				return nil, errors.WithMessagef(err, "failed to unmarshal partition manifest %v", key)
+			}
+		}
+
+		partitionMetas[key] = meta
+		manifest.Partitions = append(manifest.Partitions, meta)
+	}
+
+	a.partitionMetas = partitionMetas
+
+	return manifest, nil
+}
+
+func (a *Archive) loadFile(ctx context.Context, key string) ([]*store.Log, error) {
+	if v, ok := a.cache.Get(key); ok {
+		return v.([]*store.Log), nil
+	}
+
+	data, err := a.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := decodeLogs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	a.cache.Add(key, logs)
+	return logs, nil
+}
+
+func (a *Archive) key(elems ...string) string {
+	return path.Join(append([]string{a.config.Prefix}, elems...)...)
+}
+
+// topicsMatcher matches event logs by topics in case insensitive manner.
+type topicsMatcher [][]string
+
+func newTopicsMatcher(topics []store.VariadicValue) topicsMatcher {
+	matcher := make(topicsMatcher, len(topics))
+	for i := range topics {
+		if !topics[i].IsNull() {
+			matcher[i] = topics[i].ToSlice()
+		}
+	}
+
+	return matcher
+}
+
+func (m topicsMatcher) match(log *store.Log) bool {
+	logTopics := [4]string{log.Topic0, log.Topic1, log.Topic2, log.Topic3}
+
+	for i, values := range m {
+		if i >= len(logTopics) || len(values) == 0 { // wildcard
+			continue
+		}
+
+		matched := false
+		for _, v := range values {
+			if strings.EqualFold(v, logTopics[i]) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
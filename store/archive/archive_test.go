@@ -0,0 +1,156 @@
+package archive
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestArchive(t *testing.T) *Archive {
+	return MustNew(&Config{
+		Storage:   "local",
+		Local:     LocalConfig{Dir: t.TempDir()},
+		Prefix:    "test",
+		ChunkSize: 2,
+		CacheSize: 4,
+	})
+}
+
+func TestArchivePutAndGetLogs(t *testing.T) {
+	ctx := context.Background()
+	a := newTestArchive(t)
+
+	logs := []*store.Log{
+		{ContractID: 1, BlockNumber: 100, Epoch: 10, Topic0: "0xaa", Extra: []byte("{}")},
+		{ContractID: 2, BlockNumber: 101, Epoch: 10, Topic0: "0xbb"},
+		{ContractID: 1, BlockNumber: 105, Epoch: 11, Topic0: "0xAA", Topic1: "0x01"},
+	}
+
+	f0, err := a.Put(ctx, "logs", 0, 0, logs[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), f0.BnMin)
+	assert.Equal(t, uint64(101), f0.BnMax)
+
+	f1, err := a.Put(ctx, "logs", 0, 1, logs[2:])
+	assert.NoError(t, err)
+
+	err = a.Commit(ctx, &PartitionMeta{
+		Entity: "logs", Partition: 0, BnMin: 100, BnMax: 110, Files: []*FileMeta{f0, f1},
+	})
+	assert.NoError(t, err)
+
+	// all event logs
+	result, err := a.GetLogs(ctx, "logs", store.LogFilter{BlockFrom: 100, BlockTo: 110})
+	assert.NoError(t, err)
+	assert.Equal(t, logs, result)
+
+	// filter by block range and topics
+	result, err = a.GetLogs(ctx, "logs", store.LogFilter{
+		BlockFrom: 100,
+		BlockTo:   110,
+		Topics:    []store.VariadicValue{store.NewVariadicValue("0xaa"), store.NewVariadicValue("0x01")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, logs[2:], result)
+
+	// block range not archived
+	_, err = a.GetLogs(ctx, "logs", store.LogFilter{BlockFrom: 90, BlockTo: 100})
+	assert.ErrorIs(t, err, store.ErrAlreadyPruned)
+
+	// entity not archived
+	_, err = a.GetLogs(ctx, "clogs_1", store.LogFilter{BlockFrom: 100, BlockTo: 110})
+	assert.ErrorIs(t, err, store.ErrAlreadyPruned)
+}
+
+func TestArchiveCommitReplaces(t *testing.T) {
+	ctx := context.Background()
+	a := newTestArchive(t)
+
+	logs := []*store.Log{{BlockNumber: 100}, {BlockNumber: 101}}
+	for i := 0; i < 2; i++ {
+		file, err := a.Put(ctx, "logs", 0, 0, logs)
+		assert.NoError(t, err)
+
+		err = a.Commit(ctx, &PartitionMeta{Entity: "logs", BnMin: 100, BnMax: 101, Files: []*FileMeta{file}})
+		assert.NoError(t, err)
+	}
+
+	// reload manifest from storage
+	manifest, err := a.loadManifest(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Partitions, 1)
+}
+
+func TestArchiveSearchPartitions(t *testing.T) {
+	ctx := context.Background()
+	a := newTestArchive(t)
+
+	// partition 2 pruned without archived
+	for _, p := range []*PartitionMeta{
+		{Entity: "logs", Partition: 0, BnMin: 100, BnMax: 199},
+		{Entity: "logs", Partition: 1, BnMin: 200, BnMax: 299},
+		{Entity: "logs", Partition: 3, BnMin: 400, BnMax: 499},
+	} {
+		assert.NoError(t, a.Commit(ctx, p))
+	}
+
+	testCases := []struct {
+		bnFrom, bnTo uint64
+		partitions   []uint32
+	}{
+		{bnFrom: 150, bnTo: 250, partitions: []uint32{0, 1}},
+		{bnFrom: 200, bnTo: 299, partitions: []uint32{1}},
+		{bnFrom: 410, bnTo: 420, partitions: []uint32{3}},
+		{bnFrom: 90, bnTo: 150},  // before the first archived partition
+		{bnFrom: 250, bnTo: 350}, // gap between archived partitions
+		{bnFrom: 450, bnTo: 550}, // beyond the last archived partition
+	}
+
+	for _, tc := range testCases {
+		partitions, err := a.searchPartitions(ctx, "logs", tc.bnFrom, tc.bnTo)
+		if len(tc.partitions) == 0 {
+			assert.ErrorIs(t, err, store.ErrAlreadyPruned)
+			continue
+		}
+
+		assert.NoError(t, err)
+
+		var indexes []uint32
+		for _, p := range partitions {
+			indexes = append(indexes, p.Partition)
+		}
+
+		assert.Equal(t, tc.partitions, indexes)
+	}
+}
+
+func TestArchiveConcurrentCommits(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// archives of different processes sharing the same storage
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(partition uint32) {
+			defer wg.Done()
+
+			a := MustNew(&Config{Storage: "local", Local: LocalConfig{Dir: dir}, Prefix: "test"})
+			err := a.Commit(ctx, &PartitionMeta{
+				Entity: "logs", Partition: partition, BnMin: uint64(partition) * 100, BnMax: uint64(partition)*100 + 99,
+			})
+			assert.NoError(t, err)
+		}(uint32(i))
+	}
+
+	wg.Wait()
+
+	a := MustNew(&Config{Storage: "local", Local: LocalConfig{Dir: dir}, Prefix: "test"})
+	manifest, err := a.loadManifest(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Partitions, 8)
+}
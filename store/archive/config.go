@@ -0,0 +1,37 @@
+package archive
+
+import "time"
+
+// Config configurations to archive pruned event log partitions into cold storage.
+type Config struct {
+	Enabled bool
+
+	// storage type to save archive files, available options are `local` and `s3`
+	Storage string `default:"local"`
+	Local   LocalConfig
+	S3      S3Config
+
+	// key prefix of archive files within the storage
+	Prefix string
+	// max number of event logs per archive file
+	ChunkSize int `default:"100000"`
+	// max number of decoded archive files to cache in memory
+	CacheSize int `default:"16"`
+	// interval to reload manifest from storage for data serving
+	ManifestRefreshInterval time.Duration `default:"1m"`
+}
+
+// LocalConfig configurations of local filesystem storage.
+type LocalConfig struct {
+	Dir string `default:"./data/archive"`
+}
+
+// S3Config configurations of S3 compatible object storage (eg., AWS S3 or MinIO).
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool `default:"true"`
+}
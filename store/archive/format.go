@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"encoding/json"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// format version of archive file
+	logColumnsVersion = 1
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// logColumns columnar layout of event logs within an archive file, which is encoded as json
+// and compressed by zstd.
+//
+// Be noted that it's a deliberate deviation from standard columnar formats (e.g., Parquet), since
+// archive files are only read back by confura as a whole, and zstd compressed columns achieve
+// comparable size without pulling in a heavy dependency. The format is versioned, so that any
+// standard columnar format could be introduced as a new version if archive files are required
+// to be queried by external tools.
+type logColumns struct {
+	Version int `json:"version"`
+
+	ContractIDs  []uint64 `json:"cid"`
+	BlockNumbers []uint64 `json:"bn"`
+	Epochs       []uint64 `json:"epoch"`
+	Topic0s      []string `json:"topic0"`
+	Topic1s      []string `json:"topic1"`
+	Topic2s      []string `json:"topic2"`
+	Topic3s      []string `json:"topic3"`
+	LogIndexes   []uint64 `json:"logIndex"`
+	TxHashIds    []uint64 `json:"txHashId"`
+	Extras       [][]byte `json:"extra"`
+}
+
+// encodeLogs encodes event logs into compressed columnar data.
+func encodeLogs(logs []*store.Log) ([]byte, error) {
+	cols := logColumns{
+		Version:      logColumnsVersion,
+		ContractIDs:  make([]uint64, 0, len(logs)),
+		BlockNumbers: make([]uint64, 0, len(logs)),
+		Epochs:       make([]uint64, 0, len(logs)),
+		Topic0s:      make([]string, 0, len(logs)),
+		Topic1s:      make([]string, 0, len(logs)),
+		Topic2s:      make([]string, 0, len(logs)),
+		Topic3s:      make([]string, 0, len(logs)),
+		LogIndexes:   make([]uint64, 0, len(logs)),
+		TxHashIds:    make([]uint64, 0, len(logs)),
+		Extras:       make([][]byte, 0, len(logs)),
+	}
+
+	for _, log := range logs {
+		cols.ContractIDs = append(cols.ContractIDs, log.ContractID)
+		cols.BlockNumbers = append(cols.BlockNumbers, log.BlockNumber)
+		cols.Epochs = append(cols.Epochs, log.Epoch)
+		cols.Topic0s = append(cols.Topic0s, log.Topic0)
+		cols.Topic1s = append(cols.Topic1s, log.Topic1)
+		cols.Topic2s = append(cols.Topic2s, log.Topic2)
+		cols.Topic3s = append(cols.Topic3s, log.Topic3)
+		cols.LogIndexes = append(cols.LogIndexes, log.LogIndex)
+		cols.TxHashIds = append(cols.TxHashIds, log.TxHashId)
+		cols.Extras = append(cols.Extras, log.Extra)
+	}
+
+	data, err := json.Marshal(&cols)
+	if err != nil {
+		return nil, err
+	}
+
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+// decodeLogs decodes event logs from compressed columnar data.
+func decodeLogs(compressed []byte) ([]*store.Log, error) {
+	data, err := zstdDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to decompress")
+	}
+
+	var cols logColumns
+	if err := json.Unmarshal(data, &cols); err != nil {
+		return nil, errors.WithMessage(err, "failed to unmarshal")
+	}
+
+	if cols.Version != logColumnsVersion {
+		return nil, errors.Errorf("unsupported format version %v", cols.Version)
+	}
+
+	n := len(cols.BlockNumbers)
+	for _, size := range []int{
+		len(cols.ContractIDs), len(cols.Epochs), len(cols.Topic0s), len(cols.Topic1s), len(cols.Topic2s),
+		len(cols.Topic3s), len(cols.LogIndexes), len(cols.TxHashIds), len(cols.Extras),
+	} {
+		if size != n {
+			return nil, errors.New("mismatched column size")
+		}
+	}
+
+	logs := make([]*store.Log, n)
+	for i := range logs {
+		logs[i] = &store.Log{
+			ContractID:  cols.ContractIDs[i],
+			BlockNumber: cols.BlockNumbers[i],
+			Epoch:       cols.Epochs[i],
+			Topic0:      cols.Topic0s[i],
+			Topic1:      cols.Topic1s[i],
+			Topic2:      cols.Topic2s[i],
+			Topic3:      cols.Topic3s[i],
+			LogIndex:    cols.LogIndexes[i],
+			TxHashId:    cols.TxHashIds[i],
+			Extra:       cols.Extras[i],
+		}
+	}
+
+	return logs, nil
+}
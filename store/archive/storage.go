@@ -0,0 +1,163 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/errors"
+)
+
+var (
+	errObjectNotFound = errors.New("object not found")
+)
+
+// Storage object storage to save archive files.
+type Storage interface {
+	// Put saves the object data with the specified key, or overwrites it if already existed.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the object data of the specified key, or `errObjectNotFound` if not existed.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns keys of all the objects with the specified key prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// NewStorage creates an object storage by the configurations.
+func NewStorage(config *Config) (Storage, error) {
+	switch config.Storage {
+	case "local":
+		return newLocalStorage(config.Local.Dir)
+	case "s3":
+		return newS3Storage(&config.S3)
+	default:
+		return nil, errors.Errorf("unknown storage type %v", config.Storage)
+	}
+}
+
+// localStorage object storage on local filesystem.
+type localStorage struct {
+	dir string
+}
+
+func newLocalStorage(dir string) (*localStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithMessage(err, "failed to create storage directory")
+	}
+
+	return &localStorage{dir: dir}, nil
+}
+
+func (s *localStorage) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// write into temp file at first, and then rename it for atomicity
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func (s *localStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, errObjectNotFound
+	}
+
+	return data, err
+}
+
+func (s *localStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	root := filepath.Join(s.dir, filepath.FromSlash(prefix))
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) { // nothing saved yet
+			return nil
+		}
+
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// s3Storage object storage on S3 compatible service.
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+func newS3Storage(config *S3Config) (*s3Storage, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create s3 client")
+	}
+
+	return &s3Storage{client: client, bucket: config.Bucket}, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(
+		ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"},
+	)
+	return err
+}
+
+func (s *s3Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, errObjectNotFound
+	}
+
+	return data, err
+}
+
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true}
+	for obj := range s.client.ListObjects(ctx, s.bucket, opts) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+
+		keys = append(keys, obj.Key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// EpochDigest digest of epoch data, which is used to audit data integrity of the index against
+// that of fullnode.
+type EpochDigest struct {
+	Epoch     uint64
+	PivotHash string
+	NumBlocks int // number of blocks within the epoch
+	NumTxs    int // number of executed transactions within the epoch
+	NumLogs   int // number of event logs within the epoch
+	LogsHash  string
+}
+
+// NewEpochDigest computes the digest of epoch data queried from fullnode.
+func NewEpochDigest(data *EpochData) *EpochDigest {
+	digest := &EpochDigest{
+		Epoch:     data.Number,
+		PivotHash: data.GetPivotBlock().Hash.String(),
+		NumBlocks: len(data.Blocks),
+	}
+
+	var logs []*Log
+	for _, block := range data.Blocks {
+		bn := block.BlockNumber.ToInt().Uint64()
+
+		for _, tx := range block.Transactions {
+			receipt := data.Receipts[tx.Hash]
+
+			// Skip transactions that unexecuted in block.
+			if receipt == nil || !util.IsTxExecutedInBlock(&tx) {
+				continue
+			}
+
+			digest.NumTxs++
+
+			for i := range receipt.Logs {
+				// contract id is not used to compute digest
+				logs = append(logs, ParseCfxLog(&receipt.Logs[i], 0, bn, nil))
+			}
+		}
+	}
+
+	digest.NumLogs, digest.LogsHash = len(logs), DigestLogs(logs)
+
+	return digest
+}
+
+// DigestLogs computes hash of event logs by block number, log index and topics, which are in
+// order of block number and log index.
+func DigestLogs(logs []*Log) string {
+	sorted := make([]*Log, len(logs))
+	copy(sorted, logs)
+	sort.Sort(LogSlice(sorted))
+
+	hasher := sha256.New()
+	for _, log := range sorted {
+		fmt.Fprintf(hasher, "%v:%v:%v:%v:%v:%v;",
+			log.BlockNumber, log.LogIndex, log.Topic0, log.Topic1, log.Topic2, log.Topic3,
+		)
+	}
+
+	return hexutil.Encode(hasher.Sum(nil))
+}
+
+// AuditMismatch mismatched field of epoch digest between the index and fullnode.
+type AuditMismatch struct {
+	// field name, available options are `pivotHash`, `blocks`, `txs` and `logs`
+	Field    string
+	Expected string // value from fullnode
+	Actual   string // value from the index
+}
+
+// Compare compares with the expected epoch digest from fullnode for the specified data types, and
+// returns all the mismatched fields.
+func (digest *EpochDigest) Compare(expected *EpochDigest, dataTypes []EpochDataType) []AuditMismatch {
+	var mismatches []AuditMismatch
+
+	if digest.PivotHash != expected.PivotHash {
+		mismatches = append(mismatches, AuditMismatch{
+			Field: "pivotHash", Expected: expected.PivotHash, Actual: digest.PivotHash,
+		})
+	}
+
+	for _, dt := range dataTypes {
+		switch dt {
+		case EpochBlock:
+			if digest.NumBlocks != expected.NumBlocks {
+				mismatches = append(mismatches, AuditMismatch{
+					Field:    "blocks",
+					Expected: strconv.Itoa(expected.NumBlocks),
+					Actual:   strconv.Itoa(digest.NumBlocks),
+				})
+			}
+		case EpochTransaction:
+			if digest.NumTxs != expected.NumTxs {
+				mismatches = append(mismatches, AuditMismatch{
+					Field:    "txs",
+					Expected: strconv.Itoa(expected.NumTxs),
+					Actual:   strconv.Itoa(digest.NumTxs),
+				})
+			}
+		case EpochLog:
+			if digest.NumLogs != expected.NumLogs || digest.LogsHash != expected.LogsHash {
+				mismatches = append(mismatches, AuditMismatch{
+					Field:    "logs",
+					Expected: fmt.Sprintf("%v/%v", expected.NumLogs, expected.LogsHash),
+					Actual:   fmt.Sprintf("%v/%v", digest.NumLogs, digest.LogsHash),
+				})
+			}
+		}
+	}
+
+	return mismatches
+}
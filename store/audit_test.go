@@ -0,0 +1,45 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestLogsOrderIndependent(t *testing.T) {
+	logs := []*Log{
+		{BlockNumber: 100, LogIndex: 0, Topic0: "0xaa"},
+		{BlockNumber: 100, LogIndex: 1, Topic0: "0xbb", Topic1: "0x01"},
+		{BlockNumber: 101, LogIndex: 0, Topic0: "0xcc"},
+	}
+
+	reversed := []*Log{logs[2], logs[1], logs[0]}
+	assert.Equal(t, DigestLogs(logs), DigestLogs(reversed))
+
+	// order of the original slice is left unchanged
+	assert.Equal(t, uint64(101), reversed[0].BlockNumber)
+
+	tampered := []*Log{logs[0], logs[1], {BlockNumber: 101, LogIndex: 0, Topic0: "0xdd"}}
+	assert.NotEqual(t, DigestLogs(logs), DigestLogs(tampered))
+}
+
+func TestEpochDigestCompare(t *testing.T) {
+	expected := &EpochDigest{
+		Epoch: 1, PivotHash: "0x01", NumBlocks: 2, NumTxs: 3, NumLogs: 4, LogsHash: "0xaa",
+	}
+
+	actual := *expected
+	assert.Empty(t, actual.Compare(expected, OpEpochDataTypes))
+
+	actual.NumTxs, actual.LogsHash = 2, "0xbb"
+	mismatches := actual.Compare(expected, OpEpochDataTypes)
+	assert.Len(t, mismatches, 2)
+	assert.Equal(t, "txs", mismatches[0].Field)
+	assert.Equal(t, "logs", mismatches[1].Field)
+
+	// only the specified data types are compared besides pivot hash
+	actual.PivotHash = "0x02"
+	mismatches = actual.Compare(expected, []EpochDataType{EpochBlock})
+	assert.Len(t, mismatches, 1)
+	assert.Equal(t, "pivotHash", mismatches[0].Field)
+}
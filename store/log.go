@@ -20,6 +20,7 @@ type Log struct {
 	Topic2      string
 	Topic3      string
 	LogIndex    uint64
+	TxHashId    uint64
 	Extra       []byte
 }
 
@@ -68,6 +69,14 @@ func ParseCfxLog(log *types.Log, cid, bn uint64, logExt *LogExtra) *Log {
 		return log.Topics[index].String()
 	}
 
+	convertLogTxHashIdFunc := func(log *types.Log) uint64 {
+		if log.TransactionHash == nil {
+			return 0
+		}
+
+		return util.GetShortIdOfHash(log.TransactionHash.String())
+	}
+
 	return &Log{
 		ContractID:  cid,
 		BlockNumber: bn,
@@ -77,6 +86,7 @@ func ParseCfxLog(log *types.Log, cid, bn uint64, logExt *LogExtra) *Log {
 		Topic2:      convertLogTopicFunc(log, 2),
 		Topic3:      convertLogTopicFunc(log, 3),
 		LogIndex:    log.LogIndex.ToInt().Uint64(),
+		TxHashId:    convertLogTxHashIdFunc(log),
 		Extra: util.MustMarshalJson(logExtraData{
 			Address:             log.Address,
 			BlockHash:           log.BlockHash,
@@ -37,6 +37,7 @@ var ( // Log filter constants
 	MaxLogBlockHashesSize  int
 	MaxLogFilterAddrCount  int
 	MaxLogFilterTopicCount int
+	MaxLogTxHashesSize     int
 
 	MaxLogEpochRange uint64
 	MaxLogBlockRange uint64
@@ -121,6 +122,7 @@ func initLogFilter() {
 		MaxBlockHashCount int `default:"32"`
 		MaxAddressCount   int `default:"32"`
 		MaxTopicCount     int `default:"32"`
+		MaxTxHashCount    int `default:"100"`
 
 		MaxSplitEpochRange uint64 `default:"1000"`
 		MaxSplitBlockRange uint64 `default:"1000"`
@@ -131,6 +133,7 @@ func initLogFilter() {
 	MaxLogBlockHashesSize = lfc.MaxBlockHashCount
 	MaxLogFilterAddrCount = lfc.MaxAddressCount
 	MaxLogFilterTopicCount = lfc.MaxTopicCount
+	MaxLogTxHashesSize = lfc.MaxTxHashCount
 
 	MaxLogEpochRange = lfc.MaxSplitEpochRange
 	MaxLogBlockRange = lfc.MaxSplitBlockRange
@@ -209,3 +212,49 @@ func ParseEthLogFilterRaw(blockFrom, blockTo uint64, filter *web3Types.FilterQue
 		Topics:    vvs,
 	}
 }
+
+// Validate validates the number of contract addresses and topics of the log filter.
+func (f *LogFilter) Validate() error {
+	if f.Contracts.Count() > MaxLogFilterAddrCount {
+		return errors.Errorf(
+			"filter.address can contain up to %v addresses; %v were provided.",
+			MaxLogFilterAddrCount, f.Contracts.Count(),
+		)
+	}
+
+	// event hash and indexed data 1, 2, 3
+	if len(f.Topics) > 4 {
+		return errors.Errorf("filter.topics must be no more than 4-dimensional array; %v were provided.", len(f.Topics))
+	}
+
+	for i := range f.Topics {
+		if f.Topics[i].Count() > MaxLogFilterTopicCount {
+			return errors.Errorf(
+				"filter.topics can contain up to %v topics per dimension; %v were provided.",
+				MaxLogFilterTopicCount, f.Topics[i].Count(),
+			)
+		}
+	}
+
+	return nil
+}
+
+// Match checks if the event log of the specified contract address and topics matches the
+// log filter, regardless of the block range.
+func (f *LogFilter) Match(contract string, topics []string) bool {
+	if !f.Contracts.IsNull() && !f.Contracts.Contains(contract) {
+		return false
+	}
+
+	for i := range f.Topics {
+		if f.Topics[i].IsNull() {
+			continue
+		}
+
+		if len(topics) <= i || !f.Topics[i].Contains(topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
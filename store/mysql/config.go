@@ -4,11 +4,14 @@ import (
 	"fmt"
 	stdLog "log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/Conflux-Chain/confura/store/archive"
 	"github.com/Conflux-Chain/go-conflux-util/dlock"
 	"github.com/Conflux-Chain/go-conflux-util/viper"
 	gosql "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -27,6 +30,16 @@ var allModels = []interface{}{
 	&bnPartition{},
 	&NodeRoute{},
 	&dlock.Dlock{},
+	&topicBloom{},
+	&headBlock{},
+	&headLog{},
+	&auditMismatch{},
+	&WebhookDeadLetter{},
+	&WebhookWindow{},
+	&WebhookOutbox{},
+	&VirtualFilter{},
+	&VirtualFilterMember{},
+	&CdcOutbox{},
 }
 
 // Config represents the mysql configurations to open a database instance.
@@ -47,6 +60,17 @@ type Config struct {
 	AddressIndexedLogEnabled    bool   `default:"true"`
 	AddressIndexedLogPartitions uint32 `default:"100"`
 
+	BigContract BigContractPolicyConfig
+
+	// cold storage to archive pruned event log partitions
+	Archive archive.Config
+
+	// topic bloom index to pre-filter event logs by topics
+	TopicBloom TopicBloomConfig
+
+	// unsafe head tier to index the latest blocks beyond the safe blocks (evm space only)
+	UnsafeHeadEnabled bool
+
 	MaxBnRangedArchiveLogPartitions uint32 `default:"5"`
 }
 
@@ -58,6 +82,18 @@ func mustNewConfigFromViper(key string) *Config {
 		return &Config{}
 	}
 
+	if bcp := cfg.BigContract; bcp.AutoEnabled && bcp.PromoteWindowLogs <= bcp.DemoteWindowLogs {
+		logrus.WithField("bigContract", bcp).Fatal(
+			"Promote window logs must be greater than demote window logs of big contract policy",
+		)
+	}
+
+	if tbc := cfg.TopicBloom; tbc.Enabled && (tbc.BucketSize == 0 || tbc.BloomBits < 8 || tbc.BloomBits&(tbc.BloomBits-1) != 0) {
+		logrus.WithField("topicBloom", tbc).Fatal(
+			"Bucket size must be positive and bloom bits must be power of 2 (at least 8) for topic bloom",
+		)
+	}
+
 	if len(cfg.Dsn) == 0 {
 		return &cfg
 	}
@@ -84,6 +120,12 @@ func MustNewEthStoreConfigFromViper() *Config {
 	return mustNewConfigFromViper("ethstore.mysql")
 }
 
+// MustNewChainStoreConfigFromViper creates an instance of Config for the EVM chain from the specified
+// viper key or panic on error.
+func MustNewChainStoreConfigFromViper(key string) *Config {
+	return mustNewConfigFromViper(key)
+}
+
 // MustOpenOrCreate creates an instance of store or exits on any erorr.
 func (config *Config) MustOpenOrCreate(option StoreOption) *MysqlStore {
 	newCreated := config.mustCreateDatabaseIfAbsent()
@@ -99,6 +141,30 @@ func (config *Config) MustOpenOrCreate(option StoreOption) *MysqlStore {
 		}
 
 		newCreated = (len(tables) == 0)
+
+		// event log tables created before transaction hash id introduced shall be migrated explicitly,
+		// since it may take a long time to alter large tables
+		if unmigrated := unmigratedLogTxHashIdTables(db, tables); len(unmigrated) > 0 {
+			logrus.WithField("tables", unmigrated).Warn(
+				"Event log tables not migrated with tx hash id, please run `confura migrate txhashid` at first",
+			)
+		}
+
+		// create tables introduced afterwards if absent
+		afterwards := []interface{}{
+			&topicBloom{}, &headBlock{}, &headLog{}, &auditMismatch{}, &WebhookDeadLetter{},
+			&VirtualFilter{}, &VirtualFilterMember{}, &CdcOutbox{}, &WebhookWindow{},
+			&WebhookOutbox{},
+		}
+		for _, model := range afterwards {
+			if newCreated || db.Migrator().HasTable(model) {
+				continue
+			}
+
+			if err := db.Migrator().CreateTable(model); err != nil {
+				logrus.WithError(err).Fatalf("Failed to create table for %T", model)
+			}
+		}
 	}
 
 	if newCreated {
@@ -127,6 +193,69 @@ func (config *Config) MustOpenOrCreate(option StoreOption) *MysqlStore {
 	return mustNewStore(db, config, option)
 }
 
+// logTxHashIdModel returns the event log model of the specified table, which is indexed by transaction
+// hash id, or false if not an event log table.
+func logTxHashIdModel(table string) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(table, log{}.TableName()+"_"):
+		return &log{}, true
+	case strings.HasPrefix(table, AddressIndexedLog{}.TableName()+"_"):
+		return &AddressIndexedLog{}, true
+	case strings.HasPrefix(table, "clogs_"):
+		return &contractLog{}, true
+	default:
+		return nil, false
+	}
+}
+
+// unmigratedLogTxHashIdTables returns the event log tables without transaction hash id column or index.
+func unmigratedLogTxHashIdTables(db *gorm.DB, tables []string) (res []string) {
+	for _, table := range tables {
+		model, ok := logTxHashIdModel(table)
+		if !ok {
+			continue
+		}
+
+		migrator := db.Table(table).Migrator()
+		if !migrator.HasColumn(model, "TxHashId") || !migrator.HasIndex(model, "idx_tx_hash_id") {
+			res = append(res, table)
+		}
+	}
+
+	return res
+}
+
+// migrateLogTxHashIdColumn adds the transaction hash id column along with index to the existing
+// event log tables if absent. Note the column of the existing event logs will be left as 0, which
+// are still searchable by the block range of transaction.
+func migrateLogTxHashIdColumn(db *gorm.DB, tables []string) error {
+	for _, table := range tables {
+		model, ok := logTxHashIdModel(table)
+		if !ok {
+			continue
+		}
+
+		migrator := db.Table(table).Migrator()
+		if !migrator.HasColumn(model, "TxHashId") {
+			if err := migrator.AddColumn(model, "TxHashId"); err != nil {
+				return errors.WithMessagef(err, "failed to add column for table %v", table)
+			}
+
+			logrus.WithField("table", table).Info("Event log table migrated with tx hash id column")
+		}
+
+		if !migrator.HasIndex(model, "idx_tx_hash_id") {
+			if err := migrator.CreateIndex(model, "idx_tx_hash_id"); err != nil {
+				return errors.WithMessagef(err, "failed to create index for table %v", table)
+			}
+
+			logrus.WithField("table", table).Info("Event log table migrated with tx hash id index")
+		}
+	}
+
+	return nil
+}
+
 func (config *Config) mustNewDB(database string) *gorm.DB {
 	logrusLogLevel := logrus.GetLevel()
 	gLogLevel := gormLogger.Warn
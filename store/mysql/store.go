@@ -4,10 +4,13 @@ import (
 	"context"
 	"io"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/archive"
 	citypes "github.com/Conflux-Chain/confura/types"
+	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
@@ -15,13 +18,15 @@ import (
 
 var (
 	_ store.Readable      = (*MysqlStore)(nil)
+	_ store.TxLogReadable = (*MysqlStore)(nil)
 	_ store.StackOperable = (*MysqlStore)(nil)
 	_ store.Configurable  = (*MysqlStore)(nil)
 	_ io.Closer           = (*MysqlStore)(nil)
 )
 
 type StoreOption struct {
-	Disabler store.ChainDataDisabler
+	Disabler  store.ChainDataDisabler
+	Retention *store.RetentionConfig
 }
 
 // MysqlStore aggregation store for chain data persistence operation.
@@ -34,16 +39,26 @@ type MysqlStore struct {
 	*UserStore
 	*RateLimitStore
 	*VirtualFilterLogStore
+	*VirtualFilterStore
 	*NodeRouteStore
 	ls   *logStore
 	ails *AddressIndexedLogStore
 	bcls *bigContractLogStore
 	cs   *ContractStore
 
+	// cold storage of pruned event logs
+	archive *archive.Archive
+	// topic bloom index of event logs, nil if disabled
+	tbs *topicBloomStore
+	// unsafe head tier, nil if disabled
+	hs *HeadStore
+
 	// config
 	config *Config
 	// store chaindata disabler
 	disabler store.ChainDataDisabler
+	// data retention policy
+	retention *store.RetentionConfig
 	// store pruner
 	pruner *storePruner
 }
@@ -53,27 +68,65 @@ func mustNewStore(db *gorm.DB, config *Config, option StoreOption) *MysqlStore {
 	cs := NewContractStore(db)
 	ebms := newEpochBlockMapStore(db, config)
 	ails := NewAddressIndexedLogStore(db, cs, config.AddressIndexedLogPartitions)
+	ls := newLogStore(db, cs, ebms, pruner.newBnPartitionObsChan)
+	bcls := newBigContractLogStore(db, cs, ebms, ails, &config.BigContract, pruner.newBnPartitionObsChan)
+
+	// archive event log partitions into cold storage before pruned if enabled
+	var logArchive *archive.Archive
+	if config.Archive.Enabled {
+		if len(config.Archive.Prefix) == 0 {
+			config.Archive.Prefix = config.Database
+		}
+
+		logArchive = archive.MustNew(&config.Archive)
+
+		archiver := newLogPartitionArchiver(db, logArchive)
+		pruner.partitionedStore.archiver = archiver
+		ls.archiver, bcls.archiver = archiver, archiver
+	}
+
+	var tbs *topicBloomStore
+	if config.TopicBloom.Enabled {
+		tbs = newTopicBloomStore(db, &config.TopicBloom)
+	}
+
+	confs := newConfStore(db)
+
+	var hs *HeadStore
+	if config.UnsafeHeadEnabled {
+		hs = newHeadStore(db, cs, confs)
+	}
 
 	return &MysqlStore{
 		baseStore:             newBaseStore(db),
 		epochBlockMapStore:    ebms,
 		txStore:               newTxStore(db),
 		blockStore:            newBlockStore(db),
-		confStore:             newConfStore(db),
+		confStore:             confs,
 		UserStore:             newUserStore(db),
 		RateLimitStore:        NewRateLimitStore(db),
 		VirtualFilterLogStore: NewVirtualFilterLogStore(db),
+		VirtualFilterStore:    NewVirtualFilterStore(db),
 		NodeRouteStore:        NewNodeRouteStore(db),
-		ls:                    newLogStore(db, cs, ebms, pruner.newBnPartitionObsChan),
-		bcls:                  newBigContractLogStore(db, cs, ebms, ails, pruner.newBnPartitionObsChan),
+		ls:                    ls,
+		bcls:                  bcls,
 		ails:                  ails,
 		cs:                    cs,
+		archive:               logArchive,
+		tbs:                   tbs,
+		hs:                    hs,
 		config:                config,
 		disabler:              option.Disabler,
+		retention:             option.Retention,
 		pruner:                pruner,
 	}
 }
 
+// Head returns the unsafe head tier, or nil if disabled.
+func (ms *MysqlStore) Head() *HeadStore {
+	return ms.hs
+}
+
 func (ms *MysqlStore) Push(data *store.EpochData) error {
 	return ms.Pushn([]*store.EpochData{data})
 }
@@ -104,6 +157,10 @@ func (ms *MysqlStore) PushnWithFinalizer(dataSlice []*store.EpochData, finalizer
 	startTime := time.Now()
 	defer metrics.Registry.Store.Push("mysql").UpdateSince(startTime)
 
+	// serialize epoch data writes with the big contract demotion in background
+	ms.bcls.mu.Lock()
+	defer ms.bcls.mu.Unlock()
+
 	// the log partition to write universal event logs
 	var logPartition bnPartition
 	// the log partition to write event logs for specified big contract
@@ -177,6 +234,13 @@ func (ms *MysqlStore) PushnWithFinalizer(dataSlice []*store.EpochData, finalizer
 			if err := ms.ls.Add(dbTx, dataSlice, logPartition); err != nil {
 				return errors.WithMessage(err, "failed to save event logs")
 			}
+
+			// build topic blooms of event logs
+			if ms.tbs != nil {
+				if err := ms.tbs.Add(dbTx, dataSlice); err != nil {
+					return errors.WithMessage(err, "failed to save topic blooms")
+				}
+			}
 		}
 
 		// save epoch to block mapping data
@@ -211,6 +275,10 @@ func (ms *MysqlStore) PopnWithFinalizer(epochUntil uint64, finalizer func(*gorm.
 	startTime := time.Now()
 	defer metrics.Registry.Store.Pop("mysql").UpdateSince(startTime)
 
+	// serialize epoch data writes with the big contract demotion in background
+	ms.bcls.mu.Lock()
+	defer ms.bcls.mu.Unlock()
+
 	return ms.baseStore.db.Transaction(func(dbTx *gorm.DB) error {
 		if !ms.disabler.IsChainBlockDisabled() {
 			// remove blocks
@@ -244,6 +312,22 @@ func (ms *MysqlStore) PopnWithFinalizer(epochUntil uint64, finalizer func(*gorm.
 			if err := ms.ls.Popn(dbTx, epochUntil); err != nil {
 				return errors.WithMessage(err, "failed to remove universal event logs")
 			}
+
+			// shrink indexed block range of topic blooms
+			if ms.tbs != nil {
+				bnRange, ok, err := ms.epochBlockMapStore.BlockRange(epochUntil)
+				if err != nil {
+					return errors.WithMessagef(err, "failed to get block range of epoch %v", epochUntil)
+				}
+
+				if !ok {
+					return errors.Errorf("no block mapping found for epoch %v", epochUntil)
+				}
+
+				if err := ms.tbs.Popn(dbTx, bnRange.From); err != nil {
+					return errors.WithMessage(err, "failed to pop topic blooms")
+				}
+			}
 		}
 
 		// remove epoch to block mapping data
@@ -273,7 +357,7 @@ func (ms *MysqlStore) GetLogs(ctx context.Context, storeFilter store.LogFilter)
 	// if address not specified, query from universal event log table partition
 	// ranged by block number.
 	if len(contracts) == 0 {
-		return ms.ls.GetLogs(ctx, storeFilter)
+		return ms.getUniversalLogs(ctx, storeFilter)
 	}
 
 	filter := LogFilter{
@@ -303,6 +387,12 @@ func (ms *MysqlStore) GetLogs(ctx context.Context, storeFilter store.LogFilter)
 		// if the contract is a big contract, find the event logs from seperate table.
 		if isBigContract {
 			logs, err := ms.bcls.GetContractLogs(ctx, cid, storeFilter)
+			if ms.archive != nil && errors.Is(err, store.ErrAlreadyPruned) {
+				logs, err = ms.getLogsWithArchive(ctx, ms.bcls.contractEntity(cid), storeFilter, func(f store.LogFilter) ([]*store.Log, error) {
+					return ms.bcls.GetContractLogs(ctx, cid, f)
+				})
+			}
+
 			if err != nil {
 				return nil, err
 			}
@@ -352,7 +442,150 @@ func (ms *MysqlStore) GetLogs(ctx context.Context, storeFilter store.LogFilter)
 	return result, nil
 }
 
+// getUniversalLogs gets event logs from the universal event log partitions, with block ranges that
+// cannot match the topics skipped by topic bloom index if enabled.
+func (ms *MysqlStore) getUniversalLogs(ctx context.Context, storeFilter store.LogFilter) ([]*store.Log, error) {
+	ranges := []citypes.RangeUint64{{From: storeFilter.BlockFrom, To: storeFilter.BlockTo}}
+
+	if ms.tbs != nil {
+		var err error
+		if ranges, err = ms.tbs.candidateRanges(storeFilter.BlockFrom, storeFilter.BlockTo, storeFilter.Topics); err != nil {
+			return nil, errors.WithMessage(err, "failed to pre-filter by topic blooms")
+		}
+	}
+
+	var result []*store.Log
+	for _, r := range ranges {
+		filter := storeFilter
+		filter.BlockFrom, filter.BlockTo = r.From, r.To
+
+		logs, err := ms.ls.GetLogs(ctx, filter)
+		if ms.archive != nil && errors.Is(err, store.ErrAlreadyPruned) {
+			logs, err = ms.getLogsWithArchive(ctx, bnPartitionedLogEntity, filter, func(f store.LogFilter) ([]*store.Log, error) {
+				return ms.ls.GetLogs(ctx, f)
+			})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, logs...)
+
+		// check log count
+		if len(result) > int(store.MaxLogLimit) {
+			return nil, store.ErrFilterResultSetTooLarge
+		}
+	}
+
+	return result, nil
+}
+
+// GetLogsByTxHashes returns event logs emitted by the specified transactions from the universal
+// event log partitions, which are indexed by transaction hash id.
+//
+// The search is narrowed down to the block ranges of the transactions if stored, which also covers
+// event logs stored before the transaction hash id introduced. Otherwise, all the partitions will
+// be searched by the transaction hash id.
+func (ms *MysqlStore) GetLogsByTxHashes(ctx context.Context, txHashes []string) ([]*store.Log, error) {
+	if ms.disabler.IsChainLogDisabled() {
+		return nil, store.ErrUnsupported
+	}
+
+	startTime := time.Now()
+	defer metrics.Registry.Store.GetLogsByTxHashes().UpdateSince(startTime)
+
+	hashSet := make(map[string]bool, len(txHashes))
+	hashes := make([]string, 0, len(txHashes))
+
+	for _, hash := range txHashes {
+		hash = strings.ToLower(hash)
+		if hashSet[hash] { // dedupe
+			continue
+		}
+
+		hashSet[hash] = true
+		hashes = append(hashes, hash)
+	}
+
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	var bnRanges []citypes.RangeUint64
+	var rangedHashIds, unknownHashIds []uint64
+
+	epochs := make(map[string]uint64)
+	if !ms.disabler.IsChainTxnDisabled() {
+		var err error
+		if epochs, err = ms.txStore.loadTxEpochs(hashes); err != nil {
+			return nil, errors.WithMessage(err, "failed to load transaction epochs")
+		}
+	}
+
+	for _, hash := range hashes {
+		epoch, ok := epochs[hash]
+		if !ok {
+			unknownHashIds = append(unknownHashIds, util.GetShortIdOfHash(hash))
+			continue
+		}
+
+		bnRange, ok, err := ms.epochBlockMapStore.BlockRange(epoch)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get block range of epoch")
+		}
+
+		if ok {
+			bnRanges = append(bnRanges, bnRange)
+			rangedHashIds = append(rangedHashIds, util.GetShortIdOfHash(hash))
+		} else {
+			unknownHashIds = append(unknownHashIds, util.GetShortIdOfHash(hash))
+		}
+	}
+
+	// filter out event logs of other transactions due to hash id collision, or event logs stored
+	// without hash id within the block ranges
+	match := func(log *store.Log) bool {
+		cfxLog, _ := log.ToCfxLog()
+		return cfxLog.TransactionHash != nil && hashSet[strings.ToLower(cfxLog.TransactionHash.String())]
+	}
+
+	result, err := ms.ls.GetLogsByTxHashIds(ctx, bnRanges, rangedHashIds, unknownHashIds, match)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(store.LogSlice(result))
+
+	return result, nil
+}
+
+// MigrateLogTxHashId migrates the event log tables created before transaction hash id introduced,
+// by adding the transaction hash id column along with index if absent.
+func (ms *MysqlStore) MigrateLogTxHashId() error {
+	var tables []string
+	if err := ms.baseStore.db.Raw("SHOW TABLES").Scan(&tables).Error; err != nil {
+		return errors.WithMessage(err, "failed to query database tables")
+	}
+
+	return migrateLogTxHashIdColumn(ms.baseStore.db, tables)
+}
+
+// EvaluateBigContractPolicy evaluates the big contract policy for a dry run report, without any
+// contract to be promoted or demoted actually.
+func (ms *MysqlStore) EvaluateBigContractPolicy() (*BigContractPolicyReport, error) {
+	return ms.bcls.policy.evaluate()
+}
+
+// ScheduleBigContractPolicy periodically evaluates the big contract policy to promote or demote
+// contracts automatically if enabled.
+func (ms *MysqlStore) ScheduleBigContractPolicy() {
+	if ms.config.AddressIndexedLogEnabled && ms.config.BigContract.AutoEnabled {
+		go ms.bcls.policy.schedule()
+	}
+}
+
 // Prune prune data from db store.
 func (ms *MysqlStore) Prune() {
-	go ms.pruner.schedulePrune(ms.config)
+	go ms.pruner.schedulePrune(ms.config, ms.retentionOverridden, ms.onPartitionsPruned)
 }
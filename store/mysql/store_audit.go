@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// auditMismatch mismatched epoch data between the index and fullnode detected by auditor.
+type auditMismatch struct {
+	ID       uint64
+	Epoch    uint64 `gorm:"not null;index"`
+	Field    string `gorm:"size:32;not null"`
+	Expected string `gorm:"size:128;not null"`
+	Actual   string `gorm:"size:128;not null"`
+	// whether the epoch data has been re-synchronized
+	Resynced  bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+}
+
+func (auditMismatch) TableName() string {
+	return "audit_mismatches"
+}
+
+// GetEpochDigest returns the digest of epoch data in db store for the specified data types, or false
+// if the epoch not synchronized yet.
+func (ms *MysqlStore) GetEpochDigest(epoch uint64, dataTypes []store.EpochDataType) (*store.EpochDigest, bool, error) {
+	pivotHash, ok, err := ms.PivotHash(epoch)
+	if err != nil || !ok {
+		return nil, false, errors.WithMessage(err, "failed to get pivot hash")
+	}
+
+	digest := &store.EpochDigest{Epoch: epoch, PivotHash: pivotHash}
+
+	for _, dt := range dataTypes {
+		var count int64
+
+		switch dt {
+		case store.EpochBlock:
+			err = ms.baseStore.db.Model(&block{}).Where("epoch = ?", epoch).Count(&count).Error
+			digest.NumBlocks = int(count)
+		case store.EpochTransaction:
+			err = ms.baseStore.db.Model(&transaction{}).Where("epoch = ?", epoch).Count(&count).Error
+			digest.NumTxs = int(count)
+		case store.EpochLog:
+			var logs []*store.Log
+			if logs, err = ms.getEpochLogs(epoch); err == nil {
+				digest.NumLogs, digest.LogsHash = len(logs), store.DigestLogs(logs)
+			}
+		}
+
+		if err != nil {
+			return nil, false, errors.WithMessagef(err, "failed to digest epoch %v", dt.Name())
+		}
+	}
+
+	return digest, true, nil
+}
+
+// getEpochLogs returns all the universal event logs of the specified epoch without limit.
+func (ms *MysqlStore) getEpochLogs(epoch uint64) ([]*store.Log, error) {
+	bnRange, ok, err := ms.BlockRange(epoch)
+	if err != nil || !ok {
+		return nil, errors.WithMessage(err, "failed to get block range")
+	}
+
+	partitions, err := ms.ls.searchOverlapPartitions(bnPartitionedLogEntity, bnRange)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to search partitions")
+	}
+
+	var result []*store.Log
+	for _, partition := range partitions {
+		var logs []*log
+
+		tblName := ms.ls.getPartitionedTableName(&ms.ls.model, partition.Index)
+		err := ms.baseStore.db.Table(tblName).Where("bn BETWEEN ? AND ?", bnRange.From, bnRange.To).Find(&logs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range logs {
+			result = append(result, (*store.Log)(v))
+		}
+	}
+
+	return result, nil
+}
+
+// AddAuditMismatches saves the mismatched fields of epoch data detected by auditor.
+func (ms *MysqlStore) AddAuditMismatches(epoch uint64, mismatches []store.AuditMismatch) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	rows := make([]*auditMismatch, 0, len(mismatches))
+	for _, m := range mismatches {
+		rows = append(rows, &auditMismatch{
+			Epoch: epoch, Field: m.Field, Expected: m.Expected, Actual: m.Actual,
+		})
+	}
+
+	return ms.baseStore.db.Create(rows).Error
+}
+
+// MarkAuditMismatchesResynced marks the mismatches of the specified epoch as re-synchronized.
+func (ms *MysqlStore) MarkAuditMismatchesResynced(dbTx *gorm.DB, epoch uint64) error {
+	return dbTx.Model(&auditMismatch{}).
+		Where("epoch = ? AND resynced = ?", epoch, false).
+		Update("resynced", true).
+		Error
+}
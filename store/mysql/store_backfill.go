@@ -0,0 +1,327 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/types"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// BackfillWithFinalizer re-indexes chain data of the specified data types for the continuous epoch
+// data slice, by replacing the existing rows of the epoch range idempotently. Epoch data must have
+// already been synchronized into db store, which is used to re-index historic data only, and any pivot
+// switched epoch results in `store.ErrEpochPivotSwitched` error.
+func (ms *MysqlStore) BackfillWithFinalizer(
+	dataSlice []*store.EpochData, dataTypes []store.EpochDataType, finalizer func(*gorm.DB) error,
+) error {
+	if len(dataSlice) == 0 {
+		return nil
+	}
+
+	epochFrom, epochTo := dataSlice[0].Number, dataSlice[len(dataSlice)-1].Number
+
+	// serialize epoch data writes with the big contract demotion in background
+	ms.bcls.mu.Lock()
+	defer ms.bcls.mu.Unlock()
+
+	return ms.baseStore.db.Transaction(func(dbTx *gorm.DB) error {
+		// ensure epoch data is consistent with that of db store, and not pivot switched by the live
+		// syncer (in-process or not) until transaction committed
+		if err := ms.epochBlockMapStore.CheckPivotHashesForUpdate(dbTx, dataSlice); err != nil {
+			return err
+		}
+
+		for _, dt := range dataTypes {
+			if ms.disabler.IsDisabledForType(dt) {
+				continue
+			}
+
+			var err error
+			switch dt {
+			case store.EpochBlock:
+				if err = ms.blockStore.Remove(dbTx, epochFrom, epochTo); err == nil {
+					err = ms.blockStore.Add(dbTx, dataSlice)
+				}
+			case store.EpochTransaction:
+				skipTxn, skipRcpt := ms.disabler.IsChainTxnDisabled(), ms.disabler.IsChainReceiptDisabled()
+				if err = ms.txStore.Remove(dbTx, epochFrom, epochTo); err == nil {
+					err = ms.txStore.Add(dbTx, dataSlice, skipTxn, skipRcpt)
+				}
+			case store.EpochLog:
+				err = ms.backfillLogs(dbTx, dataSlice)
+			default:
+				err = store.ErrUnsupported
+			}
+
+			if err != nil {
+				return errors.WithMessagef(err, "failed to backfill %v", dt.Name())
+			}
+		}
+
+		if finalizer != nil {
+			return finalizer(dbTx)
+		}
+
+		return nil
+	})
+}
+
+// backfillLogs replaces event logs of universal, big contract and address indexed log tables.
+func (ms *MysqlStore) backfillLogs(dbTx *gorm.DB, dataSlice []*store.EpochData) error {
+	bnRange := types.RangeUint64{
+		From: dataSlice[0].Blocks[0].BlockNumber.ToInt().Uint64(),
+		To:   dataSlice[len(dataSlice)-1].GetPivotBlock().BlockNumber.ToInt().Uint64(),
+	}
+
+	logs, err := parseEpochLogs(ms.cs, dataSlice)
+	if err != nil {
+		return err
+	}
+
+	if ms.config.AddressIndexedLogEnabled {
+		// replaced event logs of the big contract being demoted should be merged back again
+		ms.bcls.rewindDemotion(bnRange.From)
+
+		contracts, err := ms.bcls.bigContracts()
+		if err != nil {
+			return errors.WithMessage(err, "failed to get big contracts")
+		}
+
+		bigContractIds := make(map[uint64]bool, len(contracts))
+		for _, c := range contracts {
+			bigContractIds[c.ID] = true
+		}
+
+		contract2Logs := make(map[uint64][]*store.Log)
+		for _, log := range logs {
+			if bigContractIds[log.ContractID] {
+				contract2Logs[log.ContractID] = append(contract2Logs[log.ContractID], log)
+			}
+		}
+
+		contract2Delta := make(map[uint64]int, len(bigContractIds))
+		for cid := range bigContractIds {
+			delta, err := ms.bcls.replaceBnRangeRows(
+				dbTx, ms.bcls.contractEntity(cid), ms.bcls.contractTabler(cid), bnRange, contract2Logs[cid],
+				func(logs []*store.Log) interface{} {
+					rows := make([]*contractLog, 0, len(logs))
+					for _, v := range logs {
+						rows = append(rows, (*contractLog)(v))
+					}
+					return rows
+				},
+			)
+			if err != nil {
+				return errors.WithMessagef(err, "failed to replace event logs of big contract %v", cid)
+			}
+
+			contract2Delta[cid] = delta
+		}
+
+		epochTo := dataSlice[len(dataSlice)-1].Number
+		if err := ms.cs.updateBackfilledContractStats(dbTx, contract2Delta, epochTo); err != nil {
+			return errors.WithMessage(err, "failed to update big contract stats")
+		}
+
+		if err := ms.ails.replaceAddressIndexedLogs(dbTx, dataSlice, bigContractIds); err != nil {
+			return errors.WithMessage(err, "failed to replace address indexed event logs")
+		}
+	}
+
+	_, err = ms.ls.replaceBnRangeRows(
+		dbTx, bnPartitionedLogEntity, &ms.ls.model, bnRange, logs,
+		func(logs []*store.Log) interface{} {
+			rows := make([]*log, 0, len(logs))
+			for _, v := range logs {
+				rows = append(rows, (*log)(v))
+			}
+			return rows
+		},
+	)
+	if err != nil {
+		return errors.WithMessage(err, "failed to replace universal event logs")
+	}
+
+	// topic blooms are merged, so it's safe to add again
+	if ms.tbs != nil {
+		if err := ms.tbs.Add(dbTx, dataSlice); err != nil {
+			return errors.WithMessage(err, "failed to save topic blooms")
+		}
+	}
+
+	return nil
+}
+
+// replaceBnRangeRows replaces entity rows of the block range with the specified event logs, which
+// must be covered by the entity partitions, and returns the delta number of rows.
+func (bnps *bnPartitionedStore) replaceBnRangeRows(
+	dbTx *gorm.DB,
+	entity string,
+	tabler schema.Tabler,
+	bnRange types.RangeUint64,
+	logs []*store.Log,
+	toRows func([]*store.Log) interface{},
+) (int, error) {
+	partitions, err := bnps.searchOverlapPartitions(entity, bnRange)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to search partitions")
+	}
+
+	partition2Logs := make(map[uint32][]*store.Log, len(partitions))
+	for _, log := range logs {
+		var found bool
+		for _, p := range partitions {
+			if p.BnMin.Valid && p.BnMax.Valid &&
+				uint64(p.BnMin.Int64) <= log.BlockNumber && log.BlockNumber <= uint64(p.BnMax.Int64) {
+				partition2Logs[p.Index] = append(partition2Logs[p.Index], log)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, errors.Errorf(
+				"block %v not covered by %v partitions (pruned or not synchronized yet)", log.BlockNumber, entity,
+			)
+		}
+	}
+
+	totalDelta := 0
+	for _, p := range partitions {
+		tblName := bnps.getPartitionedTableName(tabler, p.Index)
+
+		sql := fmt.Sprintf("DELETE FROM %v WHERE bn BETWEEN ? AND ?", tblName)
+		res := dbTx.Exec(sql, bnRange.From, bnRange.To)
+		if res.Error != nil {
+			return 0, res.Error
+		}
+
+		delta := -int(res.RowsAffected)
+		if plogs := partition2Logs[p.Index]; len(plogs) > 0 {
+			if err := dbTx.Table(tblName).CreateInBatches(toRows(plogs), defaultBatchSizeLogInsert).Error; err != nil {
+				return 0, err
+			}
+
+			delta += len(plogs)
+		}
+
+		// update partition data count
+		err := dbTx.Model(&bnPartition{}).
+			Where("id = ?", p.ID).
+			UpdateColumn("count", gorm.Expr("GREATEST(0, CAST(count AS SIGNED) + ?)", delta)).
+			Error
+		if err != nil {
+			return 0, errors.WithMessage(err, "failed to update partition size")
+		}
+
+		totalDelta += delta
+	}
+
+	return totalDelta, nil
+}
+
+// replaceAddressIndexedLogs replaces address indexed event logs (with that of big contract ignored) of
+// the epoch range.
+func (ls *AddressIndexedLogStore) replaceAddressIndexedLogs(
+	dbTx *gorm.DB, dataSlice []*store.EpochData, bigContractIds map[uint64]bool,
+) error {
+	epochFrom, epochTo := dataSlice[0].Number, dataSlice[len(dataSlice)-1].Number
+
+	var excludedCids []uint64
+	for cid := range bigContractIds {
+		excludedCids = append(excludedCids, cid)
+	}
+
+	// delete by epoch range per partition, and count the deleted event logs of each contract ahead
+	// to update contract statistics.
+	contract2Delta := make(map[uint64]int)
+	for i := uint32(0); i < ls.partitions; i++ {
+		tableName := ls.getPartitionedTableName(&ls.model, i)
+
+		var counts []struct {
+			Cid   uint64
+			Total int
+		}
+
+		db := dbTx.Table(tableName).Where("epoch BETWEEN ? AND ?", epochFrom, epochTo)
+		if len(excludedCids) > 0 {
+			db = db.Where("cid NOT IN (?)", excludedCids)
+		}
+
+		if err := db.Session(&gorm.Session{}).
+			Select("cid, COUNT(*) AS total").
+			Group("cid").
+			Scan(&counts).Error; err != nil {
+			return errors.WithMessage(err, "failed to count address indexed logs")
+		}
+
+		if len(counts) == 0 {
+			continue
+		}
+
+		if err := db.Delete(&AddressIndexedLog{}).Error; err != nil {
+			return err
+		}
+
+		for _, c := range counts {
+			contract2Delta[c.Cid] -= c.Total
+		}
+	}
+
+	for _, data := range dataSlice {
+		partition2Logs, contract2LogCount, err := ls.convertToPartitionedLogs(data, bigContractIds)
+		if err != nil {
+			return err
+		}
+
+		for partition, logs := range partition2Logs {
+			tableName := ls.getPartitionedTableName(&ls.model, partition)
+			if err := dbTx.Table(tableName).CreateInBatches(&logs, defaultBatchSizeLogInsert).Error; err != nil {
+				return err
+			}
+		}
+
+		for cid, count := range contract2LogCount {
+			contract2Delta[cid] += count
+		}
+	}
+
+	if err := ls.cs.updateBackfilledContractStats(dbTx, contract2Delta, epochTo); err != nil {
+		return errors.WithMessage(err, "failed to update contract stats")
+	}
+
+	return nil
+}
+
+// updateBackfilledContractStats updates statistics of contracts whose event logs are backfilled until
+// the specified epoch. Note the latest updated epoch never goes backward for historic data, so that
+// contracts could still be found to pop event logs on chain reorg.
+func (cs *ContractStore) updateBackfilledContractStats(
+	dbTx *gorm.DB, contract2Delta map[uint64]int, epochTo uint64,
+) error {
+	if len(contract2Delta) == 0 {
+		return nil
+	}
+
+	cids := make([]uint64, 0, len(contract2Delta))
+	for cid := range contract2Delta {
+		cids = append(cids, cid)
+	}
+
+	var contracts []*Contract
+	if err := dbTx.Select("id", "latest_updated_epoch").Where("id IN ?", cids).Find(&contracts).Error; err != nil {
+		return errors.WithMessage(err, "failed to get contracts")
+	}
+
+	for _, c := range contracts {
+		latestUpdatedEpoch := max(c.LatestUpdatedEpoch, epochTo)
+		if err := cs.UpdateContractStats(dbTx, c.ID, contract2Delta[c.ID], latestUpdatedEpoch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBackfilledContractStats(t *testing.T) {
+	db := newTestDB(t, &Contract{})
+	cs := &ContractStore{baseStore: newBaseStore(db)}
+
+	require.NoError(t, db.Create([]*Contract{
+		{ID: 1, Address: "cfx:1", LogCount: 10, LatestUpdatedEpoch: 100},
+		{ID: 2, Address: "cfx:2", LogCount: 5, LatestUpdatedEpoch: 20},
+	}).Error)
+
+	require.NoError(t, cs.updateBackfilledContractStats(db, map[uint64]int{1: 3, 2: 2}, 50))
+
+	var contracts []*Contract
+	require.NoError(t, db.Order("id").Find(&contracts).Error)
+
+	// latest updated epoch never goes backward for historic data
+	assert.Equal(t, 13, int(contracts[0].LogCount))
+	assert.Equal(t, uint64(100), contracts[0].LatestUpdatedEpoch)
+
+	assert.Equal(t, 7, int(contracts[1].LogCount))
+	assert.Equal(t, uint64(50), contracts[1].LatestUpdatedEpoch)
+}
@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CdcOutbox change data events staged within the db transaction to store (or revert) epoch data,
+// which are published into sink only after the transaction committed.
+type CdcOutbox struct {
+	ID        uint64
+	Space     string `gorm:"size:32;not null;index"`
+	Epoch     uint64 `gorm:"not null"`
+	Events    string `gorm:"type:longText;not null"` // json encoded events in order
+	CreatedAt time.Time
+}
+
+func (CdcOutbox) TableName() string {
+	return "cdc_outboxes"
+}
+
+// AddCdcOutboxWithTx stages change data events within the specified db transaction.
+func (ms *MysqlStore) AddCdcOutboxWithTx(dbTx *gorm.DB, outbox *CdcOutbox) error {
+	return dbTx.Create(outbox).Error
+}
+
+// GetCdcOutboxes returns the earliest staged change data events of the specified space in order.
+func (ms *MysqlStore) GetCdcOutboxes(space string, limit int) (res []*CdcOutbox, err error) {
+	err = ms.baseStore.db.Where("space = ?", space).Order("id ASC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// DelCdcOutboxes removes the staged change data events of the specified space until the id
+// (inclusive) once published.
+func (ms *MysqlStore) DelCdcOutboxes(space string, idTo uint64) error {
+	return ms.baseStore.db.Where("space = ? AND id <= ?", space, idTo).Delete(&CdcOutbox{}).Error
+}
@@ -44,3 +44,25 @@ func (bs *baseStore) exists(modelPtr interface{}, whereQuery string, args ...int
 
 	return false, err
 }
+
+// deleteInBatches deletes the records matched by the query in batches, so as to avoid long running
+// transaction and lock contention due to too many records deleted at a time.
+func deleteInBatches(query *gorm.DB, modelPtr interface{}, batchSize int) (int64, error) {
+	var total int64
+
+	// new session to reuse the query conditions for each batch
+	query = query.Session(&gorm.Session{})
+
+	for {
+		res := query.Limit(batchSize).Delete(modelPtr)
+		if res.Error != nil {
+			return total, res.Error
+		}
+
+		total += res.RowsAffected
+
+		if res.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -50,6 +50,9 @@ func (partition bnPartition) IsInitial() bool {
 type bnPartitionedStore struct {
 	*baseStore
 	partitionedStore
+
+	// optional archiver to archive partition data before pruned
+	archiver partitionArchiver
 }
 
 func newBnPartitionedStore(db *gorm.DB) *bnPartitionedStore {
@@ -218,6 +221,17 @@ func (bnps *bnPartitionedStore) searchOverlapPartitions(entity string, searchRan
 	return partitions, err
 }
 
+// dataPartitions returns all the entity partitions which hold entity data in ascending order of partition index.
+func (bnps *bnPartitionedStore) dataPartitions(entity string) ([]*bnPartition, error) {
+	db := bnps.db.Where("entity = ?", entity).
+		Where("bn_min IS NOT NULL AND bn_max IS NOT NULL").
+		Order("pi asc")
+
+	var partitions []*bnPartition
+	err := db.Find(&partitions).Error
+	return partitions, err
+}
+
 func errBnPartitionsPruned(srange, bnPartRange types.RangeUint64) error {
 	return errors.WithMessagef(store.ErrAlreadyPruned,
 		"range %v not contained in the inclusion range %v formed by all bnPartitions",
@@ -425,6 +439,15 @@ func (bnps *bnPartitionedStore) entityRange(selector string, entity string) (
 	return
 }
 
+// entitiesByPrefix returns all the partitioned entities with the specified name prefix.
+func (bnps *bnPartitionedStore) entitiesByPrefix(prefix string) ([]string, error) {
+	var entities []string
+
+	db := bnps.db.Model(&bnPartition{}).Distinct("entity").Where("entity LIKE ?", prefix+"%")
+	err := db.Pluck("entity", &entities).Error
+	return entities, err
+}
+
 // deleteEntityPartitions deletes all entity partitions
 func (bnps *bnPartitionedStore) deleteEntityPartitions(entity string, tabler schema.Tabler) ([]*bnPartition, error) {
 	var partitions []*bnPartition
@@ -479,6 +502,10 @@ func (bnps *bnPartitionedStore) pruneArchivePartitions(
 			break
 		}
 
+		if err := bnps.archivePartition(entity, tabler, i); err != nil {
+			return prunedPartitions, errors.WithMessagef(err, "failed to archive partition %d", i)
+		}
+
 		partition, err := bnps.shrinkPartition(entity, tabler, int(i))
 		if err != nil {
 			return prunedPartitions, errors.WithMessagef(err, "failed to shrink partition %d", i)
@@ -489,3 +516,66 @@ func (bnps *bnPartitionedStore) pruneArchivePartitions(
 
 	return prunedPartitions, nil
 }
+
+// pruneArchivePartitionsUntil iteratively prunes archive partitions chronologically from the
+// oldest partition, of which the max block number is no more than the specified block number.
+//
+// Note the latest partition will never be pruned, and the iterative prune operations are not atomic.
+func (bnps *bnPartitionedStore) pruneArchivePartitionsUntil(
+	entity string, tabler schema.Tabler, bnUntil uint64,
+) ([]*bnPartition, error) {
+	var prunedPartitions []*bnPartition
+
+	_, endPartIdx, existed, err := bnps.indexRange(entity)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get partition index range")
+	}
+
+	if !existed { // no partitions found
+		return nil, nil
+	}
+
+	for {
+		partition, existed, err := bnps.oldestPartition(entity)
+		if err != nil {
+			return prunedPartitions, errors.WithMessage(err, "failed to get oldest partition")
+		}
+
+		if !existed || partition.Index >= endPartIdx { // no archive partition to prune
+			return prunedPartitions, nil
+		}
+
+		if partition.BnMax.Valid && uint64(partition.BnMax.Int64) > bnUntil {
+			return prunedPartitions, nil
+		}
+
+		if err := bnps.archivePartition(entity, tabler, partition.Index); err != nil {
+			return prunedPartitions, errors.WithMessagef(err, "failed to archive partition %d", partition.Index)
+		}
+
+		pruned, err := bnps.shrinkPartition(entity, tabler, int(partition.Index))
+		if err != nil {
+			return prunedPartitions, errors.WithMessagef(err, "failed to shrink partition %d", partition.Index)
+		}
+
+		prunedPartitions = append(prunedPartitions, pruned)
+	}
+}
+
+// archivePartition archives data of the entity partition with the specified index if archiver provided.
+func (bnps *bnPartitionedStore) archivePartition(entity string, tabler schema.Tabler, partitionIndex uint32) error {
+	if bnps.archiver == nil {
+		return nil
+	}
+
+	partition, err := bnps.getPartitionByIndex(entity, partitionIndex)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get partition")
+	}
+
+	if !partition.BnMin.Valid || !partition.BnMax.Valid { // no data
+		return nil
+	}
+
+	return bnps.archiver.archivePartition(entity, tabler, partition)
+}
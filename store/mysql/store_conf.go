@@ -17,6 +17,9 @@ import (
 const (
 	MysqlConfKeyReorgVersion = "reorg.version"
 
+	// finalized epoch of the data stored, which is recorded by syncer
+	MysqlConfKeyFinalizedEpoch = "sync.finalized"
+
 	// pre-defined ratelimit strategy config key prefix
 	RateLimitStrategyConfKeyPrefix   = "ratelimit.strategy."
 	rateLimitStrategySqlMatchPattern = RateLimitStrategyConfKeyPrefix + "%"
@@ -69,7 +72,12 @@ func (cs *confStore) LoadConfig(confNames ...string) (map[string]interface{}, er
 }
 
 func (cs *confStore) StoreConfig(confName string, confVal interface{}) error {
-	return cs.db.Clauses(clause.OnConflict{
+	return cs.StoreConfigWithTx(cs.db, confName, confVal)
+}
+
+// StoreConfigWithTx stores config within the specified db transaction.
+func (cs *confStore) StoreConfigWithTx(dbTx *gorm.DB, confName string, confVal interface{}) error {
+	return dbTx.Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "name"}},
 		DoUpdates: clause.Assignments(map[string]interface{}{
 			"value":      confVal,
@@ -114,6 +122,24 @@ func (cs *confStore) createOrUpdateReorgVersion(dbTx *gorm.DB) error {
 	return cs.StoreConfig(MysqlConfKeyReorgVersion, newVersion)
 }
 
+// finality config
+
+// GetFinalizedEpoch returns the finalized epoch of the stored data, or false if never recorded.
+func (cs *confStore) GetFinalizedEpoch() (uint64, bool, error) {
+	var result conf
+	exists, err := cs.exists(&result, "name = ?", MysqlConfKeyFinalizedEpoch)
+	if err != nil || !exists {
+		return 0, false, err
+	}
+
+	epoch, err := strconv.ParseUint(result.Value, 10, 64)
+	return epoch, err == nil, err
+}
+
+func (cs *confStore) StoreFinalizedEpoch(epoch uint64) error {
+	return cs.StoreConfig(MysqlConfKeyFinalizedEpoch, strconv.FormatUint(epoch, 10))
+}
+
 // access control config
 func (cs *confStore) LoadAclAllowList(name string) (*acl.AllowList, error) {
 	var cfg conf
@@ -172,6 +172,16 @@ func (cs *ContractStore) GetUpdatedContractsSinceEpoch(epoch uint64) ([]*Contrac
 	return contracts, nil
 }
 
+// GetContractsByMinLogCount gets all contracts that have no less than the specified number of event logs.
+func (cs *ContractStore) GetContractsByMinLogCount(minLogCount int) ([]*Contract, error) {
+	var contracts []*Contract
+	if err := cs.db.Where("log_count >= ?", minLogCount).Find(&contracts).Error; err != nil {
+		return nil, err
+	}
+
+	return contracts, nil
+}
+
 // UpdateContractStats updates statistics (log count/latest updated epoch) of the specified contract.
 func (cs *ContractStore) UpdateContractStats(
 	dbTx *gorm.DB, cid uint64, countDelta int, latestUpdatedEpoch uint64,
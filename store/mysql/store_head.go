@@ -0,0 +1,222 @@
+package mysql
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// headBlock block indexed by the unsafe head tier, which is beyond the synchronized (safe) blocks
+// and might be reverted due to chain reorg.
+type headBlock struct {
+	BlockNumber uint64 `gorm:"column:bn;primaryKey;autoIncrement:false"`
+	Hash        string `gorm:"size:66;not null"`
+	ParentHash  string `gorm:"size:66;not null"`
+}
+
+func (headBlock) TableName() string {
+	return "head_blocks"
+}
+
+// headLog event log indexed by the unsafe head tier, which has the same layout as `log`.
+type headLog struct {
+	ID          uint64
+	ContractID  uint64 `gorm:"column:cid;size:64;not null;index:idx_cid_bn,priority:1"`
+	BlockNumber uint64 `gorm:"column:bn;not null;index:idx_bn;index:idx_cid_bn,priority:2"`
+	Epoch       uint64 `gorm:"not null"`
+	Topic0      string `gorm:"size:66;not null"`
+	Topic1      string `gorm:"size:66"`
+	Topic2      string `gorm:"size:66"`
+	Topic3      string `gorm:"size:66"`
+	LogIndex    uint64 `gorm:"not null"`
+	TxHashId    uint64 `gorm:"column:tx_hash_id;not null;default:0"`
+	Extra       []byte `gorm:"type:mediumText"` // extension json field
+}
+
+func (headLog) TableName() string {
+	return "head_logs"
+}
+
+// HeadStore unsafe head tier to index the latest blocks near the chain head, which are not safe yet
+// and removed once synchronized as safe blocks.
+type HeadStore struct {
+	db *gorm.DB
+	cs *ContractStore
+	// used to update reorg version when head blocks reverted
+	confs *confStore
+}
+
+func newHeadStore(db *gorm.DB, cs *ContractStore, confs *confStore) *HeadStore {
+	return &HeadStore{db: db, cs: cs, confs: confs}
+}
+
+// BlockRange returns the block range of the unsafe head tier.
+func (hs *HeadStore) BlockRange() (uint64, uint64, bool, error) {
+	var result struct {
+		MinBn *uint64
+		MaxBn *uint64
+	}
+
+	err := hs.db.Model(&headBlock{}).Select("MIN(bn) AS min_bn, MAX(bn) AS max_bn").Scan(&result).Error
+	if err != nil || result.MinBn == nil || result.MaxBn == nil {
+		return 0, 0, false, err
+	}
+
+	return *result.MinBn, *result.MaxBn, true, nil
+}
+
+// Block returns the block hash and parent hash of the specified block within the unsafe head tier.
+func (hs *HeadStore) Block(bn uint64) (hash, parentHash string, ok bool, err error) {
+	var block headBlock
+
+	err = hs.db.Where("bn = ?", bn).Take(&block).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", false, nil
+	}
+
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return block.Hash, block.ParentHash, true, nil
+}
+
+// PushnWithFinalizer saves the latest blocks and event logs into the unsafe head tier, with an extra
+// finalizer to commit or rollback the transaction.
+func (hs *HeadStore) PushnWithFinalizer(dataSlice []*store.EpochData, finalizer func(*gorm.DB) error) error {
+	if len(dataSlice) == 0 {
+		return nil
+	}
+
+	return hs.db.Transaction(func(dbTx *gorm.DB) error {
+		blocks := make([]*headBlock, 0, len(dataSlice))
+		for _, data := range dataSlice {
+			pivot := data.GetPivotBlock()
+			blocks = append(blocks, &headBlock{
+				BlockNumber: pivot.BlockNumber.ToInt().Uint64(),
+				Hash:        pivot.Hash.String(),
+				ParentHash:  pivot.ParentHash.String(),
+			})
+		}
+
+		if err := dbTx.Create(blocks).Error; err != nil {
+			return errors.WithMessage(err, "failed to save head blocks")
+		}
+
+		logs, err := parseEpochLogs(hs.cs, dataSlice)
+		if err != nil {
+			return errors.WithMessage(err, "failed to parse event logs")
+		}
+
+		if len(logs) > 0 {
+			headLogs := make([]*headLog, 0, len(logs))
+			for _, v := range logs {
+				headLogs = append(headLogs, (*headLog)(v))
+			}
+
+			if err := dbTx.CreateInBatches(headLogs, defaultBatchSizeLogInsert).Error; err != nil {
+				return errors.WithMessage(err, "failed to save head event logs")
+			}
+		}
+
+		if finalizer != nil {
+			return finalizer(dbTx)
+		}
+
+		return nil
+	})
+}
+
+// PopnWithFinalizer reverts blocks and event logs from the specified block number due to chain reorg,
+// with an extra finalizer to commit or rollback the transaction.
+func (hs *HeadStore) PopnWithFinalizer(bnFrom uint64, finalizer func(*gorm.DB) error) error {
+	return hs.db.Transaction(func(dbTx *gorm.DB) error {
+		res := dbTx.Where("bn >= ?", bnFrom).Delete(&headBlock{})
+		if res.Error != nil {
+			return errors.WithMessage(res.Error, "failed to remove head blocks")
+		}
+
+		if res.RowsAffected == 0 { // nothing reverted
+			return nil
+		}
+
+		if err := dbTx.Where("bn >= ?", bnFrom).Delete(&headLog{}).Error; err != nil {
+			return errors.WithMessage(err, "failed to remove head event logs")
+		}
+
+		// update reorg version so that event logs queried during revert will be retried
+		if err := hs.confs.createOrUpdateReorgVersion(dbTx); err != nil {
+			return errors.WithMessage(err, "failed to update reorg version")
+		}
+
+		if finalizer != nil {
+			return finalizer(dbTx)
+		}
+
+		return nil
+	})
+}
+
+// Prune removes blocks and event logs until the specified block number, which have already been
+// synchronized as safe blocks.
+func (hs *HeadStore) Prune(bnUntil uint64) error {
+	return hs.db.Transaction(func(dbTx *gorm.DB) error {
+		if err := dbTx.Where("bn <= ?", bnUntil).Delete(&headBlock{}).Error; err != nil {
+			return errors.WithMessage(err, "failed to prune head blocks")
+		}
+
+		if err := dbTx.Where("bn <= ?", bnUntil).Delete(&headLog{}).Error; err != nil {
+			return errors.WithMessage(err, "failed to prune head event logs")
+		}
+
+		return nil
+	})
+}
+
+// GetLogs returns event logs from the unsafe head tier for the specified log filter.
+func (hs *HeadStore) GetLogs(ctx context.Context, storeFilter store.LogFilter) ([]*store.Log, error) {
+	db := hs.db.Model(&headLog{}).Where("bn BETWEEN ? AND ?", storeFilter.BlockFrom, storeFilter.BlockTo)
+
+	if contracts := storeFilter.Contracts.ToSlice(); len(contracts) > 0 {
+		var cids []uint64
+		for _, addr := range contracts {
+			cid, exists, err := hs.cs.GetContractIdByAddress(addr)
+			if err != nil {
+				return nil, err
+			}
+
+			if exists {
+				cids = append(cids, cid)
+			}
+		}
+
+		if len(cids) == 0 {
+			return nil, nil
+		}
+
+		db = db.Where("cid IN (?)", cids)
+	}
+
+	db = applyTopicsFilter(db, storeFilter.Topics)
+
+	var logs []*headLog
+	if err := db.WithContext(ctx).Limit(int(store.MaxLogLimit) + 1).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(logs) > int(store.MaxLogLimit) {
+		return nil, store.ErrFilterResultSetTooLarge
+	}
+
+	result := make([]*store.Log, 0, len(logs))
+	for _, v := range logs {
+		result = append(result, (*store.Log)(v))
+	}
+
+	sort.Sort(store.LogSlice(result))
+
+	return result, nil
+}
@@ -1,7 +1,9 @@
 package mysql
 
 import (
+	"cmp"
 	"context"
+	"slices"
 
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/types"
@@ -27,6 +29,7 @@ type log struct {
 	Topic2      string `gorm:"size:66"`
 	Topic3      string `gorm:"size:66"`
 	LogIndex    uint64 `gorm:"not null"`
+	TxHashId    uint64 `gorm:"column:tx_hash_id;not null;default:0;index:idx_tx_hash_id"`
 	Extra       []byte `gorm:"type:mediumText"` // extension json field
 }
 
@@ -65,46 +68,20 @@ func (ls *logStore) Add(dbTx *gorm.DB, dataSlice []*store.EpochData, logPartitio
 	// containers to collect event logs for batch inserting
 	var logs []*log
 
-	for _, data := range dataSlice {
-		for _, block := range data.Blocks {
-			bn := block.BlockNumber.ToInt().Uint64()
-
-			for _, tx := range block.Transactions {
-				receipt := data.Receipts[tx.Hash]
-
-				// Skip transactions that unexecuted in block.
-				if receipt == nil || !util.IsTxExecutedInBlock(&tx) {
-					continue
-				}
-
-				var rcptExt *store.ReceiptExtra
-				if len(data.ReceiptExts) > 0 {
-					rcptExt = data.ReceiptExts[tx.Hash]
-				}
-
-				for k, rlog := range receipt.Logs {
-					cid, _, err := ls.cs.AddContractIfAbsent(rlog.Address.MustGetBase32Address())
-					if err != nil {
-						return errors.WithMessage(err, "failed to add contract")
-					}
-
-					var logExt *store.LogExtra
-					if rcptExt != nil && k < len(rcptExt.LogExts) {
-						logExt = rcptExt.LogExts[k]
-					}
+	storeLogs, err := parseEpochLogs(ls.cs, dataSlice)
+	if err != nil {
+		return err
+	}
 
-					clog := store.ParseCfxLog(&rlog, cid, bn, logExt)
-					logs = append(logs, (*log)(clog))
-				}
-			}
-		}
+	for _, v := range storeLogs {
+		logs = append(logs, (*log)(v))
 	}
 
 	// update block range for log partition router
 	bnMin := dataSlice[0].Blocks[0].BlockNumber.ToInt().Uint64()
 	bnMax := dataSlice[len(dataSlice)-1].GetPivotBlock().BlockNumber.ToInt().Uint64()
 
-	err := ls.expandBnRange(dbTx, bnPartitionedLogEntity, int(logPartition.Index), bnMin, bnMax)
+	err = ls.expandBnRange(dbTx, bnPartitionedLogEntity, int(logPartition.Index), bnMin, bnMax)
 	if err != nil {
 		return errors.WithMessage(err, "failed to expand partition bn range")
 	}
@@ -224,6 +201,151 @@ func (ls *logStore) GetBnPartitionedLogs(filter LogFilter, partition bnPartition
 	return res, err
 }
 
+// GetLogsByTxHashIds returns event logs of transactions from the block number partitioned log
+// tables. For transactions with known block number ranges, only the overlapped partitions are
+// searched within the ranges by the transaction hash id index, including event logs stored without
+// transaction hash id (as 0). For the others, all the partitions are searched by the transaction
+// hash id index.
+//
+// Note the rows may contain event logs of other transactions due to hash id collision or stored
+// without hash id, so they are filtered by the `match` function (if any) before the result set
+// size is checked.
+func (ls *logStore) GetLogsByTxHashIds(
+	ctx context.Context,
+	bnRanges []types.RangeUint64, rangedHashIds, unknownHashIds []uint64,
+	match func(log *store.Log) bool,
+) ([]*store.Log, error) {
+	var result []*store.Log
+
+	collect := func(partition *bnPartition, where string, args ...interface{}) error {
+		// check timeout before query
+		select {
+		case <-ctx.Done():
+			return store.ErrGetLogsTimeout
+		default:
+		}
+
+		var logs []*log
+
+		tblName := ls.getPartitionedTableName(&ls.model, partition.Index)
+		if err := ls.db.Table(tblName).Where(where, args...).Find(&logs).Error; err != nil {
+			return err
+		}
+
+		// convert to common store log
+		for _, v := range logs {
+			if log := (*store.Log)(v); match == nil || match(log) {
+				result = append(result, log)
+			}
+		}
+
+		// check log count
+		if len(result) > int(store.MaxLogLimit) {
+			return store.ErrFilterResultSetTooLarge
+		}
+
+		return nil
+	}
+
+	if len(bnRanges) > 0 {
+		// event logs stored before transaction hash id introduced are indexed as 0
+		hashIds := append(slices.Clone(rangedHashIds), 0)
+
+		for _, bnRange := range mergeRanges(bnRanges) {
+			partitions, err := ls.searchOverlapPartitions(bnPartitionedLogEntity, bnRange)
+			if err != nil {
+				return nil, errors.WithMessage(err, "failed to search overlap partitions")
+			}
+
+			for _, partition := range partitions {
+				err := collect(
+					partition, "bn BETWEEN ? AND ? AND tx_hash_id IN (?)", bnRange.From, bnRange.To, hashIds,
+				)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if len(unknownHashIds) == 0 {
+		return result, nil
+	}
+
+	partitions, err := ls.dataPartitions(bnPartitionedLogEntity)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get data partitions")
+	}
+
+	for _, partition := range partitions {
+		if err := collect(partition, "tx_hash_id IN (?)", unknownHashIds); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// mergeRanges merges the overlapped or adjacent ranges in ascending order.
+func mergeRanges(ranges []types.RangeUint64) []types.RangeUint64 {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b types.RangeUint64) int { return cmp.Compare(a.From, b.From) })
+
+	var merged []types.RangeUint64
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.From <= merged[n-1].To+1 {
+			merged[n-1].To = max(merged[n-1].To, r.To)
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// parseEpochLogs parses event logs of executed transactions within epoch data slice, with contract
+// added if absent.
+func parseEpochLogs(cs *ContractStore, dataSlice []*store.EpochData) ([]*store.Log, error) {
+	var logs []*store.Log
+
+	for _, data := range dataSlice {
+		for _, block := range data.Blocks {
+			bn := block.BlockNumber.ToInt().Uint64()
+
+			for _, tx := range block.Transactions {
+				receipt := data.Receipts[tx.Hash]
+
+				// Skip transactions that unexecuted in block.
+				if receipt == nil || !util.IsTxExecutedInBlock(&tx) {
+					continue
+				}
+
+				var rcptExt *store.ReceiptExtra
+				if len(data.ReceiptExts) > 0 {
+					rcptExt = data.ReceiptExts[tx.Hash]
+				}
+
+				for k, rlog := range receipt.Logs {
+					cid, _, err := cs.AddContractIfAbsent(rlog.Address.MustGetBase32Address())
+					if err != nil {
+						return nil, errors.WithMessage(err, "failed to add contract")
+					}
+
+					var logExt *store.LogExtra
+					if rcptExt != nil && k < len(rcptExt.LogExts) {
+						logExt = rcptExt.LogExts[k]
+					}
+
+					logs = append(logs, store.ParseCfxLog(&rlog, cid, bn, logExt))
+				}
+			}
+		}
+	}
+
+	return logs, nil
+}
+
 // extractUniqueContractAddresses extracts unique contract addresses of event logs within epoch data slice.
 func extractUniqueContractAddresses(slice ...*store.EpochData) map[string]bool {
 	contracts := make(map[string]bool)
@@ -27,6 +27,7 @@ type AddressIndexedLog struct {
 	Topic2      string `gorm:"size:66"`
 	Topic3      string `gorm:"size:66"`
 	LogIndex    uint64 `gorm:"not null"`
+	TxHashId    uint64 `gorm:"column:tx_hash_id;not null;default:0;index:idx_tx_hash_id"`
 	Extra       []byte `gorm:"type:mediumText"` // extention json field
 }
 
@@ -176,6 +177,52 @@ func (ls *AddressIndexedLogStore) DeleteAddressIndexedLogs(dbTx *gorm.DB, epochF
 	return nil
 }
 
+// pruneAddressIndexedLogs removes address indexed event logs until the specified epoch from all the
+// partitions in batches, except for event logs of the excluded contracts.
+func (ls *AddressIndexedLogStore) pruneAddressIndexedLogs(epochUntil uint64, excludedCids []uint64) (int64, error) {
+	var totalPruned int64
+
+	for i := uint32(0); i < ls.partitions; i++ {
+		db := ls.db.Table(ls.getPartitionedTableName(&ls.model, i)).Where("epoch <= ?", epochUntil)
+		if len(excludedCids) > 0 {
+			db = db.Where("cid NOT IN (?)", excludedCids)
+		}
+
+		numPruned, err := deleteInBatches(db, &AddressIndexedLog{}, defaultBatchSizePruneDelete)
+		totalPruned += numPruned
+
+		if err != nil {
+			return totalPruned, err
+		}
+	}
+
+	return totalPruned, nil
+}
+
+// pruneContractLogs removes address indexed event logs of the contract until the specified epoch in batches.
+func (ls *AddressIndexedLogStore) pruneContractLogs(contract *Contract, epochUntil uint64) (int64, error) {
+	query := ls.db.Table(ls.GetPartitionedTableName(contract.Address)).
+		Where("cid = ? AND epoch <= ?", contract.ID, epochUntil)
+
+	return deleteInBatches(query, &AddressIndexedLog{}, defaultBatchSizePruneDelete)
+}
+
+// oldestContractLogEpoch returns the epoch of the oldest address indexed event log for the contract.
+func (ls *AddressIndexedLogStore) oldestContractLogEpoch(contract *Contract) (uint64, bool, error) {
+	var oldest AddressIndexedLog
+
+	err := ls.db.Table(ls.GetPartitionedTableName(contract.Address)).
+		Select("epoch").
+		Where("cid = ?", contract.ID).
+		Order("bn").
+		Take(&oldest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+
+	return oldest.Epoch, err == nil, err
+}
+
 // GetAddressIndexedLogs returns event logs for the specified filter.
 func (ls *AddressIndexedLogStore) GetAddressIndexedLogs(
 	filter AddressIndexedLogFilter,
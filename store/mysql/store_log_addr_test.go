@@ -0,0 +1,42 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneAddressIndexedLogs(t *testing.T) {
+	db := newTestDB(t)
+	createTestPartitionTables(t, db, &AddressIndexedLog{}, "addr_logs_0")
+
+	var aiLogs []*AddressIndexedLog
+	aiLogs = append(aiLogs, newTestAddressIndexedLogs(1, 10, 20, 30)...)
+	aiLogs = append(aiLogs, newTestAddressIndexedLogs(2, 10, 20, 30)...)
+	require.NoError(t, db.Table("addr_logs_0").Create(aiLogs).Error)
+
+	ails := NewAddressIndexedLogStore(db, nil, 1)
+	contract := &Contract{ID: 2, Address: "cfx:2"}
+
+	// contract 2 excluded with retention policy overridden
+	pruned, err := ails.pruneAddressIndexedLogs(2, []uint64{contract.ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), pruned)
+	assert.Equal(t, []uint64{30}, queryTestAddressIndexedLogBns(t, db, 1))
+	assert.Equal(t, []uint64{10, 20, 30}, queryTestAddressIndexedLogBns(t, db, 2))
+
+	epoch, ok, err := ails.oldestContractLogEpoch(contract)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), epoch)
+
+	pruned, err = ails.pruneContractLogs(contract, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+	assert.Equal(t, []uint64{20, 30}, queryTestAddressIndexedLogBns(t, db, 2))
+
+	_, ok, err = ails.oldestContractLogEpoch(&Contract{ID: 3, Address: "cfx:3"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
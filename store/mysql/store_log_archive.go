@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/archive"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// partitionArchiver archives entity partition data before the partition is pruned.
+type partitionArchiver interface {
+	archivePartition(entity string, tabler schema.Tabler, partition *bnPartition) error
+}
+
+// logPartitionArchiver archives event log partitions into cold storage before pruned.
+type logPartitionArchiver struct {
+	partitionedStore
+	db      *gorm.DB
+	archive *archive.Archive
+}
+
+func newLogPartitionArchiver(db *gorm.DB, archive *archive.Archive) *logPartitionArchiver {
+	return &logPartitionArchiver{db: db, archive: archive}
+}
+
+func (la *logPartitionArchiver) archivePartition(entity string, tabler schema.Tabler, partition *bnPartition) error {
+	start := time.Now()
+	ctx := context.Background()
+	tblName := la.getPartitionedTableName(tabler, partition.Index)
+
+	var files []*archive.FileMeta
+	putChunk := func(logs []*store.Log) error {
+		file, err := la.archive.Put(ctx, entity, partition.Index, len(files), logs)
+		if err == nil {
+			files = append(files, file)
+		}
+
+		return err
+	}
+
+	var err error
+	switch t := tabler.(type) {
+	case *log:
+		var rows []*log
+		err = la.db.Table(tblName).FindInBatches(&rows, la.archive.ChunkSize(), func(tx *gorm.DB, batch int) error {
+			logs := make([]*store.Log, 0, len(rows))
+			for _, row := range rows {
+				logs = append(logs, (*store.Log)(row))
+			}
+
+			return putChunk(logs)
+		}).Error
+	case *contractLog:
+		var rows []*contractLog
+		err = la.db.Table(tblName).FindInBatches(&rows, la.archive.ChunkSize(), func(tx *gorm.DB, batch int) error {
+			logs := make([]*store.Log, 0, len(rows))
+			for _, row := range rows {
+				// fill contract id since it's not persisted in db
+				row.ContractID = t.ContractID
+				logs = append(logs, (*store.Log)(row))
+			}
+
+			return putChunk(logs)
+		}).Error
+	default:
+		return errors.Errorf("unsupported event log tabler %T", tabler)
+	}
+
+	if err != nil {
+		return errors.WithMessage(err, "failed to archive event logs in batches")
+	}
+
+	err = la.archive.Commit(ctx, &archive.PartitionMeta{
+		Entity:    entity,
+		Partition: partition.Index,
+		BnMin:     uint64(partition.BnMin.Int64),
+		BnMax:     uint64(partition.BnMax.Int64),
+		Files:     files,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to commit archive files")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"partition": partition,
+		"files":     len(files),
+		"elapsed":   time.Since(start),
+	}).Info("Event log partition archived")
+
+	return nil
+}
+
+// getLogsWithArchive gets event logs of the entity from both archive files and db partitions, in
+// case that part of the block range of the log filter has already been pruned from db.
+func (ms *MysqlStore) getLogsWithArchive(
+	ctx context.Context,
+	entity string,
+	filter store.LogFilter,
+	dbGetLogs func(store.LogFilter) ([]*store.Log, error),
+) ([]*store.Log, error) {
+	bnStart, _, existed, err := ms.ls.bnRange(entity)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get block range of partitions")
+	}
+
+	archiveFilter := filter
+	if existed && archiveFilter.BlockTo >= bnStart {
+		archiveFilter.BlockTo = bnStart - 1
+	}
+
+	logs, err := ms.archive.GetLogs(ctx, entity, archiveFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	if existed && filter.BlockTo >= bnStart {
+		dbFilter := filter
+		dbFilter.BlockFrom = bnStart
+
+		dbLogs, err := dbGetLogs(dbFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		logs = append(logs, dbLogs...)
+	}
+
+	// archived event logs are not guaranteed in order, so merged event logs are sorted by
+	// (block number, log index) before the result set limit applied.
+	sort.Stable(store.LogSlice(logs))
+
+	if len(logs) > int(store.MaxLogLimit) {
+		return nil, store.ErrFilterResultSetTooLarge
+	}
+
+	return logs, nil
+}
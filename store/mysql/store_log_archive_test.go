@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLogsWithArchiveAcrossBoundary(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &bnPartition{})
+
+	a := archive.MustNew(&archive.Config{
+		Storage:   "local",
+		Local:     archive.LocalConfig{Dir: t.TempDir()},
+		Prefix:    "test",
+		ChunkSize: 2,
+		CacheSize: 4,
+	})
+
+	// archived partition [100, 109] with files out of order
+	archived := [][]*store.Log{
+		{{BlockNumber: 105, LogIndex: 0}, {BlockNumber: 105, LogIndex: 1}},
+		{{BlockNumber: 101, LogIndex: 0}, {BlockNumber: 103, LogIndex: 2}},
+	}
+
+	var files []*archive.FileMeta
+	for i, logs := range archived {
+		file, err := a.Put(ctx, bnPartitionedLogEntity, 0, i, logs)
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+
+	require.NoError(t, a.Commit(ctx, &archive.PartitionMeta{
+		Entity: bnPartitionedLogEntity, Partition: 0, BnMin: 100, BnMax: 109, Files: files,
+	}))
+
+	// db partition [110, 119] not pruned yet
+	require.NoError(t, db.Create(&bnPartition{
+		Entity: bnPartitionedLogEntity,
+		Index:  1,
+		BnMin:  sql.NullInt64{Int64: 110, Valid: true},
+		BnMax:  sql.NullInt64{Int64: 119, Valid: true},
+	}).Error)
+
+	ms := &MysqlStore{ls: &logStore{bnPartitionedStore: newBnPartitionedStore(db)}, archive: a}
+
+	var dbFilter store.LogFilter
+	dbLogs := []*store.Log{{BlockNumber: 110, LogIndex: 0}, {BlockNumber: 112, LogIndex: 3}}
+
+	filter := store.LogFilter{BlockFrom: 102, BlockTo: 115}
+	result, err := ms.getLogsWithArchive(ctx, bnPartitionedLogEntity, filter, func(f store.LogFilter) ([]*store.Log, error) {
+		dbFilter = f
+		return dbLogs, nil
+	})
+	require.NoError(t, err)
+
+	// db queried only for the block range not pruned yet
+	assert.Equal(t, store.LogFilter{BlockFrom: 110, BlockTo: 115}, dbFilter)
+
+	// merged in order of (block number, log index) across the archive/db boundary
+	var positions [][2]uint64
+	for _, log := range result {
+		positions = append(positions, [2]uint64{log.BlockNumber, log.LogIndex})
+	}
+
+	assert.Equal(t, [][2]uint64{{103, 2}, {105, 0}, {105, 1}, {110, 0}, {112, 3}}, positions)
+}
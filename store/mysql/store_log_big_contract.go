@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/types"
@@ -12,11 +13,6 @@ import (
 	"gorm.io/gorm"
 )
 
-const (
-	// threshold count of event logs for contract to be regarded as big contract.
-	thresholdBigContractLogCount = 100_000
-)
-
 // contractLog event logs for specified contract
 type contractLog struct {
 	ID          uint64
@@ -28,6 +24,7 @@ type contractLog struct {
 	Topic2      string `gorm:"size:66"`
 	Topic3      string `gorm:"size:66"`
 	LogIndex    uint64 `gorm:"not null"`
+	TxHashId    uint64 `gorm:"column:tx_hash_id;not null;default:0;index:idx_tx_hash_id"`
 	Extra       []byte `gorm:"type:mediumText"` // extension json field
 }
 
@@ -42,6 +39,12 @@ type bigContractLogStore struct {
 	cs   *ContractStore
 	ebms *epochBlockMapStore
 	ails *AddressIndexedLogStore
+	// policy to promote or demote big contract
+	policy *bigContractPolicy
+	// write lock to serialize the data sync with the big contract demotion in background
+	mu sync.Mutex
+	// big contract being demoted in background if any
+	demotion *bigContractDemotion
 	// notify channel for new bn partition created
 	bnPartitionNotifyChan chan<- *bnPartition
 }
@@ -51,12 +54,16 @@ func newBigContractLogStore(
 	cs *ContractStore,
 	ebms *epochBlockMapStore,
 	ails *AddressIndexedLogStore,
+	policyConfig *BigContractPolicyConfig,
 	notifyChan chan<- *bnPartition,
 ) *bigContractLogStore {
-	return &bigContractLogStore{
+	bcls := &bigContractLogStore{
 		bnPartitionedStore:    newBnPartitionedStore(db),
 		bnPartitionNotifyChan: notifyChan, cs: cs, ebms: ebms, ails: ails,
 	}
+
+	bcls.policy = newBigContractPolicy(bcls, policyConfig)
+	return bcls
 }
 
 // preparePartition create new contract log partitions for the big contract if necessary.
@@ -81,8 +88,12 @@ func (bcls *bigContractLogStore) preparePartitions(dataSlice []*store.EpochData)
 			return nil, errors.WithMessage(store.ErrNotFound, "contract not found")
 		}
 
-		if contract.LogCount < thresholdBigContractLogCount {
-			// not qualified for big contract
+		qualified, err := bcls.policy.qualified(contract)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to check big contract qualification")
+		}
+
+		if !qualified {
 			continue
 		}
 
@@ -113,6 +124,8 @@ func (bcls *bigContractLogStore) preparePartitions(dataSlice []*store.EpochData)
 		if err := bcls.migrate(contract, partition); err != nil {
 			return nil, errors.WithMessage(err, "failed to migrate contract logs")
 		}
+
+		bcls.policy.promoted(contract.ID)
 	}
 
 	return contract2BnPartitions, nil
@@ -191,11 +204,280 @@ func (bcls *bigContractLogStore) migrate(contract *Contract, partition bnPartiti
 	})
 }
 
+// bigContractDemotion progress of the big contract being demoted in background.
+type bigContractDemotion struct {
+	contract *Contract
+	// block number from which the event logs are not merged back yet
+	bnFrom uint64
+}
+
+// demote merges event logs of the big contract from seperate log table partitions back into the
+// address indexed log table in batches, and then removes all the seperate log table partitions.
+//
+// Each batch is serialized with the data sync by the write lock, so that event logs written or
+// popped during the demotion will be merged back eventually, and the contract is always regarded
+// as big contract until all event logs are merged back.
+func (bcls *bigContractLogStore) demote(contract *Contract, batchSize int) error {
+	bcls.mu.Lock()
+	bcls.demotion = &bigContractDemotion{contract: contract}
+	bcls.mu.Unlock()
+
+	defer func() {
+		bcls.mu.Lock()
+		bcls.demotion = nil
+		bcls.mu.Unlock()
+	}()
+
+	var totalMergedLogs int64
+	for {
+		merged, done, err := bcls.demoteBatch(max(batchSize, 1))
+		if err != nil {
+			return err
+		}
+
+		totalMergedLogs += merged
+
+		if done {
+			break
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"contract":        contract,
+		"aiTableName":     bcls.ails.GetPartitionedTableName(contract.Address),
+		"totalMergedLogs": totalMergedLogs,
+	}).Info("Big contract event logs merged back into address indexed event logs table")
+
+	return nil
+}
+
+// demoteBatch merges a batch of event logs of the big contract being demoted back into the address
+// indexed log table, and removes all the seperate log table partitions once all merged back.
+func (bcls *bigContractLogStore) demoteBatch(batchSize int) (merged int64, done bool, err error) {
+	bcls.mu.Lock()
+	defer bcls.mu.Unlock()
+
+	demotion := bcls.demotion
+	clEntity, clTabler := bcls.contractEntity(demotion.contract.ID), bcls.contractTabler(demotion.contract.ID)
+
+	partitions, err := bcls.dataPartitions(clEntity)
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to get data partitions")
+	}
+
+	for _, partition := range partitions {
+		if uint64(partition.BnMax.Int64) < demotion.bnFrom {
+			continue
+		}
+
+		clTableName := bcls.getPartitionedTableName(clTabler, partition.Index)
+
+		// event logs of the same block are always merged back within the same batch
+		bnTo := uint64(partition.BnMax.Int64)
+
+		var bns []uint64
+		err := bcls.db.Table(clTableName).
+			Where("bn >= ?", demotion.bnFrom).
+			Order("bn").
+			Offset(batchSize-1).
+			Limit(1).
+			Pluck("bn", &bns).Error
+		if err != nil {
+			return 0, false, errors.WithMessage(err, "failed to get block number of batch end")
+		}
+
+		if len(bns) > 0 {
+			bnTo = bns[0]
+		}
+
+		err = bcls.db.Transaction(func(dbTx *gorm.DB) error {
+			merged, err = bcls.mergeBack(dbTx, demotion.contract, clTableName, demotion.bnFrom, bnTo)
+			return err
+		})
+		if err != nil {
+			return 0, false, errors.WithMessage(err, "failed to merge back contract logs")
+		}
+
+		demotion.bnFrom = bnTo + 1
+
+		if merged > 0 {
+			return merged, false, nil
+		}
+	}
+
+	// All event logs merged back, remove all partitions so that the contract will no longer be
+	// regarded as big contract.
+	startPartIdx, endPartIdx, existed, err := bcls.indexRange(clEntity)
+	if err != nil || !existed {
+		return 0, true, errors.WithMessage(err, "failed to get partition index range")
+	}
+
+	if err := bcls.db.Where("entity = ?", clEntity).Delete(&bnPartition{}).Error; err != nil {
+		return 0, false, errors.WithMessage(err, "failed to remove contract log partitions")
+	}
+
+	// Drop seperate log table partitions, which will never be used afterwards even if failed.
+	for i := startPartIdx; i <= endPartIdx; i++ {
+		if _, err := bcls.deletePartitionedTable(bcls.db, clTabler, i); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"entity": clEntity,
+				"table":  bcls.getPartitionedTableName(clTabler, i),
+			}).WithError(err).Error("Failed to drop demoted contract log partition table")
+		}
+	}
+
+	return 0, true, nil
+}
+
+// mergeBack merges event logs of the big contract within the block range from the seperate log
+// table partition back into the address indexed log table.
+func (bcls *bigContractLogStore) mergeBack(
+	dbTx *gorm.DB, contract *Contract, clTableName string, bnFrom, bnTo uint64,
+) (int64, error) {
+	aiTableName := bcls.ails.GetPartitionedTableName(contract.Address)
+
+	// remove event logs merged back before, in case of block range re-merged due to pivot switch
+	err := dbTx.Table(aiTableName).
+		Where("cid = ? AND bn BETWEEN ? AND ?", contract.ID, bnFrom, bnTo).
+		Delete(&AddressIndexedLog{}).Error
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to delete address indexed logs")
+	}
+
+	var clLogs []*contractLog
+	err = dbTx.Table(clTableName).Where("bn BETWEEN ? AND ?", bnFrom, bnTo).Order("id").Find(&clLogs).Error
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to get contract logs")
+	}
+
+	if len(clLogs) == 0 {
+		return 0, nil
+	}
+
+	aiLogs := make([]*AddressIndexedLog, 0, len(clLogs))
+	for _, clLog := range clLogs {
+		// copy contract event log
+		aiLog := (AddressIndexedLog)(*clLog)
+		// clear primary id and fill contract id since it's not persisted in contract log table
+		aiLog.ID, aiLog.ContractID = 0, contract.ID
+
+		aiLogs = append(aiLogs, &aiLog)
+	}
+
+	// insert into address indexed log table
+	if err := dbTx.Table(aiTableName).CreateInBatches(aiLogs, defaultBatchSizeLogInsert).Error; err != nil {
+		return 0, errors.WithMessage(err, "failed to insert address indexed logs")
+	}
+
+	return int64(len(aiLogs)), nil
+}
+
+// rewindDemotion rewinds the progress of the big contract being demoted if any, so that event logs
+// changed since the specified block number will be merged back again. Note, caller must hold the
+// write lock.
+func (bcls *bigContractLogStore) rewindDemotion(bn uint64) {
+	if bcls.demotion != nil && bcls.demotion.bnFrom > bn {
+		bcls.demotion.bnFrom = bn
+	}
+}
+
+// countContractLogs counts the event logs of the big contract since the specified block number.
+func (bcls *bigContractLogStore) countContractLogs(cid uint64, bnFrom uint64) (int64, error) {
+	partitions, err := bcls.dataPartitions(bcls.contractEntity(cid))
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to get data partitions")
+	}
+
+	var total int64
+	for _, partition := range partitions {
+		if uint64(partition.BnMax.Int64) < bnFrom {
+			continue
+		}
+
+		var count int64
+
+		tblName := bcls.getPartitionedTableName(bcls.contractTabler(cid), partition.Index)
+		if err := bcls.db.Table(tblName).Where("bn >= ?", bnFrom).Count(&count).Error; err != nil {
+			return 0, err
+		}
+
+		total += count
+	}
+
+	return total, nil
+}
+
+// bigContracts returns all the big contracts which have separate contract log partitions.
+func (bcls *bigContractLogStore) bigContracts() ([]*Contract, error) {
+	entities, err := bcls.entitiesByPrefix("clogs_")
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get big contract log entities")
+	}
+
+	contracts := make([]*Contract, 0, len(entities))
+	for _, entity := range entities {
+		cid, ok := bcls.parseContractEntity(entity)
+		if !ok {
+			logrus.WithField("entity", entity).Warn("Invalid big contract log entity")
+			continue
+		}
+
+		contract, ok, err := bcls.cs.GetContractById(cid)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get contract by id")
+		}
+
+		if ok {
+			contracts = append(contracts, contract)
+		}
+	}
+
+	return contracts, nil
+}
+
+// pruneContractLogs prunes archive contract log partitions of the big contract, of which the max
+// block number is no more than the specified block number.
+func (bcls *bigContractLogStore) pruneContractLogs(cid uint64, bnUntil uint64) ([]*bnPartition, error) {
+	return bcls.pruneArchivePartitionsUntil(bcls.contractEntity(cid), bcls.contractTabler(cid), bnUntil)
+}
+
+// oldestContractLogEpoch returns the epoch of the oldest event log for the big contract.
+func (bcls *bigContractLogStore) oldestContractLogEpoch(cid uint64) (uint64, bool, error) {
+	partitions, err := bcls.dataPartitions(bcls.contractEntity(cid))
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to get data partitions")
+	}
+
+	for _, partition := range partitions {
+		var oldest contractLog
+
+		tblName := bcls.getPartitionedTableName(bcls.contractTabler(cid), partition.Index)
+		err := bcls.db.Table(tblName).Select("epoch").Order("bn").Take(&oldest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) { // no event logs on partition
+			continue
+		}
+
+		if err != nil {
+			return 0, false, err
+		}
+
+		return oldest.Epoch, true, nil
+	}
+
+	return 0, false, nil
+}
+
 // contractEntity gets partition entity of contract logs
 func (bcls *bigContractLogStore) contractEntity(cid uint64) string {
 	return contractLog{ContractID: cid}.TableName()
 }
 
+// parseContractEntity parses contract id from partition entity of contract logs
+func (bcls *bigContractLogStore) parseContractEntity(entity string) (cid uint64, ok bool) {
+	_, err := fmt.Sscanf(entity, "clogs_%d", &cid)
+	return cid, err == nil
+}
+
 // contractTabler get partition tabler of contract logs
 func (bcls *bigContractLogStore) contractTabler(cid uint64) *contractLog {
 	return &contractLog{ContractID: cid}
@@ -304,6 +586,9 @@ func (bcls *bigContractLogStore) Popn(dbTx *gorm.DB, epochUntil uint64) error {
 		return errors.Errorf("no block mapping found for epoch %v", epochUntil)
 	}
 
+	// popped event logs of the big contract being demoted should be merged back again
+	bcls.rewindDemotion(bn.From)
+
 	// delete event logs for all possible contracts.
 	for _, contract := range contracts {
 		contractEntity := bcls.contractEntity(contract.ID)
@@ -0,0 +1,234 @@
+package mysql
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// BigContractPolicyConfig configurations to promote contract as big contract, which has separate
+// contract log partitions, or demote big contract to merge event logs back into address indexed
+// log partitions.
+type BigContractPolicyConfig struct {
+	// threshold count of event logs for contract to be regarded as big contract
+	PromoteLogCount int `default:"100000"`
+	// whether to promote or demote big contracts automatically based on log rate
+	AutoEnabled bool
+	// interval to evaluate the log rate of contracts
+	Interval time.Duration `default:"1h"`
+	// number of latest epochs within which to measure the log rate of contracts
+	RateWindowEpochs uint64 `default:"1000000"`
+	// min number of event logs within the rate window for contract to be promoted
+	PromoteWindowLogs int64 `default:"50000"`
+	// max number of event logs within the rate window for big contract to be demoted
+	DemoteWindowLogs int64 `default:"500"`
+	// max number of contracts to be promoted or demoted per evaluation in case of IO hogging
+	MaxChanges int `default:"1"`
+	// max number of event logs to be merged back per batch when demoting big contract
+	DemoteBatchSize int `default:"5000"`
+}
+
+// BigContractDecision decision to promote or demote some contract.
+type BigContractDecision struct {
+	Contract *Contract
+	// number of event logs within the rate window
+	WindowLogs int64
+}
+
+// BigContractPolicyReport evaluation report of the big contract policy.
+type BigContractPolicyReport struct {
+	// epoch range of the rate window
+	WindowFromEpoch uint64
+	WindowToEpoch   uint64
+
+	Promotions []BigContractDecision
+	Demotions  []BigContractDecision
+}
+
+// bigContractPolicy evaluates the log rate of contracts to decide which contract to be promoted or demoted.
+//
+// Promotions are only marked as pending by the policy, and will be applied by the data sync thread
+// to avoid concurrent writes of event logs for the same contract, while demotions are applied in
+// background in batches so as not to stall the data sync.
+type bigContractPolicy struct {
+	config *BigContractPolicyConfig
+	bcls   *bigContractLogStore
+
+	mu                sync.Mutex
+	pendingPromotions map[uint64]bool // contract id => pending
+}
+
+func newBigContractPolicy(bcls *bigContractLogStore, config *BigContractPolicyConfig) *bigContractPolicy {
+	return &bigContractPolicy{
+		config:            config,
+		bcls:              bcls,
+		pendingPromotions: make(map[uint64]bool),
+	}
+}
+
+// schedule periodically evaluates the policy to mark the promotions as pending and apply the
+// demotions. Be noted this function will block caller thread.
+func (p *bigContractPolicy) schedule() {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := p.evaluate()
+		if err != nil {
+			logrus.WithError(err).Error("Failed to evaluate big contract policy")
+			continue
+		}
+
+		p.mu.Lock()
+
+		for _, d := range report.Promotions {
+			p.pendingPromotions[d.Contract.ID] = true
+		}
+
+		p.mu.Unlock()
+
+		if len(report.Promotions) > 0 || len(report.Demotions) > 0 {
+			logrus.WithField("report", report).Info("Big contract policy evaluated")
+		}
+
+		for _, d := range report.Demotions {
+			if err := p.bcls.demote(d.Contract, p.config.DemoteBatchSize); err != nil {
+				logrus.WithField("contract", d.Contract).WithError(err).Error("Failed to demote big contract")
+			}
+		}
+	}
+}
+
+// qualified checks if the contract is qualified to write event logs into separate contract log partitions.
+func (p *bigContractPolicy) qualified(contract *Contract) (bool, error) {
+	if !p.config.AutoEnabled {
+		return contract.LogCount >= p.config.PromoteLogCount, nil
+	}
+
+	// big contract until demoted
+	_, existed, err := p.bcls.oldestPartition(p.bcls.contractEntity(contract.ID))
+	if err != nil || existed {
+		return existed, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.pendingPromotions[contract.ID], nil
+}
+
+// promoted removes the contract from pending promotions.
+func (p *bigContractPolicy) promoted(cid uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pendingPromotions, cid)
+}
+
+// evaluate measures the log rate of contracts within the rate window to decide which contract to be
+// promoted or demoted.
+func (p *bigContractPolicy) evaluate() (*BigContractPolicyReport, error) {
+	report := &BigContractPolicyReport{}
+
+	maxEpoch, ok, err := p.bcls.ebms.MaxEpoch()
+	if err != nil || !ok {
+		return report, errors.WithMessage(err, "failed to get max epoch")
+	}
+
+	report.WindowToEpoch = maxEpoch
+	if maxEpoch >= p.config.RateWindowEpochs {
+		report.WindowFromEpoch = maxEpoch - p.config.RateWindowEpochs + 1
+	}
+
+	var windowFromBn uint64
+	if bnRange, ok, err := p.bcls.ebms.BlockRange(report.WindowFromEpoch); err != nil {
+		return nil, errors.WithMessage(err, "failed to get block range of rate window")
+	} else if ok {
+		windowFromBn = bnRange.From
+	}
+
+	if report.Promotions, err = p.evaluatePromotions(windowFromBn); err != nil {
+		return nil, errors.WithMessage(err, "failed to evaluate promotions")
+	}
+
+	if report.Demotions, err = p.evaluateDemotions(windowFromBn); err != nil {
+		return nil, errors.WithMessage(err, "failed to evaluate demotions")
+	}
+
+	return report, nil
+}
+
+func (p *bigContractPolicy) evaluatePromotions(windowFromBn uint64) ([]BigContractDecision, error) {
+	contracts, err := p.bcls.cs.GetContractsByMinLogCount(p.config.PromoteLogCount)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get contracts by min log count")
+	}
+
+	var decisions []BigContractDecision
+	for _, contract := range contracts {
+		_, existed, err := p.bcls.oldestPartition(p.bcls.contractEntity(contract.ID))
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get contract log partition")
+		}
+
+		if existed { // already big contract
+			continue
+		}
+
+		var windowLogs int64
+		err = p.bcls.db.Table(p.bcls.ails.GetPartitionedTableName(contract.Address)).
+			Where("cid = ? AND bn >= ?", contract.ID, windowFromBn).
+			Count(&windowLogs).Error
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to count address indexed logs")
+		}
+
+		if windowLogs >= p.config.PromoteWindowLogs {
+			decisions = append(decisions, BigContractDecision{Contract: contract, WindowLogs: windowLogs})
+		}
+	}
+
+	// promote the most active contracts at first
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].WindowLogs > decisions[j].WindowLogs
+	})
+
+	return p.limitChanges(decisions), nil
+}
+
+func (p *bigContractPolicy) evaluateDemotions(windowFromBn uint64) ([]BigContractDecision, error) {
+	contracts, err := p.bcls.bigContracts()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get big contracts")
+	}
+
+	var decisions []BigContractDecision
+	for _, contract := range contracts {
+		windowLogs, err := p.bcls.countContractLogs(contract.ID, windowFromBn)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to count contract logs")
+		}
+
+		if windowLogs < p.config.DemoteWindowLogs {
+			decisions = append(decisions, BigContractDecision{Contract: contract, WindowLogs: windowLogs})
+		}
+	}
+
+	// demote the most inactive contracts at first
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].WindowLogs < decisions[j].WindowLogs
+	})
+
+	return p.limitChanges(decisions), nil
+}
+
+func (p *bigContractPolicy) limitChanges(decisions []BigContractDecision) []BigContractDecision {
+	if p.config.MaxChanges > 0 && len(decisions) > p.config.MaxChanges {
+		return decisions[:p.config.MaxChanges]
+	}
+
+	return decisions
+}
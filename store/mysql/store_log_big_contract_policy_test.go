@@ -0,0 +1,166 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestBigContractLogStore(t *testing.T, config *BigContractPolicyConfig) (*gorm.DB, *bigContractLogStore) {
+	db := newTestDB(t, &bnPartition{}, &Contract{}, &epochBlockMap{})
+	createTestPartitionTables(t, db, &AddressIndexedLog{}, "addr_logs_0")
+
+	cs := NewContractStore(db)
+	ails := NewAddressIndexedLogStore(db, cs, 1)
+	ebms := newEpochBlockMapStore(db, &Config{})
+
+	return db, newBigContractLogStore(db, cs, ebms, ails, config, make(chan *bnPartition, 1))
+}
+
+func newTestContractLogs(bns ...uint64) []*contractLog {
+	logs := make([]*contractLog, 0, len(bns))
+	for _, bn := range bns {
+		logs = append(logs, &contractLog{BlockNumber: bn, Epoch: bn / 10})
+	}
+
+	return logs
+}
+
+func newTestAddressIndexedLogs(cid uint64, bns ...uint64) []*AddressIndexedLog {
+	logs := make([]*AddressIndexedLog, 0, len(bns))
+	for _, bn := range bns {
+		logs = append(logs, &AddressIndexedLog{ContractID: cid, BlockNumber: bn, Epoch: bn / 10})
+	}
+
+	return logs
+}
+
+func queryTestAddressIndexedLogBns(t *testing.T, db *gorm.DB, cid uint64) []uint64 {
+	var bns []uint64
+	err := db.Table("addr_logs_0").Where("cid = ?", cid).Order("bn").Pluck("bn", &bns).Error
+	require.NoError(t, err)
+
+	return bns
+}
+
+func TestBigContractPolicyEvaluate(t *testing.T) {
+	db, bcls := newTestBigContractLogStore(t, &BigContractPolicyConfig{
+		PromoteLogCount:   3,
+		AutoEnabled:       true,
+		RateWindowEpochs:  10,
+		PromoteWindowLogs: 3,
+		DemoteWindowLogs:  2,
+		MaxChanges:        1,
+	})
+
+	// epoch N contains blocks [10N, 10N+9], so the rate window starts from block 110
+	var maps []*epochBlockMap
+	for epoch := uint64(1); epoch <= 20; epoch++ {
+		maps = append(maps, &epochBlockMap{Epoch: epoch, BnMin: epoch * 10, BnMax: epoch*10 + 9})
+	}
+	require.NoError(t, db.Create(maps).Error)
+
+	require.NoError(t, db.Create([]*Contract{
+		{ID: 1, Address: "cfx:1", LogCount: 4},
+		{ID: 2, Address: "cfx:2", LogCount: 5},
+		{ID: 3, Address: "cfx:3", LogCount: 2},
+		{ID: 4, Address: "cfx:4", LogCount: 3},
+		{ID: 5, Address: "cfx:5", LogCount: 3},
+	}).Error)
+
+	var aiLogs []*AddressIndexedLog
+	aiLogs = append(aiLogs, newTestAddressIndexedLogs(1, 50, 120, 130, 140)...)
+	aiLogs = append(aiLogs, newTestAddressIndexedLogs(2, 150, 160, 170, 180, 190)...)
+	aiLogs = append(aiLogs, newTestAddressIndexedLogs(3, 150, 160)...)
+	require.NoError(t, db.Table("addr_logs_0").Create(aiLogs).Error)
+
+	createTestPartitionTables(t, db, &contractLog{}, "clogs_4_0", "clogs_5_0")
+	require.NoError(t, db.Create([]*bnPartition{
+		newTestBnPartition("clogs_4", 0, 100, 200),
+		newTestBnPartition("clogs_5", 0, 100, 200),
+	}).Error)
+	require.NoError(t, db.Table("clogs_4_0").Create(newTestContractLogs(100, 105, 150)).Error)
+	require.NoError(t, db.Table("clogs_5_0").Create(newTestContractLogs(150, 160, 170)).Error)
+
+	report, err := bcls.policy.evaluate()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(11), report.WindowFromEpoch)
+	assert.Equal(t, uint64(20), report.WindowToEpoch)
+
+	// the most active contract promoted at first due to max changes limit
+	require.Len(t, report.Promotions, 1)
+	assert.Equal(t, uint64(2), report.Promotions[0].Contract.ID)
+	assert.Equal(t, int64(5), report.Promotions[0].WindowLogs)
+
+	require.Len(t, report.Demotions, 1)
+	assert.Equal(t, uint64(4), report.Demotions[0].Contract.ID)
+	assert.Equal(t, int64(1), report.Demotions[0].WindowLogs)
+}
+
+func TestBigContractDemote(t *testing.T) {
+	db, bcls := newTestBigContractLogStore(t, &BigContractPolicyConfig{})
+
+	contract := &Contract{ID: 4, Address: "cfx:4", LogCount: 6}
+	require.NoError(t, db.Create(contract).Error)
+
+	createTestPartitionTables(t, db, &contractLog{}, "clogs_4_0", "clogs_4_1")
+	require.NoError(t, db.Create([]*bnPartition{
+		newTestBnPartition("clogs_4", 0, 1, 100),
+		newTestBnPartition("clogs_4", 1, 101, 200),
+	}).Error)
+	require.NoError(t, db.Table("clogs_4_0").Create(newTestContractLogs(10, 10, 20, 30)).Error)
+	require.NoError(t, db.Table("clogs_4_1").Create(newTestContractLogs(150, 160)).Error)
+
+	require.NoError(t, bcls.demote(contract, 3))
+
+	// event logs of the same block merged back within the same batch
+	assert.Equal(t, []uint64{10, 10, 20, 30, 150, 160}, queryTestAddressIndexedLogBns(t, db, 4))
+
+	isBigContract, err := bcls.IsBigContract(4)
+	require.NoError(t, err)
+	assert.False(t, isBigContract)
+
+	assert.False(t, db.Migrator().HasTable("clogs_4_0"))
+	assert.False(t, db.Migrator().HasTable("clogs_4_1"))
+	assert.Nil(t, bcls.demotion)
+}
+
+func TestBigContractDemoteRewind(t *testing.T) {
+	db, bcls := newTestBigContractLogStore(t, &BigContractPolicyConfig{})
+
+	contract := &Contract{ID: 4, Address: "cfx:4", LogCount: 4}
+	require.NoError(t, db.Create(contract).Error)
+
+	createTestPartitionTables(t, db, &contractLog{}, "clogs_4_0")
+	require.NoError(t, db.Create(newTestBnPartition("clogs_4", 0, 1, 100)).Error)
+	require.NoError(t, db.Table("clogs_4_0").Create(newTestContractLogs(10, 20, 30, 40)).Error)
+
+	bcls.demotion = &bigContractDemotion{contract: contract}
+
+	for i := 0; i < 3; i++ {
+		merged, done, err := bcls.demoteBatch(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), merged)
+		assert.False(t, done)
+	}
+
+	assert.Equal(t, []uint64{10, 20, 30}, queryTestAddressIndexedLogBns(t, db, 4))
+
+	// pivot switched from block 20 during demotion
+	require.NoError(t, db.Table("clogs_4_0").Where("bn >= ?", 20).Delete(&contractLog{}).Error)
+	require.NoError(t, db.Table("addr_logs_0").Where("epoch >= ?", 2).Delete(&AddressIndexedLog{}).Error)
+	bcls.rewindDemotion(20)
+
+	require.NoError(t, db.Table("clogs_4_0").Create(newTestContractLogs(25, 25, 35)).Error)
+
+	for done := false; !done; {
+		_, d, err := bcls.demoteBatch(1)
+		require.NoError(t, err)
+		done = d
+	}
+
+	assert.Equal(t, []uint64{10, 25, 25, 35}, queryTestAddressIndexedLogBns(t, db, 4))
+}
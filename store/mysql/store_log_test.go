@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/types"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeRanges(t *testing.T) {
+	ranges := []types.RangeUint64{{From: 10, To: 20}, {From: 1, To: 3}, {From: 4, To: 5}, {From: 15, To: 30}}
+	assert.Equal(t, []types.RangeUint64{{From: 1, To: 5}, {From: 10, To: 30}}, mergeRanges(ranges))
+	assert.Empty(t, mergeRanges(nil))
+}
+
+func TestGetLogsByTxHashIds(t *testing.T) {
+	db := newTestDB(t, &bnPartition{})
+	createTestPartitionTables(t, db, &log{}, "logs_0", "logs_1")
+
+	require.NoError(t, db.Create([]*bnPartition{
+		newTestBnPartition(bnPartitionedLogEntity, 0, 1, 100),
+		newTestBnPartition(bnPartitionedLogEntity, 1, 101, 200),
+	}).Error)
+
+	require.NoError(t, db.Table("logs_0").Create([]*log{
+		{ID: 1, BlockNumber: 50, TxHashId: 1},
+		{ID: 2, BlockNumber: 60, TxHashId: 2},
+	}).Error)
+
+	require.NoError(t, db.Table("logs_1").Create([]*log{
+		{ID: 3, BlockNumber: 150, TxHashId: 0}, // stored before tx hash id introduced
+		{ID: 4, BlockNumber: 160, TxHashId: 1},
+		{ID: 5, BlockNumber: 150, TxHashId: 3}, // other transaction within block range
+	}).Error)
+
+	ls := newLogStore(db, nil, nil, nil)
+
+	testCases := []struct {
+		bnRanges       []types.RangeUint64
+		rangedHashIds  []uint64
+		unknownHashIds []uint64
+		expected       []uint64
+	}{
+		// search all partitions by tx hash id
+		{nil, nil, []uint64{1}, []uint64{1, 4}},
+		// search overlapped partitions within block range by tx hash id, including logs without tx hash id
+		{[]types.RangeUint64{{From: 140, To: 150}}, []uint64{4}, nil, []uint64{3}},
+		{[]types.RangeUint64{{From: 150, To: 150}, {From: 145, To: 155}}, []uint64{3}, []uint64{2}, []uint64{3, 5, 2}},
+	}
+
+	for _, tc := range testCases {
+		logs, err := ls.GetLogsByTxHashIds(context.Background(), tc.bnRanges, tc.rangedHashIds, tc.unknownHashIds, nil)
+		require.NoError(t, err)
+
+		var actual []uint64
+		for _, log := range logs {
+			actual = append(actual, log.ID)
+		}
+
+		assert.Equal(t, tc.expected, actual)
+	}
+
+	// result set size checked after event logs of other transactions filtered out
+	others := make([]*log, 0, store.MaxLogLimit)
+	for i := uint64(0); i < store.MaxLogLimit; i++ {
+		others = append(others, &log{ID: 100 + i, BlockNumber: 180, TxHashId: 0})
+	}
+	require.NoError(t, db.Table("logs_1").CreateInBatches(others, 500).Error)
+
+	bnRanges := []types.RangeUint64{{From: 160, To: 180}}
+	match := func(log *store.Log) bool { return log.ID == 4 }
+
+	logs, err := ls.GetLogsByTxHashIds(context.Background(), bnRanges, []uint64{1}, nil, match)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+
+	_, err = ls.GetLogsByTxHashIds(context.Background(), bnRanges, []uint64{1}, nil, nil)
+	assert.ErrorIs(t, err, store.ErrFilterResultSetTooLarge)
+}
+
+func TestLoadTxEpochs(t *testing.T) {
+	db := newTestDB(t, &transaction{})
+	ts := newTxStore(db)
+
+	hash := "0x6f6c0fd7f2db0c3a0bff6b0c7b6b4ac9a62c3c0d1ee4ed5cbe2bc3d0d6b0c3f1"
+	require.NoError(t, db.Create(&transaction{Epoch: 7, Hash: hash, HashId: util.GetShortIdOfHash(hash)}).Error)
+
+	epochs, err := ts.loadTxEpochs([]string{hash, "0x01"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{hash: 7}, epochs)
+}
+
+func TestMigrateLogTxHashIdColumn(t *testing.T) {
+	db := newTestDB(t)
+
+	// event log table created before tx hash id introduced
+	require.NoError(t, db.Exec("CREATE TABLE `logs_0` (`id` integer PRIMARY KEY, `bn` integer NOT NULL)").Error)
+	require.NoError(t, db.Exec("INSERT INTO `logs_0` (`id`, `bn`) VALUES (1, 100)").Error)
+
+	tables := []string{"logs_0", "contracts"}
+	assert.Equal(t, []string{"logs_0"}, unmigratedLogTxHashIdTables(db, tables))
+
+	require.NoError(t, migrateLogTxHashIdColumn(db, tables))
+	assert.Empty(t, unmigratedLogTxHashIdTables(db, tables))
+
+	// existing event logs are left as 0
+	var txHashId uint64
+	require.NoError(t, db.Raw("SELECT tx_hash_id FROM `logs_0` WHERE id = 1").Row().Scan(&txHashId))
+	assert.Zero(t, txHashId)
+}
@@ -0,0 +1,394 @@
+package mysql
+
+import (
+	"bytes"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/types"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// number of hash functions of topic bloom
+	topicBloomHashes = 4
+)
+
+var (
+	bloomEncoder, _ = zstd.NewWriter(nil)
+	bloomDecoder, _ = zstd.NewReader(nil)
+)
+
+// TopicBloomConfig configurations of topic bloom index, which is used to pre-filter block ranges
+// that cannot match the topics of log filter without any contract address.
+//
+// Be noted the bucket size and bloom bits should never be changed once topic blooms are built.
+type TopicBloomConfig struct {
+	Enabled bool
+	// number of blocks per bloom bucket
+	BucketSize uint64 `default:"1000"`
+	// number of bits per bloom, which must be power of 2
+	BloomBits uint64 `default:"1048576"`
+}
+
+// topicBloom bloom of all the event log topics within a bucket of block range.
+type topicBloom struct {
+	ID     uint64
+	BnFrom uint64 `gorm:"column:bn_from;not null;uniqueIndex"`
+	Bloom  []byte `gorm:"type:MEDIUMBLOB;not null"` // zstd compressed bloom bits
+	// Continuous block range within the bucket indexed by the bloom, beyond which the bloom is
+	// not trusted, e.g., bloom enabled or popped in the middle of the bucket.
+	IndexedFrom uint64 `gorm:"not null;default:0"`
+	IndexedTo   uint64 `gorm:"not null;default:0"`
+}
+
+// indexed checks if the block range is fully indexed by the bloom.
+func (tb *topicBloom) indexed(r types.RangeUint64) bool {
+	return tb.IndexedFrom <= r.From && r.To <= tb.IndexedTo
+}
+
+// addIndexed adds the indexed block range, which is merged with the existing indexed block range
+// if overlapped or adjacent. Otherwise, only the longer one is kept, since the bloom is still the
+// superset for either of them.
+func (tb *topicBloom) addIndexed(r types.RangeUint64) {
+	if r.From <= tb.IndexedTo+1 && tb.IndexedFrom <= r.To+1 {
+		tb.IndexedFrom, tb.IndexedTo = min(tb.IndexedFrom, r.From), max(tb.IndexedTo, r.To)
+		return
+	}
+
+	if r.To-r.From > tb.IndexedTo-tb.IndexedFrom {
+		tb.IndexedFrom, tb.IndexedTo = r.From, r.To
+	}
+}
+
+func (topicBloom) TableName() string {
+	return "topic_blooms"
+}
+
+// bloomBits bloom bits of event log topics.
+type bloomBits []byte
+
+func newBloomBits(numBits uint64) bloomBits {
+	return make(bloomBits, numBits/8)
+}
+
+// positions returns bit positions of the topic by double hashing.
+func (b bloomBits) positions(topic string) [topicBloomHashes]uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(strings.ToLower(topic)))
+	h := hasher.Sum64()
+	h1, h2 := h&0xffffffff, h>>32|1
+
+	var result [topicBloomHashes]uint64
+	for i := range result {
+		result[i] = (h1 + uint64(i)*h2) & (uint64(len(b))*8 - 1)
+	}
+
+	return result
+}
+
+func (b bloomBits) add(topic string) {
+	for _, pos := range b.positions(topic) {
+		b[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+func (b bloomBits) test(topic string) bool {
+	for _, pos := range b.positions(topic) {
+		if b[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// merge merges other bloom bits of the same size, and returns whether any bit changed.
+func (b bloomBits) merge(other bloomBits) bool {
+	changed := false
+	for i := range b {
+		changed = changed || b[i]|other[i] != b[i]
+		b[i] |= other[i]
+	}
+
+	return changed
+}
+
+// testAny checks if any of the topics might be present, or true if no topic specified.
+func (b bloomBits) testAny(topics store.VariadicValue) bool {
+	if topics.IsNull() {
+		return true
+	}
+
+	for _, topic := range topics.ToSlice() {
+		if b.test(topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topicBloomStore stores topic blooms of event logs per bucket of block range.
+type topicBloomStore struct {
+	db     *gorm.DB
+	config *TopicBloomConfig
+
+	// encoded bloom without any topic
+	emptyBloom []byte
+
+	mu sync.Mutex
+	// decoded bloom bits of the latest saved bucket keyed by the encoded bloom, which saves decoding
+	// the same bucket over again when epochs are synchronized one batch after another.
+	cached struct {
+		bucket  uint64
+		encoded []byte
+		bits    bloomBits
+	}
+}
+
+func newTopicBloomStore(db *gorm.DB, config *TopicBloomConfig) *topicBloomStore {
+	return &topicBloomStore{
+		db:         db,
+		config:     config,
+		emptyBloom: bloomEncoder.EncodeAll(newBloomBits(config.BloomBits), nil),
+	}
+}
+
+func (tbs *topicBloomStore) bucket(bn uint64) uint64 {
+	return bn / tbs.config.BucketSize * tbs.config.BucketSize
+}
+
+// Add builds topic blooms for the epoch data and merges them into the existing topic blooms.
+//
+// To reduce the overhead, bloom bits are only re-encoded and saved for buckets with new topics,
+// while only the indexed block range is updated for the other buckets.
+//
+// Be noted bloom bits are not rolled back when chain reorg happens, which only results in more
+// false positives, while the indexed block range is shrunk by `Popn`.
+func (tbs *topicBloomStore) Add(dbTx *gorm.DB, dataSlice []*store.EpochData) error {
+	if len(dataSlice) == 0 {
+		return nil
+	}
+
+	bnFrom := dataSlice[0].Blocks[0].BlockNumber.ToInt().Uint64()
+	bnTo := dataSlice[len(dataSlice)-1].GetPivotBlock().BlockNumber.ToInt().Uint64()
+
+	// bucket => bloom bits, only for buckets with any event log topic
+	blooms := make(map[uint64]bloomBits)
+
+	for _, data := range dataSlice {
+		for _, block := range data.Blocks {
+			bucket := tbs.bucket(block.BlockNumber.ToInt().Uint64())
+
+			for _, tx := range block.Transactions {
+				receipt := data.Receipts[tx.Hash]
+
+				// Skip transactions that unexecuted in block.
+				if receipt == nil || !util.IsTxExecutedInBlock(&tx) {
+					continue
+				}
+
+				for _, rlog := range receipt.Logs {
+					for _, topic := range rlog.Topics {
+						if blooms[bucket] == nil {
+							blooms[bucket] = newBloomBits(tbs.config.BloomBits)
+						}
+
+						blooms[bucket].add(topic.String())
+					}
+				}
+			}
+		}
+	}
+
+	var existings []*topicBloom
+	err := dbTx.Where("bn_from BETWEEN ? AND ?", tbs.bucket(bnFrom), tbs.bucket(bnTo)).Find(&existings).Error
+	if err != nil {
+		return errors.WithMessage(err, "failed to get topic blooms")
+	}
+
+	bucket2Blooms := make(map[uint64]*topicBloom, len(existings))
+	for _, v := range existings {
+		bucket2Blooms[v.BnFrom] = v
+	}
+
+	tbs.mu.Lock()
+	defer tbs.mu.Unlock()
+
+	for bucket := tbs.bucket(bnFrom); bucket <= bnTo; bucket += tbs.config.BucketSize {
+		indexed := types.RangeUint64{
+			From: max(bucket, bnFrom),
+			To:   min(bucket+tbs.config.BucketSize-1, bnTo),
+		}
+
+		bits := blooms[bucket]
+
+		existing, ok := bucket2Blooms[bucket]
+		if !ok {
+			// always create bloom buckets even without any event log, so as to distinguish from
+			// block ranges that have never been indexed.
+			tb := topicBloom{BnFrom: bucket, Bloom: tbs.emptyBloom, IndexedFrom: indexed.From, IndexedTo: indexed.To}
+			if bits != nil {
+				tb.Bloom = bloomEncoder.EncodeAll(bits, nil)
+				tbs.cache(bucket, tb.Bloom, bits)
+			}
+
+			if err := dbTx.Create(&tb).Error; err != nil {
+				return errors.WithMessage(err, "failed to create topic bloom")
+			}
+
+			continue
+		}
+
+		existing.addIndexed(indexed)
+		updates := map[string]interface{}{
+			"indexed_from": existing.IndexedFrom,
+			"indexed_to":   existing.IndexedTo,
+		}
+
+		if bits != nil {
+			merged, err := tbs.decodeCached(bucket, existing.Bloom)
+			if err != nil {
+				return errors.WithMessagef(err, "failed to decode topic bloom of bucket %v", bucket)
+			}
+
+			if merged.merge(bits) {
+				encoded := bloomEncoder.EncodeAll(merged, nil)
+				tbs.cache(bucket, encoded, merged)
+				updates["bloom"] = encoded
+			}
+		}
+
+		if err := dbTx.Model(existing).Updates(updates).Error; err != nil {
+			return errors.WithMessage(err, "failed to update topic bloom")
+		}
+	}
+
+	return nil
+}
+
+// decodeCached decodes the encoded bloom of the bucket, or copies from cache if matched.
+func (tbs *topicBloomStore) decodeCached(bucket uint64, encoded []byte) (bloomBits, error) {
+	if tbs.cached.bits != nil && tbs.cached.bucket == bucket && bytes.Equal(tbs.cached.encoded, encoded) {
+		return slices.Clone(tbs.cached.bits), nil
+	}
+
+	return tbs.decode(encoded)
+}
+
+// cache caches the decoded bloom bits of the bucket. Be noted the cache is keyed by the encoded
+// bloom, so it's still safe even if the db transaction is rolled back.
+func (tbs *topicBloomStore) cache(bucket uint64, encoded []byte, bits bloomBits) {
+	tbs.cached.bucket, tbs.cached.encoded, tbs.cached.bits = bucket, encoded, slices.Clone(bits)
+}
+
+// Popn shrinks the indexed block range of topic blooms when event logs popped from the specified
+// block number, while the bloom bits are retained as false positives.
+func (tbs *topicBloomStore) Popn(dbTx *gorm.DB, bnFrom uint64) error {
+	// remove topic blooms indexed after the popped block number
+	if err := dbTx.Where("indexed_from >= ?", bnFrom).Delete(&topicBloom{}).Error; err != nil {
+		return errors.WithMessage(err, "failed to delete topic blooms")
+	}
+
+	if bnFrom == 0 { // all topic blooms removed
+		return nil
+	}
+
+	err := dbTx.Model(&topicBloom{}).Where("indexed_to >= ?", bnFrom).Update("indexed_to", bnFrom-1).Error
+	return errors.WithMessage(err, "failed to shrink indexed block range of topic blooms")
+}
+
+// Prune removes topic blooms of buckets fully covered until the specified block number, which is
+// used to prune topic blooms along with the pruned or archived event log partitions.
+func (tbs *topicBloomStore) Prune(bnUntil uint64) (int64, error) {
+	res := tbs.db.Where("bn_from < ?", tbs.bucket(bnUntil+1)).Delete(&topicBloom{})
+	return res.RowsAffected, res.Error
+}
+
+// candidateRanges returns the block ranges within the specified block range, which might match
+// the topics by topic blooms. Block ranges not fully indexed by topic bloom are always regarded as
+// candidates.
+func (tbs *topicBloomStore) candidateRanges(
+	bnFrom, bnTo uint64, topics []store.VariadicValue,
+) ([]types.RangeUint64, error) {
+	fullRange := []types.RangeUint64{{From: bnFrom, To: bnTo}}
+
+	hasTopics := false
+	for i := range topics {
+		hasTopics = hasTopics || !topics[i].IsNull()
+	}
+
+	if !hasTopics {
+		return fullRange, nil
+	}
+
+	var blooms []*topicBloom
+	err := tbs.db.Where("bn_from BETWEEN ? AND ?", tbs.bucket(bnFrom), tbs.bucket(bnTo)).Find(&blooms).Error
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get topic blooms")
+	}
+
+	bucket2Blooms := make(map[uint64]*topicBloom, len(blooms))
+	for _, v := range blooms {
+		bucket2Blooms[v.BnFrom] = v
+	}
+
+	var ranges []types.RangeUint64
+	for b := tbs.bucket(bnFrom); b <= bnTo; b += tbs.config.BucketSize {
+		r := types.RangeUint64{
+			From: max(b, bnFrom),
+			To:   min(b+tbs.config.BucketSize-1, bnTo),
+		}
+
+		if tb, ok := bucket2Blooms[b]; ok && tb.indexed(r) {
+			bits, err := tbs.decode(tb.Bloom)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to decode topic bloom of bucket %v", b)
+			}
+
+			if !tbs.test(bits, topics) {
+				continue
+			}
+		}
+
+		// merge with the previous consecutive range
+		if n := len(ranges); n > 0 && ranges[n-1].To+1 == r.From {
+			ranges[n-1].To = r.To
+		} else {
+			ranges = append(ranges, r)
+		}
+	}
+
+	return ranges, nil
+}
+
+// test checks if the topics of all positions might be present in the bloom.
+func (tbs *topicBloomStore) test(bits bloomBits, topics []store.VariadicValue) bool {
+	for i := range topics {
+		if !bits.testAny(topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (tbs *topicBloomStore) decode(data []byte) (bloomBits, error) {
+	bits, err := bloomDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(bits))*8 != tbs.config.BloomBits {
+		return nil, errors.Errorf("mismatched bloom size %v", len(bits)*8)
+	}
+
+	return bits, nil
+}
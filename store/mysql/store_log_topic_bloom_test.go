@@ -0,0 +1,167 @@
+package mysql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/types"
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testTopicA = "0x000000000000000000000000000000000000000000000000000000000000000a"
+	testTopicB = "0x000000000000000000000000000000000000000000000000000000000000000b"
+	testTopicC = "0x000000000000000000000000000000000000000000000000000000000000000c"
+)
+
+// newTestTopicBloomEpochs creates epoch data slice with one block per epoch, and event log topics
+// of the specified blocks.
+func newTestTopicBloomEpochs(bnFrom, bnTo uint64, bn2Topics map[uint64]string) []*store.EpochData {
+	var dataSlice []*store.EpochData
+
+	for bn := bnFrom; bn <= bnTo; bn++ {
+		hash := cfxtypes.Hash(fmt.Sprintf("0x%x", bn))
+		status := hexutil.Uint64(0)
+
+		receipt := &cfxtypes.TransactionReceipt{TransactionHash: hash}
+		if topic, ok := bn2Topics[bn]; ok {
+			receipt.Logs = append(receipt.Logs, cfxtypes.Log{Topics: []cfxtypes.Hash{cfxtypes.Hash(topic)}})
+		}
+
+		block := &cfxtypes.Block{Transactions: []cfxtypes.Transaction{{Hash: hash, BlockHash: &hash, Status: &status}}}
+		block.Hash, block.BlockNumber = hash, cfxtypes.NewBigInt(bn)
+
+		dataSlice = append(dataSlice, &store.EpochData{
+			Number:   bn,
+			Blocks:   []*cfxtypes.Block{block},
+			Receipts: map[cfxtypes.Hash]*cfxtypes.TransactionReceipt{hash: receipt},
+		})
+	}
+
+	return dataSlice
+}
+
+func assertTopicBloomCandidateRanges(
+	t *testing.T, tbs *topicBloomStore, bnFrom, bnTo uint64, topic string, expected ...types.RangeUint64,
+) {
+	ranges, err := tbs.candidateRanges(bnFrom, bnTo, []store.VariadicValue{store.NewVariadicValue(topic)})
+	require.NoError(t, err)
+	assert.Equal(t, expected, ranges, "topic %v within [%v, %v]", topic, bnFrom, bnTo)
+}
+
+func newTestTopicBloomStore(t *testing.T) (*gorm.DB, *topicBloomStore) {
+	db := newTestDB(t, &topicBloom{})
+	return db, newTopicBloomStore(db, &TopicBloomConfig{Enabled: true, BucketSize: 10, BloomBits: 1024})
+}
+
+func TestTopicBloomCandidateRanges(t *testing.T) {
+	db, tbs := newTestTopicBloomStore(t)
+
+	// topic bloom enabled in the middle of bucket
+	dataSlice := newTestTopicBloomEpochs(15, 34, map[uint64]string{17: testTopicA, 31: testTopicB})
+	require.NoError(t, tbs.Add(db, dataSlice[:10]))
+	require.NoError(t, tbs.Add(db, dataSlice[10:]))
+
+	// block ranges not fully indexed are always candidates
+	assertTopicBloomCandidateRanges(
+		t, tbs, 0, 39, testTopicA, types.RangeUint64{From: 0, To: 19}, types.RangeUint64{From: 30, To: 39},
+	)
+	assertTopicBloomCandidateRanges(t, tbs, 15, 29, testTopicA, types.RangeUint64{From: 15, To: 19})
+	assertTopicBloomCandidateRanges(t, tbs, 15, 34, testTopicB, types.RangeUint64{From: 30, To: 34})
+	assertTopicBloomCandidateRanges(t, tbs, 15, 34, testTopicC)
+
+	// backfill the beginning of bucket
+	require.NoError(t, tbs.Add(db, newTestTopicBloomEpochs(10, 14, nil)))
+	assertTopicBloomCandidateRanges(t, tbs, 0, 29, testTopicA, types.RangeUint64{From: 0, To: 19})
+	assertTopicBloomCandidateRanges(t, tbs, 10, 29, testTopicB)
+}
+
+func TestTopicBloomPopn(t *testing.T) {
+	db, tbs := newTestTopicBloomStore(t)
+
+	require.NoError(t, tbs.Add(db, newTestTopicBloomEpochs(20, 34, map[uint64]string{22: testTopicA, 31: testTopicB})))
+
+	// event logs popped, which are not indexed any more
+	require.NoError(t, tbs.Popn(db, 32))
+	assertTopicBloomCandidateRanges(t, tbs, 30, 31, testTopicC)
+	assertTopicBloomCandidateRanges(t, tbs, 30, 34, testTopicC, types.RangeUint64{From: 30, To: 34})
+
+	// topic blooms rebuilt after chain reorg
+	require.NoError(t, tbs.Popn(db, 25))
+	require.NoError(t, tbs.Add(db, newTestTopicBloomEpochs(25, 34, map[uint64]string{27: testTopicC})))
+
+	assertTopicBloomCandidateRanges(t, tbs, 20, 34, testTopicA, types.RangeUint64{From: 20, To: 29})
+	assertTopicBloomCandidateRanges(t, tbs, 20, 34, testTopicC, types.RangeUint64{From: 20, To: 29})
+	assertTopicBloomCandidateRanges(t, tbs, 20, 34, testTopicB)
+}
+
+func TestTopicBloomAddIndexed(t *testing.T) {
+	tb := &topicBloom{IndexedFrom: 15, IndexedTo: 19}
+
+	tb.addIndexed(types.RangeUint64{From: 10, To: 14}) // adjacent
+	assert.Equal(t, [2]uint64{10, 19}, [2]uint64{tb.IndexedFrom, tb.IndexedTo})
+
+	tb.addIndexed(types.RangeUint64{From: 12, To: 16}) // overlapped
+	assert.Equal(t, [2]uint64{10, 19}, [2]uint64{tb.IndexedFrom, tb.IndexedTo})
+
+	tb.addIndexed(types.RangeUint64{From: 25, To: 26}) // shorter range with gap
+	assert.Equal(t, [2]uint64{10, 19}, [2]uint64{tb.IndexedFrom, tb.IndexedTo})
+
+	tb.addIndexed(types.RangeUint64{From: 21, To: 39}) // longer range with gap
+	assert.Equal(t, [2]uint64{21, 39}, [2]uint64{tb.IndexedFrom, tb.IndexedTo})
+}
+
+func TestTopicBloomPopnAll(t *testing.T) {
+	db, tbs := newTestTopicBloomStore(t)
+
+	require.NoError(t, tbs.Add(db, newTestTopicBloomEpochs(0, 14, map[uint64]string{2: testTopicA})))
+	require.NoError(t, tbs.Popn(db, 0))
+
+	var count int64
+	require.NoError(t, db.Model(&topicBloom{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestTopicBloomAddIncrementally(t *testing.T) {
+	db, tbs := newTestTopicBloomStore(t)
+
+	dataSlice := newTestTopicBloomEpochs(10, 19, map[uint64]string{11: testTopicA, 13: testTopicB})
+	for i := range dataSlice {
+		require.NoError(t, tbs.Add(db, dataSlice[i:i+1]))
+	}
+
+	assertTopicBloomCandidateRanges(t, tbs, 10, 19, testTopicA, types.RangeUint64{From: 10, To: 19})
+	assertTopicBloomCandidateRanges(t, tbs, 10, 19, testTopicB, types.RangeUint64{From: 10, To: 19})
+	assertTopicBloomCandidateRanges(t, tbs, 10, 19, testTopicC)
+
+	// topic bloom changed by others, e.g., db transaction rolled back, which mismatches the cache
+	require.NoError(t, db.Model(&topicBloom{}).Where("bn_from = ?", 10).Update("bloom", tbs.emptyBloom).Error)
+	require.NoError(t, tbs.Add(db, newTestTopicBloomEpochs(15, 15, map[uint64]string{15: testTopicC})))
+
+	assertTopicBloomCandidateRanges(t, tbs, 10, 19, testTopicA)
+	assertTopicBloomCandidateRanges(t, tbs, 10, 19, testTopicC, types.RangeUint64{From: 10, To: 19})
+}
+
+func TestTopicBloomPrune(t *testing.T) {
+	db, tbs := newTestTopicBloomStore(t)
+
+	require.NoError(t, tbs.Add(db, newTestTopicBloomEpochs(5, 34, map[uint64]string{7: testTopicA})))
+
+	// bucket partially covered is retained
+	numPruned, err := tbs.Prune(18)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), numPruned)
+
+	numPruned, err = tbs.Prune(19)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), numPruned)
+
+	var buckets []uint64
+	require.NoError(t, db.Model(&topicBloom{}).Order("bn_from").Pluck("bn_from", &buckets).Error)
+	assert.Equal(t, []uint64{20, 30}, buckets)
+}
@@ -7,6 +7,7 @@ import (
 	citypes "github.com/Conflux-Chain/confura/types"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -161,6 +162,64 @@ func (e2bms *epochBlockMapStore) PivotHash(epoch uint64) (string, bool, error) {
 	return e2bmap.PivotHash, existed, nil
 }
 
+// PivotHashes returns the pivot hashes of the epochs within the given range, keyed by epoch number.
+func (e2bms *epochBlockMapStore) PivotHashes(epochFrom, epochTo uint64) (map[uint64]string, error) {
+	var e2bmaps []epochBlockMap
+
+	err := e2bms.db.Select("epoch", "pivot_hash").
+		Where("epoch BETWEEN ? AND ?", epochFrom, epochTo).
+		Find(&e2bmaps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	pivotHashes := make(map[uint64]string, len(e2bmaps))
+	for _, v := range e2bmaps {
+		pivotHashes[v.Epoch] = v.PivotHash
+	}
+
+	return pivotHashes, nil
+}
+
+// CheckPivotHashesForUpdate checks the pivot hashes of the epoch data slice against that of db store
+// within the db transaction, and locks the mappings until the transaction ends, so as to serialize
+// with the other transactions to push or pop epoch data. Any pivot switched epoch results in the
+// `store.ErrEpochPivotSwitched` error.
+func (e2bms *epochBlockMapStore) CheckPivotHashesForUpdate(dbTx *gorm.DB, dataSlice []*store.EpochData) error {
+	epochFrom, epochTo := dataSlice[0].Number, dataSlice[len(dataSlice)-1].Number
+
+	var mappings []*epochBlockMap
+	err := dbTx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("epoch BETWEEN ? AND ?", epochFrom, epochTo).
+		Find(&mappings).Error
+	if err != nil {
+		return errors.WithMessage(err, "failed to lock epoch to block mappings")
+	}
+
+	var maxEpoch sql.NullInt64
+	if err := dbTx.Model(&epochBlockMap{}).Select("MAX(epoch)").Find(&maxEpoch).Error; err != nil {
+		return errors.WithMessage(err, "failed to get max epoch")
+	}
+
+	if !maxEpoch.Valid || epochTo > uint64(maxEpoch.Int64) {
+		return errors.Errorf("epoch range [%v, %v] not synchronized yet", epochFrom, epochTo)
+	}
+
+	pivotHashes := make(map[uint64]string, len(mappings))
+	for _, m := range mappings {
+		pivotHashes[m.Epoch] = m.PivotHash
+	}
+
+	for _, data := range dataSlice {
+		pivotHash, ok := pivotHashes[data.Number]
+		if ok && pivotHash != data.GetPivotBlock().Hash.String() {
+			return errors.WithMessagef(store.ErrEpochPivotSwitched, "epoch %v", data.Number)
+		}
+	}
+
+	return nil
+}
+
 // Add batch saves epoch to block mapping data to db store.
 func (e2bms *epochBlockMapStore) Add(dbTx *gorm.DB, dataSlice []*store.EpochData) error {
 	var mappings []*epochBlockMap
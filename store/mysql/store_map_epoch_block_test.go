@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestPivotEpochData(epoch uint64, pivotHash cfxtypes.Hash) *store.EpochData {
+	return &store.EpochData{
+		Number: epoch,
+		Blocks: []*cfxtypes.Block{{BlockHeader: cfxtypes.BlockHeader{Hash: pivotHash}}},
+	}
+}
+
+func TestCheckPivotHashesForUpdate(t *testing.T) {
+	db := newTestDB(t, &epochBlockMap{})
+	require.NoError(t, db.Create([]*epochBlockMap{
+		{Epoch: 1, PivotHash: "0x01"},
+		{Epoch: 2, PivotHash: "0x02"},
+	}).Error)
+
+	e2bms := &epochBlockMapStore{}
+	check := func(dataSlice ...*store.EpochData) error {
+		return db.Transaction(func(dbTx *gorm.DB) error {
+			return e2bms.CheckPivotHashesForUpdate(dbTx, dataSlice)
+		})
+	}
+
+	assert.NoError(t, check(newTestPivotEpochData(1, "0x01"), newTestPivotEpochData(2, "0x02")))
+
+	// pivot switched
+	err := check(newTestPivotEpochData(1, "0x01"), newTestPivotEpochData(2, "0x0b"))
+	assert.ErrorIs(t, err, store.ErrEpochPivotSwitched)
+
+	// popped or not synchronized yet
+	require.NoError(t, db.Delete(&epochBlockMap{}, 2).Error)
+	assert.ErrorContains(t, check(newTestPivotEpochData(2, "0x02")), "not synchronized yet")
+}
+
+func TestPivotHashes(t *testing.T) {
+	db := newTestDB(t, &epochBlockMap{})
+	require.NoError(t, db.Create([]*epochBlockMap{
+		{Epoch: 1, PivotHash: "0x01"},
+		{Epoch: 2, PivotHash: "0x02"},
+		{Epoch: 4, PivotHash: "0x04"},
+	}).Error)
+
+	e2bms := &epochBlockMapStore{baseStore: newBaseStore(db)}
+
+	pivotHashes, err := e2bms.PivotHashes(2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64]string{2: "0x02", 4: "0x04"}, pivotHashes)
+}
@@ -41,8 +41,13 @@ func (sp *storePruner) observe() {
 }
 
 // schedulePrune periodically monitors and removes extra more than the max sepcified number of
-// archive bn partitions. Be noted this function will block caller thread.
-func (sp *storePruner) schedulePrune(config *Config) {
+// archive bn partitions, except for the entity whose retention policy is overridden. Be noted
+// this function will block caller thread.
+//
+// Optional `onPruned` callback will be invoked with the pruned partitions of the entity.
+func (sp *storePruner) schedulePrune(
+	config *Config, retentionOverridden func(entity string) bool, onPruned func(entity string, pruned []*bnPartition),
+) {
 	ticker := time.NewTicker(time.Minute * 15)
 	defer ticker.Stop()
 
@@ -51,6 +56,11 @@ func (sp *storePruner) schedulePrune(config *Config) {
 			entity := key.(string)
 			tabler := value.(schema.Tabler)
 
+			if retentionOverridden(entity) {
+				sp.bnPartitionObsEntitySet.Delete(entity)
+				return true
+			}
+
 			pruned, err := sp.partitionedStore.pruneArchivePartitions(
 				entity, tabler, config.MaxBnRangedArchiveLogPartitions,
 			)
@@ -63,6 +73,10 @@ func (sp *storePruner) schedulePrune(config *Config) {
 
 			if len(pruned) > 0 {
 				logger.WithField("prunedPartitions", pruned).Info("Archive partitions pruned")
+
+				if onPruned != nil {
+					onPruned(entity, pruned)
+				}
 			}
 
 			if err == nil {
@@ -0,0 +1,382 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// batch delete size to prune historical data
+	defaultBatchSizePruneDelete = 5000
+)
+
+var (
+	_ store.Dequeuable            = (*MysqlStore)(nil)
+	_ store.ContractLogDequeuable = (*MysqlStore)(nil)
+)
+
+// GetEpochTimestamp returns the timestamp of the pivot block for the specified epoch from the stored
+// block summaries, or false if not found (e.g., pruned already).
+func (ms *MysqlStore) GetEpochTimestamp(epoch uint64) (uint64, bool, error) {
+	summary, err := ms.blockStore.GetBlockSummaryByEpoch(context.Background(), epoch)
+	if ms.baseStore.IsRecordNotFound(err) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to get block summary")
+	}
+
+	if summary.CfxBlockSummary.Timestamp == nil {
+		return 0, false, errors.Errorf("no timestamp in block summary of epoch %v", epoch)
+	}
+
+	return summary.CfxBlockSummary.Timestamp.ToInt().Uint64(), true, nil
+}
+
+func (ms *MysqlStore) GetBlockEpochRange() (uint64, uint64, error) {
+	return ms.epochRange(&block{})
+}
+
+func (ms *MysqlStore) GetTransactionEpochRange() (uint64, uint64, error) {
+	return ms.epochRange(&transaction{})
+}
+
+// GetLogEpochRange returns the epoch range of the universal event logs, which is partitioned
+// by block number.
+func (ms *MysqlStore) GetLogEpochRange() (uint64, uint64, error) {
+	partitions, err := ms.ls.dataPartitions(bnPartitionedLogEntity)
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "failed to get data partitions")
+	}
+
+	if len(partitions) == 0 {
+		return 0, 0, store.ErrNotFound
+	}
+
+	// epoch of the oldest event log
+	var oldest log
+
+	tblName := ms.ls.getPartitionedTableName(&ms.ls.model, partitions[0].Index)
+	err = ms.baseStore.db.Table(tblName).Select("epoch").Where("bn = ?", partitions[0].BnMin.Int64).Take(&oldest).Error
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "failed to get the oldest event log")
+	}
+
+	maxEpoch, ok, err := ms.MaxEpoch()
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "failed to get max epoch")
+	}
+
+	if !ok {
+		return 0, 0, store.ErrNotFound
+	}
+
+	return oldest.Epoch, maxEpoch, nil
+}
+
+// onPartitionsPruned prunes the relative topic blooms once universal event log partitions pruned.
+func (ms *MysqlStore) onPartitionsPruned(entity string, pruned []*bnPartition) {
+	if entity == bnPartitionedLogEntity {
+		ms.pruneTopicBlooms(pruned)
+	}
+}
+
+// pruneTopicBlooms removes topic blooms of the buckets fully covered by the pruned universal event
+// log partitions, so that topic blooms will not grow without bound.
+func (ms *MysqlStore) pruneTopicBlooms(pruned []*bnPartition) {
+	if ms.tbs == nil {
+		return
+	}
+
+	var bnUntil int64 = -1
+	for _, partition := range pruned {
+		if partition.BnMax.Valid {
+			bnUntil = max(bnUntil, partition.BnMax.Int64)
+		}
+	}
+
+	if bnUntil < 0 { // no data pruned
+		return
+	}
+
+	logger := logrus.WithField("bnUntil", bnUntil)
+
+	numPruned, err := ms.tbs.Prune(uint64(bnUntil))
+	if err != nil {
+		logger.WithError(err).Error("Failed to prune topic blooms")
+	} else if numPruned > 0 {
+		logger.WithField("prunedBlooms", numPruned).Info("Topic blooms pruned")
+	}
+}
+
+// epochRange returns the epoch range of the epoch data model.
+func (ms *MysqlStore) epochRange(model interface{}) (uint64, uint64, error) {
+	var er struct {
+		Max, Min sql.NullInt64
+	}
+
+	if err := ms.baseStore.db.Model(model).Select("MAX(epoch) AS max, MIN(epoch) AS min").Find(&er).Error; err != nil {
+		return 0, 0, err
+	}
+
+	if !er.Max.Valid || !er.Min.Valid {
+		return 0, 0, store.ErrNotFound
+	}
+
+	return uint64(er.Min.Int64), uint64(er.Max.Int64), nil
+}
+
+// DequeueBlocks removes blocks until the specified epoch in batches.
+func (ms *MysqlStore) DequeueBlocks(epochUntil uint64) error {
+	query := ms.baseStore.db.Where("epoch <= ?", epochUntil)
+	_, err := deleteInBatches(query, &block{}, defaultBatchSizePruneDelete)
+	return err
+}
+
+// DequeueTransactions removes transactions until the specified epoch in batches.
+func (ms *MysqlStore) DequeueTransactions(epochUntil uint64) error {
+	query := ms.baseStore.db.Where("epoch <= ?", epochUntil)
+	_, err := deleteInBatches(query, &transaction{}, defaultBatchSizePruneDelete)
+	return err
+}
+
+// DequeueLogs removes the universal and address indexed event logs until the specified epoch. Be noted
+// universal event logs are removed by archive partitions, so event logs of the partition partially
+// covered by the specified epoch will be retained, and address indexed event logs of contracts with
+// retention policy overridden will be retained to be dequeued separately.
+func (ms *MysqlStore) DequeueLogs(epochUntil uint64) error {
+	bnRange, ok, err := ms.BlockRange(epochUntil)
+	if err != nil || !ok {
+		return errors.WithMessage(err, "failed to get block range of epoch")
+	}
+
+	pruned, err := ms.ls.pruneArchivePartitionsUntil(bnPartitionedLogEntity, &ms.ls.model, bnRange.To)
+	if len(pruned) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"epochUntil":       epochUntil,
+			"prunedPartitions": pruned,
+		}).Info("Universal event log partitions dequeued")
+
+		ms.pruneTopicBlooms(pruned)
+	}
+
+	if err != nil || !ms.config.AddressIndexedLogEnabled {
+		return err
+	}
+
+	overridden, err := ms.retentionOverriddenContracts()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get contracts with retention policy overridden")
+	}
+
+	excludedCids := make([]uint64, 0, len(overridden))
+	for _, contract := range overridden {
+		excludedCids = append(excludedCids, contract.ID)
+	}
+
+	numPruned, err := ms.ails.pruneAddressIndexedLogs(epochUntil, excludedCids)
+	if numPruned > 0 {
+		logrus.WithFields(logrus.Fields{
+			"epochUntil": epochUntil,
+			"prunedLogs": numPruned,
+		}).Info("Address indexed event logs dequeued")
+	}
+
+	return errors.WithMessage(err, "failed to prune address indexed event logs")
+}
+
+// GetDequeuableLogContracts returns all the big contracts which have separate contract log partitions,
+// and the contracts with retention policy overridden.
+func (ms *MysqlStore) GetDequeuableLogContracts() ([]string, error) {
+	if !ms.config.AddressIndexedLogEnabled {
+		return nil, nil
+	}
+
+	bigContracts, err := ms.bcls.bigContracts()
+	if err != nil {
+		return nil, err
+	}
+
+	overridden, err := ms.retentionOverriddenContracts()
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	dedup := make(map[uint64]bool)
+
+	for _, contract := range append(bigContracts, overridden...) {
+		if !dedup[contract.ID] {
+			dedup[contract.ID] = true
+			addresses = append(addresses, contract.Address)
+		}
+	}
+
+	return addresses, nil
+}
+
+// GetContractLogEpochRange returns the epoch range of event logs for the specified contract, which
+// are either stored separately if big contract or address indexed.
+func (ms *MysqlStore) GetContractLogEpochRange(contract string) (uint64, uint64, error) {
+	c, isBigContract, err := ms.getDequeuableLogContract(contract)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var minEpoch uint64
+	var ok bool
+
+	if isBigContract {
+		minEpoch, ok, err = ms.bcls.oldestContractLogEpoch(c.ID)
+	} else {
+		minEpoch, ok, err = ms.ails.oldestContractLogEpoch(c)
+	}
+
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "failed to get the oldest contract event log")
+	}
+
+	if !ok {
+		return 0, 0, store.ErrNotFound
+	}
+
+	maxEpoch, ok, err := ms.MaxEpoch()
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "failed to get max epoch")
+	}
+
+	if !ok {
+		return 0, 0, store.ErrNotFound
+	}
+
+	return minEpoch, maxEpoch, nil
+}
+
+// DequeueContractLogs removes event logs of the contract until the specified epoch. Be noted event logs
+// of big contract are removed by archive partitions, so event logs of the partition partially covered
+// by the specified epoch will be retained.
+func (ms *MysqlStore) DequeueContractLogs(contract string, epochUntil uint64) error {
+	c, isBigContract, err := ms.getDequeuableLogContract(contract)
+	if err != nil {
+		return err
+	}
+
+	if !isBigContract {
+		numPruned, err := ms.ails.pruneContractLogs(c, epochUntil)
+		if numPruned > 0 {
+			logrus.WithFields(logrus.Fields{
+				"contract":   contract,
+				"epochUntil": epochUntil,
+				"prunedLogs": numPruned,
+			}).Info("Address indexed contract event logs dequeued")
+		}
+
+		return err
+	}
+
+	bnRange, ok, err := ms.BlockRange(epochUntil)
+	if err != nil || !ok {
+		return errors.WithMessage(err, "failed to get block range of epoch")
+	}
+
+	pruned, err := ms.bcls.pruneContractLogs(c.ID, bnRange.To)
+	if len(pruned) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"contract":         contract,
+			"epochUntil":       epochUntil,
+			"prunedPartitions": pruned,
+		}).Info("Big contract event log partitions dequeued")
+	}
+
+	return err
+}
+
+// getDequeuableLogContract returns the contract by address, and whether it's big contract.
+func (ms *MysqlStore) getDequeuableLogContract(address string) (*Contract, bool, error) {
+	cid, ok, err := ms.cs.GetContractIdByAddress(address)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to get contract id")
+	}
+
+	if !ok {
+		return nil, false, errors.WithMessage(store.ErrNotFound, "contract not found")
+	}
+
+	contract, ok, err := ms.cs.GetContractById(cid)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to get contract by id")
+	}
+
+	if !ok {
+		return nil, false, errors.WithMessage(store.ErrNotFound, "contract not found")
+	}
+
+	isBigContract, err := ms.bcls.IsBigContract(cid)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to check big contract")
+	}
+
+	return contract, isBigContract, nil
+}
+
+// retentionOverriddenContracts returns the existing contracts with retention policy overridden.
+func (ms *MysqlStore) retentionOverriddenContracts() ([]*Contract, error) {
+	if ms.retention == nil {
+		return nil, nil
+	}
+
+	var contracts []*Contract
+	for _, crc := range ms.retention.Contracts {
+		cid, ok, err := ms.cs.GetContractIdByAddress(crc.Address)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get contract id")
+		}
+
+		if !ok {
+			continue
+		}
+
+		contract, ok, err := ms.cs.GetContractById(cid)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get contract by id")
+		}
+
+		if ok {
+			contracts = append(contracts, contract)
+		}
+	}
+
+	return contracts, nil
+}
+
+// retentionOverridden checks if the retention policy of the partitioned entity is overridden,
+// in which case the entity partitions should not be pruned by count.
+func (ms *MysqlStore) retentionOverridden(entity string) bool {
+	if ms.retention == nil {
+		return false
+	}
+
+	cid, ok := ms.bcls.parseContractEntity(entity)
+	if !ok {
+		return false
+	}
+
+	address, ok, err := ms.cs.GetContractAddressById(cid)
+	if err != nil {
+		logrus.WithField("entity", entity).WithError(err).Error("Failed to get contract address by id")
+		// avoid pruning unexpectedly
+		return true
+	}
+
+	if !ok {
+		return false
+	}
+
+	_, overridden := ms.retention.ContractLogMaxAge(address)
+	return overridden
+}
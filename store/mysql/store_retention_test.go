@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"math/big"
+	"testing"
+
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMysqlStoreWithBlocks(t *testing.T, numEpochs uint64) *MysqlStore {
+	db := newTestDB(t, &block{})
+	bs := newBlockStore(db)
+
+	var blocks []*block
+	for epoch := uint64(0); epoch < numEpochs; epoch++ {
+		hash := cfxtypes.Hash(common.BigToHash(new(big.Int).SetUint64(epoch + 1)).Hex())
+		data := &cfxtypes.Block{BlockHeader: cfxtypes.BlockHeader{
+			Hash:        hash,
+			EpochNumber: (*hexutil.Big)(new(big.Int).SetUint64(epoch)),
+			BlockNumber: (*hexutil.Big)(new(big.Int).SetUint64(epoch)),
+			Timestamp:   (*hexutil.Big)(new(big.Int).SetUint64(1000 + epoch)),
+			Miner:       cfxaddress.MustNewFromHex("0x1000000000000000000000000000000000000000", 1),
+		}}
+
+		blocks = append(blocks, newBlock(data, true, nil))
+	}
+
+	require.NoError(t, db.Create(blocks).Error)
+
+	return &MysqlStore{baseStore: newBaseStore(db), blockStore: bs}
+}
+
+func TestGetEpochTimestamp(t *testing.T) {
+	ms := newTestMysqlStoreWithBlocks(t, 10)
+
+	timestamp, ok, err := ms.GetEpochTimestamp(5)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1005), timestamp)
+
+	require.NoError(t, ms.DequeueBlocks(5))
+
+	// block summary pruned already
+	_, ok, err = ms.GetEpochTimestamp(5)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDeleteInBatches(t *testing.T) {
+	ms := newTestMysqlStoreWithBlocks(t, 10)
+
+	numDeleted, err := deleteInBatches(ms.baseStore.db.Where("epoch <= ?", 6), &block{}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), numDeleted)
+
+	var remaining []uint64
+	require.NoError(t, ms.baseStore.db.Model(&block{}).Order("epoch").Pluck("epoch", &remaining).Error)
+	assert.Equal(t, []uint64{7, 8, 9}, remaining)
+}
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB creates in-memory sqlite db with tables of the specified models for testing.
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	dsn := fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	require.NoError(t, db.AutoMigrate(models...))
+
+	return db
+}
+
+// createTestPartitionTables creates the partitioned tables of the model without any index, since
+// index names are global in sqlite and would conflict among the partitioned tables.
+func createTestPartitionTables(t *testing.T, db *gorm.DB, model interface{}, tables ...string) {
+	// create table in a scratch db to get the table definition only
+	dsn := fmt.Sprintf("file:%v_ddl?mode=memory", t.Name())
+	scratch, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	require.NoError(t, scratch.Table(tables[0]).Migrator().CreateTable(model))
+
+	var ddl string
+	err = scratch.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", tables[0]).Row().Scan(&ddl)
+	require.NoError(t, err)
+
+	for _, table := range tables {
+		sql := strings.Replace(ddl, "`"+tables[0]+"`", "`"+table+"`", 1)
+		require.NoError(t, db.Exec(sql).Error)
+	}
+}
+
+func newTestBnPartition(entity string, index uint32, bnMin, bnMax int64) *bnPartition {
+	return &bnPartition{
+		Entity: entity,
+		Index:  index,
+		BnMin:  sql.NullInt64{Int64: bnMin, Valid: true},
+		BnMax:  sql.NullInt64{Int64: bnMax, Valid: true},
+	}
+}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"math/big"
+	"strings"
 
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/util"
@@ -112,6 +113,27 @@ func (ts *txStore) loadTx(txHash types.Hash) (*transaction, error) {
 	return &tx, nil
 }
 
+// loadTxEpochs returns the epochs of the specified transactions by hash, which are absent if not found.
+func (ts *txStore) loadTxEpochs(txHashes []string) (map[string]uint64, error) {
+	hashIds := make([]uint64, 0, len(txHashes))
+	for _, hash := range txHashes {
+		hashIds = append(hashIds, util.GetShortIdOfHash(hash))
+	}
+
+	var txs []transaction
+	err := ts.db.Select("hash", "epoch").Where("hash_id IN (?) AND hash IN (?)", hashIds, txHashes).Find(&txs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	epochs := make(map[string]uint64, len(txs))
+	for _, tx := range txs {
+		epochs[strings.ToLower(tx.Hash)] = tx.Epoch
+	}
+
+	return epochs, nil
+}
+
 func (ts *txStore) GetTransaction(ctx context.Context, txHash types.Hash) (*store.Transaction, error) {
 	tx, err := ts.loadTx(txHash)
 	if err != nil {
@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VirtualFilter durable virtual filter registration, so that the virtual filter survives restart
+// and could be served by any virtual filter service instance.
+type VirtualFilter struct {
+	// virtual filter ID
+	ID string `gorm:"primaryKey;size:128"`
+	// filter type, eg., log filter, block filter or pending txn filter
+	Type uint8 `gorm:"not null"`
+	// delegate full node url
+	NodeUrl string `gorm:"size:256;not null"`
+	// json representation of log filter criteria
+	Crit string `gorm:"type:text"`
+	// block (or epoch) number up to which filter changes have been delivered
+	Cursor uint64 `gorm:"not null;default:0"`
+	// owner identity for admission control, or empty if admitted without quota
+	Owner string `gorm:"size:128;not null;default:'';index"`
+	// max number of buffered logs of the owner quota, or unlimited if zero
+	MaxLogs int `gorm:"not null;default:0"`
+	// last polling time
+	PolledAt time.Time `gorm:"not null;index"`
+}
+
+func (VirtualFilter) TableName() string {
+	return "virtual_filters"
+}
+
+// VirtualFilterMember virtual filter service instance which shards virtual filters with others.
+type VirtualFilterMember struct {
+	// exposed RPC url of the service instance
+	Url string `gorm:"primaryKey;size:256"`
+	// last heartbeat time
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+func (VirtualFilterMember) TableName() string {
+	return "virtual_filter_members"
+}
+
+type VirtualFilterStore struct {
+	*baseStore
+}
+
+func NewVirtualFilterStore(db *gorm.DB) *VirtualFilterStore {
+	return &VirtualFilterStore{baseStore: newBaseStore(db)}
+}
+
+// SaveVirtualFilter creates or overwrites the virtual filter registration.
+func (vfs *VirtualFilterStore) SaveVirtualFilter(vf *VirtualFilter) error {
+	return vfs.db.Save(vf).Error
+}
+
+func (vfs *VirtualFilterStore) GetVirtualFilter(id string) (*VirtualFilter, error) {
+	var res VirtualFilter
+
+	exists, err := vfs.exists(&res, "id = ?", id)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (vfs *VirtualFilterStore) DeleteVirtualFilter(id string) (bool, error) {
+	res := vfs.db.Delete(&VirtualFilter{}, "id = ?", id)
+	return res.RowsAffected > 0, res.Error
+}
+
+// TouchVirtualFilter updates the cursor and last polling time of the virtual filter.
+func (vfs *VirtualFilterStore) TouchVirtualFilter(id string, cursor uint64) error {
+	return vfs.db.Model(&VirtualFilter{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"cursor": cursor, "polled_at": time.Now()}).
+		Error
+}
+
+// SetVirtualFilterQuota updates the owner and max number of buffered logs of the virtual filter.
+func (vfs *VirtualFilterStore) SetVirtualFilterQuota(id, owner string, maxLogs int) error {
+	return vfs.db.Model(&VirtualFilter{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"owner": owner, "max_logs": maxLogs}).
+		Error
+}
+
+// CountVirtualFiltersByOwner returns the number of the virtual filters of the owner.
+func (vfs *VirtualFilterStore) CountVirtualFiltersByOwner(owner string) (count int64, err error) {
+	err = vfs.db.Model(&VirtualFilter{}).Where("owner = ?", owner).Count(&count).Error
+	return count, err
+}
+
+// ExpireVirtualFilters deletes virtual filters not polled since the specified time.
+func (vfs *VirtualFilterStore) ExpireVirtualFilters(before time.Time) (int64, error) {
+	res := vfs.db.Delete(&VirtualFilter{}, "polled_at < ?", before)
+	return res.RowsAffected, res.Error
+}
+
+// HeartbeatVirtualFilterMember registers or refreshes the virtual filter service instance.
+func (vfs *VirtualFilterStore) HeartbeatVirtualFilterMember(url string) error {
+	member := VirtualFilterMember{Url: url, UpdatedAt: time.Now()}
+
+	return vfs.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&member).Error
+}
+
+// GetVirtualFilterMembers returns the urls of the virtual filter service instances heartbeated
+// since the specified time.
+func (vfs *VirtualFilterStore) GetVirtualFilterMembers(since time.Time) (res []string, err error) {
+	err = vfs.db.Model(&VirtualFilterMember{}).
+		Where("updated_at >= ?", since).
+		Order("url").
+		Pluck("url", &res).
+		Error
+
+	return res, err
+}
@@ -0,0 +1,246 @@
+package mysql
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// pre-defined webhook config key prefix
+	WebhookConfKeyPrefix   = "webhook."
+	webhookSqlMatchPattern = WebhookConfKeyPrefix + "%"
+)
+
+// Webhook webhook registration to push matched event logs to the callback url.
+type Webhook struct {
+	ID     uint32          `json:"-"`      // webhook ID
+	Name   string          `json:"-"`      // webhook name
+	Url    string          `json:"url"`    // callback url
+	Secret string          `json:"secret"` // secret to sign payload
+	Filter json.RawMessage `json:"filter"` // log filter of the network space
+}
+
+func (cs *confStore) StoreWebhook(hook *Webhook) error {
+	cfgVal, err := json.Marshal(hook)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal webhook")
+	}
+
+	return cs.StoreConfig(WebhookConfKeyPrefix+hook.Name, string(cfgVal))
+}
+
+func (cs *confStore) DelWebhook(name string) (bool, error) {
+	return cs.DeleteConfig(WebhookConfKeyPrefix + name)
+}
+
+// LoadWebhooks loads webhooks of the specified names, or all webhooks if no name specified.
+func (cs *confStore) LoadWebhooks(names ...string) (res map[string]*Webhook, err error) {
+	var cfgs []conf
+
+	if len(names) == 0 {
+		err = cs.db.Where("name LIKE ?", webhookSqlMatchPattern).Find(&cfgs).Error
+	} else {
+		var confKeys []string
+		for _, name := range names {
+			confKeys = append(confKeys, WebhookConfKeyPrefix+name)
+		}
+
+		err = cs.db.Where("name IN (?)", confKeys).Find(&cfgs).Error
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res = make(map[string]*Webhook)
+
+	// decode webhook from config item
+	for _, v := range cfgs {
+		hook, err := cs.decodeWebhook(v)
+		if err != nil {
+			logrus.WithField("cfg", v).WithError(err).Warn("Invalid webhook config")
+			continue
+		}
+
+		res[hook.Name] = hook
+	}
+
+	return res, nil
+}
+
+func (cs *confStore) decodeWebhook(cfg conf) (*Webhook, error) {
+	// eg., webhook.uniswap
+	name := cfg.Name[len(WebhookConfKeyPrefix):]
+	if len(name) == 0 {
+		return nil, errors.New("webhook name is too short")
+	}
+
+	hook := Webhook{ID: cfg.ID, Name: name}
+	if err := json.Unmarshal([]byte(cfg.Value), &hook); err != nil {
+		return nil, err
+	}
+
+	return &hook, nil
+}
+
+// WebhookDeadLetter webhook delivery that failed after all attempts, which could be redelivered
+// manually later.
+type WebhookDeadLetter struct {
+	ID         uint64
+	Webhook    string `gorm:"size:128;not null;index"`
+	DeliveryID string `gorm:"size:256;not null"`
+	Payload    string `gorm:"type:mediumText;not null"`
+	Error      string `gorm:"size:1024;not null"`
+	Attempts   int    `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+func (WebhookDeadLetter) TableName() string {
+	return "webhook_dead_letters"
+}
+
+func (ms *MysqlStore) AddWebhookDeadLetter(letter *WebhookDeadLetter) error {
+	if len(letter.Error) > 1024 {
+		letter.Error = letter.Error[:1024]
+	}
+
+	return ms.baseStore.db.Create(letter).Error
+}
+
+// GetWebhookDeadLetters returns the latest dead letters of the specified webhook, or of all
+// webhooks if webhook name is empty.
+func (ms *MysqlStore) GetWebhookDeadLetters(webhook string, limit int) (res []*WebhookDeadLetter, err error) {
+	db := ms.baseStore.db.Order("id DESC").Limit(limit)
+	if len(webhook) > 0 {
+		db = db.Where("webhook = ?", webhook)
+	}
+
+	err = db.Find(&res).Error
+	return res, err
+}
+
+func (ms *MysqlStore) GetWebhookDeadLetter(id uint64) (*WebhookDeadLetter, bool, error) {
+	var letter WebhookDeadLetter
+
+	exists, err := ms.baseStore.exists(&letter, "id = ?", id)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+
+	return &letter, true, nil
+}
+
+func (ms *MysqlStore) DelWebhookDeadLetter(id uint64) (bool, error) {
+	res := ms.baseStore.db.Delete(&WebhookDeadLetter{}, id)
+	return res.RowsAffected > 0, res.Error
+}
+
+// WebhookWindow payloads delivered within a recent epoch, which are persisted so that they could be
+// retracted on chain reorg even after restart.
+type WebhookWindow struct {
+	ID       uint64
+	Space    string `gorm:"size:32;not null;index:idx_space_epoch,priority:1"`
+	Epoch    uint64 `gorm:"not null;index:idx_space_epoch,priority:2"`
+	Payloads string `gorm:"type:mediumText;not null"` // json encoded payloads
+}
+
+func (WebhookWindow) TableName() string {
+	return "webhook_windows"
+}
+
+// SaveWebhookWindowsWithTx replaces the delivered payloads of the window epochs in ascending order
+// within the specified db transaction, and evicts payloads of the epochs out of the retract window
+// size at the same time. Payloads of the window epochs are removed only if empty.
+func (ms *MysqlStore) SaveWebhookWindowsWithTx(
+	dbTx *gorm.DB, space string, windows []*WebhookWindow, windowSize uint64,
+) error {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	epochs := make([]uint64, 0, len(windows))
+	for _, w := range windows {
+		epochs = append(epochs, w.Epoch)
+	}
+
+	err := dbTx.
+		Where("space = ? AND (epoch IN (?) OR epoch + ? <= ?)", space, epochs, windowSize, epochs[len(epochs)-1]).
+		Delete(&WebhookWindow{}).
+		Error
+	if err != nil {
+		return err
+	}
+
+	var nonEmpties []*WebhookWindow
+	for _, w := range windows {
+		if len(w.Payloads) > 0 {
+			nonEmpties = append(nonEmpties, w)
+		}
+	}
+
+	if len(nonEmpties) == 0 {
+		return nil
+	}
+
+	return dbTx.Create(nonEmpties).Error
+}
+
+// PopWebhookWindowsWithTx removes and returns the delivered payloads of the space since the specified
+// epoch in descending order within the specified db transaction.
+func (ms *MysqlStore) PopWebhookWindowsWithTx(dbTx *gorm.DB, space string, epochFrom uint64) ([]*WebhookWindow, error) {
+	var windows []*WebhookWindow
+
+	db := dbTx.Where("space = ? AND epoch >= ?", space, epochFrom)
+	if err := db.Order("epoch DESC").Find(&windows).Error; err != nil {
+		return nil, err
+	}
+
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	if err := db.Delete(&WebhookWindow{}).Error; err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// WebhookOutbox payload to deliver, which is staged within the db transaction to store (or revert)
+// epoch data, and removed once delivered or dead-lettered.
+type WebhookOutbox struct {
+	ID         uint64
+	Space      string `gorm:"size:32;not null;index"`
+	Webhook    string `gorm:"size:128;not null"`
+	DeliveryID string `gorm:"size:256;not null"`
+	Payload    string `gorm:"type:mediumText;not null"` // json encoded payload
+	CreatedAt  time.Time
+}
+
+func (WebhookOutbox) TableName() string {
+	return "webhook_outboxes"
+}
+
+// AddWebhookOutboxesWithTx stages payloads to deliver within the specified db transaction.
+func (ms *MysqlStore) AddWebhookOutboxesWithTx(dbTx *gorm.DB, outboxes []*WebhookOutbox) error {
+	if len(outboxes) == 0 {
+		return nil
+	}
+
+	return dbTx.Create(outboxes).Error
+}
+
+// GetWebhookOutboxes returns the staged payloads of the specified space after the id in order.
+func (ms *MysqlStore) GetWebhookOutboxes(space string, idAfter uint64, limit int) (res []*WebhookOutbox, err error) {
+	err = ms.baseStore.db.Where("space = ? AND id > ?", space, idAfter).Order("id ASC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// DelWebhookOutbox removes the staged payload once delivered or dead-lettered.
+func (ms *MysqlStore) DelWebhookOutbox(id uint64) error {
+	return ms.baseStore.db.Delete(&WebhookOutbox{}, id).Error
+}
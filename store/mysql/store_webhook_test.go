@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookWindows(t *testing.T) {
+	db := newTestDB(t, &WebhookWindow{})
+	ms := &MysqlStore{}
+
+	var windows []*WebhookWindow
+	for epoch := uint64(1); epoch <= 5; epoch++ {
+		windows = append(windows, &WebhookWindow{Space: "cfx", Epoch: epoch, Payloads: "[]"})
+	}
+	require.NoError(t, ms.SaveWebhookWindowsWithTx(db, "cfx", windows, 10))
+
+	// payloads out of the retract window are evicted, and empty payloads are removed
+	require.NoError(t, ms.SaveWebhookWindowsWithTx(db, "cfx", []*WebhookWindow{
+		{Space: "cfx", Epoch: 5}, {Space: "cfx", Epoch: 12, Payloads: "[]"},
+	}, 10))
+
+	var epochs []uint64
+	require.NoError(t, db.Model(&WebhookWindow{}).Order("epoch").Pluck("epoch", &epochs).Error)
+	assert.Equal(t, []uint64{3, 4, 12}, epochs)
+
+	popped, err := ms.PopWebhookWindowsWithTx(db, "cfx", 4)
+	require.NoError(t, err)
+	require.Len(t, popped, 2)
+	assert.Equal(t, uint64(12), popped[0].Epoch)
+	assert.Equal(t, uint64(4), popped[1].Epoch)
+
+	require.NoError(t, db.Model(&WebhookWindow{}).Pluck("epoch", &epochs).Error)
+	assert.Equal(t, []uint64{3}, epochs)
+}
@@ -292,6 +292,23 @@ func (rs *RedisStore) Popn(epochUntil uint64) error {
 	return err
 }
 
+func (rs *RedisStore) GetEpochTimestamp(epoch uint64) (uint64, bool, error) {
+	summary, err := rs.GetBlockSummaryByEpoch(rs.ctx, epoch)
+	if rs.IsRecordNotFound(err) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	if summary.CfxBlockSummary.Timestamp == nil {
+		return 0, false, errors.Errorf("no timestamp in block summary of epoch %v", epoch)
+	}
+
+	return summary.CfxBlockSummary.Timestamp.ToInt().Uint64(), true, nil
+}
+
 func (rs *RedisStore) DequeueBlocks(epochUntil uint64) error {
 	return rs.dequeueEpochRangeData(store.EpochBlock, epochUntil)
 }
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// RetentionConfig retention policy to prune historical data by age, where zero age means
+// to keep full history.
+type RetentionConfig struct {
+	// interval to run pruning by retention policy
+	Interval time.Duration `default:"10m"`
+	// max epochs to prune at a time in case of IO hogging
+	MaxPruneEpochs uint64 `default:"1000"`
+
+	Blocks time.Duration // max age of blocks to retain
+	Txs    time.Duration // max age of transactions to retain
+	Logs   time.Duration // max age of event logs to retain
+
+	// per contract overrides to retain event logs, which take effect for event logs indexed by
+	// contract address (eg., address indexed or big contract event logs), while the universal
+	// event logs are always retained by the max age of event logs.
+	Contracts []ContractRetentionConfig
+}
+
+// ContractRetentionConfig retention policy to prune event logs of some contract.
+type ContractRetentionConfig struct {
+	Address string
+	Logs    time.Duration // max age of event logs to retain
+}
+
+// Enabled checks if any data retention policy is configured.
+func (rc *RetentionConfig) Enabled() bool {
+	if rc.Blocks > 0 || rc.Txs > 0 || rc.Logs > 0 {
+		return true
+	}
+
+	for i := range rc.Contracts {
+		if rc.Contracts[i].Logs > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaxAge returns the max age of the specified epoch data type to retain.
+func (rc *RetentionConfig) MaxAge(dt EpochDataType) time.Duration {
+	switch dt {
+	case EpochBlock:
+		return rc.Blocks
+	case EpochTransaction:
+		return rc.Txs
+	case EpochLog:
+		return rc.Logs
+	}
+
+	return 0
+}
+
+// ContractLogMaxAge returns the max age of event logs to retain for the specified contract,
+// or false if no override configured for the contract.
+func (rc *RetentionConfig) ContractLogMaxAge(contract string) (time.Duration, bool) {
+	for i := range rc.Contracts {
+		if strings.EqualFold(rc.Contracts[i].Address, contract) {
+			return rc.Contracts[i].Logs, true
+		}
+	}
+
+	return 0, false
+}
+
+// SearchEpochByTimestamp binary searches the max epoch within the specified epoch range, of which
+// the timestamp is no later than the specified timestamp. It assumes the timestamp of epochs is
+// non-decreasing, and returns false if no such epoch found.
+func SearchEpochByTimestamp(
+	epochFrom, epochTo, timestamp uint64, epochTimestamp func(epoch uint64) (uint64, error),
+) (uint64, bool, error) {
+	if epochFrom > epochTo {
+		return 0, false, nil
+	}
+
+	ts, err := epochTimestamp(epochFrom)
+	if err != nil || ts > timestamp {
+		return 0, false, err
+	}
+
+	// invariant: timestamp of `low` epoch is no later than the specified timestamp
+	low, high := epochFrom, epochTo
+	for low < high {
+		mid := low + (high-low+1)/2
+
+		if ts, err = epochTimestamp(mid); err != nil {
+			return 0, false, err
+		}
+
+		if ts <= timestamp {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return low, true, nil
+}
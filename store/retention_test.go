@@ -0,0 +1,55 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEpochByTimestamp(t *testing.T) {
+	// timestamp of epoch N is 10N
+	epochTimestamp := func(epoch uint64) (uint64, error) {
+		return epoch * 10, nil
+	}
+
+	testCases := []struct {
+		epochFrom, epochTo, timestamp uint64
+		expected                      uint64
+		found                         bool
+	}{
+		{epochFrom: 0, epochTo: 100, timestamp: 555, expected: 55, found: true},
+		{epochFrom: 50, epochTo: 100, timestamp: 550, expected: 55, found: true},
+		{epochFrom: 50, epochTo: 100, timestamp: 5000, expected: 100, found: true},
+		{epochFrom: 60, epochTo: 100, timestamp: 550, found: false},
+		{epochFrom: 100, epochTo: 50, timestamp: 550, found: false},
+	}
+
+	for _, tc := range testCases {
+		epoch, ok, err := SearchEpochByTimestamp(tc.epochFrom, tc.epochTo, tc.timestamp, epochTimestamp)
+		require.NoError(t, err)
+		assert.Equal(t, tc.found, ok)
+		assert.Equal(t, tc.expected, epoch)
+	}
+
+	_, _, err := SearchEpochByTimestamp(0, 100, 555, func(uint64) (uint64, error) {
+		return 0, errors.New("unavailable")
+	})
+	assert.Error(t, err)
+}
+
+func TestRetentionConfigContractLogMaxAge(t *testing.T) {
+	rc := RetentionConfig{
+		Logs:      time.Hour,
+		Contracts: []ContractRetentionConfig{{Address: "CFX:AAA", Logs: 0}},
+	}
+
+	maxAge, ok := rc.ContractLogMaxAge("cfx:aaa")
+	assert.True(t, ok)
+	assert.Zero(t, maxAge)
+
+	_, ok = rc.ContractLogMaxAge("cfx:bbb")
+	assert.False(t, ok)
+}
@@ -17,13 +17,22 @@ var (
 
 // Prunable is used to prune historical data.
 type Prunable interface {
-	GetBlockEpochRange() (uint64, uint64, error)
-	GetTransactionEpochRange() (uint64, uint64, error)
-	GetLogEpochRange() (uint64, uint64, error)
+	Dequeuable
 
 	GetNumBlocks() (uint64, error)
 	GetNumTransactions() (uint64, error)
 	GetNumLogs() (uint64, error)
+}
+
+// Dequeuable is used to remove historical data from the oldest epoch.
+type Dequeuable interface {
+	GetBlockEpochRange() (uint64, uint64, error)
+	GetTransactionEpochRange() (uint64, uint64, error)
+	GetLogEpochRange() (uint64, uint64, error)
+
+	// GetEpochTimestamp returns the timestamp of the pivot block for the specified epoch from the
+	// stored block summaries, or false if not found (e.g., pruned already).
+	GetEpochTimestamp(epoch uint64) (uint64, bool, error)
 
 	// DequeueBlocks removes epoch blocks from the store like dequeuing a queue,
 	// which is deleting data from the oldest epoch to some new epoch
@@ -36,6 +45,18 @@ type Prunable interface {
 	DequeueLogs(epochUntil uint64) error
 }
 
+// ContractLogDequeuable is used to remove historical event logs of contracts separately.
+type ContractLogDequeuable interface {
+	// GetDequeuableLogContracts returns all the contracts whose event logs could be dequeued separately,
+	// e.g., contracts whose event logs are stored separately or with retention policy overridden.
+	GetDequeuableLogContracts() ([]string, error)
+	// GetContractLogEpochRange returns the epoch range of event logs for the specified contract.
+	GetContractLogEpochRange(contract string) (uint64, uint64, error)
+	// DequeueContractLogs removes event logs of the specified contract from the oldest epoch
+	// to some new epoch.
+	DequeueContractLogs(contract string, epochUntil uint64) error
+}
+
 // Readable is used for RPC to read cached data from database.
 type Readable interface {
 	GetLogs(ctx context.Context, filter LogFilter) ([]*Log, error)
@@ -52,6 +73,12 @@ type Readable interface {
 	GetBlockSummaryByBlockNumber(ctx context.Context, blockNumber uint64) (*BlockSummary, error)
 }
 
+// TxLogReadable is used for RPC to read event logs of transactions from database.
+type TxLogReadable interface {
+	// GetLogsByTxHashes returns event logs emitted by the specified transactions.
+	GetLogsByTxHashes(ctx context.Context, txHashes []string) ([]*Log, error)
+}
+
 type Configurable interface {
 	// LoadConfig load configurations with specified names
 	LoadConfig(confNames ...string) (map[string]interface{}, error)
@@ -108,6 +135,8 @@ type storeConfig struct {
 	// disabled store chain data types, available options are:
 	// `block`, `transaction`, `receipt` and `log`
 	Disables []string `default:"[block,transaction,receipt]"`
+	// retention policy to prune historical data by age
+	Retention RetentionConfig
 
 	disabledDataTypeMapping map[string]bool
 }
@@ -123,3 +123,12 @@ func (vv *VariadicValue) FlatMultiple() ([]string, bool) {
 
 	return result, true
 }
+
+// Contains checks if the specified value is included, and null value contains nothing.
+func (vv *VariadicValue) Contains(v string) bool {
+	if vv.count == 1 {
+		return vv.single == v
+	}
+
+	return vv.multiple[v]
+}
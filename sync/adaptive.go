@@ -0,0 +1,181 @@
+package sync
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/util/metrics"
+)
+
+// adaptiveConfig configurations to tune sync batch size and fetch concurrency adaptively from the
+// observed fetch latency, db write latency and rows per epoch.
+type adaptiveConfig struct {
+	// whether to tune batch size and concurrency adaptively, otherwise the max epochs (or blocks)
+	// is used as static batch size without concurrency
+	Enabled bool
+	// lower bound of the batch size
+	MinBatch uint64 `default:"1"`
+	// upper bound of the batch size
+	MaxBatch uint64 `default:"100"`
+	// upper bound of the number of epochs (or blocks) fetched concurrently
+	MaxConcurrency int `default:"4"`
+	// expected duration of a sync round (fetch and db write), batch size shrinks if exceeded and
+	// grows if below the half
+	TargetLatency time.Duration `default:"1s"`
+	// maximum number of db rows (blocks, transactions and logs) to write in a sync round
+	MaxRows int `default:"10000"`
+}
+
+// syncStat statistics of a sync round.
+type syncStat struct {
+	epochs int           // number of epochs (or blocks) synced
+	rows   int           // number of db rows written
+	fetch  time.Duration // fetch latency from fullnode
+	write  time.Duration // write latency to db store
+}
+
+// adaptiveController tunes the sync batch size and fetch concurrency within the configured bounds,
+// which increases additively when sync rounds are light and decreases multiplicatively when heavy.
+type adaptiveController struct {
+	conf  *adaptiveConfig
+	space string
+
+	batch       uint64 // current batch size
+	concurrency int    // current fetch concurrency
+}
+
+func newAdaptiveController(space string, conf *adaptiveConfig, maxEpochs uint64) *adaptiveController {
+	c := &adaptiveController{
+		conf:        conf,
+		space:       space,
+		batch:       max(maxEpochs, 1),
+		concurrency: 1,
+	}
+
+	if conf.Enabled {
+		c.conf.MinBatch = max(conf.MinBatch, 1)
+		c.conf.MaxBatch = max(conf.MaxBatch, c.conf.MinBatch)
+		c.conf.MaxConcurrency = max(conf.MaxConcurrency, 1)
+
+		c.batch = min(max(c.batch, c.conf.MinBatch), c.conf.MaxBatch)
+	}
+
+	c.report()
+	return c
+}
+
+// batchSize returns the number of epochs (or blocks) to sync once.
+func (c *adaptiveController) batchSize() uint64 {
+	return c.batch
+}
+
+// fetchConcurrency returns the number of epochs (or blocks) to fetch concurrently.
+func (c *adaptiveController) fetchConcurrency() int {
+	return c.concurrency
+}
+
+// observe tunes batch size and concurrency from the statistics of a succeeded sync round.
+func (c *adaptiveController) observe(stat syncStat) {
+	if stat.epochs == 0 {
+		return
+	}
+
+	metrics.Registry.Sync.AdaptiveFetchLatency(c.space).Update(stat.fetch)
+	metrics.Registry.Sync.AdaptiveWriteLatency(c.space).Update(stat.write)
+	metrics.Registry.Sync.AdaptiveEpochRows(c.space).Update(int64(stat.rows / stat.epochs))
+
+	if !c.conf.Enabled {
+		return
+	}
+
+	elapsed := stat.fetch + stat.write
+
+	switch {
+	case elapsed > c.conf.TargetLatency || stat.rows > c.conf.MaxRows:
+		// heavy round, shrink quickly to avoid timeout
+		c.batch = max(c.batch/2, c.conf.MinBatch)
+	case elapsed < c.conf.TargetLatency/2 && stat.rows < c.conf.MaxRows/2 && uint64(stat.epochs) >= c.batch:
+		// light round with full batch, grow slowly to save round trips
+		c.batch = min(c.batch+max(c.batch/4, 1), c.conf.MaxBatch)
+	}
+
+	switch {
+	case stat.fetch > 2*stat.write && stat.epochs > c.concurrency:
+		// fetching dominates, more concurrency helps
+		c.concurrency = min(c.concurrency+1, c.conf.MaxConcurrency)
+	case stat.fetch < stat.write:
+		// db write dominates, concurrency is wasted
+		c.concurrency = max(c.concurrency-1, 1)
+	}
+
+	c.report()
+}
+
+// observeError shrinks batch size upon failed sync round, which is probably due to timeout.
+func (c *adaptiveController) observeError() {
+	if c.conf.Enabled {
+		c.batch = max(c.batch/2, c.conf.MinBatch)
+		c.report()
+	}
+}
+
+func (c *adaptiveController) report() {
+	metrics.Registry.Sync.AdaptiveBatchSize(c.space).Update(int64(c.batch))
+	metrics.Registry.Sync.AdaptiveConcurrency(c.space).Update(int64(c.concurrency))
+}
+
+// fetchConcurrently fetches data of the specified size with the specified concurrency, and returns
+// the results in order. Once failed, data after the failed one will not be fetched any more.
+func fetchConcurrently[T any](size uint64, concurrency int, fetch func(i uint64) (T, error)) ([]T, []error) {
+	results, errs := make([]T, size), make([]error, size)
+
+	var failedAt atomic.Uint64
+	failedAt.Store(math.MaxUint64)
+
+	var next atomic.Uint64
+	var wg sync.WaitGroup
+
+	for w := 0; w < min(max(concurrency, 1), int(size)); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := next.Add(1) - 1; i < size && i < failedAt.Load(); i = next.Add(1) - 1 {
+				results[i], errs[i] = fetch(i)
+				if errs[i] == nil {
+					continue
+				}
+
+				for failed := failedAt.Load(); i < failed; failed = failedAt.Load() {
+					if failedAt.CompareAndSwap(failed, i) {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return results, errs
+}
+
+// newSyncStat creates statistics of a sync round, of which db rows are counted as blocks,
+// transactions (receipts) and event logs.
+func newSyncStat(epochDataSlice []*store.EpochData, fetch, write time.Duration) syncStat {
+	stat := syncStat{epochs: len(epochDataSlice), fetch: fetch, write: write}
+
+	for _, data := range epochDataSlice {
+		stat.rows += len(data.Blocks)
+
+		for _, receipt := range data.Receipts {
+			stat.rows += 1 + len(receipt.Logs)
+		}
+	}
+
+	return stat
+}
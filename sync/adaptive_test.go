@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveControllerDisabled(t *testing.T) {
+	c := newAdaptiveController("test", &adaptiveConfig{}, 10)
+
+	c.observe(syncStat{epochs: 10, rows: 100, fetch: time.Millisecond, write: time.Millisecond})
+	assert.Equal(t, uint64(10), c.batchSize())
+
+	c.observeError()
+	assert.Equal(t, uint64(10), c.batchSize())
+	assert.Equal(t, 1, c.fetchConcurrency())
+}
+
+func TestAdaptiveControllerBatchSize(t *testing.T) {
+	conf := adaptiveConfig{
+		Enabled: true, MinBatch: 2, MaxBatch: 20, MaxConcurrency: 4,
+		TargetLatency: time.Second, MaxRows: 1000,
+	}
+	c := newAdaptiveController("test", &conf, 10)
+	assert.Equal(t, uint64(10), c.batchSize())
+
+	// light round with full batch grows additively
+	c.observe(syncStat{epochs: 10, rows: 100, fetch: 100 * time.Millisecond, write: 100 * time.Millisecond})
+	assert.Equal(t, uint64(12), c.batchSize())
+
+	// light round without full batch keeps unchanged
+	c.observe(syncStat{epochs: 5, rows: 50, fetch: 100 * time.Millisecond, write: 100 * time.Millisecond})
+	assert.Equal(t, uint64(12), c.batchSize())
+
+	// grows up to the upper bound
+	for i := 0; i < 10; i++ {
+		c.observe(syncStat{epochs: int(c.batchSize()), rows: 100, fetch: time.Millisecond, write: time.Millisecond})
+	}
+	assert.Equal(t, uint64(20), c.batchSize())
+
+	// too many rows shrinks multiplicatively
+	c.observe(syncStat{epochs: 20, rows: 2000, fetch: time.Millisecond, write: time.Millisecond})
+	assert.Equal(t, uint64(10), c.batchSize())
+
+	// too slow shrinks multiplicatively
+	c.observe(syncStat{epochs: 10, rows: 100, fetch: time.Second, write: time.Second})
+	assert.Equal(t, uint64(5), c.batchSize())
+
+	// error shrinks down to the lower bound
+	c.observeError()
+	c.observeError()
+	assert.Equal(t, uint64(2), c.batchSize())
+}
+
+func TestAdaptiveControllerConcurrency(t *testing.T) {
+	conf := adaptiveConfig{
+		Enabled: true, MinBatch: 1, MaxBatch: 100, MaxConcurrency: 2,
+		TargetLatency: time.Second, MaxRows: 1000,
+	}
+	c := newAdaptiveController("test", &conf, 10)
+	assert.Equal(t, 1, c.fetchConcurrency())
+
+	// fetch dominates
+	for i := 0; i < 3; i++ {
+		c.observe(syncStat{epochs: 10, rows: 100, fetch: 300 * time.Millisecond, write: 10 * time.Millisecond})
+	}
+	assert.Equal(t, 2, c.fetchConcurrency())
+
+	// write dominates
+	for i := 0; i < 3; i++ {
+		c.observe(syncStat{epochs: 10, rows: 100, fetch: 10 * time.Millisecond, write: 300 * time.Millisecond})
+	}
+	assert.Equal(t, 1, c.fetchConcurrency())
+}
+
+func TestFetchConcurrently(t *testing.T) {
+	errFetch := errors.New("fetch error")
+
+	results, errs := fetchConcurrently(10, 3, func(i uint64) (uint64, error) {
+		if i == 5 {
+			return 0, errFetch
+		}
+		return i * 2, nil
+	})
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, uint64(i*2), results[i])
+	}
+
+	assert.ErrorIs(t, errs[5], errFetch)
+}
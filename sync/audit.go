@@ -0,0 +1,356 @@
+package sync
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/rpc/cfxbridge"
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/election"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-util/dlock"
+	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/web3go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// config key prefix to store audit progress
+	auditProgressConfKeyPrefix = "audit.progress."
+
+	// viper config keys of auditor, which are also used as HA election keys
+	cfxAuditConfKey = "sync.audit"
+	ethAuditConfKey = "sync.eth.audit"
+)
+
+// AuditConfig configurations of data integrity auditor.
+type AuditConfig struct {
+	Enabled bool
+	// interval to audit a batch of epochs, which restricts the audit rate
+	Interval time.Duration `default:"1s"`
+	// max number of epochs to audit per batch
+	BatchSize uint64 `default:"10"`
+	// number of the latest synchronized epochs excluded from audit in case of chain reorg
+	Lag uint64 `default:"100"`
+	// whether to re-sync the corrupted epochs automatically
+	Resync bool
+}
+
+// Auditor continuously walks through the synchronized epochs at a set rate, and compares the digest
+// of epoch data (pivot hash, number of blocks, transactions and event logs along with hash) in db
+// store against that of fullnode.
+//
+// Mismatches are reported by metrics and alert, and persisted into db. Corrupted epochs could be
+// re-synchronized by backfill if enabled, unless the pivot hash mismatched.
+type Auditor struct {
+	space string
+	conf  *AuditConfig
+	db    *mysql.MysqlStore
+	// HA leader/follower election, so that only one auditor runs at the same time
+	elm election.LeaderManager
+	// function to fetch epoch data from fullnode
+	fetch func(ctx context.Context, epoch uint64) (*store.EpochData, error)
+}
+
+// MustNewCfxAuditor creates an instance of Auditor for core space, or nil if disabled.
+func MustNewCfxAuditor(cfx sdk.ClientOperator, db *mysql.MysqlStore) *Auditor {
+	var conf AuditConfig
+	viperutil.MustUnmarshalKey(cfxAuditConfKey, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	return newAuditor("cfx", cfxAuditConfKey, &conf, db, func(ctx context.Context, epoch uint64) (*store.EpochData, error) {
+		data, err := store.QueryEpochData(cfx, epoch, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return &data, nil
+	})
+}
+
+// MustNewEthAuditor creates an instance of Auditor for evm space, or nil if disabled.
+func MustNewEthAuditor(w3c *web3go.Client, db *mysql.MysqlStore) *Auditor {
+	var conf AuditConfig
+	viperutil.MustUnmarshalKey(ethAuditConfKey, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	chainId, err := w3c.Eth.ChainId()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to get chain ID from eth space")
+	}
+
+	return newAuditor("eth", ethAuditConfKey, &conf, db, func(ctx context.Context, bn uint64) (*store.EpochData, error) {
+		data, err := store.QueryEthData(ctx, w3c, bn)
+		if err != nil {
+			return nil, err
+		}
+
+		return cfxbridge.ConvertToEpochData(data, uint32(*chainId)), nil
+	})
+}
+
+// newAuditor creates an auditor, which campaigns for leadership by the config key so that the election
+// is in line with the config namespace, e.g. `sync.audit` or `sync.eth.audit`.
+func newAuditor(
+	space, confKey string,
+	conf *AuditConfig,
+	db *mysql.MysqlStore,
+	fetch func(ctx context.Context, epoch uint64) (*store.EpochData, error),
+) *Auditor {
+	dlm := dlock.NewLockManager(dlock.NewMySQLBackend(db.DB()))
+
+	return &Auditor{
+		space: space,
+		conf:  conf,
+		db:    db,
+		elm:   election.MustNewLeaderManagerFromViper(dlm, confKey),
+		fetch: fetch,
+	}
+}
+
+// Audit starts to audit the synchronized epoch data periodically.
+func (a *Auditor) Audit(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	go a.elm.Campaign(ctx)
+	defer a.elm.Stop()
+
+	ticker := time.NewTicker(a.conf.Interval)
+	defer ticker.Stop()
+
+	logrus.WithField("space", a.space).Info("Auditor starting to audit epoch data")
+
+	for a.elm.Await(ctx) {
+		select {
+		case <-ctx.Done():
+			logrus.WithField("space", a.space).Info("Auditor shutdown ok")
+			return
+		case <-ticker.C:
+			if err := a.auditOnce(ctx); err != nil {
+				logrus.WithField("space", a.space).WithError(err).Error("Auditor failed to audit epoch data")
+			}
+		}
+	}
+}
+
+// auditOnce audits a batch of epochs from where it left off, and starts over from the oldest epoch
+// once the latest auditable epoch reached.
+func (a *Auditor) auditOnce(ctx context.Context) error {
+	ranges, err := a.epochRanges()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get epoch ranges")
+	}
+
+	start, end, ok := a.auditableRange(ranges)
+	if !ok {
+		return nil
+	}
+
+	next, err := a.loadProgress()
+	if err != nil {
+		return errors.WithMessage(err, "failed to load audit progress")
+	}
+
+	if next > end { // audit pass completed
+		logrus.WithFields(logrus.Fields{
+			"space": a.space, "start": start, "end": end,
+		}).Info("Auditor completed a pass and will start over from the oldest epoch")
+		next = start
+	}
+
+	next = max(next, start)
+	to := util.MinUint64(next+a.conf.BatchSize-1, end)
+
+	for epoch := next; epoch <= to; epoch++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		if err := a.auditEpoch(ctx, epoch, ranges); err != nil {
+			return errors.WithMessagef(err, "failed to audit epoch %v", epoch)
+		}
+
+		if err := a.saveProgress(epoch + 1); err != nil {
+			return errors.WithMessage(err, "failed to save audit progress")
+		}
+
+		metrics.Registry.Sync.AuditProgress(a.space).Update(int64(epoch))
+	}
+
+	return nil
+}
+
+// epochRanges returns the epoch ranges of the data types stored in db, with data types disabled or
+// without any data excluded.
+func (a *Auditor) epochRanges() (map[store.EpochDataType][2]uint64, error) {
+	ranges := make(map[store.EpochDataType][2]uint64)
+
+	for dt, epochRange := range map[store.EpochDataType]func() (uint64, uint64, error){
+		store.EpochBlock:       a.db.GetBlockEpochRange,
+		store.EpochTransaction: a.db.GetTransactionEpochRange,
+		store.EpochLog:         a.db.GetLogEpochRange,
+	} {
+		minEpoch, maxEpoch, err := epochRange()
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to get epoch range of %v", dt.Name())
+		}
+
+		ranges[dt] = [2]uint64{minEpoch, maxEpoch}
+	}
+
+	return ranges, nil
+}
+
+// auditableRange returns the epoch range to audit, which ends before the latest epochs to be lagged.
+func (a *Auditor) auditableRange(ranges map[store.EpochDataType][2]uint64) (uint64, uint64, bool) {
+	maxEpoch, ok, err := a.db.MaxEpoch()
+	if err != nil || !ok || maxEpoch < a.conf.Lag {
+		return 0, 0, false
+	}
+
+	start, end := maxEpoch, maxEpoch-a.conf.Lag
+	for _, r := range ranges {
+		start = min(start, r[0])
+	}
+
+	return start, end, start <= end
+}
+
+// auditEpoch compares the digest of epoch data in db store against that of fullnode.
+func (a *Auditor) auditEpoch(ctx context.Context, epoch uint64, ranges map[store.EpochDataType][2]uint64) error {
+	// only audit the data types that are not pruned yet
+	var dataTypes []store.EpochDataType
+	for _, dt := range store.OpEpochDataTypes {
+		if r, ok := ranges[dt]; ok && r[0] <= epoch && epoch <= r[1] {
+			dataTypes = append(dataTypes, dt)
+		}
+	}
+
+	data, err := a.fetch(ctx, epoch)
+	if err != nil {
+		return errors.WithMessage(err, "failed to fetch epoch data from fullnode")
+	}
+
+	actual, ok, err := a.db.GetEpochDigest(epoch, dataTypes)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get epoch digest from db")
+	}
+
+	metrics.Registry.Sync.AuditEpochs(a.space).Mark(1)
+
+	if !ok { // popped due to chain reorg
+		return nil
+	}
+
+	mismatches := actual.Compare(store.NewEpochDigest(data), dataTypes)
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	for _, m := range mismatches {
+		metrics.Registry.Sync.AuditMismatches(a.space, m.Field).Inc(1)
+
+		// alert
+		logrus.WithFields(logrus.Fields{
+			"space":    a.space,
+			"epoch":    epoch,
+			"field":    m.Field,
+			"expected": m.Expected,
+			"actual":   m.Actual,
+		}).Error("Auditor detected epoch data mismatched with fullnode")
+	}
+
+	if err := a.db.AddAuditMismatches(epoch, mismatches); err != nil {
+		return errors.WithMessage(err, "failed to save audit mismatches")
+	}
+
+	if a.conf.Resync {
+		return a.resync(ctx, data, mismatches)
+	}
+
+	return nil
+}
+
+// resync re-synchronizes the data types of the corrupted epoch by backfill.
+func (a *Auditor) resync(ctx context.Context, data *store.EpochData, mismatches []store.AuditMismatch) error {
+	var dataTypes []store.EpochDataType
+
+	for _, m := range mismatches {
+		switch m.Field {
+		case "pivotHash":
+			// pivot switched epochs could not be backfilled, which requires manual intervention
+			logrus.WithFields(logrus.Fields{
+				"space": a.space, "epoch": data.Number,
+			}).Error("Auditor unable to re-sync epoch data due to pivot hash mismatched")
+			return nil
+		case "blocks":
+			dataTypes = append(dataTypes, store.EpochBlock)
+		case "txs":
+			dataTypes = append(dataTypes, store.EpochTransaction)
+		case "logs":
+			dataTypes = append(dataTypes, store.EpochLog)
+		}
+	}
+
+	err := a.db.BackfillWithFinalizer([]*store.EpochData{data}, dataTypes, func(dbTx *gorm.DB) error {
+		if err := a.db.MarkAuditMismatchesResynced(dbTx, data.Number); err != nil {
+			return errors.WithMessage(err, "failed to mark audit mismatches re-synced")
+		}
+
+		return a.elm.Extend(ctx)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to re-sync epoch data")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"space": a.space, "epoch": data.Number, "mismatches": len(mismatches),
+	}).Info("Auditor succeeded to re-sync corrupted epoch data")
+
+	return nil
+}
+
+func (a *Auditor) progressKey() string {
+	return auditProgressConfKeyPrefix + a.space
+}
+
+// loadProgress loads the next epoch to audit.
+func (a *Auditor) loadProgress() (uint64, error) {
+	key := a.progressKey()
+
+	confs, err := a.db.LoadConfig(key)
+	if err != nil {
+		return 0, err
+	}
+
+	val, ok := confs[key]
+	if !ok {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(val.(string), 10, 64)
+}
+
+func (a *Auditor) saveProgress(next uint64) error {
+	return a.db.StoreConfig(a.progressKey(), strconv.FormatUint(next, 10))
+}
@@ -0,0 +1,242 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/catchup"
+	"github.com/Conflux-Chain/confura/sync/election"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-util/dlock"
+	"github.com/openweb3/web3go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// config key prefix to store backfill progress
+	backfillProgressConfKeyPrefix = "backfill.progress."
+)
+
+// ParseBackfillDataTypes parses data types to backfill, available options are `block`, `transaction`
+// (or `receipt`, which shares the same rows with transaction) and `log`.
+func ParseBackfillDataTypes(names []string) ([]store.EpochDataType, error) {
+	var result []store.EpochDataType
+	added := make(map[store.EpochDataType]bool)
+
+	for _, name := range names {
+		var dt store.EpochDataType
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "block":
+			dt = store.EpochBlock
+		case "transaction", "tx", "receipt":
+			dt = store.EpochTransaction
+		case "log":
+			dt = store.EpochLog
+		default:
+			return nil, errors.Errorf("invalid data type %v", name)
+		}
+
+		if !added[dt] {
+			added[dt] = true
+			result = append(result, dt)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("no data type specified")
+	}
+
+	return result, nil
+}
+
+// Backfiller re-indexes chain data of the specified data types for a historic epoch range, which
+// replaces the existing rows idempotently without disturbing the live syncer.
+//
+// Epoch data is fetched concurrently by the catch-up workers, and the backfill progress is stored
+// in db so that it could be resumed from where it left off.
+type Backfiller struct {
+	db        *mysql.MysqlStore
+	dataTypes []store.EpochDataType
+	// epoch (or block for evm space) range to backfill
+	from, to uint64
+	// HA leader/follower election, so that only one backfill runs at the same time
+	elm election.LeaderManager
+	// factory to create catch-up syncer to fetch epoch data concurrently
+	newSyncer func(elm election.LeaderManager, opts ...catchup.SyncOption) *catchup.Syncer
+}
+
+// MustNewCfxBackfiller creates an instance of Backfiller for core space.
+func MustNewCfxBackfiller(
+	cfx *sdk.Client, db *mysql.MysqlStore, from, to uint64, dataTypes []store.EpochDataType,
+) *Backfiller {
+	newSyncer := func(elm election.LeaderManager, opts ...catchup.SyncOption) *catchup.Syncer {
+		return catchup.MustNewSyncer(cfx, db, elm, opts...)
+	}
+
+	return newBackfiller(db, from, to, dataTypes, "sync.backfill", newSyncer)
+}
+
+// MustNewEthBackfiller creates an instance of Backfiller for evm space.
+func MustNewEthBackfiller(
+	w3c *web3go.Client, db *mysql.MysqlStore, from, to uint64, dataTypes []store.EpochDataType,
+) *Backfiller {
+	chainId, err := w3c.Eth.ChainId()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to get chain ID from eth space")
+	}
+
+	newSyncer := func(elm election.LeaderManager, opts ...catchup.SyncOption) *catchup.Syncer {
+		return catchup.MustNewEthSyncer(w3c, uint32(*chainId), db, elm, opts...)
+	}
+
+	return newBackfiller(db, from, to, dataTypes, "sync.eth.backfill", newSyncer)
+}
+
+func newBackfiller(
+	db *mysql.MysqlStore,
+	from, to uint64,
+	dataTypes []store.EpochDataType,
+	elecKey string,
+	newSyncer func(elm election.LeaderManager, opts ...catchup.SyncOption) *catchup.Syncer,
+) *Backfiller {
+	dlm := dlock.NewLockManager(dlock.NewMySQLBackend(db.DB()))
+
+	return &Backfiller{
+		db:        db,
+		dataTypes: dataTypes,
+		from:      from,
+		to:        to,
+		elm:       election.MustNewLeaderManagerFromViper(dlm, elecKey),
+		newSyncer: newSyncer,
+	}
+}
+
+// Backfill backfills the epoch range until completed, or returns the context error if canceled before
+// completion, in which case the backfill could be resumed later.
+func (b *Backfiller) Backfill(ctx context.Context) error {
+	if b.from > b.to {
+		return errors.Errorf("invalid epoch range [%v, %v]", b.from, b.to)
+	}
+
+	go b.elm.Campaign(ctx)
+	defer b.elm.Stop()
+
+	for b.elm.Await(ctx) {
+		next, err := b.loadProgress()
+		if err != nil {
+			return errors.WithMessage(err, "failed to load backfill progress")
+		}
+
+		logger := logrus.WithFields(logrus.Fields{
+			"from": b.from, "to": b.to, "next": next, "types": b.typeNames(),
+		})
+
+		if next > b.to {
+			logger.Info("Backfill completed")
+			return nil
+		}
+
+		logger.Info("Backfill starting")
+
+		// always use new syncer in case of stale epoch data buffered by workers of the last run
+		err = b.backfillRange(ctx, next, b.to)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, context.Canceled) {
+			return errors.WithMessagef(err, "backfill interrupted at epoch %v", next)
+		}
+
+		if errors.Is(err, store.ErrLeaderRenewal) {
+			logger.WithField("leaderIdentity", b.elm.Identity()).
+				Info("Backfill failed to renew leadership and will be resumed once elected again")
+			continue
+		}
+
+		return err
+	}
+
+	// interrupted while waiting for leadership
+	return ctx.Err()
+}
+
+func (b *Backfiller) backfillRange(ctx context.Context, start, end uint64) error {
+	syncer := b.newSyncer(b.elm, catchup.WithPersister(b.persist))
+	defer syncer.Close()
+
+	if syncer.NumWorkers() == 0 {
+		return errors.New("no fullnodes configured in catch-up pool")
+	}
+
+	return syncer.SyncRange(ctx, start, end)
+}
+
+// persist replaces epoch data of the data types and saves backfill progress afterwards.
+func (b *Backfiller) persist(ctx context.Context, epochs []*store.EpochData) error {
+	start := time.Now()
+
+	err := b.db.BackfillWithFinalizer(epochs, b.dataTypes, func(d *gorm.DB) error {
+		return b.elm.Extend(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	epochTo := epochs[len(epochs)-1].Number
+	if err := b.saveProgress(epochTo + 1); err != nil {
+		return errors.WithMessage(err, "failed to save backfill progress")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"epochFrom": epochs[0].Number,
+		"epochTo":   epochTo,
+		"elapsed":   time.Since(start),
+	}).Info("Backfill succeeded to replace epoch data")
+
+	return nil
+}
+
+// progressKey returns config key of the backfill progress, which is unique per epoch range and
+// data types.
+func (b *Backfiller) progressKey() string {
+	return fmt.Sprintf("%v%v-%v.%v", backfillProgressConfKeyPrefix, b.from, b.to, strings.Join(b.typeNames(), ","))
+}
+
+// loadProgress loads the next epoch to backfill.
+func (b *Backfiller) loadProgress() (uint64, error) {
+	key := b.progressKey()
+
+	confs, err := b.db.LoadConfig(key)
+	if err != nil {
+		return 0, err
+	}
+
+	val, ok := confs[key]
+	if !ok {
+		return b.from, nil
+	}
+
+	return strconv.ParseUint(val.(string), 10, 64)
+}
+
+func (b *Backfiller) saveProgress(next uint64) error {
+	return b.db.StoreConfig(b.progressKey(), strconv.FormatUint(next, 10))
+}
+
+func (b *Backfiller) typeNames() []string {
+	names := make([]string, 0, len(b.dataTypes))
+	for _, dt := range b.dataTypes {
+		names = append(names, dt.Name())
+	}
+
+	return names
+}
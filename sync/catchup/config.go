@@ -3,6 +3,8 @@ package catchup
 type config struct {
 	// list of Conflux fullnodes to accelerate catching up until the latest stable epoch
 	CfxPool []string
+	// list of EVM space fullnodes to accelerate catching up until the latest finalized block
+	EthPool []string
 	// threshold for num of db rows per batch persistence
 	DbRowsThreshold int `default:"2500"`
 	// max number of db rows collected before persistence
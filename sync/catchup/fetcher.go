@@ -0,0 +1,64 @@
+package catchup
+
+import (
+	"context"
+
+	"github.com/Conflux-Chain/confura/rpc/cfxbridge"
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/util/rpc"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/openweb3/web3go"
+)
+
+// epochFetcher fetches epoch data from fullnode, which is an epoch for core space
+// or a block for evm space.
+type epochFetcher interface {
+	Fetch(ctx context.Context, epochNo uint64) (*store.EpochData, error)
+	Close()
+}
+
+// cfxFetcher fetches core space epoch data.
+type cfxFetcher struct {
+	cfx sdk.ClientOperator
+}
+
+func mustNewCfxFetcher(nodeUrl string) *cfxFetcher {
+	return &cfxFetcher{cfx: rpc.MustNewCfxClient(nodeUrl)}
+}
+
+func (f *cfxFetcher) Fetch(ctx context.Context, epochNo uint64) (*store.EpochData, error) {
+	epochData, err := store.QueryEpochData(f.cfx, epochNo, true)
+	if err == nil {
+		return &epochData, nil
+	}
+
+	return nil, err
+}
+
+func (f *cfxFetcher) Close() {
+	f.cfx.Close()
+}
+
+// ethFetcher fetches evm space block data, which is converted to epoch data so as to
+// reuse the db store logic.
+type ethFetcher struct {
+	w3c     *web3go.Client
+	chainId uint32
+}
+
+func mustNewEthFetcher(nodeUrl string, chainId uint32) *ethFetcher {
+	return &ethFetcher{w3c: rpc.MustNewEthClient(nodeUrl), chainId: chainId}
+}
+
+func (f *ethFetcher) Fetch(ctx context.Context, blockNo uint64) (*store.EpochData, error) {
+	ethData, err := store.QueryEthData(ctx, f.w3c, blockNo)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfxbridge.ConvertToEpochData(ethData, f.chainId), nil
+}
+
+func (f *ethFetcher) Close() {
+	f.w3c.Provider().Close()
+}
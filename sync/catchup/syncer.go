@@ -14,18 +14,24 @@ import (
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	logutil "github.com/Conflux-Chain/go-conflux-util/log"
 	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/web3go"
+	ethtypes "github.com/openweb3/web3go/types"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
-// Syncer accelerates core space epoch data catch-up using concurrently workers.
-// Specifically, each worker will be dispatched as round-robin load balancing.
+// Syncer accelerates core space epoch data or evm space block data catch-up using concurrently
+// workers. Specifically, each worker will be dispatched as round-robin load balancing.
 type Syncer struct {
 	// goroutine workers to fetch epoch data concurrently
 	workers []*worker
-	// conflux sdk client delegated to get network status
-	cfx sdk.ClientOperator
+	// function to get the latest stable epoch (or block for evm space) to catch up
+	latestStableEpoch func() (uint64, error)
+	// epoch (or block for evm space) to catch up from if db store is empty
+	fromEpoch uint64
+	// chain data disabler to count db rows to be stored
+	disabler store.ChainDataDisabler
 	// db store to persist epoch data
 	db *mysql.MysqlStore
 	// min num of db rows per batch persistence
@@ -38,6 +44,8 @@ type Syncer struct {
 	elm election.LeaderManager
 	// sync monitor
 	monitor *monitor.Monitor
+	// persister to persist collected epoch data, default to push into db store
+	persister func(ctx context.Context, epochs []*store.EpochData) error
 }
 
 // functional options for syncer
@@ -67,35 +75,109 @@ func WithBenchmark(benchmark bool) SyncOption {
 	}
 }
 
+func WithMonitor(monitor *monitor.Monitor) SyncOption {
+	return func(s *Syncer) {
+		s.monitor = monitor
+	}
+}
+
+func WithFromEpoch(epoch uint64) SyncOption {
+	return func(s *Syncer) {
+		s.fromEpoch = epoch
+	}
+}
+
+func WithPersister(persister func(ctx context.Context, epochs []*store.EpochData) error) SyncOption {
+	return func(s *Syncer) {
+		s.persister = persister
+	}
+}
+
+// MustNewSyncer creates a catch-up syncer for core space, which catches up until the maximum of
+// the latest finalized and checkpoint epoch.
 func MustNewSyncer(
 	cfx sdk.ClientOperator,
 	db *mysql.MysqlStore,
 	elm election.LeaderManager,
 	opts ...SyncOption) *Syncer {
-	var conf config
-	viperutil.MustUnmarshalKey("sync.catchup", &conf)
+	conf := mustLoadConfig()
 
 	var workers []*worker
 	for i, nodeUrl := range conf.CfxPool { // initialize workers
 		name := fmt.Sprintf("CUWorker#%v", i)
-		worker := mustNewWorker(name, nodeUrl, conf.WorkerChanSize)
+		worker := newWorker(name, mustNewCfxFetcher(nodeUrl), conf.WorkerChanSize)
+		workers = append(workers, worker)
+	}
+
+	latestStableEpoch := func() (uint64, error) {
+		status, err := cfx.GetStatus()
+		if err != nil {
+			return 0, errors.WithMessage(err, "failed to get network status")
+		}
+
+		return util.MaxUint64(uint64(status.LatestFinalized), uint64(status.LatestCheckpoint)), nil
+	}
+
+	newOpts := append(conf.syncOptions(), WithWorkers(workers))
+	return newSyncer(latestStableEpoch, store.StoreConfig(), db, elm, append(newOpts, opts...)...)
+}
+
+// MustNewEthSyncer creates a catch-up syncer for evm space, which catches up until the latest
+// finalized block.
+func MustNewEthSyncer(
+	w3c *web3go.Client,
+	chainId uint32,
+	db *mysql.MysqlStore,
+	elm election.LeaderManager,
+	opts ...SyncOption) *Syncer {
+	conf := mustLoadConfig()
+
+	var workers []*worker
+	for i, nodeUrl := range conf.EthPool { // initialize workers
+		name := fmt.Sprintf("ETHCUWorker#%v", i)
+		worker := newWorker(name, mustNewEthFetcher(nodeUrl, chainId), conf.WorkerChanSize)
 		workers = append(workers, worker)
 	}
 
-	var newOpts []SyncOption
-	newOpts = append(newOpts,
+	latestStableEpoch := func() (uint64, error) {
+		block, err := w3c.Eth.BlockByNumber(ethtypes.FinalizedBlockNumber, false)
+		if err != nil {
+			return 0, errors.WithMessage(err, "failed to get the latest finalized block")
+		}
+
+		return block.Number.Uint64(), nil
+	}
+
+	newOpts := append(conf.syncOptions(), WithWorkers(workers))
+	return newSyncer(latestStableEpoch, store.EthStoreConfig(), db, elm, append(newOpts, opts...)...)
+}
+
+func mustLoadConfig() *config {
+	var conf config
+	viperutil.MustUnmarshalKey("sync.catchup", &conf)
+	return &conf
+}
+
+func (conf *config) syncOptions() []SyncOption {
+	return []SyncOption{
 		WithMaxDbRows(conf.MaxDbRows),
 		WithMinBatchDbRows(conf.DbRowsThreshold),
-		WithWorkers(workers),
-	)
-
-	return newSyncer(cfx, db, elm, append(newOpts, opts...)...)
+	}
 }
 
 func newSyncer(
-	cfx sdk.ClientOperator, db *mysql.MysqlStore,
-	elm election.LeaderManager, opts ...SyncOption) *Syncer {
-	syncer := &Syncer{elm: elm, db: db, cfx: cfx, minBatchDbRows: 1500}
+	latestStableEpoch func() (uint64, error),
+	disabler store.ChainDataDisabler,
+	db *mysql.MysqlStore,
+	elm election.LeaderManager,
+	opts ...SyncOption) *Syncer {
+	syncer := &Syncer{
+		latestStableEpoch: latestStableEpoch,
+		disabler:          disabler,
+		elm:               elm,
+		db:                db,
+		minBatchDbRows:    1500,
+	}
 	for _, opt := range opts {
 		opt(syncer)
 	}
@@ -103,6 +185,11 @@ func newSyncer(
 	return syncer
 }
 
+// NumWorkers returns the number of workers to fetch epoch data concurrently.
+func (s *Syncer) NumWorkers() int {
+	return len(s.workers)
+}
+
 func (s *Syncer) Close() {
 	for _, w := range s.workers {
 		w.Close()
@@ -137,7 +224,16 @@ func (s *Syncer) Sync(ctx context.Context) {
 	}
 }
 
-func (s *Syncer) syncOnce(ctx context.Context, start, end uint64) {
+// SyncRange fetches epoch data of the specified epoch range concurrently and persists them in order.
+func (s *Syncer) SyncRange(ctx context.Context, start, end uint64) error {
+	if len(s.workers) == 0 {
+		return errors.New("no workers configured")
+	}
+
+	return s.syncOnce(ctx, start, end)
+}
+
+func (s *Syncer) syncOnce(ctx context.Context, start, end uint64) (resultErr error) {
 	var bmarker *benchmarker
 	if s.benchmark {
 		bmarker = newBenchmarker()
@@ -157,6 +253,8 @@ func (s *Syncer) syncOnce(ctx context.Context, start, end uint64) {
 		defer cancel()
 
 		err := s.fetchResult(ctx, start, end, bmarker)
+		resultErr = err
+
 		if err != nil && !errors.Is(err, context.Canceled) {
 			if errors.Is(err, store.ErrLeaderRenewal) {
 				logrus.WithFields(logrus.Fields{
@@ -182,6 +280,7 @@ func (s *Syncer) syncOnce(ctx context.Context, start, end uint64) {
 	}
 
 	wg.Wait()
+	return resultErr
 }
 
 func (s *Syncer) fetchResult(ctx context.Context, start, end uint64, bmarker *benchmarker) error {
@@ -203,10 +302,12 @@ func (s *Syncer) fetchResult(ctx context.Context, start, end uint64, bmarker *be
 				// collect epoch data
 				eno++
 
-				s.monitor.Update(eno)
+				if s.monitor != nil {
+					s.monitor.Update(eno)
+				}
 			}
 
-			epochDbRows, storeDbRows := state.update(epochData)
+			epochDbRows, storeDbRows := state.update(epochData, s.disabler)
 
 			logrus.WithFields(logrus.Fields{
 				"workerName":         w.name,
@@ -250,8 +351,8 @@ func (s *persistState) numEpochs() int {
 	return len(s.epochs)
 }
 
-func (s *persistState) update(epochData *store.EpochData) (int, int) {
-	totalDbRows, storeDbRows := countDbRows(epochData)
+func (s *persistState) update(epochData *store.EpochData, disabler store.ChainDataDisabler) (int, int) {
+	totalDbRows, storeDbRows := countDbRows(epochData, disabler)
 
 	s.epochs = append(s.epochs, epochData)
 	s.totalDbRows += totalDbRows
@@ -267,9 +368,15 @@ func (s *Syncer) persist(ctx context.Context, state *persistState, bmarker *benc
 	}
 
 	start := time.Now()
-	err := s.db.PushnWithFinalizer(state.epochs, func(d *gorm.DB) error {
-		return s.elm.Extend(ctx)
-	})
+
+	var err error
+	if s.persister != nil {
+		err = s.persister(ctx, state.epochs)
+	} else {
+		err = s.db.PushnWithFinalizer(state.epochs, func(d *gorm.DB) error {
+			return s.elm.Extend(ctx)
+		})
+	}
 
 	if err != nil {
 		return errors.WithMessage(err, "failed to push db store")
@@ -283,7 +390,7 @@ func (s *Syncer) persist(ctx context.Context, state *persistState, bmarker *benc
 }
 
 // nextSyncRange gets the sync range by loading max epoch number from the database as the start
-// and fetching the maximum epoch of the latest finalized or the latest checkpoint epoch as the end
+// and fetching the latest stable epoch as the end
 func (s *Syncer) nextSyncRange() (uint64, uint64, error) {
 	start, ok, err := s.db.MaxEpoch()
 	if err != nil {
@@ -293,22 +400,19 @@ func (s *Syncer) nextSyncRange() (uint64, uint64, error) {
 	if ok {
 		start++
 	} else {
-		start = 0
+		start = s.fromEpoch
 	}
 
-	status, err := s.cfx.GetStatus()
+	end, err := s.latestStableEpoch()
 	if err != nil {
-		return 0, 0, errors.WithMessage(err, "failed to get network status")
+		return 0, 0, err
 	}
 
-	end := util.MaxUint64(uint64(status.LatestFinalized), uint64(status.LatestCheckpoint))
 	return start, end, nil
 }
 
 // countDbRows count total db rows and to be stored db row from epoch data.
-func countDbRows(epoch *store.EpochData) (totalDbRows int, storeDbRows int) {
-	storeDisabler := store.StoreConfig()
-
+func countDbRows(epoch *store.EpochData, storeDisabler store.ChainDataDisabler) (totalDbRows int, storeDbRows int) {
 	// db rows for block
 	totalDbRows += len(epoch.Blocks)
 	if !storeDisabler.IsChainBlockDisabled() {
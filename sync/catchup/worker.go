@@ -6,8 +6,6 @@ import (
 	"time"
 
 	"github.com/Conflux-Chain/confura/store"
-	"github.com/Conflux-Chain/confura/util/rpc"
-	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	logutil "github.com/Conflux-Chain/go-conflux-util/log"
 	"github.com/sirupsen/logrus"
 )
@@ -17,15 +15,15 @@ type worker struct {
 	name string
 	// result channel to collect queried epoch data
 	resultChan chan *store.EpochData
-	// conflux sdk client delegated to fetch epoch data
-	cfx sdk.ClientOperator
+	// fetcher delegated to fetch epoch data
+	fetcher epochFetcher
 }
 
-func mustNewWorker(name, nodeUrl string, chanSize int) *worker {
+func newWorker(name string, fetcher epochFetcher, chanSize int) *worker {
 	return &worker{
 		name:       name,
 		resultChan: make(chan *store.EpochData, chanSize),
-		cfx:        rpc.MustNewCfxClient(nodeUrl),
+		fetcher:    fetcher,
 	}
 }
 
@@ -38,7 +36,7 @@ func (w *worker) Sync(ctx context.Context, wg *sync.WaitGroup, epochFrom, epochT
 		case <-ctx.Done():
 			return
 		default:
-			epochData, err := w.fetchEpoch(eno)
+			epochData, err := w.fetcher.Fetch(ctx, eno)
 			etLogger.Log(
 				logrus.WithFields(logrus.Fields{
 					"epochNo":    eno,
@@ -62,19 +60,10 @@ func (w *worker) Sync(ctx context.Context, wg *sync.WaitGroup, epochFrom, epochT
 }
 
 func (w *worker) Close() {
-	w.cfx.Close()
+	w.fetcher.Close()
 	close(w.resultChan)
 }
 
 func (w *worker) Data() <-chan *store.EpochData {
 	return w.resultChan
 }
-
-func (w *worker) fetchEpoch(epochNo uint64) (*store.EpochData, error) {
-	epochData, err := store.QueryEpochData(w.cfx, epochNo, true)
-	if err == nil {
-		return &epochData, nil
-	}
-
-	return nil, err
-}
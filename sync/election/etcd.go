@@ -0,0 +1,318 @@
+package election
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	logutil "github.com/Conflux-Chain/go-conflux-util/log"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+var (
+	_ LeaderManager = (*EtcdLeaderManager)(nil)
+)
+
+// EtcdConfig holds the configuration for etcd election backend.
+type EtcdConfig struct {
+	// Endpoints of the etcd cluster
+	Endpoints []string
+	// Timeout for failing to establish a connection
+	DialTimeout time.Duration `default:"5s"`
+}
+
+// MustNewEtcdClient creates a new etcd client from the provided configuration.
+func MustNewEtcdClient(conf EtcdConfig) *clientv3.Client {
+	if len(conf.Endpoints) == 0 {
+		logrus.Fatal("No etcd endpoint configured for leader election")
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   conf.Endpoints,
+		DialTimeout: conf.DialTimeout,
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create etcd client for leader election")
+	}
+
+	return client
+}
+
+// EtcdLeaderManager manages the leader election process using etcd lease and campaign, of which
+// the leadership is kept alive by the session lease until ousted or resigned.
+type EtcdLeaderManager struct {
+	Config // election configuration
+
+	electionKey    string       // election key
+	electionStatus LeaderStatus // election status
+
+	client   *clientv3.Client      // etcd client
+	sessMu   sync.Mutex            // mutex lock for the session and election
+	session  *concurrency.Session  // session with lease kept alive
+	election *concurrency.Election // election upon the session
+
+	mu               sync.Mutex        // mutex lock
+	cancel           atomic.Value      // function to cancel the campaign process.
+	electedCallbacks []ElectedCallback // leader elected callback functions
+	oustedCallbacks  []OustedCallback  // leader ousted callback functions
+	errorCallbacks   []ErrorCallback   // leader election error callback functions
+}
+
+// NewEtcdLeaderManager creates a new `LeaderManager` with the provided etcd client, configuration
+// and election key.
+func NewEtcdLeaderManager(client *clientv3.Client, conf Config, elecKey string) *EtcdLeaderManager {
+	conf.ID += "#" + uuid.NewString()[:8] // append a unique suffix to the `Id`
+	return &EtcdLeaderManager{
+		Config:      conf,
+		client:      client,
+		electionKey: elecKey,
+	}
+}
+
+func (l *EtcdLeaderManager) Identity() string {
+	return l.ID
+}
+
+// Await blocks until being elected as leader or context canceled.
+func (l *EtcdLeaderManager) Await(ctx context.Context) bool {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			if atomic.LoadInt32(&l.electionStatus) == StatusElected {
+				return true
+			}
+
+			timer.Reset(l.Retry)
+		}
+	}
+}
+
+// Extend confirms the leadership is still held, since the lease is kept alive by the session.
+func (l *EtcdLeaderManager) Extend(ctx context.Context) error {
+	if atomic.LoadInt32(&l.electionStatus) != StatusElected {
+		return store.ErrLeaderRenewal
+	}
+
+	l.sessMu.Lock()
+	defer l.sessMu.Unlock()
+
+	if l.election == nil {
+		return store.ErrLeaderRenewal
+	}
+
+	// proclaim fails unless the leader key created by this campaign still exists
+	err := l.election.Proclaim(ctx, l.ID)
+	if errors.Is(err, concurrency.ErrElectionNotLeader) {
+		return store.ErrLeaderRenewal
+	}
+
+	return err
+}
+
+// Campaign starts the election process, which will run in a goroutine until contex canceled.
+func (l *EtcdLeaderManager) Campaign(ctx context.Context) {
+	newCtx, cancelFn := context.WithCancel(ctx)
+	l.cancel.Store(cancelFn)
+
+	logger := logutil.NewErrorTolerantLogger(logutil.DefaultETConfig)
+	etlog := func(err error) {
+		logger.Log(
+			logrus.WithFields(logrus.Fields{
+				"electionKey": l.electionKey,
+				"leaderID":    l.ID,
+			}), err, "Leader election campaign error",
+		)
+	}
+
+	for {
+		// campaign until elected
+		sessDone, err := l.campaign(newCtx)
+		etlog(err)
+
+		if err != nil {
+			l.closeSession()
+
+			if newCtx.Err() != nil {
+				return
+			}
+
+			l.onError(newCtx, err)
+			time.Sleep(l.Retry)
+			continue
+		}
+
+		// if we got here we are the leader
+		l.onElected(newCtx)
+
+		// the leadership is held until the session lease expired or context canceled
+		select {
+		case <-newCtx.Done():
+		case <-sessDone:
+		}
+
+		// if we got here we are not the leader anymore
+		l.onOusted(newCtx)
+		l.closeSession()
+
+		if newCtx.Err() != nil {
+			return
+		}
+	}
+}
+
+// campaign creates a new session and campaigns upon it until elected.
+func (l *EtcdLeaderManager) campaign(ctx context.Context) (<-chan struct{}, error) {
+	ttl := int(l.Lease.Seconds())
+	if ttl <= 0 {
+		ttl = 1
+	}
+
+	sess, err := concurrency.NewSession(
+		l.client, concurrency.WithTTL(ttl), concurrency.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create etcd session")
+	}
+
+	election := concurrency.NewElection(sess, l.electionKey)
+
+	l.sessMu.Lock()
+	l.session = sess
+	l.sessMu.Unlock()
+
+	if err := election.Campaign(ctx, l.ID); err != nil {
+		return nil, errors.WithMessage(err, "failed to campaign")
+	}
+
+	l.sessMu.Lock()
+	l.election = election
+	l.sessMu.Unlock()
+
+	return sess.Done(), nil
+}
+
+// closeSession closes the current session if any, which revokes the lease so that the leadership
+// (if held) is resigned immediately.
+func (l *EtcdLeaderManager) closeSession() error {
+	l.sessMu.Lock()
+	defer l.sessMu.Unlock()
+
+	sess := l.session
+	l.session, l.election = nil, nil
+
+	if sess == nil {
+		return nil
+	}
+
+	// Revoke the lease explicitly rather than closing the session, which revokes upon the session
+	// context that might be canceled already, in which case the leadership would not be resigned
+	// until the lease expired.
+	sess.Orphan()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.Lease)
+	defer cancel()
+
+	_, err := l.client.Revoke(ctx, sess.Lease())
+	if errors.Is(err, rpctypes.ErrLeaseNotFound) { // lease expired already
+		return nil
+	}
+
+	return err
+}
+
+// Stop stops the leader election process and resigns from the leadership if appliable.
+func (l *EtcdLeaderManager) Stop() error {
+	if v := l.cancel.Load(); v != nil {
+		if cancelFn, ok := v.(context.CancelFunc); ok {
+			cancelFn()
+		}
+	}
+
+	return l.closeSession()
+}
+
+// OnElected registers a callback function to be invoked on leader elected.
+func (l *EtcdLeaderManager) OnElected(cb ElectedCallback) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.electedCallbacks = append(l.electedCallbacks, cb)
+}
+
+// onElected is called after the current process is elected as the leader.
+func (l *EtcdLeaderManager) onElected(ctx context.Context) {
+	logrus.WithFields(logrus.Fields{
+		"electionKey": l.electionKey,
+		"leaderID":    l.ID,
+	}).Info("Leader elected")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, cb := range l.electedCallbacks {
+		cb(ctx, l)
+	}
+
+	atomic.StoreInt32(&l.electionStatus, StatusElected)
+}
+
+// OnOusted registers a callback function to be invoked on leader ousted.
+func (l *EtcdLeaderManager) OnOusted(cb OustedCallback) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.oustedCallbacks = append(l.oustedCallbacks, cb)
+}
+
+// onOusted is called after the current process is not the leader anymore,
+// either because of session lease expired or context canceled.
+func (l *EtcdLeaderManager) onOusted(ctx context.Context) {
+	logrus.WithFields(logrus.Fields{
+		"electionKey": l.electionKey,
+		"leaderID":    l.ID,
+	}).Info("Leader ousted")
+
+	atomic.StoreInt32(&l.electionStatus, StatusOusted)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, cb := range l.oustedCallbacks {
+		cb(ctx, l)
+	}
+}
+
+// OnError registers a callback function to be invoked on election error.
+func (l *EtcdLeaderManager) OnError(cb ErrorCallback) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.errorCallbacks = append(l.errorCallbacks, cb)
+}
+
+// onError is called when an error occurs during the election process.
+func (l *EtcdLeaderManager) onError(ctx context.Context, err error) {
+	logrus.WithFields(logrus.Fields{
+		"electionKey": l.electionKey,
+		"leaderID":    l.ID,
+	}).WithError(err).Info("Leader election error")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, cb := range l.errorCallbacks {
+		cb(ctx, l, err)
+	}
+}
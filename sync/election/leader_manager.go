@@ -2,6 +2,7 @@ package election
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,6 +18,13 @@ import (
 
 type LeaderStatus = int32
 
+const (
+	// leader election backends
+	BackendMysql = "mysql"
+	BackendRedis = "redis"
+	BackendEtcd  = "etcd"
+)
+
 const (
 	StatusInit LeaderStatus = iota
 	StatusElected
@@ -61,18 +69,37 @@ func MustNewLeaderManagerFromViper(dlm *dlock.LockManager, elecKey string) Leade
 	var conf Config
 	viper.MustUnmarshalKey("sync.election", &conf)
 
-	if conf.Enabled {
-		logrus.WithField("config", conf).Info("HA leader election enabled")
+	if !conf.Enabled {
+		return &noopLeaderManager{}
+	}
+
+	logrus.WithField("config", conf).Info("HA leader election enabled")
+
+	switch strings.ToLower(conf.Backend) {
+	case BackendMysql:
 		return NewDlockLeaderManager(dlm, conf, elecKey)
+	case BackendRedis:
+		dlm := dlock.NewLockManager(MustNewRedisBackend(conf.Redis))
+		return NewDlockLeaderManager(dlm, conf, elecKey)
+	case BackendEtcd:
+		return NewEtcdLeaderManager(MustNewEtcdClient(conf.Etcd), conf, elecKey)
+	default:
+		logrus.WithField("backend", conf.Backend).Fatal("Invalid leader election backend")
 	}
 
-	return &noopLeaderManager{}
+	return nil
 }
 
 // Config holds the configuration for the leader election.
 type Config struct {
 	// Leader election enabled or not?
 	Enabled bool
+	// Election backend, available options are `mysql`, `redis` and `etcd`
+	Backend string `default:"mysql"`
+	// Redis backend configurations
+	Redis RedisConfig
+	// Etcd backend configurations
+	Etcd EtcdConfig
 	// Unique identifier for the leader
 	ID string `default:"leader"`
 	// Duration of the leader term
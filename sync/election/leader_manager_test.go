@@ -3,18 +3,25 @@ package election
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/go-conflux-util/dlock"
 	"github.com/Conflux-Chain/go-conflux-util/store/mysql"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/mcuadros/go-defaults"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
 	"gorm.io/gorm"
 )
 
@@ -92,7 +99,173 @@ func TestLeaderManager(t *testing.T) {
 		t.SkipNow()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
+	dlm := dlock.NewLockManager(testMysqlBe)
+	testLeaderManager(t, 50, 25*time.Second, func(conf Config) LeaderManager {
+		return NewDlockLeaderManager(dlm, conf, elecKey)
+	})
+}
+
+func TestRedisLeaderManager(t *testing.T) {
+	var urls []string
+	for i := 0; i < 3; i++ {
+		mr := miniredis.RunT(t)
+		urls = append(urls, "redis://"+mr.Addr())
+	}
+
+	dlm := dlock.NewLockManager(MustNewRedisBackend(RedisConfig{Urls: urls, Timeout: time.Second}))
+	testLeaderManager(t, 10, 10*time.Second, func(conf Config) LeaderManager {
+		return NewDlockLeaderManager(dlm, conf, elecKey)
+	})
+}
+
+// newTestEtcdClient starts an embedded single node etcd server, and returns the client connected to it.
+func newTestEtcdClient(t *testing.T) *clientv3.Client {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+
+	clientUrl, peerUrl := newTestEtcdUrl(t), newTestEtcdUrl(t)
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{clientUrl}, []url.URL{clientUrl}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{peerUrl}, []url.URL{peerUrl}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	server, err := embed.StartEtcd(cfg)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Embedded etcd server not ready in time")
+	}
+
+	client := MustNewEtcdClient(EtcdConfig{Endpoints: []string{clientUrl.String()}, DialTimeout: 5 * time.Second})
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+// newTestEtcdUrl returns a local url with a free port.
+func newTestEtcdUrl(t *testing.T) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	return url.URL{Scheme: "http", Host: listener.Addr().String()}
+}
+
+// awaitTestLeader waits until any of the leader managers elected, and returns the elected one.
+func awaitTestLeader(t *testing.T, leaderMans ...*EtcdLeaderManager) *EtcdLeaderManager {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for {
+		for _, lm := range leaderMans {
+			if atomic.LoadInt32(&lm.electionStatus) == StatusElected {
+				return lm
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("No leader elected in time")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestEtcdLeaderManager(t *testing.T) {
+	client := newTestEtcdClient(t)
+
+	testLeaderManager(t, 10, 10*time.Second, func(conf Config) LeaderManager {
+		return NewEtcdLeaderManager(client, conf, elecKey)
+	})
+}
+
+func TestEtcdLeaderManagerLeaseLost(t *testing.T) {
+	client := newTestEtcdClient(t)
+
+	conf := elecConfig
+	conf.ID = "leader"
+	leaderMan := NewEtcdLeaderManager(client, conf, elecKey)
+
+	ousted := make(chan struct{}, 1)
+	leaderMan.OnOusted(func(ctx context.Context, lm LeaderManager) {
+		select {
+		case ousted <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go leaderMan.Campaign(ctx)
+	defer leaderMan.Stop()
+
+	awaitTestLeader(t, leaderMan)
+	assert.NoError(t, leaderMan.Extend(ctx))
+
+	// lease lost, e.g., expired due to network partition
+	leaderMan.sessMu.Lock()
+	leaseID := leaderMan.session.Lease()
+	leaderMan.sessMu.Unlock()
+
+	_, err := client.Revoke(ctx, leaseID)
+	require.NoError(t, err)
+
+	select {
+	case <-ousted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Leader not ousted after lease lost")
+	}
+
+	// leadership can not be extended with the lost lease, but will be campaigned again
+	assert.ErrorIs(t, leaderMan.Extend(ctx), store.ErrLeaderRenewal)
+	awaitTestLeader(t, leaderMan)
+	assert.NoError(t, leaderMan.Extend(ctx))
+}
+
+func TestEtcdLeaderManagerFailover(t *testing.T) {
+	client := newTestEtcdClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var leaderMans []*EtcdLeaderManager
+	for i := 0; i < 2; i++ {
+		conf := elecConfig
+		conf.ID = fmt.Sprintf("leader%d", i)
+
+		lm := NewEtcdLeaderManager(client, conf, elecKey)
+		leaderMans = append(leaderMans, lm)
+
+		go lm.Campaign(ctx)
+		defer lm.Stop()
+	}
+
+	leader := awaitTestLeader(t, leaderMans...)
+	require.NoError(t, leader.Stop())
+	assert.ErrorIs(t, leader.Extend(ctx), store.ErrLeaderRenewal)
+
+	var follower *EtcdLeaderManager
+	for _, lm := range leaderMans {
+		if lm != leader {
+			follower = lm
+		}
+	}
+
+	// follower takes over once the leader resigned
+	assert.Equal(t, follower, awaitTestLeader(t, follower))
+	assert.NoError(t, follower.Extend(ctx))
+}
+
+// testLeaderManager runs multiple election processes concurrently, and checks that the shared
+// integer sequence written by leaders is continuous, which would be broken by multiple leaders.
+func testLeaderManager(
+	t *testing.T, numProcs int, duration time.Duration, newLeaderMan func(conf Config) LeaderManager,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
 	defer cancel()
 
 	start := int64(0)
@@ -101,11 +274,10 @@ func TestLeaderManager(t *testing.T) {
 	wg := sync.WaitGroup{}
 	mu := sync.Mutex{}
 
-	dlm := dlock.NewLockManager(testMysqlBe)
-	for i := 0; i < 50; i++ {
+	for i := 0; i < numProcs; i++ {
 		conf := elecConfig
 		conf.ID = fmt.Sprintf("leader%d", i)
-		leaderMan := NewDlockLeaderManager(dlm, conf, elecKey)
+		leaderMan := newLeaderMan(conf)
 
 		wg.Add(1)
 		go func() {
@@ -0,0 +1,161 @@
+package election
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/dlock"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+var (
+	_ dlock.Backend = (*RedisBackend)(nil)
+
+	// acquires or renews the lock if absent or held by the same nonce
+	redisAcquireScript = redis.NewScript(`
+local v = redis.call("GET", KEYS[1])
+if v == false or v == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0`)
+
+	// releases the lock only if held by the same nonce
+	redisReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+const (
+	// clock drift factor of lease validity
+	redlockClockDriftFactor = 0.01
+)
+
+// RedisConfig holds the configuration for Redis election backend.
+type RedisConfig struct {
+	// URLs of independent Redis instances, and the lock is acquired only if the majority of
+	// instances are locked within the lease validity.
+	Urls []string
+	// Timeout of each Redis operation, which should be much less than the lease
+	Timeout time.Duration `default:"200ms"`
+}
+
+// RedisBackend provides Redlock-style implementation for dlock `Backend` interface upon a set of
+// independent Redis instances.
+type RedisBackend struct {
+	clients []*redis.Client
+	timeout time.Duration
+}
+
+// MustNewRedisBackend creates a new instance of RedisBackend from the provided configuration.
+func MustNewRedisBackend(conf RedisConfig) *RedisBackend {
+	be, err := NewRedisBackend(conf)
+	if err != nil {
+		panic(errors.WithMessage(err, "failed to create redis election backend"))
+	}
+
+	return be
+}
+
+// NewRedisBackend creates a new instance of RedisBackend from the provided configuration.
+func NewRedisBackend(conf RedisConfig) (*RedisBackend, error) {
+	if len(conf.Urls) == 0 {
+		return nil, errors.New("no redis url configured")
+	}
+
+	clients := make([]*redis.Client, 0, len(conf.Urls))
+	for _, url := range conf.Urls {
+		opt, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid redis url %v", url)
+		}
+
+		clients = append(clients, redis.NewClient(opt))
+	}
+
+	return &RedisBackend{clients: clients, timeout: conf.Timeout}, nil
+}
+
+// WriteEntry acquires or renews the lock on the majority of Redis instances within the lease
+// validity, otherwise the lock is released from all instances.
+func (r *RedisBackend) WriteEntry(ctx context.Context, key, nonce string, lease time.Duration) (bool, error) {
+	start := time.Now()
+
+	locked, err := r.forEach(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		res, err := redisAcquireScript.Run(ctx, client, []string{key}, nonce, lease.Milliseconds()).Int()
+		return res == 1, err
+	})
+
+	drift := time.Duration(float64(lease)*redlockClockDriftFactor) + 2*time.Millisecond
+	validity := lease - time.Since(start) - drift
+
+	if locked >= r.quorum() && validity > 0 {
+		return true, nil
+	}
+
+	// release the partially acquired lock
+	r.DelEntry(ctx, key, nonce)
+
+	if locked == 0 && err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// DelEntry releases the lock from all Redis instances if held by the same nonce.
+func (r *RedisBackend) DelEntry(ctx context.Context, key, nonce string) (bool, error) {
+	released, err := r.forEach(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		res, err := redisReleaseScript.Run(ctx, client, []string{key}, nonce).Int()
+		return res == 1, err
+	})
+
+	if released == 0 && err != nil {
+		return false, err
+	}
+
+	return released > 0, nil
+}
+
+// forEach executes the operation on all Redis instances concurrently, and returns the number of
+// succeeded instances along with the last error if any.
+func (r *RedisBackend) forEach(
+	ctx context.Context, op func(context.Context, *redis.Client) (bool, error),
+) (int, error) {
+	type result struct {
+		ok  bool
+		err error
+	}
+
+	resultCh := make(chan result, len(r.clients))
+	for _, client := range r.clients {
+		go func(client *redis.Client) {
+			ctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			ok, err := op(ctx, client)
+			resultCh <- result{ok, err}
+		}(client)
+	}
+
+	var succeeded int
+	var lastErr error
+
+	for range r.clients {
+		res := <-resultCh
+		if res.err != nil {
+			lastErr = res.err
+		} else if res.ok {
+			succeeded++
+		}
+	}
+
+	return succeeded, lastErr
+}
+
+func (r *RedisBackend) quorum() int {
+	return len(r.clients)/2 + 1
+}
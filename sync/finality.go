@@ -0,0 +1,160 @@
+package sync
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/openweb3/web3go"
+	ethtypes "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// sync target tags by finality
+	syncTargetMined     = "mined"
+	syncTargetState     = "state"
+	syncTargetConfirmed = "confirmed"
+	syncTargetFinalized = "finalized"
+
+	// interval to record finalized epoch of the stored data
+	finalityRecordInterval = 3 * time.Second
+)
+
+// syncTargetConfig configurations of the sync target, either by finality tag or confirmation depth.
+type syncTargetConfig struct {
+	// finality tag, available options are `mined`, `state`, `confirmed` and `finalized`
+	Tag string `default:"confirmed"`
+	// confirmation depth behind the latest mined epoch or block, which takes precedence over the
+	// finality tag if greater than 0
+	Depth uint64
+}
+
+func (conf *syncTargetConfig) mustValidate() {
+	switch strings.ToLower(conf.Tag) {
+	case syncTargetMined, syncTargetState, syncTargetConfirmed, syncTargetFinalized:
+	default:
+		logrus.WithField("tag", conf.Tag).Fatal("Invalid sync target tag")
+	}
+}
+
+// cfxSyncTarget returns the core space epoch to sync data against.
+func cfxSyncTarget(cfx sdk.ClientOperator, conf *syncTargetConfig) (uint64, error) {
+	epochTag := types.EpochLatestConfirmed
+
+	switch strings.ToLower(conf.Tag) {
+	case syncTargetMined:
+		epochTag = types.EpochLatestMined
+	case syncTargetState:
+		epochTag = types.EpochLatestState
+	case syncTargetFinalized:
+		epochTag = types.EpochLatestFinalized
+	}
+
+	if conf.Depth > 0 {
+		epochTag = types.EpochLatestMined
+	}
+
+	epoch, err := cfx.GetEpochNumber(epochTag)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed to query the %v epoch number", epochTag)
+	}
+
+	return saturatingSub(epoch.ToInt().Uint64(), conf.Depth), nil
+}
+
+// ethSyncTarget returns the evm space block to sync data against, of which both `mined` and `state`
+// tags follow the latest block, and `confirmed` tag follows the safe block.
+func ethSyncTarget(w3c *web3go.Client, conf *syncTargetConfig) (uint64, error) {
+	blockTag := ethtypes.SafeBlockNumber
+
+	switch strings.ToLower(conf.Tag) {
+	case syncTargetMined, syncTargetState:
+		blockTag = ethtypes.LatestBlockNumber
+	case syncTargetFinalized:
+		blockTag = ethtypes.FinalizedBlockNumber
+	}
+
+	if conf.Depth > 0 {
+		blockTag = ethtypes.LatestBlockNumber
+	}
+
+	block, err := w3c.Eth.BlockByNumber(blockTag, false)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed to query the %v block", blockTag)
+	}
+
+	return saturatingSub(block.Number.Uint64(), conf.Depth), nil
+}
+
+func saturatingSub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+
+	return a - b
+}
+
+// finalityRecorder records the finalized epoch of data stored in db store periodically, so that
+// RPC could tell whether the indexed data is final.
+type finalityRecorder struct {
+	db             *mysql.MysqlStore
+	queryFinalized func() (uint64, error)
+
+	lastRecorded uint64
+	lastUpdated  time.Time
+}
+
+func newCfxFinalityRecorder(cfx sdk.ClientOperator, db *mysql.MysqlStore) *finalityRecorder {
+	return &finalityRecorder{
+		db: db,
+		queryFinalized: func() (uint64, error) {
+			epoch, err := cfx.GetEpochNumber(types.EpochLatestFinalized)
+			if err != nil {
+				return 0, err
+			}
+			return epoch.ToInt().Uint64(), nil
+		},
+	}
+}
+
+func newEthFinalityRecorder(w3c *web3go.Client, db *mysql.MysqlStore) *finalityRecorder {
+	return &finalityRecorder{
+		db: db,
+		queryFinalized: func() (uint64, error) {
+			block, err := w3c.Eth.BlockByNumber(ethtypes.FinalizedBlockNumber, false)
+			if err != nil {
+				return 0, err
+			}
+			return block.Number.Uint64(), nil
+		},
+	}
+}
+
+// update records the finalized epoch, which is bounded by the latest epoch stored in db store.
+func (r *finalityRecorder) update(latestStored uint64) error {
+	if time.Since(r.lastUpdated) < finalityRecordInterval {
+		return nil
+	}
+
+	finalized, err := r.queryFinalized()
+	if err != nil {
+		return errors.WithMessage(err, "failed to query the finalized epoch")
+	}
+
+	finalized = util.MinUint64(finalized, latestStored)
+	if finalized != r.lastRecorded || r.lastUpdated.IsZero() {
+		if err := r.db.StoreFinalizedEpoch(finalized); err != nil {
+			return errors.WithMessage(err, "failed to store the finalized epoch")
+		}
+
+		r.lastRecorded = finalized
+	}
+
+	r.lastUpdated = time.Now()
+	return nil
+}
@@ -0,0 +1,276 @@
+package sync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/util"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/openweb3/web3go"
+	ethtypes "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionPruner is used to prune blockchain data in store periodly by retention policy, which
+// maps the max age of data to the epoch until which data to be pruned through block summaries.
+//
+// Epoch timestamps are retrieved from the block summaries stored in db at first, and fullnode is
+// only requested for the epochs whose block summaries are pruned already, e.g. blocks are retained
+// for a shorter period than event logs.
+type RetentionPruner struct {
+	name   string
+	store  store.Dequeuable
+	config *store.RetentionConfig
+	// function to get the timestamp of the pivot block for some epoch
+	epochTimestamp func(epoch uint64) (uint64, error)
+}
+
+// NewCfxRetentionPruner creates an instance of RetentionPruner to prune core space blockchain data,
+// whose epoch timestamp is retrieved from fullnode if absent in store.
+func NewCfxRetentionPruner(cfx sdk.ClientOperator, s store.Dequeuable, rc *store.RetentionConfig) *RetentionPruner {
+	return newRetentionPruner("CfxRetentionPruner", s, rc, func(epoch uint64) (uint64, error) {
+		block, err := cfx.GetBlockSummaryByEpoch(types.NewEpochNumberUint64(epoch))
+		if err != nil {
+			return 0, err
+		}
+
+		if block == nil || block.Timestamp == nil {
+			return 0, errors.Errorf("invalid block summary for epoch %v", epoch)
+		}
+
+		return block.Timestamp.ToInt().Uint64(), nil
+	})
+}
+
+// NewEthRetentionPruner creates an instance of RetentionPruner to prune evm space blockchain data,
+// whose block timestamp is retrieved from fullnode if absent in store.
+func NewEthRetentionPruner(w3c *web3go.Client, s store.Dequeuable, rc *store.RetentionConfig) *RetentionPruner {
+	return newRetentionPruner("EthRetentionPruner", s, rc, func(bn uint64) (uint64, error) {
+		block, err := w3c.Eth.BlockByNumber(ethtypes.BlockNumber(bn), false)
+		if err != nil {
+			return 0, err
+		}
+
+		if block == nil {
+			return 0, errors.Errorf("invalid block summary for block %v", bn)
+		}
+
+		return block.Timestamp, nil
+	})
+}
+
+func newRetentionPruner(
+	name string, s store.Dequeuable, rc *store.RetentionConfig, nodeEpochTimestamp func(uint64) (uint64, error),
+) *RetentionPruner {
+	epochTimestamp := func(epoch uint64) (uint64, error) {
+		timestamp, ok, err := s.GetEpochTimestamp(epoch)
+		if err != nil {
+			return 0, errors.WithMessage(err, "failed to get epoch timestamp from store")
+		}
+
+		if ok {
+			return timestamp, nil
+		}
+
+		// block summary pruned already
+		return nodeEpochTimestamp(epoch)
+	}
+
+	return &RetentionPruner{name: name, store: s, config: rc, epochTimestamp: epochTimestamp}
+}
+
+func (pruner *RetentionPruner) Prune(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	ticker := time.NewTicker(pruner.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Infof("%v shutdown ok", pruner.name)
+			return
+		case <-ticker.C:
+			if err := pruner.doTicker(ctx); err != nil {
+				logrus.WithError(err).Errorf("%v ticked error", pruner.name)
+			}
+		}
+	}
+}
+
+func (pruner *RetentionPruner) doTicker(ctx context.Context) error {
+	// epochs resolved by max age during this tick
+	resolved := make(map[time.Duration]uint64)
+
+	for _, dt := range store.OpEpochDataTypes {
+		if err := pruner.pruneEpochData(ctx, dt, resolved); err != nil {
+			return errors.WithMessagef(err, "failed to prune epoch %v", dt.Name())
+		}
+	}
+
+	if err := pruner.pruneContractLogs(ctx, resolved); err != nil {
+		return errors.WithMessage(err, "failed to prune contract logs")
+	}
+
+	return nil
+}
+
+func (pruner *RetentionPruner) pruneEpochData(
+	ctx context.Context, dt store.EpochDataType, resolved map[time.Duration]uint64,
+) error {
+	var getEpochRange func() (uint64, uint64, error)
+	var dequeue func(uint64) error
+
+	switch dt {
+	case store.EpochBlock:
+		dequeue = pruner.store.DequeueBlocks
+		getEpochRange = pruner.store.GetBlockEpochRange
+	case store.EpochTransaction:
+		dequeue = pruner.store.DequeueTransactions
+		getEpochRange = pruner.store.GetTransactionEpochRange
+	case store.EpochLog:
+		dequeue = pruner.store.DequeueLogs
+		getEpochRange = pruner.store.GetLogEpochRange
+	default:
+		return unexpectedEpochDataType
+	}
+
+	maxAge := pruner.config.MaxAge(dt)
+	if maxAge <= 0 { // keep full history
+		return nil
+	}
+
+	minEpoch, maxEpoch, err := getEpochRange()
+	if errors.Is(err, store.ErrNotFound) { // no data to prune
+		return nil
+	}
+
+	if err != nil {
+		return errors.WithMessage(err, "failed to get epoch range")
+	}
+
+	epochUntil, ok, err := pruner.resolveEpoch(minEpoch, maxEpoch, maxAge, resolved)
+	if err != nil || !ok {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"maxAge": maxAge, "minEpoch": minEpoch, "epochUntil": epochUntil,
+	}).Infof("%v starting to prune epoch %v", pruner.name, dt.Name())
+
+	return pruner.dequeue(ctx, minEpoch, epochUntil, dequeue)
+}
+
+// pruneContractLogs prunes event logs of contracts which are stored separately or with the retention
+// policy overridden per contract.
+func (pruner *RetentionPruner) pruneContractLogs(ctx context.Context, resolved map[time.Duration]uint64) error {
+	cld, ok := pruner.store.(store.ContractLogDequeuable)
+	if !ok {
+		return nil
+	}
+
+	contracts, err := cld.GetDequeuableLogContracts()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get dequeuable log contracts")
+	}
+
+	for _, contract := range contracts {
+		maxAge, overridden := pruner.config.ContractLogMaxAge(contract)
+		if !overridden {
+			maxAge = pruner.config.Logs
+		}
+
+		if maxAge <= 0 { // keep full history
+			continue
+		}
+
+		minEpoch, maxEpoch, err := cld.GetContractLogEpochRange(contract)
+		if errors.Is(err, store.ErrNotFound) { // no data to prune
+			continue
+		}
+
+		if err != nil {
+			return errors.WithMessagef(err, "failed to get log epoch range of contract %v", contract)
+		}
+
+		epochUntil, ok, err := pruner.resolveEpoch(minEpoch, maxEpoch, maxAge, resolved)
+		if err != nil {
+			return err
+		}
+
+		if !ok { // no data older than the max age
+			continue
+		}
+
+		err = pruner.dequeue(ctx, minEpoch, epochUntil, func(epoch uint64) error {
+			return cld.DequeueContractLogs(contract, epoch)
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "failed to dequeue event logs of contract %v", contract)
+		}
+	}
+
+	return nil
+}
+
+// resolveEpoch resolves the max epoch within the specified epoch range, of which the data is
+// older than the max age. Resolved epochs are cached by max age to reduce repeated searches.
+func (pruner *RetentionPruner) resolveEpoch(
+	minEpoch, maxEpoch uint64, maxAge time.Duration, resolved map[time.Duration]uint64,
+) (uint64, bool, error) {
+	epoch, ok := resolved[maxAge]
+	if !ok {
+		deadline := uint64(time.Now().Add(-maxAge).Unix())
+
+		// search from the oldest epoch not pruned yet rather than the genesis
+		var err error
+		epoch, ok, err = store.SearchEpochByTimestamp(minEpoch, maxEpoch, deadline, pruner.epochTimestamp)
+		if err != nil {
+			return 0, false, errors.WithMessage(err, "failed to search epoch by timestamp")
+		}
+
+		if !ok {
+			return 0, false, nil
+		}
+
+		resolved[maxAge] = epoch
+	}
+
+	if epoch < minEpoch {
+		return 0, false, nil
+	}
+
+	return util.MinUint64(epoch, maxEpoch), true, nil
+}
+
+// dequeue removes epoch data from the oldest epoch to the specified epoch with no more than
+// the max number of epochs at a time.
+func (pruner *RetentionPruner) dequeue(
+	ctx context.Context, minEpoch, epochUntil uint64, dequeue func(uint64) error,
+) error {
+	if pruner.config.MaxPruneEpochs == 0 { // no limit
+		return dequeue(epochUntil)
+	}
+
+	for epoch := minEpoch; epoch <= epochUntil; {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		end := util.MinUint64(epoch+pruner.config.MaxPruneEpochs-1, epochUntil)
+		if err := dequeue(end); err != nil {
+			return errors.WithMessagef(err, "failed to dequeue until epoch %v", end)
+		}
+
+		epoch = end + 1
+	}
+
+	return nil
+}
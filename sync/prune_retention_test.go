@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRetentionStore in-memory dequeuable store which records the dequeued epochs.
+type testRetentionStore struct {
+	store.Dequeuable
+
+	epochTimestamps        map[uint64]uint64    // epoch => timestamp of stored block summary
+	contractLogEpochRanges map[string][2]uint64 // contract => [min epoch, max epoch]
+	dequeuedContractLogs   map[string][]uint64  // contract => dequeued epochs
+}
+
+func (s *testRetentionStore) GetEpochTimestamp(epoch uint64) (uint64, bool, error) {
+	timestamp, ok := s.epochTimestamps[epoch]
+	return timestamp, ok, nil
+}
+
+func (s *testRetentionStore) GetDequeuableLogContracts() ([]string, error) {
+	var contracts []string
+	for contract := range s.contractLogEpochRanges {
+		contracts = append(contracts, contract)
+	}
+
+	return contracts, nil
+}
+
+func (s *testRetentionStore) GetContractLogEpochRange(contract string) (uint64, uint64, error) {
+	er, ok := s.contractLogEpochRanges[contract]
+	if !ok {
+		return 0, 0, store.ErrNotFound
+	}
+
+	return er[0], er[1], nil
+}
+
+func (s *testRetentionStore) DequeueContractLogs(contract string, epochUntil uint64) error {
+	s.dequeuedContractLogs[contract] = append(s.dequeuedContractLogs[contract], epochUntil)
+	return nil
+}
+
+func TestRetentionPrunerPruneContractLogs(t *testing.T) {
+	s := &testRetentionStore{
+		contractLogEpochRanges: map[string][2]uint64{
+			"cfx:big":        {40, 100},
+			"cfx:overridden": {10, 100},
+			"cfx:fullhist":   {0, 100},
+		},
+		dequeuedContractLogs: make(map[string][]uint64),
+	}
+
+	rc := &store.RetentionConfig{
+		MaxPruneEpochs: 10,
+		Logs:           50 * time.Hour,
+		Contracts: []store.ContractRetentionConfig{
+			{Address: "cfx:overridden", Logs: 80 * time.Hour},
+			{Address: "cfx:fullhist", Logs: 0},
+		},
+	}
+
+	// epoch N is generated (100 - N) hours ago
+	now := time.Now()
+	var minSearchedEpoch uint64 = 100
+	pruner := newRetentionPruner("test", s, rc, func(epoch uint64) (uint64, error) {
+		minSearchedEpoch = min(minSearchedEpoch, epoch)
+		return uint64(now.Add(-time.Duration(100-epoch) * time.Hour).Unix()), nil
+	})
+
+	require.NoError(t, pruner.pruneContractLogs(context.Background(), make(map[time.Duration]uint64)))
+
+	// dequeued in batches from the oldest epoch of contract event logs
+	assert.Equal(t, []uint64{49, 50}, s.dequeuedContractLogs["cfx:big"])
+	assert.Equal(t, []uint64{19, 20}, s.dequeuedContractLogs["cfx:overridden"])
+	assert.Empty(t, s.dequeuedContractLogs["cfx:fullhist"])
+
+	// epochs searched from the oldest epoch not pruned yet
+	assert.Equal(t, uint64(10), minSearchedEpoch)
+}
+
+func TestRetentionPrunerEpochTimestampFromStore(t *testing.T) {
+	// epoch N is generated (100 - N) hours ago
+	now := time.Now()
+	epochTimestamp := func(epoch uint64) uint64 {
+		return uint64(now.Add(-time.Duration(100-epoch) * time.Hour).Unix())
+	}
+
+	// block summaries before epoch 30 are pruned already
+	s := &testRetentionStore{
+		epochTimestamps:        make(map[uint64]uint64),
+		contractLogEpochRanges: map[string][2]uint64{"cfx:new": {40, 100}, "cfx:old": {10, 100}},
+		dequeuedContractLogs:   make(map[string][]uint64),
+	}
+	for epoch := uint64(30); epoch <= 100; epoch++ {
+		s.epochTimestamps[epoch] = epochTimestamp(epoch)
+	}
+
+	rc := &store.RetentionConfig{
+		Logs:      50 * time.Hour,
+		Contracts: []store.ContractRetentionConfig{{Address: "cfx:old", Logs: 80 * time.Hour}},
+	}
+
+	var nodeRequestedEpochs []uint64
+	pruner := newRetentionPruner("test", s, rc, func(epoch uint64) (uint64, error) {
+		nodeRequestedEpochs = append(nodeRequestedEpochs, epoch)
+		return epochTimestamp(epoch), nil
+	})
+
+	require.NoError(t, pruner.pruneContractLogs(context.Background(), make(map[time.Duration]uint64)))
+
+	assert.Equal(t, []uint64{50}, s.dequeuedContractLogs["cfx:new"])
+	assert.Equal(t, []uint64{20}, s.dequeuedContractLogs["cfx:old"])
+
+	// fullnode requested only for epochs whose block summaries are pruned
+	assert.NotEmpty(t, nodeRequestedEpochs)
+	for _, epoch := range nodeRequestedEpochs {
+		assert.Less(t, epoch, uint64(30))
+	}
+}
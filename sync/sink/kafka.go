@@ -0,0 +1,225 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var (
+	_ TransactionalSink = (*kafkaSink)(nil)
+)
+
+// KafkaConfig configurations of Kafka sink.
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string `default:"confura"`
+	// compacted topic to store the outbox cursor of published events per space
+	CursorTopic string `default:"confura.cursor"`
+	// prefix of the producer transactional id, which is suffixed by space and must be stable
+	// across restarts so as to fence the stale producer.
+	TransactionalID string `default:"confura.cdc"`
+}
+
+// kafkaSink publishes events into Kafka topic by idempotent and transactional producer. Events of the
+// same space are keyed by space so as to be published into the same partition in order, and event id
+// is attached as message header for deduplication by consumers.
+//
+// The outbox cursor of published events is produced into the cursor topic within the same transaction,
+// so that events and the cursor are committed atomically, and consumers with `read_committed` isolation
+// level will never see events of aborted transactions.
+type kafkaSink struct {
+	conf   *KafkaConfig
+	space  string
+	client *kgo.Client
+}
+
+func newKafkaSink(conf *KafkaConfig, space string) (*kafkaSink, error) {
+	if len(conf.Brokers) == 0 {
+		return nil, errors.New("no kafka brokers configured")
+	}
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(conf.Brokers...),
+		kgo.DefaultProduceTopic(conf.Topic),
+		kgo.TransactionalID(fmt.Sprintf("%v.%v", conf.TransactionalID, space)),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create kafka client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// create the compacted cursor topic if absent, which only retains the latest cursor per space
+	_, err = kadm.NewClient(client).CreateTopic(
+		ctx, 1, -1, map[string]*string{"cleanup.policy": kadm.StringPtr("compact")}, conf.CursorTopic,
+	)
+	if err != nil && !errors.Is(err, kerr.TopicAlreadyExists) {
+		client.Close()
+		return nil, errors.WithMessagef(err, "failed to ensure cursor topic %v", conf.CursorTopic)
+	}
+
+	return &kafkaSink{conf: conf, space: space, client: client}, nil
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, events []*Event) error {
+	return s.publish(ctx, events, nil)
+}
+
+func (s *kafkaSink) PublishWithCursor(ctx context.Context, events []*Event, cursor uint64) error {
+	return s.publish(ctx, events, &cursor)
+}
+
+// publish produces events along with the outbox cursor if specified within a transaction.
+func (s *kafkaSink) publish(ctx context.Context, events []*Event, cursor *uint64) error {
+	records := make([]*kgo.Record, 0, len(events)+1)
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return errors.WithMessage(err, "failed to marshal event")
+		}
+
+		records = append(records, &kgo.Record{
+			Key:     []byte(event.Space),
+			Value:   data,
+			Headers: []kgo.RecordHeader{{Key: "id", Value: []byte(event.ID)}},
+		})
+	}
+
+	if cursor != nil {
+		records = append(records, &kgo.Record{
+			Topic: s.conf.CursorTopic,
+			Key:   []byte(s.space),
+			Value: []byte(strconv.FormatUint(*cursor, 10)),
+		})
+	}
+
+	if err := s.client.BeginTransaction(); err != nil {
+		return errors.WithMessage(err, "failed to begin transaction")
+	}
+
+	if err := s.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		// abort the transaction so that none of the produced records will be visible to consumers
+		if err := s.client.EndTransaction(ctx, kgo.TryAbort); err != nil {
+			logrus.WithError(err).Info("Failed to abort kafka transaction")
+		}
+
+		return errors.WithMessage(err, "failed to produce records")
+	}
+
+	return errors.WithMessage(s.client.EndTransaction(ctx, kgo.TryCommit), "failed to commit transaction")
+}
+
+// Cursor reads the outbox cursor of the last committed transaction from the cursor topic.
+func (s *kafkaSink) Cursor(ctx context.Context) (uint64, bool, error) {
+	// Initialize the producer id at first, which fences the stale producer with the same transactional
+	// id and aborts its pending transaction if any, so that the last stable offset will not be blocked.
+	if _, _, err := s.client.ProducerID(ctx); err != nil {
+		return 0, false, errors.WithMessage(err, "failed to init producer id")
+	}
+
+	admin := kadm.NewClient(s.client)
+
+	starts, err := admin.ListStartOffsets(ctx, s.conf.CursorTopic)
+	if err == nil {
+		err = starts.Error()
+	}
+
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to list start offsets of cursor topic")
+	}
+
+	// last stable offsets, before which all transactions are either committed or aborted
+	ends, err := admin.ListCommittedOffsets(ctx, s.conf.CursorTopic)
+	if err == nil {
+		err = ends.Error()
+	}
+
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to list committed offsets of cursor topic")
+	}
+
+	// partition => end offset, for partitions to read through
+	pending := make(map[int32]int64)
+	offsets := make(map[int32]kgo.Offset)
+
+	ends.Each(func(end kadm.ListedOffset) {
+		if start, ok := starts.Lookup(end.Topic, end.Partition); ok && start.Offset < end.Offset {
+			pending[end.Partition] = end.Offset
+			offsets[end.Partition] = kgo.NewOffset().At(start.Offset)
+		}
+	})
+
+	if len(pending) == 0 { // no cursor published yet
+		return 0, false, nil
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(s.conf.Brokers...),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{s.conf.CursorTopic: offsets}),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		// control records are kept to tell if the end offset is reached
+		kgo.KeepControlRecords(),
+	)
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to create kafka consumer")
+	}
+
+	defer consumer.Close()
+
+	var cursor uint64
+	var found bool
+
+	for len(pending) > 0 {
+		fetches := consumer.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			return 0, false, err
+		}
+
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return 0, false, errors.WithMessagef(errs[0].Err, "failed to fetch partition %v", errs[0].Partition)
+		}
+
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			record := iter.Next()
+
+			end, ok := pending[record.Partition]
+			if !ok || record.Offset >= end {
+				continue
+			}
+
+			if record.Offset+1 >= end {
+				delete(pending, record.Partition)
+			}
+
+			if record.Attrs.IsControl() || string(record.Key) != s.space {
+				continue
+			}
+
+			if cursor, err = strconv.ParseUint(string(record.Value), 10, 64); err != nil {
+				return 0, false, errors.WithMessagef(err, "invalid cursor at offset %v", record.Offset)
+			}
+
+			found = true
+		}
+	}
+
+	return cursor, found, nil
+}
+
+func (s *kafkaSink) Close() error {
+	s.client.Close()
+	return nil
+}
@@ -0,0 +1,57 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Please set the following enviroment before running tests:
+// `TEST_CDC_KAFKA_BROKERS`: comma separated Kafka brokers, e.g. `127.0.0.1:9092`.
+
+func TestKafkaSinkPublishWithCursor(t *testing.T) {
+	brokers := os.Getenv("TEST_CDC_KAFKA_BROKERS")
+	if len(brokers) == 0 {
+		t.SkipNow()
+	}
+
+	// topics unique per run in case of data left by the last run
+	suffix := time.Now().UnixNano()
+	conf := &KafkaConfig{
+		Brokers:         strings.Split(brokers, ","),
+		Topic:           fmt.Sprintf("test.%v", suffix),
+		CursorTopic:     fmt.Sprintf("test.cursor.%v", suffix),
+		TransactionalID: "test.cdc",
+	}
+
+	sink, err := newKafkaSink(conf, "cfx")
+	require.NoError(t, err)
+	defer sink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, ok, err := sink.Cursor(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	events := []*Event{NewRevertEvent("cfx", 1, "0x01"), NewRevertEvent("cfx", 2, "0x02")}
+	require.NoError(t, sink.PublishWithCursor(ctx, events[:1], 1))
+	require.NoError(t, sink.PublishWithCursor(ctx, events[1:], 2))
+
+	// cursor is recovered by another producer, which fences the previous one
+	recovered, err := newKafkaSink(conf, "cfx")
+	require.NoError(t, err)
+	defer recovered.Close()
+
+	cursor, ok, err := recovered.Cursor(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), cursor)
+}
@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// NatsConfig configurations of NATS JetStream sink.
+type NatsConfig struct {
+	Url string `default:"nats://127.0.0.1:4222"`
+	// JetStream stream to persist events, which is created if absent
+	Stream string `default:"CONFURA"`
+	// events are published to subject `<prefix>.<space>.<event type>`
+	SubjectPrefix string `default:"confura"`
+}
+
+// natsSink publishes events into NATS JetStream, with event id as message id for deduplication
+// within the duplicate window of stream.
+type natsSink struct {
+	conf *NatsConfig
+	nc   *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func newNatsSink(conf *NatsConfig) (*natsSink, error) {
+	nc, err := nats.Connect(conf.Url)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to connect to NATS server")
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, errors.WithMessage(err, "failed to init JetStream context")
+	}
+
+	_, err = js.StreamInfo(conf.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     conf.Stream,
+			Subjects: []string{conf.SubjectPrefix + ".>"},
+		})
+	}
+
+	if err != nil {
+		nc.Close()
+		return nil, errors.WithMessagef(err, "failed to ensure stream %v", conf.Stream)
+	}
+
+	return &natsSink{conf: conf, nc: nc, js: js}, nil
+}
+
+func (s *natsSink) Publish(ctx context.Context, events []*Event) error {
+	futures := make([]nats.PubAckFuture, 0, len(events))
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return errors.WithMessage(err, "failed to marshal event")
+		}
+
+		msg := nats.NewMsg(fmt.Sprintf("%v.%v.%v", s.conf.SubjectPrefix, event.Space, event.Type))
+		msg.Data = data
+
+		future, err := s.js.PublishMsgAsync(msg, nats.MsgId(event.ID))
+		if err != nil {
+			return errors.WithMessagef(err, "failed to publish event %v", event.ID)
+		}
+
+		futures = append(futures, future)
+	}
+
+	for i, future := range futures {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-future.Ok():
+		case err := <-future.Err():
+			return errors.WithMessagef(err, "failed to publish event %v", events[i].ID)
+		}
+	}
+
+	return nil
+}
+
+func (s *natsSink) Close() error {
+	return s.nc.Drain()
+}
@@ -0,0 +1,113 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runEmbeddedNatsServer(t *testing.T) *server.Server {
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1, // random port
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second))
+
+	t.Cleanup(ns.Shutdown)
+
+	return ns
+}
+
+func TestNatsSinkPublishDeduplicated(t *testing.T) {
+	ns := runEmbeddedNatsServer(t)
+
+	sink, err := newNatsSink(&NatsConfig{
+		Url: ns.ClientURL(), Stream: "TEST", SubjectPrefix: "test",
+	})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	events := []*Event{
+		{ID: "cfx:1:0x01:0", Space: "cfx", Type: EventBlock, Epoch: 1, PivotHash: "0x01"},
+		{ID: "cfx:1:0x01:1", Space: "cfx", Type: EventTransaction, Epoch: 1, PivotHash: "0x01"},
+		NewRevertEvent("cfx", 1, "0x01"),
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, sink.Publish(ctx, events))
+
+	// re-published events due to failure to remove outbox entries are deduplicated
+	assert.NoError(t, sink.Publish(ctx, events[:2]))
+
+	info, err := sink.js.StreamInfo("TEST")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(events)), info.State.Msgs)
+
+	// events are published in order to subjects by space and event type
+	sub, err := sink.js.SubscribeSync("test.>", nats.DeliverAll())
+	require.NoError(t, err)
+
+	for _, expected := range events {
+		msg, err := sub.NextMsg(time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "test.cfx."+string(expected.Type), msg.Subject)
+
+		var event Event
+		require.NoError(t, json.Unmarshal(msg.Data, &event))
+		assert.Equal(t, expected.ID, event.ID)
+	}
+}
+
+func TestNatsSubscriberInOrder(t *testing.T) {
+	ns := runEmbeddedNatsServer(t)
+
+	conf := NatsConfig{Url: ns.ClientURL(), Stream: "TEST", SubjectPrefix: "test"}
+
+	sink, err := newNatsSink(&conf)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	sub, err := newNatsSubscriber(&conf)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan *Event, 10)
+	go sub.Subscribe(ctx, "cfx", func(event *Event) { received <- event })
+
+	events := []*Event{
+		{ID: "cfx:1:0x01:0", Space: "cfx", Type: EventBlock, Epoch: 1, PivotHash: "0x01"},
+		{ID: "eth:1:0x01:0", Space: "eth", Type: EventBlock, Epoch: 1, PivotHash: "0x01"},
+		{ID: "cfx:1:0x01:1", Space: "cfx", Type: EventLog, Epoch: 1, PivotHash: "0x01"},
+		NewRevertEvent("cfx", 1, "0x01"),
+	}
+
+	// wait for the ordered consumer created
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, sink.Publish(ctx, events))
+
+	// only events of the subscribed space are received in order
+	for _, expected := range []*Event{events[0], events[2], events[3]} {
+		select {
+		case event := <-received:
+			assert.Equal(t, expected.ID, event.ID)
+		case <-time.After(3 * time.Second):
+			require.FailNow(t, "event not received", expected.ID)
+		}
+	}
+}
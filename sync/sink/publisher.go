@@ -0,0 +1,275 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// config key prefix to store the offset of published epoch
+	offsetConfKeyPrefix = "cdc.offset."
+
+	// max number of staged outbox entries to publish at a time
+	flushBatchSize = 100
+)
+
+// Config configurations of change data capture sink.
+type Config struct {
+	Enabled bool
+	// sink type, available options are `nats` and `kafka`
+	Type  string `default:"nats"`
+	Nats  NatsConfig
+	Kafka KafkaConfig
+}
+
+// Store stores the offset of staged epoch and the outbox of staged events, both of which must be
+// updated within the same db transaction as the epoch data stored.
+type Store interface {
+	LoadConfig(confNames ...string) (map[string]interface{}, error)
+	StoreConfigWithTx(dbTx *gorm.DB, confName string, confVal interface{}) error
+	AddCdcOutboxWithTx(dbTx *gorm.DB, outbox *mysql.CdcOutbox) error
+	GetCdcOutboxes(space string, limit int) ([]*mysql.CdcOutbox, error)
+	DelCdcOutboxes(space string, idTo uint64) error
+}
+
+// Publisher publishes change data events of the stored epoch data into sink.
+//
+// Events are staged into the outbox table within the db transaction to store epoch data, along
+// with the offset of staged epoch. Once the transaction committed, staged events are published in
+// order and then removed from the outbox. So events of rolled back transactions are never published.
+//
+// For transactional sink (e.g., Kafka), events are published exactly once, since the outbox cursor is
+// published along with events atomically, and outbox entries up to the published cursor are skipped
+// even if they failed to remove (e.g., process crashed). Otherwise, delivery is at-least-once and
+// re-published events are deduplicated by the event id.
+type Publisher struct {
+	space string
+	sink  Sink
+	store Store
+	mu    sync.Mutex // to publish staged events in order
+
+	// outbox cursor of the last published events for transactional sink, nil if not loaded
+	cursor *uint64
+}
+
+// MustNewPublisherFromViper creates an instance of Publisher from viper config, or nil if disabled.
+func MustNewPublisherFromViper(viperKey, space string, store Store) *Publisher {
+	var conf Config
+	viperutil.MustUnmarshalKey(viperKey, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	var sink Sink
+	var err error
+
+	switch strings.ToLower(conf.Type) {
+	case "nats":
+		sink, err = newNatsSink(&conf.Nats)
+	case "kafka":
+		sink, err = newKafkaSink(&conf.Kafka, space)
+	default:
+		err = errors.Errorf("invalid sink type %v", conf.Type)
+	}
+
+	if err != nil {
+		logrus.WithField("config", conf).WithError(err).Fatal("Failed to create change data sink")
+	}
+
+	return NewPublisher(space, sink, store)
+}
+
+// NewPublisher creates an instance of Publisher with the specified sink.
+func NewPublisher(space string, sink Sink, store Store) *Publisher {
+	return &Publisher{space: space, sink: sink, store: store}
+}
+
+// Offset returns the last staged epoch.
+func (p *Publisher) Offset() (uint64, bool, error) {
+	key := offsetConfKeyPrefix + p.space
+
+	confs, err := p.store.LoadConfig(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	val, ok := confs[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	offset, err := strconv.ParseUint(val.(string), 10, 64)
+	return offset, err == nil, err
+}
+
+// StageEpochs stages events of the epoch data to be stored within the db transaction, and epochs
+// that have already been staged are skipped.
+func (p *Publisher) StageEpochs(dbTx *gorm.DB, dataSlice []*store.EpochData) error {
+	if len(dataSlice) == 0 {
+		return nil
+	}
+
+	offset, ok, err := p.Offset()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get offset")
+	}
+
+	lastEpoch := dataSlice[len(dataSlice)-1].Number
+	if ok && lastEpoch <= offset {
+		return nil
+	}
+
+	var events []*Event
+	for _, data := range dataSlice {
+		if ok && data.Number <= offset {
+			continue
+		}
+
+		epochEvents, err := NewEpochEvents(p.space, data)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to build events for epoch %v", data.Number)
+		}
+
+		events = append(events, epochEvents...)
+	}
+
+	if err := p.stage(dbTx, lastEpoch, events); err != nil {
+		return errors.WithMessage(err, "failed to stage events")
+	}
+
+	return p.storeOffset(dbTx, lastEpoch)
+}
+
+// StageRevert stages revert event of epochs since the specified epoch within the db transaction to
+// revert epoch data, along with the pivot hash of the reverted epoch. Be noted revert event is not
+// staged if the reverted epochs have never been staged.
+func (p *Publisher) StageRevert(dbTx *gorm.DB, revertFrom uint64, pivotHash string) error {
+	offset, ok, err := p.Offset()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get offset")
+	}
+
+	if !ok || revertFrom > offset || revertFrom == 0 {
+		return nil
+	}
+
+	revert := NewRevertEvent(p.space, revertFrom, pivotHash)
+	if err := p.stage(dbTx, revertFrom, []*Event{revert}); err != nil {
+		return errors.WithMessage(err, "failed to stage revert event")
+	}
+
+	return p.storeOffset(dbTx, revertFrom-1)
+}
+
+func (p *Publisher) stage(dbTx *gorm.DB, epoch uint64, events []*Event) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal events")
+	}
+
+	return p.store.AddCdcOutboxWithTx(dbTx, &mysql.CdcOutbox{
+		Space: p.space, Epoch: epoch, Events: string(data),
+	})
+}
+
+// Flush publishes the staged events of committed db transactions in order, and removes them from
+// the outbox once published. Events failed to publish are retained to retry on the next flush.
+func (p *Publisher) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		outboxes, err := p.store.GetCdcOutboxes(p.space, flushBatchSize)
+		if err != nil {
+			return errors.WithMessage(err, "failed to get staged events")
+		}
+
+		if len(outboxes) == 0 {
+			return nil
+		}
+
+		lastId := outboxes[len(outboxes)-1].ID
+		if err := p.publish(ctx, outboxes); err != nil {
+			return err
+		}
+
+		if err := p.store.DelCdcOutboxes(p.space, lastId); err != nil {
+			return errors.WithMessage(err, "failed to remove published events")
+		}
+
+		if len(outboxes) < flushBatchSize {
+			return nil
+		}
+	}
+}
+
+// publish publishes events of the outbox entries, which skips the entries already published for
+// transactional sink.
+func (p *Publisher) publish(ctx context.Context, outboxes []*mysql.CdcOutbox) error {
+	ts, transactional := p.sink.(TransactionalSink)
+
+	if transactional {
+		if p.cursor == nil {
+			cursor, ok, err := ts.Cursor(ctx)
+			if err != nil {
+				return errors.WithMessage(err, "failed to get published cursor")
+			}
+
+			if !ok {
+				cursor = 0
+			}
+
+			p.cursor = &cursor
+		}
+
+		i := sort.Search(len(outboxes), func(i int) bool { return outboxes[i].ID > *p.cursor })
+		if outboxes = outboxes[i:]; len(outboxes) == 0 {
+			return nil
+		}
+	}
+
+	var events []*Event
+	for _, outbox := range outboxes {
+		var staged []*Event
+		if err := json.Unmarshal([]byte(outbox.Events), &staged); err != nil {
+			return errors.WithMessagef(err, "failed to unmarshal staged events #%v", outbox.ID)
+		}
+
+		events = append(events, staged...)
+	}
+
+	if !transactional {
+		return errors.WithMessage(p.sink.Publish(ctx, events), "failed to publish events")
+	}
+
+	lastId := outboxes[len(outboxes)-1].ID
+	if err := ts.PublishWithCursor(ctx, events, lastId); err != nil {
+		// reload the cursor, since the transaction might be committed or not
+		p.cursor = nil
+		return errors.WithMessage(err, "failed to publish events")
+	}
+
+	p.cursor = &lastId
+	return nil
+}
+
+func (p *Publisher) storeOffset(dbTx *gorm.DB, offset uint64) error {
+	err := p.store.StoreConfigWithTx(dbTx, offsetConfKeyPrefix+p.space, strconv.FormatUint(offset, 10))
+	return errors.WithMessage(err, "failed to store offset")
+}
+
+func (p *Publisher) Close() error {
+	return p.sink.Close()
+}
@@ -0,0 +1,164 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type testStore struct {
+	confs    map[string]interface{}
+	outboxes []*mysql.CdcOutbox
+	nextId   uint64
+}
+
+func (s *testStore) LoadConfig(confNames ...string) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	for _, name := range confNames {
+		if val, ok := s.confs[name]; ok {
+			res[name] = val
+		}
+	}
+
+	return res, nil
+}
+
+func (s *testStore) StoreConfigWithTx(dbTx *gorm.DB, confName string, confVal interface{}) error {
+	s.confs[confName] = confVal
+	return nil
+}
+
+func (s *testStore) AddCdcOutboxWithTx(dbTx *gorm.DB, outbox *mysql.CdcOutbox) error {
+	s.nextId++
+	outbox.ID = s.nextId
+	s.outboxes = append(s.outboxes, outbox)
+	return nil
+}
+
+func (s *testStore) GetCdcOutboxes(space string, limit int) ([]*mysql.CdcOutbox, error) {
+	return s.outboxes[:min(limit, len(s.outboxes))], nil
+}
+
+func (s *testStore) DelCdcOutboxes(space string, idTo uint64) error {
+	for len(s.outboxes) > 0 && s.outboxes[0].ID <= idTo {
+		s.outboxes = s.outboxes[1:]
+	}
+
+	return nil
+}
+
+type testSink struct {
+	events []*Event
+	err    error
+}
+
+func (s *testSink) Publish(ctx context.Context, events []*Event) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *testSink) Close() error { return nil }
+
+func TestPublisherPublishAfterCommitted(t *testing.T) {
+	store := &testStore{confs: make(map[string]interface{})}
+	sink := &testSink{err: errors.New("sink unavailable")}
+	publisher := NewPublisher("cfx", sink, store)
+
+	// events are staged only within the db transaction
+	require.NoError(t, publisher.StageRevert(nil, 0, "0x00"))
+	require.NoError(t, publisher.StageRevert(nil, 1, "0x01"))
+	assert.Empty(t, store.outboxes)
+
+	require.NoError(t, store.StoreConfigWithTx(nil, offsetConfKeyPrefix+"cfx", "5"))
+	require.NoError(t, publisher.StageRevert(nil, 5, "0x05"))
+	require.NoError(t, publisher.StageRevert(nil, 3, "0x03"))
+	assert.Len(t, store.outboxes, 2)
+	assert.Empty(t, sink.events)
+
+	offset, ok, err := publisher.Offset()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), offset)
+
+	// staged events retained if failed to publish
+	assert.Error(t, publisher.Flush(context.Background()))
+	assert.Len(t, store.outboxes, 2)
+
+	sink.err = nil
+	require.NoError(t, publisher.Flush(context.Background()))
+	assert.Empty(t, store.outboxes)
+
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, uint64(5), sink.events[0].Epoch)
+	assert.Equal(t, uint64(3), sink.events[1].Epoch)
+}
+
+// testTransactionalSink commits events along with the cursor, and fails after committed if `errAfterCommit`
+// specified to simulate the unknown transaction outcome.
+type testTransactionalSink struct {
+	testSink
+	cursor         *uint64
+	errAfterCommit error
+}
+
+func (s *testTransactionalSink) PublishWithCursor(ctx context.Context, events []*Event, cursor uint64) error {
+	if err := s.Publish(ctx, events); err != nil {
+		return err
+	}
+
+	s.cursor = &cursor
+	return s.errAfterCommit
+}
+
+func (s *testTransactionalSink) Cursor(ctx context.Context) (uint64, bool, error) {
+	if s.cursor == nil {
+		return 0, false, nil
+	}
+
+	return *s.cursor, true, nil
+}
+
+func TestPublisherPublishExactlyOnce(t *testing.T) {
+	store := &testStore{confs: make(map[string]interface{})}
+	require.NoError(t, store.StoreConfigWithTx(nil, offsetConfKeyPrefix+"cfx", "10"))
+
+	// outbox entry #1 published already, but failed to remove before process crashed
+	published := uint64(1)
+	sink := &testTransactionalSink{cursor: &published}
+	publisher := NewPublisher("cfx", sink, store)
+
+	for _, epoch := range []uint64{10, 8, 6} {
+		require.NoError(t, publisher.StageRevert(nil, epoch, "0x00"))
+	}
+
+	require.NoError(t, publisher.Flush(context.Background()))
+	assert.Empty(t, store.outboxes)
+	assert.Equal(t, uint64(3), *sink.cursor)
+
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, uint64(8), sink.events[0].Epoch)
+	assert.Equal(t, uint64(6), sink.events[1].Epoch)
+
+	// transaction committed, but failed to acknowledge
+	require.NoError(t, publisher.StageRevert(nil, 4, "0x00"))
+	sink.errAfterCommit = errors.New("commit timeout")
+	assert.Error(t, publisher.Flush(context.Background()))
+	assert.Len(t, store.outboxes, 1)
+	assert.Len(t, sink.events, 3)
+
+	// published cursor reloaded to skip the committed events
+	sink.errAfterCommit = nil
+	require.NoError(t, publisher.Flush(context.Background()))
+	assert.Empty(t, store.outboxes)
+	assert.Len(t, sink.events, 3)
+	assert.Equal(t, uint64(4), *sink.cursor)
+}
@@ -0,0 +1,140 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/pkg/errors"
+)
+
+// EventType type of change data event.
+type EventType string
+
+const (
+	EventBlock       EventType = "block"
+	EventTransaction EventType = "transaction"
+	EventLog         EventType = "log"
+	// chain data of epochs since some epoch are reverted due to chain reorg
+	EventRevert EventType = "revert"
+)
+
+// Event change data event of the indexed chain data, which is published in the same order as
+// epoch data stored.
+type Event struct {
+	// deterministic event id, which is used for deduplication by message broker or consumers
+	ID    string    `json:"id"`
+	Space string    `json:"space"`
+	Type  EventType `json:"type"`
+	// epoch (or block for evm space) number of the stored chain data, or the epoch from which
+	// (inclusive) chain data reverted.
+	Epoch uint64 `json:"epoch"`
+	// pivot block hash of the epoch, or the reverted pivot block hash for revert event
+	PivotHash string `json:"pivotHash"`
+	// payload of block summary, transaction with receipt or event log
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// TransactionData payload of transaction event.
+type TransactionData struct {
+	Transaction *types.Transaction        `json:"transaction"`
+	Receipt     *types.TransactionReceipt `json:"receipt"`
+}
+
+// LogData payload of event log.
+type LogData struct {
+	types.Log
+	// extended fields of evm space event log, e.g., log type
+	Extra *store.LogExtra `json:"extra,omitempty"`
+}
+
+// Sink publishes change data events into message broker, e.g., Kafka or NATS.
+type Sink interface {
+	// Publish publishes events in order, which returns until all events are acknowledged.
+	Publish(ctx context.Context, events []*Event) error
+	Close() error
+}
+
+// TransactionalSink publishes events along with the outbox cursor of the last event atomically, so
+// that events are published exactly once by skipping the outbox entries up to the published cursor.
+type TransactionalSink interface {
+	Sink
+	// PublishWithCursor publishes events in order along with the outbox cursor within a transaction.
+	PublishWithCursor(ctx context.Context, events []*Event, cursor uint64) error
+	// Cursor returns the outbox cursor of the last published events.
+	Cursor(ctx context.Context) (uint64, bool, error)
+}
+
+// NewEpochEvents builds change data events of the epoch data, which is in order of blocks, and
+// executed transactions each followed by its event logs within the block.
+func NewEpochEvents(space string, data *store.EpochData) ([]*Event, error) {
+	pivotHash := data.GetPivotBlock().Hash.String()
+
+	var events []*Event
+	add := func(et EventType, payload interface{}) error {
+		rawData, err := json.Marshal(payload)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to marshal %v", et)
+		}
+
+		events = append(events, &Event{
+			ID:        fmt.Sprintf("%v:%v:%v:%v", space, data.Number, pivotHash, len(events)),
+			Space:     space,
+			Type:      et,
+			Epoch:     data.Number,
+			PivotHash: pivotHash,
+			Data:      rawData,
+		})
+
+		return nil
+	}
+
+	for _, block := range data.Blocks {
+		if err := add(EventBlock, util.GetSummaryOfBlock(block)); err != nil {
+			return nil, err
+		}
+
+		for i := range block.Transactions {
+			tx := &block.Transactions[i]
+			receipt := data.Receipts[tx.Hash]
+			receiptExt := data.ReceiptExts[tx.Hash]
+
+			// Skip transactions that unexecuted in block.
+			if receipt == nil || !util.IsTxExecutedInBlock(tx) {
+				continue
+			}
+
+			if err := add(EventTransaction, &TransactionData{Transaction: tx, Receipt: receipt}); err != nil {
+				return nil, err
+			}
+
+			for j := range receipt.Logs {
+				logData := &LogData{Log: receipt.Logs[j]}
+				if receiptExt != nil && j < len(receiptExt.LogExts) {
+					logData.Extra = receiptExt.LogExts[j]
+				}
+
+				if err := add(EventLog, logData); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// NewRevertEvent builds revert event of epochs since the specified epoch (inclusive), along with
+// the reverted pivot hash to distinguish from reverts of the same epoch at different times.
+func NewRevertEvent(space string, revertFrom uint64, pivotHash string) *Event {
+	return &Event{
+		ID:        fmt.Sprintf("%v:revert:%v:%v", space, revertFrom, pivotHash),
+		Space:     space,
+		Type:      EventRevert,
+		Epoch:     revertFrom,
+		PivotHash: pivotHash,
+	}
+}
@@ -0,0 +1,44 @@
+package sink
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEpochEventsLogExtra(t *testing.T) {
+	blockHash, txHash, logType := types.Hash("0x01"), types.Hash("0x02"), "call"
+	status := hexutil.Uint64(0)
+
+	data := &store.EpochData{
+		Number: 1,
+		Blocks: []*types.Block{{
+			BlockHeader: types.BlockHeader{Hash: blockHash},
+			Transactions: []types.Transaction{
+				{Hash: txHash, BlockHash: &blockHash, Status: &status},
+			},
+		}},
+		Receipts: map[types.Hash]*types.TransactionReceipt{
+			txHash: {TransactionHash: txHash, Logs: []types.Log{{LogIndex: types.NewBigInt(0)}}},
+		},
+		ReceiptExts: map[types.Hash]*store.ReceiptExtra{
+			txHash: {LogExts: []*store.LogExtra{{LogType: &logType}}},
+		},
+	}
+
+	events, err := NewEpochEvents("eth", data)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, EventLog, events[2].Type)
+
+	var log LogData
+	require.NoError(t, json.Unmarshal(events[2].Data, &log))
+	assert.Equal(t, uint64(0), log.LogIndex.ToInt().Uint64())
+	require.NotNil(t, log.Extra)
+	assert.Equal(t, logType, *log.Extra.LogType)
+}
@@ -0,0 +1,218 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Subscriber subscribes change data events from message broker, e.g., to drive downstream services
+// by the indexed chain data.
+type Subscriber interface {
+	// Subscribe consumes events of the space published since now, and calls the handler in the
+	// published order. It blocks until the context is done or any error occurred.
+	Subscribe(ctx context.Context, space string, handler func(*Event)) error
+	Close() error
+}
+
+// MustNewSubscriberFromViper creates an instance of Subscriber from the sink config, or nil if disabled.
+func MustNewSubscriberFromViper(viperKey string) Subscriber {
+	var conf Config
+	viperutil.MustUnmarshalKey(viperKey, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	var sub Subscriber
+	var err error
+
+	switch strings.ToLower(conf.Type) {
+	case "nats":
+		sub, err = newNatsSubscriber(&conf.Nats)
+	case "kafka":
+		sub, err = newKafkaSubscriber(&conf.Kafka)
+	default:
+		err = errors.Errorf("invalid sink type %v", conf.Type)
+	}
+
+	if err != nil {
+		logrus.WithField("config", conf).WithError(err).Fatal("Failed to create change data subscriber")
+	}
+
+	return sub
+}
+
+// natsSubscriber subscribes events from NATS JetStream by ordered consumer, which is ephemeral and
+// recreated automatically in case of any message gap.
+type natsSubscriber struct {
+	conf *NatsConfig
+	nc   *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func newNatsSubscriber(conf *NatsConfig) (*natsSubscriber, error) {
+	nc, err := nats.Connect(conf.Url)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to connect to NATS server")
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, errors.WithMessage(err, "failed to init JetStream context")
+	}
+
+	return &natsSubscriber{conf: conf, nc: nc, js: js}, nil
+}
+
+func (s *natsSubscriber) Subscribe(ctx context.Context, space string, handler func(*Event)) error {
+	errCh := make(chan error, 1)
+
+	subject := fmt.Sprintf("%v.%v.*", s.conf.SubjectPrefix, space)
+	sub, err := s.js.Subscribe(subject, func(msg *nats.Msg) {
+		var event Event
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			select {
+			case errCh <- errors.WithMessage(err, "invalid event"):
+			default:
+			}
+			return
+		}
+
+		handler(&event)
+	}, nats.OrderedConsumer(), nats.DeliverNew())
+
+	if err != nil {
+		return errors.WithMessagef(err, "failed to subscribe subject %v", subject)
+	}
+
+	defer sub.Unsubscribe()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return err
+	}
+}
+
+func (s *natsSubscriber) Close() error {
+	return s.nc.Drain()
+}
+
+// kafkaSubscriber subscribes events from Kafka topic by partition readers without consumer group,
+// which consume all partitions since the latest offset so that no consumer group is left behind.
+// Events of the same space are keyed by space, and thus consumed in order from the same partition.
+type kafkaSubscriber struct {
+	conf *KafkaConfig
+}
+
+func newKafkaSubscriber(conf *KafkaConfig) (*kafkaSubscriber, error) {
+	if len(conf.Brokers) == 0 {
+		return nil, errors.New("no kafka brokers configured")
+	}
+
+	return &kafkaSubscriber{conf: conf}, nil
+}
+
+func (s *kafkaSubscriber) Subscribe(ctx context.Context, space string, handler func(*Event)) error {
+	partitions, err := s.readPartitions(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "failed to read topic partitions")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgCh := make(chan kafka.Message)
+	errCh := make(chan error, len(partitions))
+
+	for _, partition := range partitions {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   s.conf.Brokers,
+			Topic:     s.conf.Topic,
+			Partition: partition.ID,
+			// never see events of aborted transactions
+			IsolationLevel: kafka.ReadCommitted,
+		})
+
+		if err := reader.SetOffset(kafka.LastOffset); err != nil {
+			reader.Close()
+			return errors.WithMessagef(err, "failed to set offset of partition %v", partition.ID)
+		}
+
+		go s.read(ctx, reader, space, msgCh, errCh)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errCh:
+			return errors.WithMessage(err, "failed to read message")
+		case msg := <-msgCh:
+			var event Event
+			if err := json.Unmarshal(msg.Value, &event); err != nil {
+				return errors.WithMessage(err, "invalid event")
+			}
+
+			handler(&event)
+		}
+	}
+}
+
+// read reads messages of the space from the partition reader until the context is done.
+func (s *kafkaSubscriber) read(
+	ctx context.Context, reader *kafka.Reader, space string, msgCh chan<- kafka.Message, errCh chan<- error,
+) {
+	defer reader.Close()
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		// events of the same space are keyed by space
+		if string(msg.Key) != space {
+			continue
+		}
+
+		select {
+		case msgCh <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *kafkaSubscriber) readPartitions(ctx context.Context) (partitions []kafka.Partition, err error) {
+	for _, broker := range s.conf.Brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err != nil {
+			continue
+		}
+
+		partitions, err = conn.ReadPartitions(s.conf.Topic)
+		conn.Close()
+
+		if err == nil {
+			return partitions, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (s *kafkaSubscriber) Close() error {
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/Conflux-Chain/confura/sync/catchup"
 	"github.com/Conflux-Chain/confura/sync/election"
 	"github.com/Conflux-Chain/confura/sync/monitor"
+	"github.com/Conflux-Chain/confura/sync/sink"
+	"github.com/Conflux-Chain/confura/sync/webhook"
 	citypes "github.com/Conflux-Chain/confura/types"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
@@ -20,7 +22,6 @@ import (
 	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-	"gorm.io/gorm"
 )
 
 const (
@@ -34,6 +35,12 @@ type syncConfig struct {
 	MaxEpochs uint64 `default:"10"`
 	UseBatch  bool   `default:"false"`
 	Sub       syncSubConfig
+	Target    syncTargetConfig
+	// interval to sync data in normal status
+	NormalInterval time.Duration `default:"1s"`
+	// interval to sync data in catching up mode
+	CatchUpInterval time.Duration `default:"1ms"`
+	Adaptive        adaptiveConfig
 }
 
 type syncSubConfig struct {
@@ -41,7 +48,7 @@ type syncSubConfig struct {
 }
 
 // DatabaseSyncer is used to sync blockchain data into database
-// against the latest confirmed epoch.
+// against the configured target epoch, which is the latest confirmed epoch by default.
 type DatabaseSyncer struct {
 	conf *syncConfig
 	// conflux sdk client
@@ -50,8 +57,8 @@ type DatabaseSyncer struct {
 	db *mysql.MysqlStore
 	// epoch number to sync data from
 	epochFrom uint64
-	// maximum number of epochs to sync once
-	maxSyncEpochs uint64
+	// adaptive controller to tune the number of epochs to sync once
+	adaptive *adaptiveController
 	// interval to sync data in normal status
 	syncIntervalNormal time.Duration
 	// interval to sync data in catching up mode
@@ -62,20 +69,23 @@ type DatabaseSyncer struct {
 	elm election.LeaderManager
 	// sync monitor
 	monitor *monitor.Monitor
+	// change data publisher, nil if disabled
+	publisher *sink.Publisher
+	// webhook dispatcher, nil if disabled
+	dispatcher *webhook.Dispatcher
+	// finalized epoch recorder
+	finality *finalityRecorder
 }
 
 // MustNewDatabaseSyncer creates an instance of DatabaseSyncer to sync blockchain data.
 func MustNewDatabaseSyncer(cfx sdk.ClientOperator, db *mysql.MysqlStore) *DatabaseSyncer {
 	var conf syncConfig
 	viperutil.MustUnmarshalKey("sync", &conf)
+	conf.Target.mustValidate()
 
 	dlm := dlock.NewLockManager(dlock.NewMySQLBackend(db.DB()))
 	monitor := monitor.NewMonitor(monitor.NewConfig(), func() (uint64, error) {
-		epoch, err := cfx.GetEpochNumber(types.EpochLatestConfirmed)
-		if err != nil {
-			return 0, err
-		}
-		return epoch.ToInt().Uint64(), nil
+		return cfxSyncTarget(cfx, &conf.Target)
 	})
 
 	syncer := &DatabaseSyncer{
@@ -83,12 +93,15 @@ func MustNewDatabaseSyncer(cfx sdk.ClientOperator, db *mysql.MysqlStore) *Databa
 		cfx:                 cfx,
 		db:                  db,
 		epochFrom:           0,
-		maxSyncEpochs:       conf.MaxEpochs,
-		syncIntervalNormal:  time.Second,
-		syncIntervalCatchUp: time.Millisecond,
+		adaptive:            newAdaptiveController("cfx", &conf.Adaptive, conf.MaxEpochs),
+		syncIntervalNormal:  conf.NormalInterval,
+		syncIntervalCatchUp: conf.CatchUpInterval,
 		monitor:             monitor,
 		epochPivotWin:       newEpochPivotWindow(syncPivotInfoWinCapacity),
 		elm:                 election.MustNewLeaderManagerFromViper(dlm, "sync.cfx"),
+		publisher:           sink.MustNewPublisherFromViper("sync.sink", "cfx", db),
+		dispatcher:          webhook.MustNewDispatcherFromViper("sync.webhook", "cfx", db),
+		finality:            newCfxFinalityRecorder(cfx, db),
 	}
 
 	// Register leader election callbacks
@@ -123,6 +136,10 @@ func (syncer *DatabaseSyncer) Sync(ctx context.Context, wg *sync.WaitGroup) {
 	go syncer.elm.Campaign(ctx)
 	defer syncer.elm.Stop()
 
+	if syncer.dispatcher != nil {
+		go syncer.dispatcher.Run(ctx, wg)
+	}
+
 	syncer.fastCatchup(ctx)
 
 	ticker := time.NewTimer(syncer.syncIntervalCatchUp)
@@ -148,7 +165,10 @@ func (syncer *DatabaseSyncer) Sync(ctx context.Context, wg *sync.WaitGroup) {
 // fast catch-up until the latest stable epoch
 // (maximum between the latest finalized and checkpoint epoch)
 func (syncer *DatabaseSyncer) fastCatchup(ctx context.Context) {
-	catchUpSyncer := catchup.MustNewSyncer(syncer.cfx, syncer.db, syncer.elm)
+	catchUpSyncer := catchup.MustNewSyncer(
+		syncer.cfx, syncer.db, syncer.elm, catchup.WithMonitor(syncer.monitor),
+		catchup.WithPersister(catchupPersister(syncer.elm, syncer.publisher, syncer.dispatcher, syncer.db)),
+	)
 	defer catchUpSyncer.Close()
 
 	catchUpSyncer.Sync(ctx)
@@ -180,14 +200,12 @@ func (syncer *DatabaseSyncer) loadLastSyncEpoch() error {
 	return nil
 }
 
-// Sync data once and return true if catch up to the latest confirmed epoch, otherwise false.
+// Sync data once and return true if catch up to the target epoch, otherwise false.
 func (syncer *DatabaseSyncer) syncOnce(ctx context.Context) (bool, error) {
-	// Fetch latest confirmed epoch from blockchain
-	epoch, err := syncer.cfx.GetEpochNumber(types.EpochLatestConfirmed)
+	// Fetch target epoch from blockchain
+	maxEpochTo, err := cfxSyncTarget(syncer.cfx, &syncer.conf.Target)
 	if err != nil {
-		return false, errors.WithMessage(
-			err, "failed to query the latest confirmed epoch number",
-		)
+		return false, err
 	}
 
 	// Load latest sync epoch from database
@@ -195,8 +213,7 @@ func (syncer *DatabaseSyncer) syncOnce(ctx context.Context) (bool, error) {
 		return false, errors.WithMessage(err, "failed to load last sync epoch")
 	}
 
-	maxEpochTo := epoch.ToInt().Uint64()
-	if syncer.epochFrom > maxEpochTo { // cached up to the latest confirmed epoch?
+	if syncer.epochFrom > maxEpochTo { // cached up to the target epoch?
 		logrus.WithField("epochRange", citypes.RangeUint64{
 			From: syncer.epochFrom,
 			To:   maxEpochTo,
@@ -212,12 +229,20 @@ func (syncer *DatabaseSyncer) syncOnce(ctx context.Context) (bool, error) {
 	})
 	logger.Debug("DB sync started to sync with epoch range")
 
+	fetchStart := time.Now()
+	fetched, fetchErrs := fetchConcurrently(
+		syncSize, syncer.adaptive.fetchConcurrency(), func(i uint64) (store.EpochData, error) {
+			return store.QueryEpochData(syncer.cfx, syncer.epochFrom+i, syncer.conf.UseBatch)
+		},
+	)
+	fetchLatency := time.Since(fetchStart)
+
 	epochDataSlice := make([]*store.EpochData, 0, syncSize)
 	for i := uint64(0); i < syncSize; i++ {
 		epochNo := syncer.epochFrom + uint64(i)
 		eplogger := logger.WithField("epoch", epochNo)
 
-		data, err := store.QueryEpochData(syncer.cfx, epochNo, syncer.conf.UseBatch)
+		data, err := fetched[i], fetchErrs[i]
 
 		// If epoch pivot chain switched, stop the querying right now since it's pointless to query epoch data
 		// that will be reverted late.
@@ -227,6 +252,7 @@ func (syncer *DatabaseSyncer) syncOnce(ctx context.Context) (bool, error) {
 		}
 
 		if err != nil {
+			syncer.adaptive.observeError()
 			return false, errors.WithMessagef(err, "failed to query epoch data for epoch %v", epochNo)
 		}
 
@@ -283,9 +309,11 @@ func (syncer *DatabaseSyncer) syncOnce(ctx context.Context) (bool, error) {
 		return false, nil
 	}
 
-	err = syncer.db.PushnWithFinalizer(epochDataSlice, func(d *gorm.DB) error {
-		return syncer.elm.Extend(ctx)
-	})
+	writeStart := time.Now()
+	err = syncer.db.PushnWithFinalizer(
+		epochDataSlice, pushFinalizer(ctx, syncer.elm, syncer.publisher, syncer.dispatcher, epochDataSlice),
+	)
+	writeLatency := time.Since(writeStart)
 
 	if err != nil {
 		if errors.Is(err, store.ErrLeaderRenewal) {
@@ -296,12 +324,17 @@ func (syncer *DatabaseSyncer) syncOnce(ctx context.Context) (bool, error) {
 		}
 
 		logger.WithError(err).Error("Db syncer failed to save epoch data to db")
+		syncer.adaptive.observeError()
 		return false, errors.WithMessage(err, "failed to save epoch data to db")
 	}
 
+	publishCommitted(ctx, syncer.publisher, syncer.dispatcher)
+
 	syncer.epochFrom += uint64(len(epochDataSlice))
 	syncer.monitor.Update(syncer.epochFrom)
 
+	syncer.adaptive.observe(newSyncStat(epochDataSlice, fetchLatency, writeLatency))
+
 	for _, epdata := range epochDataSlice { // cache epoch pivot info for late use
 		err := syncer.epochPivotWin.Push(epdata.GetPivotBlock())
 		if err != nil {
@@ -329,6 +362,12 @@ func (syncer *DatabaseSyncer) doTicker(ctx context.Context, ticker *time.Timer)
 	complete, err := syncer.syncOnce(ctx)
 	metrics.Registry.Sync.SyncOnceQps("cfx", "db", err).UpdateSince(start)
 
+	if err == nil && syncer.epochFrom > 0 { // record finalized epoch of the stored data
+		if err := syncer.finality.update(syncer.latestStoreEpoch()); err != nil {
+			logrus.WithError(err).Info("Db syncer failed to record finalized epoch")
+		}
+	}
+
 	if err != nil {
 		ticker.Reset(syncer.syncIntervalNormal)
 	} else if complete {
@@ -341,7 +380,7 @@ func (syncer *DatabaseSyncer) doTicker(ctx context.Context, ticker *time.Timer)
 }
 
 func (syncer *DatabaseSyncer) nextEpochTo(maxEpochTo uint64) (uint64, uint64) {
-	epochTo := util.MinUint64(syncer.epochFrom+syncer.maxSyncEpochs-1, maxEpochTo)
+	epochTo := util.MinUint64(syncer.epochFrom+syncer.adaptive.batchSize()-1, maxEpochTo)
 
 	if epochTo < syncer.epochFrom {
 		return epochTo, 0
@@ -370,10 +409,13 @@ func (syncer *DatabaseSyncer) pivotSwitchRevert(ctx context.Context, revertTo ui
 
 	logger.Info("Db syncer reverting epoch data due to pivot chain switch")
 
+	finalizer, err := popFinalizer(ctx, syncer.elm, syncer.publisher, syncer.dispatcher, syncer.db, revertTo)
+	if err != nil {
+		return errors.WithMessage(err, "failed to prepare finalizer to pop epoch data")
+	}
+
 	// remove epoch data from database due to pivot switch
-	err := syncer.db.PopnWithFinalizer(revertTo, func(tx *gorm.DB) error {
-		return syncer.elm.Extend(ctx)
-	})
+	err = syncer.db.PopnWithFinalizer(revertTo, finalizer)
 
 	if err != nil {
 		if errors.Is(err, store.ErrLeaderRenewal) {
@@ -389,6 +431,8 @@ func (syncer *DatabaseSyncer) pivotSwitchRevert(ctx context.Context, revertTo ui
 		return errors.WithMessage(err, "failed to pop epoch data from db")
 	}
 
+	publishCommitted(ctx, syncer.publisher, syncer.dispatcher)
+
 	// remove pivot data of reverted epoch from cache window
 	syncer.epochPivotWin.Popn(revertTo)
 	// update syncer start epoch
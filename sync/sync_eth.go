@@ -8,29 +8,39 @@ import (
 	"github.com/Conflux-Chain/confura/rpc/cfxbridge"
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/catchup"
 	"github.com/Conflux-Chain/confura/sync/election"
 	"github.com/Conflux-Chain/confura/sync/monitor"
+	"github.com/Conflux-Chain/confura/sync/sink"
+	"github.com/Conflux-Chain/confura/sync/webhook"
 	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/chain"
 	"github.com/Conflux-Chain/confura/util/metrics"
 	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
 	"github.com/Conflux-Chain/go-conflux-util/dlock"
 	logutil "github.com/Conflux-Chain/go-conflux-util/log"
 	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
 	"github.com/openweb3/web3go"
-	ethtypes "github.com/openweb3/web3go/types"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-	"gorm.io/gorm"
 )
 
 type syncEthConfig struct {
 	FromBlock uint64 `default:"1"`
 	MaxBlocks uint64 `default:"10"`
+	Target    syncTargetConfig
+	// interval to sync data in normal status
+	NormalInterval time.Duration `default:"1s"`
+	// interval to sync data in catching up mode
+	CatchUpInterval time.Duration `default:"1ms"`
+	Adaptive        adaptiveConfig
 }
 
 // EthSyncer is used to synchronize evm space blockchain data into db store.
 type EthSyncer struct {
 	conf *syncEthConfig
+	// space name for metrics, which is `eth` for evm space or `chain<chainId>` for EVM chains
+	space string
 	// EVM space ETH client
 	w3c *web3go.Client
 	// EVM space chain id
@@ -39,8 +49,10 @@ type EthSyncer struct {
 	db *mysql.MysqlStore
 	// block number to sync chaindata from
 	fromBlock uint64
-	// maximum number of blocks to sync once
+	// maximum number of blocks to sync head once
 	maxSyncBlocks uint64
+	// adaptive controller to tune the number of blocks to sync once
+	adaptive *adaptiveController
 	// interval to sync data in normal status
 	syncIntervalNormal time.Duration
 	// interval to sync data in catching up mode
@@ -51,6 +63,14 @@ type EthSyncer struct {
 	elm election.LeaderManager
 	// sync monitor
 	monitor *monitor.Monitor
+	// change data publisher, nil if disabled
+	publisher *sink.Publisher
+	// webhook dispatcher, nil if disabled
+	dispatcher *webhook.Dispatcher
+	// finalized block recorder
+	finality *finalityRecorder
+	// whether to catch up by the catch-up workers before sync
+	fastCatchupEnabled bool
 }
 
 // MustNewEthSyncer creates an instance of EthSyncer to sync Conflux EVM space chaindata.
@@ -60,29 +80,59 @@ func MustNewEthSyncer(ethC *web3go.Client, db *mysql.MysqlStore) *EthSyncer {
 		logrus.WithError(err).Fatal("Failed to get chain ID from eth space")
 	}
 
+	syncer := mustNewEthSyncer(ethC, uint32(*ethChainId), db, "sync.eth", "eth")
+	syncer.fastCatchupEnabled = true
+
+	return syncer
+}
+
+// MustNewChainEthSyncer creates an instance of EthSyncer to sync chaindata of the EVM chain, which
+// is configured under the chain scoped viper key `sync`.
+func MustNewChainEthSyncer(ethC *web3go.Client, db *mysql.MysqlStore, cc *chain.Config) *EthSyncer {
+	ethChainId, err := ethC.Eth.ChainId()
+	if err != nil {
+		logrus.WithField("chain", cc.Name).WithError(err).Fatal("Failed to get chain ID from EVM chain")
+	}
+
+	if *ethChainId != cc.ChainId {
+		logrus.WithFields(logrus.Fields{
+			"chain":    cc.Name,
+			"expected": cc.ChainId,
+			"actual":   *ethChainId,
+		}).Fatal("Chain ID mismatched for EVM chain")
+	}
+
+	return mustNewEthSyncer(ethC, uint32(cc.ChainId), db, cc.ViperKey("sync"), "chain"+cc.Route())
+}
+
+func mustNewEthSyncer(
+	ethC *web3go.Client, chainId uint32, db *mysql.MysqlStore, viperKey, space string,
+) *EthSyncer {
 	var ethConf syncEthConfig
-	viperutil.MustUnmarshalKey("sync.eth", &ethConf)
+	viperutil.MustUnmarshalKey(viperKey, &ethConf)
+	ethConf.Target.mustValidate()
 
 	dlm := dlock.NewLockManager(dlock.NewMySQLBackend(db.DB()))
 	monitor := monitor.NewMonitor(monitor.NewConfig(), func() (uint64, error) {
-		block, err := ethC.Eth.BlockByNumber(ethtypes.SafeBlockNumber, false)
-		if err != nil {
-			return 0, err
-		}
-		return block.Number.Uint64(), nil
+		return ethSyncTarget(ethC, &ethConf.Target)
 	})
 
 	syncer := &EthSyncer{
 		conf:                &ethConf,
+		space:               space,
 		w3c:                 ethC,
-		chainId:             uint32(*ethChainId),
+		chainId:             chainId,
 		db:                  db,
 		maxSyncBlocks:       ethConf.MaxBlocks,
-		syncIntervalNormal:  time.Second,
-		syncIntervalCatchUp: time.Millisecond,
+		adaptive:            newAdaptiveController(space, &ethConf.Adaptive, ethConf.MaxBlocks),
+		syncIntervalNormal:  ethConf.NormalInterval,
+		syncIntervalCatchUp: ethConf.CatchUpInterval,
 		monitor:             monitor,
 		epochPivotWin:       newEpochPivotWindow(syncPivotInfoWinCapacity),
-		elm:                 election.MustNewLeaderManagerFromViper(dlm, "sync.eth"),
+		elm:                 election.MustNewLeaderManagerFromViper(dlm, viperKey),
+		publisher:           sink.MustNewPublisherFromViper(viperKey+".sink", space, db),
+		dispatcher:          webhook.MustNewDispatcherFromViper(viperKey+".webhook", space, db),
+		finality:            newEthFinalityRecorder(ethC, db),
 	}
 
 	// Register leader election callbacks
@@ -112,6 +162,14 @@ func (syncer *EthSyncer) Sync(ctx context.Context, wg *sync.WaitGroup) {
 	go syncer.elm.Campaign(ctx)
 	defer syncer.elm.Stop()
 
+	if syncer.dispatcher != nil {
+		go syncer.dispatcher.Run(ctx, wg)
+	}
+
+	if syncer.fastCatchupEnabled {
+		syncer.fastCatchup(ctx)
+	}
+
 	ticker := time.NewTimer(syncer.syncIntervalCatchUp)
 	defer ticker.Stop()
 
@@ -132,12 +190,35 @@ func (syncer *EthSyncer) Sync(ctx context.Context, wg *sync.WaitGroup) {
 	}
 }
 
+// fast catch-up until the latest finalized block
+func (syncer *EthSyncer) fastCatchup(ctx context.Context) {
+	catchUpSyncer := catchup.MustNewEthSyncer(
+		syncer.w3c, syncer.chainId, syncer.db, syncer.elm,
+		catchup.WithMonitor(syncer.monitor), catchup.WithFromEpoch(syncer.conf.FromBlock),
+		catchup.WithPersister(catchupPersister(syncer.elm, syncer.publisher, syncer.dispatcher, syncer.db)),
+	)
+	defer catchUpSyncer.Close()
+
+	catchUpSyncer.Sync(ctx)
+}
+
 func (syncer *EthSyncer) doTicker(ctx context.Context, ticker *time.Timer) error {
 	logrus.Debug("ETH sync ticking")
 
 	start := time.Now()
 	complete, err := syncer.syncOnce(ctx)
-	metrics.Registry.Sync.SyncOnceQps("eth", "db", err).UpdateSince(start)
+	metrics.Registry.Sync.SyncOnceQps(syncer.space, "db", err).UpdateSince(start)
+
+	if err == nil && syncer.fromBlock > 0 { // record finalized block of the stored data
+		if err := syncer.finality.update(syncer.latestStoreBlock()); err != nil {
+			logrus.WithError(err).Info("ETH syncer failed to record finalized block")
+		}
+	}
+
+	// index the latest blocks into unsafe head tier once catched up to the safe block
+	if err == nil && complete && syncer.db.Head() != nil {
+		complete, err = syncer.syncHeadOnce(ctx)
+	}
 
 	if err != nil {
 		ticker.Reset(syncer.syncIntervalNormal)
@@ -151,20 +232,18 @@ func (syncer *EthSyncer) doTicker(ctx context.Context, ticker *time.Timer) error
 }
 
 func (syncer *EthSyncer) nextBlockTo(maxBlockTo uint64) (uint64, uint64) {
-	toBlock := util.MinUint64(syncer.fromBlock+syncer.maxSyncBlocks-1, maxBlockTo)
+	toBlock := util.MinUint64(syncer.fromBlock+syncer.adaptive.batchSize()-1, maxBlockTo)
 	syncSize := toBlock - syncer.fromBlock + 1
 	return toBlock, syncSize
 }
 
 // Sync data once and return true if catch up to the most recent block, otherwise false.
 func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
-	latestBlock, err := syncer.w3c.Eth.BlockByNumber(ethtypes.SafeBlockNumber, false)
+	recentBlockNo, err := ethSyncTarget(syncer.w3c, &syncer.conf.Target)
 	if err != nil {
-		return false, errors.WithMessage(err, "failed to query the latest block number")
+		return false, err
 	}
 
-	recentBlockNo := latestBlock.Number.Uint64()
-
 	// Load latest sync block from database
 	if err := syncer.loadLastSyncBlock(); err != nil {
 		return false, errors.WithMessage(err, "failed to load last sync epoch")
@@ -191,12 +270,20 @@ func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
 
 	logger.Debug("ETH syncer started to sync with block range")
 
+	fetchStart := time.Now()
+	fetched, fetchErrs := fetchConcurrently(
+		syncSize, syncer.adaptive.fetchConcurrency(), func(i uint64) (*store.EthData, error) {
+			return store.QueryEthData(ctx, syncer.w3c, syncer.fromBlock+i)
+		},
+	)
+	fetchLatency := time.Since(fetchStart)
+
 	ethDataSlice := make([]*store.EthData, 0, syncSize)
 	for i := uint64(0); i < syncSize; i++ {
 		blockNo := syncer.fromBlock + uint64(i)
 		blogger := logger.WithField("block", blockNo)
 
-		data, err := store.QueryEthData(ctx, syncer.w3c, blockNo)
+		data, err := fetched[i], fetchErrs[i]
 
 		// If chain re-orged, stop the querying right now since it's pointless to query data
 		// that will be reverted late.
@@ -206,6 +293,7 @@ func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
 		}
 
 		if err != nil {
+			syncer.adaptive.observeError()
 			return false, errors.WithMessagef(err, "failed to query eth data for block %v", blockNo)
 		}
 
@@ -257,7 +345,7 @@ func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
 		blogger.Debug("ETH syncer succeeded to query epoch data")
 	}
 
-	metrics.Registry.Sync.SyncOnceSize("eth", "db").Update(int64(len(ethDataSlice)))
+	metrics.Registry.Sync.SyncOnceSize(syncer.space, "db").Update(int64(len(ethDataSlice)))
 
 	if len(ethDataSlice) == 0 { // empty eth data query
 		logger.Debug("ETH syncer skipped due to empty sync range")
@@ -267,13 +355,15 @@ func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
 	// reuse db store logic by converting eth data to epoch data
 	epochDataSlice := make([]*store.EpochData, 0, len(ethDataSlice))
 	for i := 0; i < len(ethDataSlice); i++ {
-		epochData := syncer.convertToEpochData(ethDataSlice[i])
+		epochData := cfxbridge.ConvertToEpochData(ethDataSlice[i], syncer.chainId)
 		epochDataSlice = append(epochDataSlice, epochData)
 	}
 
-	err = syncer.db.PushnWithFinalizer(epochDataSlice, func(d *gorm.DB) error {
-		return syncer.elm.Extend(ctx)
-	})
+	writeStart := time.Now()
+	err = syncer.db.PushnWithFinalizer(
+		epochDataSlice, pushFinalizer(ctx, syncer.elm, syncer.publisher, syncer.dispatcher, epochDataSlice),
+	)
+	writeLatency := time.Since(writeStart)
 
 	if err != nil {
 		if errors.Is(err, store.ErrLeaderRenewal) {
@@ -284,9 +374,12 @@ func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
 		}
 
 		logger.WithError(err).Error("ETH syncer failed to save eth data to ethdb")
+		syncer.adaptive.observeError()
 		return false, errors.WithMessage(err, "failed to save eth data")
 	}
 
+	publishCommitted(ctx, syncer.publisher, syncer.dispatcher)
+
 	for _, edata := range ethDataSlice { // cache eth block info for late use
 		cfxbh := cfxbridge.ConvertBlockHeader(edata.Block, syncer.chainId)
 		err := syncer.epochPivotWin.Push(&cfxtypes.Block{BlockHeader: *cfxbh})
@@ -303,6 +396,8 @@ func (syncer *EthSyncer) syncOnce(ctx context.Context) (bool, error) {
 	syncer.fromBlock += uint64(len(ethDataSlice))
 	syncer.monitor.Update(syncer.fromBlock)
 
+	syncer.adaptive.observe(newSyncStat(epochDataSlice, fetchLatency, writeLatency))
+
 	logger.WithFields(logrus.Fields{
 		"newSyncFrom":   syncer.fromBlock,
 		"finalSyncSize": len(ethDataSlice),
@@ -327,10 +422,13 @@ func (syncer *EthSyncer) reorgRevert(ctx context.Context, revertTo uint64) error
 		return nil
 	}
 
+	finalizer, err := popFinalizer(ctx, syncer.elm, syncer.publisher, syncer.dispatcher, syncer.db, revertTo)
+	if err != nil {
+		return errors.WithMessage(err, "failed to prepare finalizer to pop eth data")
+	}
+
 	// remove block data from database due to chain re-org
-	err := syncer.db.PopnWithFinalizer(revertTo, func(d *gorm.DB) error {
-		return syncer.elm.Extend(ctx)
-	})
+	err = syncer.db.PopnWithFinalizer(revertTo, finalizer)
 
 	if err != nil {
 		if errors.Is(err, store.ErrLeaderRenewal) {
@@ -346,6 +444,15 @@ func (syncer *EthSyncer) reorgRevert(ctx context.Context, revertTo uint64) error
 		return errors.WithMessage(err, "failed to pop eth data from ethdb")
 	}
 
+	publishCommitted(ctx, syncer.publisher, syncer.dispatcher)
+
+	// remove unsafe head blocks beyond the reverted block too
+	if syncer.db.Head() != nil {
+		if err := syncer.revertHead(ctx, revertTo); err != nil {
+			logger.WithError(err).Warn("ETH syncer failed to revert unsafe head data due to chain re-org")
+		}
+	}
+
 	// remove block hash of reverted block from cache window
 	syncer.epochPivotWin.Popn(revertTo)
 	// update syncer start block
@@ -397,46 +504,6 @@ func (syncer *EthSyncer) getStoreLatestBlockHash() (string, error) {
 	return pivotHash, err
 }
 
-// convertToEpochData converts evm space block data to core space epoch data. This is used to bridge
-// eth block data with epoch data to reuse code logic eg., db store logic.
-func (syncer *EthSyncer) convertToEpochData(ethData *store.EthData) *store.EpochData {
-	epochData := &store.EpochData{
-		Number:      ethData.Number,
-		Receipts:    make(map[cfxtypes.Hash]*cfxtypes.TransactionReceipt),
-		ReceiptExts: make(map[cfxtypes.Hash]*store.ReceiptExtra),
-	}
-
-	pivotBlock := cfxbridge.ConvertBlock(ethData.Block, syncer.chainId)
-	epochData.Blocks = []*cfxtypes.Block{pivotBlock}
-
-	blockExt := store.ExtractEthBlockExt(ethData.Block)
-	epochData.BlockExts = []*store.BlockExtra{blockExt}
-
-	for txh, rcpt := range ethData.Receipts {
-		txRcpt := cfxbridge.ConvertReceipt(rcpt, syncer.chainId)
-		txHash := cfxbridge.ConvertHash(txh)
-
-		epochData.Receipts[txHash] = txRcpt
-		epochData.ReceiptExts[txHash] = store.ExtractEthReceiptExt(rcpt)
-	}
-
-	// Transaction `status` field is not a standard field for evm-compatible chain, so we have
-	// to manually fill this field from their receipt.
-	for i := range pivotBlock.Transactions {
-		if pivotBlock.Transactions[i].Status != nil {
-			continue
-		}
-
-		txnHash := pivotBlock.Transactions[i].Hash
-		if rcpt, ok := epochData.Receipts[txnHash]; ok && rcpt != nil {
-			txnStatus := rcpt.OutcomeStatus
-			pivotBlock.Transactions[i].Status = &txnStatus
-		}
-	}
-
-	return epochData
-}
-
 func (syncer *EthSyncer) latestStoreBlock() uint64 {
 	if syncer.fromBlock > 0 {
 		return syncer.fromBlock - 1
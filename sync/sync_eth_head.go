@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/Conflux-Chain/confura/rpc/cfxbridge"
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/util"
+	ethtypes "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// syncHeadOnce indexes the latest blocks beyond the safe blocks into the unsafe head tier, and
+// returns true if catched up to the latest block.
+//
+// Chain reorg is handled by parent hash check, in which case the unsafe head blocks will be reverted
+// one by one until continuous again.
+func (syncer *EthSyncer) syncHeadOnce(ctx context.Context) (bool, error) {
+	hs := syncer.db.Head()
+
+	// remove unsafe head blocks that have already been synchronized as safe blocks
+	if syncer.fromBlock > 0 {
+		if err := hs.Prune(syncer.latestStoreBlock()); err != nil {
+			return false, errors.WithMessage(err, "failed to prune unsafe head blocks")
+		}
+	}
+
+	latestBlock, err := syncer.w3c.Eth.BlockByNumber(ethtypes.LatestBlockNumber, false)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to query the latest block number")
+	}
+
+	_, headTo, ok, err := hs.BlockRange()
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to get block range of unsafe head")
+	}
+
+	fromBlock, prevHash := syncer.fromBlock, ""
+	if ok { // continue from the latest unsafe head block
+		if fromBlock, prevHash, err = syncer.nextHeadBlock(ctx, headTo); err != nil {
+			return false, err
+		}
+	} else if prevHash, err = syncer.getStoreLatestBlockHash(); err != nil {
+		return false, errors.WithMessage(err, "failed to get latest block hash")
+	}
+
+	recentBlockNo := latestBlock.Number.Uint64()
+	if fromBlock > recentBlockNo { // catched up to the latest block?
+		return true, nil
+	}
+
+	toBlock := util.MinUint64(fromBlock+syncer.maxSyncBlocks-1, recentBlockNo)
+
+	logger := logrus.WithFields(logrus.Fields{
+		"fromBlock": fromBlock,
+		"toBlock":   toBlock,
+	})
+
+	var ethDataSlice []*store.EthData
+	for bn := fromBlock; bn <= toBlock; bn++ {
+		data, err := store.QueryEthData(ctx, syncer.w3c, bn)
+		if errors.Is(err, store.ErrChainReorged) {
+			logger.WithField("block", bn).WithError(err).Info("ETH syncer failed to query unsafe head data due to re-org")
+			break
+		}
+
+		if err != nil {
+			return false, errors.WithMessagef(err, "failed to query eth data for block %v", bn)
+		}
+
+		parentHash := data.Block.ParentHash.Hex()
+		if len(ethDataSlice) > 0 {
+			prevHash = ethDataSlice[len(ethDataSlice)-1].Block.Hash.Hex()
+		}
+
+		if len(prevHash) > 0 && parentHash != prevHash {
+			if len(ethDataSlice) > 0 { // truncate the batch synced block data until the previous one
+				break
+			}
+
+			if !ok || fromBlock == syncer.fromBlock {
+				// parent hash mismatched with the latest safe block, which will be handled by
+				// the safe block synchronization.
+				logger.WithField("parentHash", parentHash).Debug(
+					"ETH syncer skipped unsafe head sync due to parent hash mismatched with safe block",
+				)
+				return true, nil
+			}
+
+			// revert the latest unsafe head block due to chain reorg
+			return false, syncer.revertHead(ctx, fromBlock-1)
+		}
+
+		ethDataSlice = append(ethDataSlice, data)
+	}
+
+	if len(ethDataSlice) == 0 {
+		return false, nil
+	}
+
+	epochDataSlice := make([]*store.EpochData, 0, len(ethDataSlice))
+	for i := range ethDataSlice {
+		epochDataSlice = append(epochDataSlice, cfxbridge.ConvertToEpochData(ethDataSlice[i], syncer.chainId))
+	}
+
+	err = hs.PushnWithFinalizer(epochDataSlice, func(d *gorm.DB) error {
+		return syncer.elm.Extend(ctx)
+	})
+
+	if err != nil {
+		if errors.Is(err, store.ErrLeaderRenewal) {
+			logger.WithField("leaderIdentity", syncer.elm.Identity()).
+				WithError(err).
+				Info("ETH syncer failed to renew leadership on pushing unsafe head data to db")
+			return false, nil
+		}
+
+		return false, errors.WithMessage(err, "failed to save unsafe head data")
+	}
+
+	logger.WithField("finalSyncSize", len(ethDataSlice)).Debug("ETH syncer succeeded to sync unsafe head data")
+
+	return false, nil
+}
+
+// nextHeadBlock returns the next block number to sync into the unsafe head tier along with the hash
+// of its parent block. Unsafe head blocks will be reverted if not continuous to the safe blocks.
+func (syncer *EthSyncer) nextHeadBlock(ctx context.Context, headTo uint64) (uint64, string, error) {
+	hs := syncer.db.Head()
+
+	if headTo < syncer.fromBlock { // all unsafe head blocks are synchronized as safe blocks
+		blockHash, err := syncer.getStoreLatestBlockHash()
+		return syncer.fromBlock, blockHash, errors.WithMessage(err, "failed to get latest block hash")
+	}
+
+	// the first unsafe head block must be continuous to the latest safe block
+	_, parentHash, _, err := hs.Block(syncer.fromBlock)
+	if err != nil {
+		return 0, "", errors.WithMessage(err, "failed to get the first unsafe head block")
+	}
+
+	safeHash, err := syncer.getStoreLatestBlockHash()
+	if err != nil {
+		return 0, "", errors.WithMessage(err, "failed to get latest block hash")
+	}
+
+	if len(safeHash) > 0 && parentHash != safeHash {
+		if err := syncer.revertHead(ctx, syncer.fromBlock); err != nil {
+			return 0, "", err
+		}
+
+		return syncer.fromBlock, safeHash, nil
+	}
+
+	headHash, _, _, err := hs.Block(headTo)
+	if err != nil {
+		return 0, "", errors.WithMessage(err, "failed to get the latest unsafe head block")
+	}
+
+	return headTo + 1, headHash, nil
+}
+
+// revertHead reverts unsafe head blocks from the specified block number.
+func (syncer *EthSyncer) revertHead(ctx context.Context, revertFrom uint64) error {
+	logger := logrus.WithField("revertFrom", revertFrom)
+
+	err := syncer.db.Head().PopnWithFinalizer(revertFrom, func(d *gorm.DB) error {
+		return syncer.elm.Extend(ctx)
+	})
+
+	if err != nil {
+		if errors.Is(err, store.ErrLeaderRenewal) {
+			logger.WithField("leaderIdentity", syncer.elm.Identity()).
+				WithError(err).
+				Info("ETH syncer failed to renew leadership on popping unsafe head data from db")
+			return nil
+		}
+
+		return errors.WithMessage(err, "failed to revert unsafe head data")
+	}
+
+	logger.Info("ETH syncer reverted unsafe head data due to chain re-org")
+	return nil
+}
@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/election"
+	"github.com/Conflux-Chain/confura/sync/sink"
+	"github.com/Conflux-Chain/confura/sync/webhook"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// pushFinalizer returns the finalizer to push epoch data into db store, which renews the leadership
+// and then stages change data events if sink enabled and webhook payloads if dispatcher enabled,
+// which are published by `publishCommitted` once the db transaction committed.
+func pushFinalizer(
+	ctx context.Context, elm election.LeaderManager,
+	publisher *sink.Publisher, dispatcher *webhook.Dispatcher, dataSlice []*store.EpochData,
+) func(*gorm.DB) error {
+	return func(dbTx *gorm.DB) error {
+		if err := elm.Extend(ctx); err != nil {
+			return err
+		}
+
+		if publisher != nil {
+			if err := publisher.StageEpochs(dbTx, dataSlice); err != nil {
+				return err
+			}
+		}
+
+		if dispatcher != nil {
+			return dispatcher.Stage(dbTx, dataSlice)
+		}
+
+		return nil
+	}
+}
+
+// popFinalizer returns the finalizer to pop epoch data from db store, which renews the leadership
+// and then stages revert event if sink enabled and webhook retract payloads if dispatcher enabled,
+// which are published by `publishCommitted` once the db transaction committed.
+func popFinalizer(
+	ctx context.Context, elm election.LeaderManager,
+	publisher *sink.Publisher, dispatcher *webhook.Dispatcher, db *mysql.MysqlStore, revertTo uint64,
+) (func(*gorm.DB) error, error) {
+	var pivotHash string
+
+	if publisher != nil { // load pivot hash of the reverted epoch before popped
+		var err error
+		if pivotHash, _, err = db.PivotHash(revertTo); err != nil {
+			return nil, errors.WithMessage(err, "failed to get pivot hash of the reverted epoch")
+		}
+	}
+
+	return func(dbTx *gorm.DB) error {
+		if err := elm.Extend(ctx); err != nil {
+			return err
+		}
+
+		if publisher != nil {
+			if err := publisher.StageRevert(dbTx, revertTo, pivotHash); err != nil {
+				return err
+			}
+		}
+
+		if dispatcher != nil {
+			return dispatcher.StageRetract(dbTx, revertTo)
+		}
+
+		return nil
+	}, nil
+}
+
+// catchupPersister returns the persister of catch-up syncer to push epoch data into db store, along
+// with change data events published if sink enabled and matched event logs dispatched to webhooks
+// once committed.
+func catchupPersister(
+	elm election.LeaderManager, publisher *sink.Publisher, dispatcher *webhook.Dispatcher, db *mysql.MysqlStore,
+) func(context.Context, []*store.EpochData) error {
+	return func(ctx context.Context, epochs []*store.EpochData) error {
+		finalizer := pushFinalizer(ctx, elm, publisher, dispatcher, epochs)
+		if err := db.PushnWithFinalizer(epochs, finalizer); err != nil {
+			return err
+		}
+
+		publishCommitted(ctx, publisher, dispatcher)
+		return nil
+	}
+}
+
+// publishCommitted publishes the change data events staged by committed db transactions if sink
+// enabled, and dispatches the staged webhook payloads if dispatcher enabled. Events or payloads
+// failed to publish are retained in the outbox, and will be retried later without blocking the sync.
+func publishCommitted(ctx context.Context, publisher *sink.Publisher, dispatcher *webhook.Dispatcher) {
+	if publisher != nil {
+		if err := publisher.Flush(ctx); err != nil {
+			logrus.WithError(err).Warn("Failed to publish change data events, which will be retried later")
+		}
+	}
+
+	if dispatcher != nil {
+		if err := dispatcher.Flush(); err != nil {
+			logrus.WithError(err).Info("Failed to dispatch webhook payloads, which will be retried later")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultDeadLettersLimit = 20
+	maxDeadLettersLimit     = 1000
+)
+
+// Info webhook info, of which secret is only returned upon registration.
+type Info struct {
+	Name   string          `json:"name"`
+	Url    string          `json:"url"`
+	Secret string          `json:"secret,omitempty"`
+	Filter json.RawMessage `json:"filter"`
+}
+
+// api webhook management RPC APIs.
+type api struct {
+	space      string
+	db         *mysql.MysqlStore
+	dispatcher *Dispatcher
+}
+
+// Register registers a webhook or updates the existing one of the same name, with a random secret
+// generated if not provided.
+func (api *api) Register(name, url string, filter json.RawMessage, secrets ...string) (*Info, error) {
+	var secret string
+	if len(secrets) > 0 {
+		secret = secrets[0]
+	}
+
+	hook, err := NewWebhook(api.space, name, url, filter, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := api.db.StoreWebhook(hook); err != nil {
+		return nil, errors.WithMessage(err, "failed to store webhook")
+	}
+
+	if err := api.dispatcher.reload(); err != nil {
+		return nil, errors.WithMessage(err, "failed to reload webhooks")
+	}
+
+	return &Info{Name: hook.Name, Url: hook.Url, Secret: hook.Secret, Filter: hook.Filter}, nil
+}
+
+// Unregister removes the webhook of the specified name.
+func (api *api) Unregister(name string) (bool, error) {
+	removed, err := api.db.DelWebhook(name)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to delete webhook")
+	}
+
+	if err := api.dispatcher.reload(); err != nil {
+		return false, errors.WithMessage(err, "failed to reload webhooks")
+	}
+
+	return removed, nil
+}
+
+// List returns all registered webhooks.
+func (api *api) List() ([]*Info, error) {
+	hooks, err := api.db.LoadWebhooks()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load webhooks")
+	}
+
+	res := make([]*Info, 0, len(hooks))
+	for _, hook := range hooks {
+		res = append(res, &Info{Name: hook.Name, Url: hook.Url, Filter: hook.Filter})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+// DeadLetters returns the latest dead letters of the specified webhook, or of all webhooks if
+// webhook name is empty.
+func (api *api) DeadLetters(name string, limits ...int) ([]*mysql.WebhookDeadLetter, error) {
+	limit := defaultDeadLettersLimit
+	if len(limits) > 0 && limits[0] > 0 {
+		limit = min(limits[0], maxDeadLettersLimit)
+	}
+
+	return api.db.GetWebhookDeadLetters(name, limit)
+}
+
+// Redeliver redelivers the dead letter of the specified id, which is removed once queued.
+func (api *api) Redeliver(id uint64) error {
+	letter, ok, err := api.db.GetWebhookDeadLetter(id)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get dead letter")
+	}
+
+	if !ok {
+		return errors.Errorf("dead letter %v not found", id)
+	}
+
+	if err := api.dispatcher.redeliver(letter); err != nil {
+		return err
+	}
+
+	_, err = api.db.DelWebhookDeadLetter(id)
+	return err
+}
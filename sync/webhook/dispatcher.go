@@ -0,0 +1,561 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/rpc/ethbridge"
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc"
+	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// max number of staged payloads to enqueue per batch
+	flushOutboxBatchSize = 100
+)
+
+var (
+	errQueueFull = errors.New("delivery queue is full")
+)
+
+// Config configurations of webhook dispatcher.
+type Config struct {
+	Enabled bool
+	// management RPC endpoint, which is disabled if empty
+	Endpoint string
+	// number of delivery workers, and deliveries of the same webhook are handled by the same worker
+	Workers int `default:"4"`
+	// max number of pending deliveries per worker, and deliveries are retained in the outbox to be
+	// enqueued later once exceeded
+	QueueSize int `default:"1000"`
+	// timeout of each delivery attempt
+	Timeout time.Duration `default:"5s"`
+	// max number of delivery attempts before dead-lettered
+	MaxAttempts int `default:"5"`
+	// backoff before retry, which is doubled after each failed attempt up to the max backoff
+	Backoff    time.Duration `default:"1s"`
+	MaxBackoff time.Duration `default:"1m"`
+	// interval to reload webhooks from db store, and enqueue payloads retained in the outbox
+	ReloadInterval time.Duration `default:"10s"`
+	// number of recent epochs to retract delivered payloads on chain reorg
+	RetractWindow uint64 `default:"1000"`
+}
+
+// Store persists webhooks, dead letters of failed deliveries, payloads staged to deliver and
+// payloads delivered within the retract window.
+type Store interface {
+	LoadWebhooks(names ...string) (map[string]*mysql.Webhook, error)
+	AddWebhookDeadLetter(letter *mysql.WebhookDeadLetter) error
+	AddWebhookOutboxesWithTx(dbTx *gorm.DB, outboxes []*mysql.WebhookOutbox) error
+	GetWebhookOutboxes(space string, idAfter uint64, limit int) ([]*mysql.WebhookOutbox, error)
+	DelWebhookOutbox(id uint64) error
+	SaveWebhookWindowsWithTx(dbTx *gorm.DB, space string, windows []*mysql.WebhookWindow, windowSize uint64) error
+	PopWebhookWindowsWithTx(dbTx *gorm.DB, space string, epochFrom uint64) ([]*mysql.WebhookWindow, error)
+}
+
+// hook webhook with parsed log filter.
+type hook struct {
+	*mysql.Webhook
+	filter *store.LogFilter
+}
+
+type delivery struct {
+	outboxId uint64 // staged outbox id, or 0 if redelivered from dead letter
+	id       string
+	hook     *hook
+	body     []byte
+}
+
+// eventLog event log to match with the log filter of webhooks.
+type eventLog struct {
+	contract string
+	topics   []string
+	log      interface{} // core space or evm space event log
+}
+
+// Dispatcher matches event logs of the epoch data against the registered webhooks, and delivers
+// signed JSON payloads to the callback urls with retries and backoff. Deliveries failed after all
+// attempts are dead-lettered into db store, which could be redelivered later.
+//
+// Payloads are staged into the outbox within the same db transaction to store (or revert) epoch
+// data, and only removed from the outbox once delivered or dead-lettered, so that no delivery is
+// lost on crash. Payloads delivered within the recent epochs are also persisted in the same db
+// transaction, which are retracted once the epochs are reverted due to chain reorg.
+type Dispatcher struct {
+	space  string
+	conf   *Config
+	store  Store
+	client *http.Client
+	server *rpc.Server // management RPC server, nil if disabled
+
+	mu    sync.RWMutex
+	hooks map[string]*hook
+
+	queues []chan *delivery
+
+	// staged payloads are enqueued in order, up to the outbox id cursor
+	outboxMu     sync.Mutex
+	outboxCursor uint64
+}
+
+// MustNewDispatcherFromViper creates an instance of Dispatcher from viper config, or nil if disabled.
+func MustNewDispatcherFromViper(viperKey, space string, db *mysql.MysqlStore) *Dispatcher {
+	var conf Config
+	viperutil.MustUnmarshalKey(viperKey, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	d := NewDispatcher(space, &conf, db)
+	if err := d.reload(); err != nil {
+		logrus.WithError(err).Fatal("Failed to load webhooks from db")
+	}
+
+	if len(conf.Endpoint) > 0 {
+		d.server = rpc.MustNewServer("webhook", map[string]interface{}{
+			"webhook": &api{space: space, db: db, dispatcher: d},
+		})
+	}
+
+	return d
+}
+
+// NewDispatcher creates an instance of Dispatcher with the specified store.
+func NewDispatcher(space string, conf *Config, store Store) *Dispatcher {
+	queues := make([]chan *delivery, conf.Workers)
+	for i := range queues {
+		queues[i] = make(chan *delivery, conf.QueueSize)
+	}
+
+	return &Dispatcher{
+		space:  space,
+		conf:   conf,
+		store:  store,
+		client: &http.Client{Timeout: conf.Timeout},
+		hooks:  make(map[string]*hook),
+		queues: queues,
+	}
+}
+
+// Run starts the delivery workers and management RPC server if enabled, and reloads webhooks
+// from db store periodically.
+func (d *Dispatcher) Run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	if d.server != nil {
+		go d.server.MustServeGraceful(ctx, wg, d.conf.Endpoint, rpc.ProtocolHttp)
+	}
+
+	for i := range d.queues {
+		wg.Add(1)
+		go d.work(ctx, wg, d.queues[i])
+	}
+
+	ticker := time.NewTicker(d.conf.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		// enqueue payloads staged before restart or failed to enqueue
+		if err := d.Flush(); err != nil {
+			logrus.WithField("space", d.space).WithError(err).Info("Webhook dispatcher failed to enqueue staged payloads")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.reload(); err != nil {
+				logrus.WithField("space", d.space).WithError(err).Info("Webhook dispatcher failed to reload webhooks")
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) reload() error {
+	webhooks, err := d.store.LoadWebhooks()
+	if err != nil {
+		return err
+	}
+
+	hooks := make(map[string]*hook, len(webhooks))
+	for name, webhook := range webhooks {
+		filter, err := ParseFilter(d.space, webhook.Filter)
+		if err != nil {
+			logrus.WithField("webhook", name).WithError(err).Warn("Invalid webhook log filter")
+			continue
+		}
+
+		hooks[name] = &hook{Webhook: webhook, filter: filter}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.hooks = hooks
+	return nil
+}
+
+func (d *Dispatcher) getHook(name string) (*hook, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	h, ok := d.hooks[name]
+	return h, ok
+}
+
+func (d *Dispatcher) snapshot() []*hook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	hooks := make([]*hook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		hooks = append(hooks, h)
+	}
+
+	return hooks
+}
+
+// Stage stages payloads of matched event logs of the epoch data within the db transaction to store
+// epoch data, which are delivered by `Flush` once the transaction committed.
+func (d *Dispatcher) Stage(dbTx *gorm.DB, dataSlice []*store.EpochData) error {
+	hooks := d.snapshot()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	var outboxes []*mysql.WebhookOutbox
+	var windows []*mysql.WebhookWindow
+
+	for _, data := range dataSlice {
+		pivotHash := data.GetPivotBlock().Hash.String()
+		logs := d.extractLogs(data)
+
+		var payloads []*Payload
+		for _, h := range hooks {
+			var matched []interface{}
+			for _, log := range logs {
+				if h.filter.Match(log.contract, log.topics) {
+					matched = append(matched, log.log)
+				}
+			}
+
+			if len(matched) == 0 {
+				continue
+			}
+
+			payload := newPayload(h.Name, d.space, PayloadLogs, data.Number, pivotHash, matched)
+			payloads = append(payloads, payload)
+
+			outbox, err := d.newOutbox(payload)
+			if err != nil {
+				return err
+			}
+
+			outboxes = append(outboxes, outbox)
+		}
+
+		window := &mysql.WebhookWindow{Space: d.space, Epoch: data.Number}
+		if len(payloads) > 0 {
+			val, err := json.Marshal(payloads)
+			if err != nil {
+				return errors.WithMessage(err, "failed to marshal delivered payloads")
+			}
+
+			window.Payloads = string(val)
+		}
+
+		windows = append(windows, window)
+	}
+
+	if err := d.store.AddWebhookOutboxesWithTx(dbTx, outboxes); err != nil {
+		return errors.WithMessage(err, "failed to stage payloads")
+	}
+
+	if err := d.store.SaveWebhookWindowsWithTx(dbTx, d.space, windows, d.conf.RetractWindow); err != nil {
+		return errors.WithMessage(err, "failed to save delivered payloads of retract window")
+	}
+
+	return nil
+}
+
+// StageRetract stages payloads to retract the payloads delivered since the specified epoch within
+// the db transaction to revert epoch data due to chain reorg, which are delivered by `Flush` once
+// the transaction committed.
+func (d *Dispatcher) StageRetract(dbTx *gorm.DB, revertTo uint64) error {
+	// retract from the latest epoch
+	windows, err := d.store.PopWebhookWindowsWithTx(dbTx, d.space, revertTo)
+	if err != nil {
+		return errors.WithMessage(err, "failed to pop delivered payloads of retract window")
+	}
+
+	var outboxes []*mysql.WebhookOutbox
+	for _, w := range windows {
+		var payloads []*Payload
+		if err := json.Unmarshal([]byte(w.Payloads), &payloads); err != nil {
+			return errors.WithMessagef(err, "failed to unmarshal payloads of epoch %v", w.Epoch)
+		}
+
+		for _, p := range payloads {
+			outbox, err := d.newOutbox(newPayload(p.Webhook, p.Space, PayloadRetract, p.Epoch, p.PivotHash, p.Logs))
+			if err != nil {
+				return err
+			}
+
+			outboxes = append(outboxes, outbox)
+		}
+	}
+
+	if err := d.store.AddWebhookOutboxesWithTx(dbTx, outboxes); err != nil {
+		return errors.WithMessage(err, "failed to stage retract payloads")
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) newOutbox(payload *Payload) (*mysql.WebhookOutbox, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to marshal payload %v", payload.ID)
+	}
+
+	return &mysql.WebhookOutbox{
+		Space:      d.space,
+		Webhook:    payload.Webhook,
+		DeliveryID: payload.ID,
+		Payload:    string(body),
+	}, nil
+}
+
+// Flush enqueues the staged payloads in order to the delivery workers. Once any queue is full,
+// the remaining payloads are retained in the outbox to be enqueued on the next flush.
+func (d *Dispatcher) Flush() error {
+	d.outboxMu.Lock()
+	defer d.outboxMu.Unlock()
+
+	for {
+		outboxes, err := d.store.GetWebhookOutboxes(d.space, d.outboxCursor, flushOutboxBatchSize)
+		if err != nil {
+			return errors.WithMessage(err, "failed to get staged payloads")
+		}
+
+		for _, outbox := range outboxes {
+			if h, ok := d.getHook(outbox.Webhook); ok {
+				dl := &delivery{outboxId: outbox.ID, id: outbox.DeliveryID, hook: h, body: []byte(outbox.Payload)}
+				if !d.tryEnqueue(dl) {
+					return errQueueFull
+				}
+			} else { // webhook removed
+				d.ack(outbox.ID)
+			}
+
+			d.outboxCursor = outbox.ID
+		}
+
+		if len(outboxes) < flushOutboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// ack removes the staged payload from outbox once delivered or dead-lettered.
+func (d *Dispatcher) ack(outboxId uint64) {
+	if outboxId == 0 {
+		return
+	}
+
+	if err := d.store.DelWebhookOutbox(outboxId); err != nil {
+		logrus.WithField("outboxId", outboxId).WithError(err).Warn("Webhook dispatcher failed to remove staged payload")
+	}
+}
+
+func (d *Dispatcher) extractLogs(data *store.EpochData) (logs []eventLog) {
+	for _, block := range data.Blocks {
+		for _, tx := range block.Transactions {
+			receipt := data.Receipts[tx.Hash]
+
+			// Skip transactions that unexecuted in block.
+			if receipt == nil || !util.IsTxExecutedInBlock(&tx) {
+				continue
+			}
+
+			var logExts []*store.LogExtra
+			if rcptExt := data.ReceiptExts[tx.Hash]; rcptExt != nil {
+				logExts = rcptExt.LogExts
+			}
+
+			for i := range receipt.Logs {
+				log := &receipt.Logs[i]
+
+				if networkSpace(d.space) != "eth" {
+					topics := make([]string, len(log.Topics))
+					for j := range log.Topics {
+						topics[j] = log.Topics[j].String()
+					}
+
+					logs = append(logs, eventLog{log.Address.MustGetBase32Address(), topics, log})
+					continue
+				}
+
+				var logExt *store.LogExtra
+				if i < len(logExts) {
+					logExt = logExts[i]
+				}
+
+				ethLog := ethbridge.ConvertLog(log, logExt)
+
+				topics := make([]string, len(ethLog.Topics))
+				for j := range ethLog.Topics {
+					topics[j] = ethLog.Topics[j].Hex()
+				}
+
+				logs = append(logs, eventLog{ethLog.Address.String(), topics, ethLog})
+			}
+		}
+	}
+
+	return logs
+}
+
+func (d *Dispatcher) tryEnqueue(dl *delivery) bool {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(dl.hook.Name))
+
+	select {
+	case d.queues[hasher.Sum32()%uint32(len(d.queues))] <- dl:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context, wg *sync.WaitGroup, queue chan *delivery) {
+	defer wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// pending deliveries are retained in the outbox to deliver after restart
+			return
+		case dl := <-queue:
+			d.deliver(ctx, dl)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, dl *delivery) {
+	backoff := d.conf.Backoff
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := d.post(ctx, dl)
+		metrics.Registry.Sync.WebhookDelivery(d.space, err).UpdateSince(start)
+
+		if err == nil {
+			d.ack(dl.outboxId)
+			return
+		}
+
+		logger := logrus.WithFields(logrus.Fields{
+			"webhook":    dl.hook.Name,
+			"deliveryId": dl.id,
+			"attempt":    attempt,
+		}).WithError(err)
+
+		if attempt >= d.conf.MaxAttempts {
+			logger.Info("Webhook dispatcher failed to deliver payload after all attempts")
+			d.deadLetter(dl, attempt, err)
+			d.ack(dl.outboxId)
+			return
+		}
+
+		logger.Debug("Webhook dispatcher failed to deliver payload and will retry later")
+
+		select {
+		case <-ctx.Done(): // retained in the outbox to deliver after restart
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > d.conf.MaxBackoff {
+			backoff = d.conf.MaxBackoff
+		}
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, dl *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.hook.Url, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, dl.id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(dl.hook.Secret, timestamp, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) deadLetter(dl *delivery, attempts int, cause error) {
+	metrics.Registry.Sync.WebhookDeadLetters(d.space).Inc(1)
+
+	err := d.store.AddWebhookDeadLetter(&mysql.WebhookDeadLetter{
+		Webhook:    dl.hook.Name,
+		DeliveryID: dl.id,
+		Payload:    string(dl.body),
+		Error:      cause.Error(),
+		Attempts:   attempts,
+	})
+
+	if err != nil { // alert
+		logrus.WithFields(logrus.Fields{
+			"webhook":    dl.hook.Name,
+			"deliveryId": dl.id,
+		}).WithError(err).Error("Webhook dispatcher failed to dead-letter payload")
+	}
+}
+
+// redeliver redelivers the dead-lettered payload to the current callback url of webhook.
+func (d *Dispatcher) redeliver(letter *mysql.WebhookDeadLetter) error {
+	h, ok := d.getHook(letter.Webhook)
+	if !ok {
+		return errors.Errorf("webhook %v not found", letter.Webhook)
+	}
+
+	dl := &delivery{id: letter.DeliveryID, hook: h, body: []byte(letter.Payload)}
+	if !d.tryEnqueue(dl) {
+		return errQueueFull
+	}
+
+	return nil
+}
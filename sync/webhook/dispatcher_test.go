@@ -0,0 +1,335 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testContract = "0x8c9f9e2e9c8e2e1e2c0d8e9f2e5c6b7a8d9e0f11"
+	testTopic    = "0x0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+type memStore struct {
+	mu          sync.Mutex
+	hooks       map[string]*mysql.Webhook
+	deadLetters []*mysql.WebhookDeadLetter
+	outboxes    []*mysql.WebhookOutbox
+	nextId      uint64
+	windows     map[uint64]*mysql.WebhookWindow
+}
+
+func (s *memStore) LoadWebhooks(names ...string) (map[string]*mysql.Webhook, error) {
+	return s.hooks, nil
+}
+
+func (s *memStore) AddWebhookDeadLetter(letter *mysql.WebhookDeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = append(s.deadLetters, letter)
+	return nil
+}
+
+func (s *memStore) AddWebhookOutboxesWithTx(dbTx *gorm.DB, outboxes []*mysql.WebhookOutbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, outbox := range outboxes {
+		s.nextId++
+		outbox.ID = s.nextId
+		s.outboxes = append(s.outboxes, outbox)
+	}
+
+	return nil
+}
+
+func (s *memStore) GetWebhookOutboxes(space string, idAfter uint64, limit int) (res []*mysql.WebhookOutbox, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, outbox := range s.outboxes {
+		if outbox.ID > idAfter && len(res) < limit {
+			res = append(res, outbox)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *memStore) DelWebhookOutbox(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, outbox := range s.outboxes {
+		if outbox.ID == id {
+			s.outboxes = append(s.outboxes[:i], s.outboxes[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) SaveWebhookWindowsWithTx(
+	dbTx *gorm.DB, space string, windows []*mysql.WebhookWindow, windowSize uint64,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxEpoch := windows[len(windows)-1].Epoch
+	for epoch := range s.windows {
+		if epoch+windowSize <= maxEpoch {
+			delete(s.windows, epoch)
+		}
+	}
+
+	for _, window := range windows {
+		delete(s.windows, window.Epoch)
+
+		if len(window.Payloads) > 0 {
+			s.windows[window.Epoch] = window
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) PopWebhookWindowsWithTx(dbTx *gorm.DB, space string, epochFrom uint64) (res []*mysql.WebhookWindow, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for epoch, window := range s.windows {
+		if epoch >= epochFrom {
+			res = append(res, window)
+			delete(s.windows, epoch)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Epoch > res[j].Epoch })
+	return res, nil
+}
+
+func newTestDispatcher(t *testing.T, url string) (*Dispatcher, *memStore) {
+	store.MaxLogFilterAddrCount, store.MaxLogFilterTopicCount = 2, 2
+
+	filter := json.RawMessage(`{"address":["` + cfxaddress.MustNewFromHex(testContract, 1).String() + `"]}`)
+	hook, err := NewWebhook("cfx", "test", url, filter, "secret")
+	require.NoError(t, err)
+
+	ms := &memStore{
+		hooks:   map[string]*mysql.Webhook{"test": hook},
+		windows: make(map[uint64]*mysql.WebhookWindow),
+	}
+	d := NewDispatcher("cfx", &Config{
+		Workers: 1, QueueSize: 10, Timeout: time.Second, MaxAttempts: 2,
+		Backoff: time.Millisecond, MaxBackoff: time.Millisecond, RetractWindow: 10,
+	}, ms)
+	require.NoError(t, d.reload())
+
+	return d, ms
+}
+
+func newTestEpochData(epoch uint64, contracts ...string) *store.EpochData {
+	blockHash := types.Hash("0x" + strconv.FormatUint(epoch, 16))
+	txHash := types.Hash("0x0a")
+	status := hexutil.Uint64(0)
+
+	receipt := &types.TransactionReceipt{TransactionHash: txHash}
+	for i, contract := range contracts {
+		receipt.Logs = append(receipt.Logs, types.Log{
+			Address:     cfxaddress.MustNewFromHex(contract, 1),
+			Topics:      []types.Hash{testTopic},
+			EpochNumber: types.NewBigInt(epoch),
+			LogIndex:    types.NewBigInt(uint64(i)),
+		})
+	}
+
+	block := &types.Block{Transactions: []types.Transaction{{Hash: txHash, BlockHash: &blockHash, Status: &status}}}
+	block.Hash = blockHash
+
+	return &store.EpochData{
+		Number:   epoch,
+		Blocks:   []*types.Block{block},
+		Receipts: map[types.Hash]*types.TransactionReceipt{txHash: receipt},
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	store.MaxLogFilterAddrCount, store.MaxLogFilterTopicCount = 1, 1
+
+	_, err := ParseFilter("eth", json.RawMessage(`{"address":["`+testContract+`"],"topics":[["`+testTopic+`"]]}`))
+	assert.NoError(t, err)
+
+	_, err = ParseFilter("eth", json.RawMessage(`{"address":["`+testContract+`","0x0000000000000000000000000000000000000001"]}`))
+	assert.Error(t, err)
+
+	_, err = ParseFilter("eth", json.RawMessage(`{"topics":[null,null,null,null,null]}`))
+	assert.Error(t, err)
+
+	// log filter of EVM chains parsed the same as evm space
+	_, err = ParseFilter("chain8453", json.RawMessage(`{"address":["`+testContract+`"]}`))
+	assert.NoError(t, err)
+}
+
+func TestDispatcherDeliverAndRetract(t *testing.T) {
+	received := make(chan *Payload, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderSignature) != Sign("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var payload Payload
+		json.Unmarshal(body, &payload)
+		received <- &payload
+	}))
+	defer server.Close()
+
+	d, ms := newTestDispatcher(t, server.URL)
+
+	require.NoError(t, d.Stage(nil, []*store.EpochData{
+		newTestEpochData(1, testContract, "0x0000000000000000000000000000000000000001"),
+		newTestEpochData(2, "0x0000000000000000000000000000000000000001"),
+	}))
+	require.NoError(t, d.StageRetract(nil, 1))
+
+	// payloads are staged only until flushed
+	assert.Len(t, ms.outboxes, 2)
+	assert.Empty(t, d.queues[0])
+
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Flush()) // enqueued only once
+
+	for _, ptype := range []PayloadType{PayloadLogs, PayloadRetract} {
+		dl := <-d.queues[0]
+		d.deliver(context.Background(), dl)
+
+		payload := <-received
+		assert.Equal(t, ptype, payload.Type)
+		assert.Equal(t, uint64(1), payload.Epoch)
+		assert.Len(t, payload.Logs, 1)
+	}
+
+	assert.Empty(t, d.queues[0])
+	assert.Empty(t, ms.deadLetters)
+
+	// staged payloads removed once delivered
+	assert.Empty(t, ms.outboxes)
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d, ms := newTestDispatcher(t, server.URL)
+	require.NoError(t, d.Stage(nil, []*store.EpochData{newTestEpochData(1, testContract)}))
+	require.NoError(t, d.Flush())
+
+	d.deliver(context.Background(), <-d.queues[0])
+
+	require.Len(t, ms.deadLetters, 1)
+	assert.Equal(t, "test", ms.deadLetters[0].Webhook)
+	assert.Equal(t, 2, ms.deadLetters[0].Attempts)
+	assert.Empty(t, ms.outboxes)
+
+	// dead letter could be redelivered
+	assert.NoError(t, d.redeliver(ms.deadLetters[0]))
+	assert.Len(t, d.queues[0], 1)
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	d, ms := newTestDispatcher(t, "http://127.0.0.1")
+
+	var epochs []*store.EpochData
+	for epoch := uint64(1); epoch <= 12; epoch++ {
+		epochs = append(epochs, newTestEpochData(epoch, testContract))
+	}
+	require.NoError(t, d.Stage(nil, epochs))
+
+	// payloads retained in the outbox once queue is full
+	assert.ErrorIs(t, d.Flush(), errQueueFull)
+	assert.Len(t, d.queues[0], 10)
+	assert.Len(t, ms.outboxes, 12)
+	assert.Empty(t, ms.deadLetters)
+
+	for i := 0; i < 10; i++ {
+		d.ack((<-d.queues[0]).outboxId)
+	}
+
+	// the remaining payloads enqueued in order on the next flush
+	require.NoError(t, d.Flush())
+	require.Len(t, d.queues[0], 2)
+
+	for _, epoch := range []uint64{11, 12} {
+		var payload Payload
+		require.NoError(t, json.Unmarshal((<-d.queues[0]).body, &payload))
+		assert.Equal(t, epoch, payload.Epoch)
+	}
+}
+
+func TestDispatcherAfterRestart(t *testing.T) {
+	d, ms := newTestDispatcher(t, "http://127.0.0.1")
+
+	var epochs []*store.EpochData
+	for epoch := uint64(1); epoch <= 12; epoch++ {
+		epochs = append(epochs, newTestEpochData(epoch, testContract))
+	}
+	require.NoError(t, d.Stage(nil, epochs[:6]))
+	require.NoError(t, d.Stage(nil, epochs[6:]))
+
+	// payloads out of the retract window are evicted
+	assert.Len(t, ms.windows, 10)
+	assert.NotContains(t, ms.windows, uint64(2))
+
+	// payloads staged before restart are retained to deliver by the restarted dispatcher
+	conf := *d.conf
+	conf.QueueSize = 20
+
+	restarted := NewDispatcher("cfx", &conf, ms)
+	require.NoError(t, restarted.reload())
+
+	// payloads delivered before restart are retracted too
+	require.NoError(t, restarted.StageRetract(nil, 11))
+	assert.Len(t, ms.windows, 8)
+
+	require.NoError(t, restarted.Flush())
+	require.Len(t, restarted.queues[0], 14)
+
+	for i := 0; i < 12; i++ {
+		<-restarted.queues[0]
+	}
+
+	for _, epoch := range []uint64{12, 11} {
+		dl := <-restarted.queues[0]
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(dl.body, &payload))
+		assert.Equal(t, PayloadRetract, payload.Type)
+		assert.Equal(t, epoch, payload.Epoch)
+		assert.Len(t, payload.Logs, 1)
+	}
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HTTP headers of webhook delivery
+	HeaderDeliveryID = "X-Confura-Delivery"
+	HeaderTimestamp  = "X-Confura-Timestamp"
+	HeaderSignature  = "X-Confura-Signature"
+)
+
+// PayloadType type of webhook payload.
+type PayloadType string
+
+const (
+	// matched event logs within a committed epoch
+	PayloadLogs PayloadType = "logs"
+	// event logs delivered before are retracted due to chain reorg
+	PayloadRetract PayloadType = "retract"
+)
+
+// Payload JSON payload delivered to the callback url of webhook.
+//
+// Payloads of the same webhook are delivered in order, and payload id is unique so that receiver
+// could deduplicate the redelivered payloads.
+type Payload struct {
+	ID        string      `json:"id"`
+	Webhook   string      `json:"webhook"`
+	Space     string      `json:"space"`
+	Type      PayloadType `json:"type"`
+	Epoch     uint64      `json:"epoch"`
+	PivotHash string      `json:"pivotHash"`
+	// core space or evm space event logs
+	Logs []interface{} `json:"logs"`
+}
+
+func newPayload(hook, space string, ptype PayloadType, epoch uint64, pivotHash string, logs []interface{}) *Payload {
+	return &Payload{
+		ID:        fmt.Sprintf("%v:%v:%v:%v:%v", hook, space, epoch, pivotHash, ptype),
+		Webhook:   hook,
+		Space:     space,
+		Type:      ptype,
+		Epoch:     epoch,
+		PivotHash: pivotHash,
+		Logs:      logs,
+	}
+}
+
+// Sign computes the payload signature as hex encoded HMAC-SHA256 of `<timestamp>.<body>` with
+// the webhook secret, which receiver could use to verify the `X-Confura-Signature` header.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// networkSpace returns the network space of the sync space, which is `eth` for the EVM chains
+// synchronized with space `chain<id>`.
+func networkSpace(space string) string {
+	if strings.HasPrefix(space, "chain") {
+		return "eth"
+	}
+
+	return space
+}
+
+// ParseFilter parses and validates the log filter in JSON of the specified network space, with
+// block range ignored.
+func ParseFilter(space string, filter json.RawMessage) (*store.LogFilter, error) {
+	var sfilter store.LogFilter
+
+	switch networkSpace(space) {
+	case "cfx":
+		var lf types.LogFilter
+		if err := json.Unmarshal(filter, &lf); err != nil {
+			return nil, errors.WithMessage(err, "invalid log filter")
+		}
+
+		sfilter = store.ParseCfxLogFilter(0, 0, &lf)
+	case "eth":
+		var fq web3Types.FilterQuery
+		if err := json.Unmarshal(filter, &fq); err != nil {
+			return nil, errors.WithMessage(err, "invalid log filter")
+		}
+
+		sfilter = store.ParseEthLogFilterRaw(0, 0, &fq)
+	default:
+		return nil, errors.Errorf("invalid network space %v", space)
+	}
+
+	if err := sfilter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &sfilter, nil
+}
+
+// NewWebhook creates a validated webhook of the specified network space, and a random secret is
+// generated if not provided.
+func NewWebhook(space, name, callbackUrl string, filter json.RawMessage, secret string) (*mysql.Webhook, error) {
+	if len(name) == 0 {
+		return nil, errors.New("webhook name must not be empty")
+	}
+
+	u, err := url.Parse(callbackUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, errors.Errorf("invalid callback url %v", callbackUrl)
+	}
+
+	if _, err := ParseFilter(space, filter); err != nil {
+		return nil, err
+	}
+
+	if len(secret) == 0 {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.WithMessage(err, "failed to generate secret")
+		}
+
+		secret = hex.EncodeToString(b)
+	}
+
+	return &mysql.Webhook{Name: name, Url: callbackUrl, Secret: secret, Filter: filter}, nil
+}
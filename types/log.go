@@ -0,0 +1,46 @@
+package types
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	web3Types "github.com/openweb3/web3go/types"
+)
+
+// CfxLog core space event log with extension field `final`, which indicates whether the log is
+// finalized and will never be reverted due to chain reorg.
+type CfxLog struct {
+	types.Log
+	Final *bool // omitted if finality unknown
+}
+
+func (l CfxLog) MarshalJSON() ([]byte, error) {
+	return marshalWithFinality(l.Log, l.Final)
+}
+
+// EthLog evm space event log with extension field `final`, which indicates whether the log is
+// finalized and will never be reverted due to chain reorg.
+type EthLog struct {
+	web3Types.Log
+	Final *bool // omitted if finality unknown
+}
+
+func (l EthLog) MarshalJSON() ([]byte, error) {
+	return marshalWithFinality(l.Log, l.Final)
+}
+
+// marshalWithFinality appends the `final` field into the JSON object of the event log.
+func marshalWithFinality(log interface{}, final *bool) ([]byte, error) {
+	data, err := json.Marshal(log)
+	if err != nil || final == nil || len(data) < 2 {
+		return data, err
+	}
+
+	ext := `"final":` + strconv.FormatBool(*final) + "}"
+	if len(data) > 2 {
+		ext = "," + ext
+	}
+
+	return append(data[:len(data)-1], ext...), nil
+}
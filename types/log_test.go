@@ -0,0 +1,29 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEthLogMarshalFinality(t *testing.T) {
+	final := true
+
+	for _, v := range []*bool{nil, &final} {
+		data, err := json.Marshal(EthLog{Log: web3Types.Log{BlockNumber: 1}, Final: v})
+		require.NoError(t, err)
+
+		var res map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &res))
+
+		assert.Equal(t, "0x1", res["blockNumber"])
+		if f, ok := res["final"]; v == nil {
+			assert.False(t, ok)
+		} else {
+			assert.Equal(t, true, f)
+		}
+	}
+}
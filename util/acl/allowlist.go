@@ -24,6 +24,9 @@ type AllowList struct {
 
 	// Restricted `Origin` request headers
 	Origins []string
+
+	// Quota of virtual filters, or unlimited if not provided.
+	FilterQuota *FilterQuota
 }
 
 func NewAllowList(id uint32, name string) *AllowList {
@@ -32,3 +35,15 @@ func NewAllowList(id uint32, name string) *AllowList {
 		Name: name,
 	}
 }
+
+// FilterQuota quota of virtual filters per identity (API key or IP), and zero value means unlimited.
+type FilterQuota struct {
+	// Max number of active virtual filters.
+	MaxFilters int
+
+	// Max number of event logs buffered by a log filter between two pollings.
+	MaxLogs int
+
+	// Max number of addresses and topics of the log filter criteria.
+	MaxCriteria int
+}
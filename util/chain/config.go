@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	configs     []*Config
+	configsOnce sync.Once
+)
+
+// Config holds the configuration of an EVM chain indexed and served besides the evm space, of
+// which the chain scoped configurations (eg., `mysql`, `node` and `sync`) are nested under the
+// viper key `chains.<name>`.
+type Config struct {
+	// chain name, which is the config key under `chains`
+	Name string `mapstructure:"-"`
+	// chain ID, which must be unique and is also used as RPC route path `/v1/<chainId>`
+	ChainId uint64
+	// fullnode RPC endpoint to sync blockchain data from
+	Http string
+	// RPC host names routed to the chain besides the route path
+	Hosts []string
+}
+
+// Route returns the RPC route of the chain, which is the decimal chain ID.
+func (c *Config) Route() string {
+	return strconv.FormatUint(c.ChainId, 10)
+}
+
+// ViperKey returns the viper key of the chain scoped configuration.
+func (c *Config) ViperKey(key string) string {
+	return fmt.Sprintf("chains.%v.%v", c.Name, key)
+}
+
+// Configs returns all the configured EVM chains ordered by chain ID.
+func Configs() []*Config {
+	configsOnce.Do(func() {
+		configs = mustLoadConfigs()
+	})
+
+	return configs
+}
+
+// GetConfig returns the configured EVM chain of the specified route, which is the chain name or
+// decimal chain ID.
+func GetConfig(route string) (*Config, bool) {
+	for _, c := range Configs() {
+		if c.Name == route || c.Route() == route {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+func mustLoadConfigs() []*Config {
+	names := viper.GetStringMap("chains")
+
+	res := make([]*Config, 0, len(names))
+	chainIds := make(map[uint64]string, len(names))
+
+	for name := range names {
+		var conf Config
+		viperutil.MustUnmarshalKey("chains."+name, &conf)
+
+		logger := logrus.WithFields(logrus.Fields{"name": name, "chainId": conf.ChainId})
+
+		if conf.ChainId == 0 {
+			logger.Fatal("Chain ID must be specified for EVM chain")
+		}
+
+		if dup, ok := chainIds[conf.ChainId]; ok {
+			logger.WithField("duplicate", dup).Fatal("Chain ID must be unique for EVM chains")
+		}
+
+		conf.Name = name
+		chainIds[conf.ChainId] = name
+		res = append(res, &conf)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].ChainId < res[j].ChainId })
+
+	return res
+}
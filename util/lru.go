@@ -93,6 +93,14 @@ func (c *ExpirableLruCache) GetOrUpdate(key interface{}, updateFunc func() (inte
 	return v, nil
 }
 
+// Purge removes all the entries from the cache.
+func (c *ExpirableLruCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Purge()
+}
+
 // GetWithoutExp looks up a key's value from the cache without expiration action.
 func (c *ExpirableLruCache) GetWithoutExp(key interface{}) (v interface{}, expired, found bool) {
 	return c.get(key)
@@ -1,7 +1,10 @@
 package metrics
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/Conflux-Chain/confura/util"
@@ -62,6 +65,22 @@ func (*RpcMetrics) UpdateDuration(method string, err error, start time.Time) {
 	}
 }
 
+// UpdateChainDuration updates the rate and latency statistics of RPC requests to the EVM chain.
+func (*RpcMetrics) UpdateChainDuration(chainId uint64, method string, err error, start time.Time) {
+	var isNilErr, isRpcErr bool
+	if isNilErr = util.IsInterfaceValNil(err); !isNilErr {
+		isRpcErr = utils.IsRPCJSONError(err)
+	}
+
+	metricUtil.GetOrRegisterTimeWindowPercentageDefault(100, "infura/rpc/chain/%v/rate/success", chainId).Mark(isNilErr)
+	metricUtil.GetOrRegisterTimeWindowPercentageDefault(100, "infura/rpc/chain/%v/rate/success/%v", chainId, method).Mark(isNilErr)
+
+	if isNilErr || isRpcErr {
+		metricUtil.GetOrRegisterTimer("infura/rpc/chain/%v/duration/all", chainId).UpdateSince(start)
+		metricUtil.GetOrRegisterTimer("infura/rpc/chain/%v/duration/%v", chainId, method).UpdateSince(start)
+	}
+}
+
 // RPC metrics - inputs
 
 func (*RpcMetrics) InputEpoch(method, epoch string) metricUtil.Percentage {
@@ -103,6 +122,16 @@ func (*RpcMetrics) StoreHit(method, storeName string) metricUtil.Percentage {
 	return metricUtil.GetOrRegisterTimeWindowPercentageDefault(0, "infura/rpc/store/hit/%v/%v", storeName, method)
 }
 
+// RPC metrics - response cache hit ratio
+
+func (*RpcMetrics) ResponseCacheHit(method string) metricUtil.Percentage {
+	return metricUtil.GetOrRegisterTimeWindowPercentageDefault(0, "infura/rpc/cache/hit/%v", method)
+}
+
+func (*RpcMetrics) SharedCacheHit(method string) metricUtil.Percentage {
+	return metricUtil.GetOrRegisterTimeWindowPercentageDefault(0, "infura/rpc/cache/shared/hit/%v", method)
+}
+
 // RPC metrics - fullnode
 
 func (*RpcMetrics) FullnodeQps(node, space, method string, err error) metrics.Timer {
@@ -152,6 +181,50 @@ func (*SyncMetrics) QueryEpochDataAvailability(space string) metricUtil.Percenta
 	return metricUtil.GetOrRegisterTimeWindowPercentageDefault(0, "infura/sync/%v/fullnode/availability", space)
 }
 
+func (*SyncMetrics) AuditEpochs(space string) metrics.Meter {
+	return metricUtil.GetOrRegisterMeter("infura/sync/%v/audit/epochs", space)
+}
+
+func (*SyncMetrics) AuditMismatches(space, field string) metrics.Counter {
+	return metricUtil.GetOrRegisterCounter("infura/sync/%v/audit/mismatches/%v", space, field)
+}
+
+func (*SyncMetrics) AuditProgress(space string) metrics.Gauge {
+	return metricUtil.GetOrRegisterGauge("infura/sync/%v/audit/progress", space)
+}
+
+func (*SyncMetrics) AdaptiveBatchSize(space string) metrics.Gauge {
+	return metricUtil.GetOrRegisterGauge("infura/sync/%v/adaptive/batch", space)
+}
+
+func (*SyncMetrics) AdaptiveConcurrency(space string) metrics.Gauge {
+	return metricUtil.GetOrRegisterGauge("infura/sync/%v/adaptive/concurrency", space)
+}
+
+func (*SyncMetrics) AdaptiveFetchLatency(space string) metrics.Timer {
+	return metricUtil.GetOrRegisterTimer("infura/sync/%v/adaptive/latency/fetch", space)
+}
+
+func (*SyncMetrics) AdaptiveWriteLatency(space string) metrics.Timer {
+	return metricUtil.GetOrRegisterTimer("infura/sync/%v/adaptive/latency/write", space)
+}
+
+func (*SyncMetrics) AdaptiveEpochRows(space string) metrics.Histogram {
+	return metricUtil.GetOrRegisterHistogram("infura/sync/%v/adaptive/rows", space)
+}
+
+func (*SyncMetrics) WebhookDelivery(space string, err error) metrics.Timer {
+	if util.IsInterfaceValNil(err) {
+		return metricUtil.GetOrRegisterTimer("infura/sync/%v/webhook/delivery/success", space)
+	}
+
+	return metricUtil.GetOrRegisterTimer("infura/sync/%v/webhook/delivery/failure", space)
+}
+
+func (*SyncMetrics) WebhookDeadLetters(space string) metrics.Counter {
+	return metricUtil.GetOrRegisterCounter("infura/sync/%v/webhook/deadletters", space)
+}
+
 // Store metrics
 type StoreMetrics struct{}
 
@@ -167,6 +240,10 @@ func (*StoreMetrics) GetLogs() metrics.Timer {
 	return metricUtil.GetOrRegisterTimer("infura/store/mysql/getlogs")
 }
 
+func (*StoreMetrics) GetLogsByTxHashes() metrics.Timer {
+	return metricUtil.GetOrRegisterTimer("infura/store/mysql/getlogs/txhashes")
+}
+
 // Node manager metrics
 type NodeManagerMetrics struct{}
 
@@ -200,6 +277,22 @@ func (*VirtualFilterMetrics) Sessions(space string, filterType, node string) met
 	return metricUtil.GetOrRegisterGauge("infura/virtualFilter/%v/%v/sessions/%v", space, filterType, node)
 }
 
+// QuotaFilters returns the gauge of active virtual filters of the owner, where the owner identity
+// (API key or IP) is hashed in the metric name. To bound the cardinality, owners beyond the first
+// `maxQuotaMetricOwners` ones are not reported.
+func (*VirtualFilterMetrics) QuotaFilters(space, owner string) metrics.Gauge {
+	label, ok := quotaMetricOwners.label(space, owner)
+	if !ok {
+		return metrics.NilGauge{}
+	}
+
+	return metricUtil.GetOrRegisterGauge("infura/virtualFilter/%v/quota/owner/%v/filters", space, label)
+}
+
+func (*VirtualFilterMetrics) QuotaRejections(space string) metrics.Meter {
+	return metricUtil.GetOrRegisterMeter("infura/virtualFilter/%v/quota/rejections", space)
+}
+
 func (*VirtualFilterMetrics) PollOnceQps(space, node string, err error) metrics.Timer {
 	if util.IsInterfaceValNil(err) {
 		return metricUtil.GetOrRegisterTimer("infura/virtualFilter/%v/poll/%v/once/success", space, node)
@@ -208,6 +301,14 @@ func (*VirtualFilterMetrics) PollOnceQps(space, node string, err error) metrics.
 	return metricUtil.GetOrRegisterTimer("infura/virtualFilter/%v/poll/%v/once/failure", space, node)
 }
 
+func (*VirtualFilterMetrics) PollHashesOnce(space, filterType, node string, err error) metrics.Timer {
+	if util.IsInterfaceValNil(err) {
+		return metricUtil.GetOrRegisterTimer("infura/virtualFilter/%v/poll/%v/%v/once/success", space, filterType, node)
+	}
+
+	return metricUtil.GetOrRegisterTimer("infura/virtualFilter/%v/poll/%v/%v/once/failure", space, filterType, node)
+}
+
 func (*VirtualFilterMetrics) PollOnceSize(space, node string) metrics.Histogram {
 	return metricUtil.GetOrRegisterHistogram("infura/virtualFilter/%v/poll/%v/once/size", space, node)
 }
@@ -224,3 +325,41 @@ func (*VirtualFilterMetrics) StoreQueryPercentage(space string, node, store stri
 	metricName := fmt.Sprintf("infura/virtualFilter/%v/percentage/query/%v/filterChanges/%v", space, node, store)
 	return metricUtil.GetOrRegisterTimeWindowPercentageDefault(0, metricName)
 }
+
+// max number of distinct owners reported by the virtual filter quota metrics per space
+const maxQuotaMetricOwners = 1000
+
+var quotaMetricOwners = quotaOwnerLabels{
+	labels: make(map[string]string),
+	counts: make(map[string]int),
+}
+
+// quotaOwnerLabels hashed metric labels of the owners tracked by the virtual filter quota metrics.
+type quotaOwnerLabels struct {
+	mu     sync.Mutex
+	labels map[string]string // space/owner => hashed label
+	counts map[string]int    // space => number of owners tracked
+}
+
+// label returns the hashed label of the owner, or false if the cardinality cap reached.
+func (l *quotaOwnerLabels) label(space, owner string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := space + "/" + owner
+	if label, ok := l.labels[key]; ok {
+		return label, true
+	}
+
+	if l.counts[space] >= maxQuotaMetricOwners {
+		return "", false
+	}
+
+	hash := sha256.Sum256([]byte(owner))
+	label := hex.EncodeToString(hash[:8])
+
+	l.labels[key] = label
+	l.counts[space]++
+
+	return label, true
+}
@@ -36,6 +36,26 @@ func SVipStatusFromContext(ctx context.Context) (*SVipStatus, bool) {
 	return ki, ok && ki != nil
 }
 
+// FilterQuotaFromContext returns the virtual filter quota along with the owner identity, which is
+// either the API key if authenticated or the IP address of the requester.
+func FilterQuotaFromContext(ctx context.Context) (owner string, quota *acl.FilterQuota, ok bool) {
+	reg, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*Registry)
+	if !ok || reg == nil {
+		return "", nil, false
+	}
+
+	if quota, ok = reg.FilterQuota(ctx); !ok {
+		return "", nil, false
+	}
+
+	if authId, ok := handlers.GetAuthIdFromContext(ctx); ok && len(authId) > 0 {
+		return fmt.Sprintf("key:%v", authId), quota, true
+	}
+
+	ip, _ := handlers.GetIPAddressFromContext(ctx)
+	return fmt.Sprintf("ip:%v", ip), quota, true
+}
+
 type Registry struct {
 	*http.Registry
 	*aclRegistry
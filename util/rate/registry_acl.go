@@ -16,7 +16,7 @@ type aclRegistry struct {
 
 	kloader *KeyLoader
 
-	defaultValidator acl.Validator
+	defaultAllowList *acl.AllowList
 	valFactory       acl.ValidatorFactory
 
 	// all available allowlists
@@ -42,26 +42,49 @@ func (r *aclRegistry) Allow(ctx acl.Context) error {
 }
 
 func (r *aclRegistry) assignValidator(ctx context.Context) (acl.Validator, bool) {
+	al, ok := r.assignAllowList(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.validators[al.ID]
+	return v, ok
+}
+
+// FilterQuota returns the virtual filter quota of the allowlist assigned to the request.
+func (r *aclRegistry) FilterQuota(ctx context.Context) (*acl.FilterQuota, bool) {
+	al, ok := r.assignAllowList(ctx)
+	if !ok || al.FilterQuota == nil {
+		return nil, false
+	}
+
+	return al.FilterQuota, true
+}
+
+func (r *aclRegistry) assignAllowList(ctx context.Context) (*acl.AllowList, bool) {
 	authId, ok := handlers.GetAuthIdFromContext(ctx)
 	if !ok { // use default allowlist if not authenticated
-		return r.getDefaultValidator()
+		return r.getDefaultAllowList()
 	}
 
 	if vs, ok := handlers.VipStatusFromContext(ctx); ok {
 		// use VIP allowlsit with corresponding tier
-		return r.getVipValidator(vs)
+		return r.getVipAllowList(vs)
 	}
 
 	if ki, ok := r.kloader.Load(authId); ok && ki != nil {
 		// use allowlist with corresponding key info
-		return r.getKeyInfoValidator(ki)
+		return r.getKeyInfoAllowList(ki)
 	}
 
 	// use default allowlist as fallback
-	return r.getDefaultValidator()
+	return r.getDefaultAllowList()
 }
 
-func (r *aclRegistry) getVipValidator(vip *handlers.VipStatus) (acl.Validator, bool) {
+func (r *aclRegistry) getVipAllowList(vip *handlers.VipStatus) (*acl.AllowList, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -70,26 +93,25 @@ func (r *aclRegistry) getVipValidator(vip *handlers.VipStatus) (acl.Validator, b
 
 	for _, al := range r.allowlists {
 		if strings.EqualFold(al.Name, vipAllowList) {
-			v, ok := r.validators[al.ID]
-			return v, ok
+			return al, true
 		}
 	}
 
 	return nil, false
 }
 
-func (r *aclRegistry) getDefaultValidator() (acl.Validator, bool) {
+func (r *aclRegistry) getDefaultAllowList() (*acl.AllowList, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if v := r.defaultValidator; v != nil {
-		return v, true
+	if al := r.defaultAllowList; al != nil {
+		return al, true
 	}
 
 	return nil, false
 }
 
-func (r *aclRegistry) getKeyInfoValidator(ki *KeyInfo) (acl.Validator, bool) {
+func (r *aclRegistry) getKeyInfoAllowList(ki *KeyInfo) (*acl.AllowList, bool) {
 	if ki == nil || ki.AclID == 0 {
 		return nil, false
 	}
@@ -97,8 +119,8 @@ func (r *aclRegistry) getKeyInfoValidator(ki *KeyInfo) (acl.Validator, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	v, ok := r.validators[ki.AclID]
-	return v, ok
+	al, ok := r.allowlists[ki.AclID]
+	return al, ok
 }
 
 // allowlists reloading
@@ -136,7 +158,7 @@ func (r *aclRegistry) addAllowList(al *acl.AllowList) {
 	r.validators[al.ID] = r.valFactory(al)
 
 	if strings.EqualFold(al.Name, acl.DefaultAllowList) {
-		r.defaultValidator = r.validators[al.ID]
+		r.defaultAllowList = al
 	}
 }
 
@@ -145,7 +167,7 @@ func (r *aclRegistry) removeAllowList(al *acl.AllowList) {
 	delete(r.validators, al.ID)
 
 	if strings.EqualFold(al.Name, acl.DefaultAllowList) {
-		r.defaultValidator = nil
+		r.defaultAllowList = nil
 	}
 }
 
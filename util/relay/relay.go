@@ -37,8 +37,14 @@ func MustNewTxnRelayerFromViper() TxnRelayer {
 }
 
 func MustNewEthTxnRelayerFromViper() TxnRelayer {
+	return MustNewEthTxnRelayerFromViperKey("relay")
+}
+
+// MustNewEthTxnRelayerFromViperKey creates evm space txn relayer with configurations of the specified
+// viper key, e.g., `chains.<name>.relay` for EVM chain.
+func MustNewEthTxnRelayerFromViperKey(key string) TxnRelayer {
 	var relayConf TxnRelayerConfig
-	viper.MustUnmarshalKey("relay", &relayConf)
+	viper.MustUnmarshalKey(key, &relayConf)
 
 	return newEthTxnRelayer(&relayConf)
 }
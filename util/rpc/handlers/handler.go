@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -16,4 +17,12 @@ const (
 	CtxKeyAccessToken = CtxKey("Infura-Access-Token")
 	CtxKeyReqOrigin   = CtxKey("Infura-Req-Origin")
 	CtxKeyUserAgent   = CtxKey("Infura-User-Agent")
+
+	CtxKeyChainId = CtxKey("Infura-Chain-ID")
 )
+
+// GetChainIdFromContext returns the chain ID of the EVM chain which the request is routed to.
+func GetChainIdFromContext(ctx context.Context) (uint64, bool) {
+	val, ok := ctx.Value(CtxKeyChainId).(uint64)
+	return val, ok
+}
@@ -35,6 +35,9 @@ func Metrics(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 
 		// collect rpc QPS/latency etc.
 		metrics.Registry.RPC.UpdateDuration(metricMethod, unwrapJsonError(resp.Error), start)
+		if chainId, ok := handlers.GetChainIdFromContext(ctx); ok {
+			metrics.Registry.RPC.UpdateChainDuration(chainId, metricMethod, unwrapJsonError(resp.Error), start)
+		}
 		// collect traffic hits
 		metrics.DefaultTrafficCollector().MarkHit(getTrafficSourceFromContext(ctx))
 
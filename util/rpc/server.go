@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +29,9 @@ var (
 	defaultWsPingInterval = 10 * time.Second
 )
 
+// routePathPrefix the HTTP path prefix to route requests by, eg., `/v1/<chainId>`.
+const routePathPrefix = "/v1/"
+
 // Server serves JSON RPC services.
 type Server struct {
 	name    string
@@ -76,6 +80,61 @@ func MustNewServer(name string, rpcs map[string]interface{}, middlewares ...hand
 	}
 }
 
+// NewRoutedServer creates an instance of Server, which routes requests to the specified servers
+// by HTTP path `/v1/<route>` or host name, and to the default server otherwise.
+func NewRoutedServer(name string, defaultServer *Server, routes, hosts map[string]*Server) *Server {
+	servers := make(map[Protocol]*http.Server, len(defaultServer.servers))
+
+	for protocol, server := range defaultServer.servers {
+		routeHandlers := make(map[string]http.Handler, len(routes))
+		for route, s := range routes {
+			routeHandlers[route] = http.StripPrefix(routePathPrefix+route, s.servers[protocol].Handler)
+		}
+
+		hostHandlers := make(map[string]http.Handler, len(hosts))
+		for host, s := range hosts {
+			hostHandlers[strings.ToLower(host)] = s.servers[protocol].Handler
+		}
+
+		servers[protocol] = &http.Server{
+			Handler: newRoutedHandler(server.Handler, routeHandlers, hostHandlers),
+		}
+	}
+
+	return &Server{name: name, servers: servers}
+}
+
+func newRoutedHandler(fallback http.Handler, routes, hosts map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, routePathPrefix) {
+			route := strings.TrimPrefix(r.URL.Path, routePathPrefix)
+			if idx := strings.Index(route, "/"); idx >= 0 {
+				route = route[:idx]
+			}
+
+			if h, ok := routes[route]; ok {
+				h.ServeHTTP(w, r)
+			} else {
+				http.Error(w, "unsupported chain route "+route, http.StatusNotFound)
+			}
+
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if h, ok := hosts[strings.ToLower(host)]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		fallback.ServeHTTP(w, r)
+	})
+}
+
 // MustServe serves RPC server in blocking way or panics if failed.
 func (s *Server) MustServe(endpoint string, protocol Protocol) {
 	logger := logrus.WithFields(logrus.Fields{
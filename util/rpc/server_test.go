@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutedHandler(t *testing.T) {
+	newHandler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+":"+handlers.GetAccessToken(r))
+		})
+	}
+
+	handler := newRoutedHandler(
+		newHandler("default"),
+		map[string]http.Handler{"1": http.StripPrefix("/v1/1", newHandler("chain1"))},
+		map[string]http.Handler{"chain1.example.com": newHandler("chain1")},
+	)
+
+	for _, tc := range []struct {
+		host, path string
+		status     int
+		expected   string
+	}{
+		{"example.com", "/", http.StatusOK, "default:"},
+		{"example.com", "/token", http.StatusOK, "default:token"},
+		{"example.com", "/v1/1", http.StatusOK, "chain1:"},
+		{"example.com", "/v1/1/token", http.StatusOK, "chain1:token"},
+		{"chain1.example.com:8545", "/token", http.StatusOK, "chain1:token"},
+		{"example.com", "/v1/2/token", http.StatusNotFound, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://"+tc.host+tc.path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.path)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, tc.expected, rec.Body.String(), tc.path)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package virtualfilter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+)
+
+var (
+	errWorkerNotFound = errors.New("worker not found")
+)
+
+// filterInfo introspection info of virtual filter.
+type filterInfo struct {
+	ID              rpc.ID      `json:"id"`
+	Type            string      `json:"type"`
+	Owner           string      `json:"owner,omitempty"`
+	Node            string      `json:"node"`
+	Criteria        interface{} `json:"criteria,omitempty"`
+	Delivered       uint64      `json:"delivered,omitempty"`
+	LastPollingTime time.Time   `json:"lastPollingTime"`
+}
+
+// workerInfo introspection info of filter worker session.
+type workerInfo struct {
+	Key             string            `json:"key"`
+	Node            string            `json:"node"`
+	Type            string            `json:"type"`
+	ProxyFilter     rpc.ID            `json:"proxyFilter"`
+	LastPollingTime time.Time         `json:"lastPollingTime"`
+	Head            uint64            `json:"head"` // latest block (or epoch) for log worker, or hash sequence
+	Lags            map[rpc.ID]uint64 `json:"lags"` // virtual filter id => cursor lag behind the head
+}
+
+// filterNodeInfo introspection info of filter chain node.
+type filterNodeInfo struct {
+	Height    uint64 `json:"height"`
+	Hash      string `json:"hash"`
+	Reorged   bool   `json:"reorged,omitempty"`
+	ForkPoint bool   `json:"forkPoint,omitempty"`
+}
+
+// criteriaFilter virtual log filter with filter criteria.
+type criteriaFilter interface {
+	criteria() interface{}
+}
+
+// adminWorker filter worker which could be introspected and reset by admin.
+type adminWorker interface {
+	info() workerInfo
+	reset() error
+}
+
+// adminApi admin RPC API to introspect and operate the virtual filter service instance.
+type adminApi struct {
+	fs        *filterSystemBase
+	uninstall func(id rpc.ID, local bool) (bool, error)
+}
+
+func newAdminApi(fs *filterSystemBase, uninstall func(id rpc.ID, local bool) (bool, error)) *adminApi {
+	return &adminApi{fs: fs, uninstall: uninstall}
+}
+
+// ListFilters lists the active virtual filters in memory of this service instance.
+func (api *adminApi) ListFilters() []filterInfo {
+	res := []filterInfo{}
+
+	api.fs.filterMgr.rangeFilters(func(f virtualFilter) {
+		fi := filterInfo{
+			ID:              f.fid(),
+			Type:            f.ftype().String(),
+			Owner:           api.fs.quotas.owner(f.fid()),
+			Node:            f.nodeName(),
+			LastPollingTime: f.polledAt(),
+		}
+
+		if cf, ok := f.(criteriaFilter); ok {
+			fi.Criteria = cf.criteria()
+		}
+
+		if df, ok := f.(durableFilter); ok {
+			fi.Delivered = df.deliveredTo()
+		}
+
+		res = append(res, fi)
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// ListWorkers lists the filter worker sessions, including the cursor lag of virtual filters.
+func (api *adminApi) ListWorkers() []workerInfo {
+	res := []workerInfo{}
+
+	api.rangeWorkers(func(key string, w adminWorker) {
+		wi := w.info()
+		wi.Key = key
+		res = append(res, wi)
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
+
+// DumpFilterChain dumps the filter chain nodes of the log filter worker.
+func (api *adminApi) DumpFilterChain(key string) ([]filterNodeInfo, error) {
+	w, ok := api.fs.workers.Load(key)
+	if !ok {
+		return nil, errWorkerNotFound
+	}
+
+	return w.(interface {
+		dumpChain() ([]filterNodeInfo, error)
+	}).dumpChain()
+}
+
+// UninstallFilter forces to uninstall the virtual filter.
+func (api *adminApi) UninstallFilter(id rpc.ID) (bool, error) {
+	return api.uninstall(id, false)
+}
+
+// ResetWorker forces to re-establish the session of the filter worker.
+func (api *adminApi) ResetWorker(key string) error {
+	var worker adminWorker
+	api.rangeWorkers(func(k string, w adminWorker) {
+		if k == key {
+			worker = w
+		}
+	})
+
+	if worker == nil {
+		return errWorkerNotFound
+	}
+
+	return worker.reset()
+}
+
+// rangeWorkers iterates both log filter workers and block or pending txn hash workers.
+func (api *adminApi) rangeWorkers(fn func(key string, w adminWorker)) {
+	for _, workers := range []*util.ConcurrentMap{&api.fs.workers, &api.fs.hashWorkers} {
+		workers.Range(func(k, v interface{}) bool {
+			fn(k.(string), v.(adminWorker))
+			return true
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package virtualfilter
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	cmdutil "github.com/Conflux-Chain/confura/cmd/util"
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminApi(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdownCtx := cmdutil.GracefulShutdownContext{Ctx: ctx, Wg: &sync.WaitGroup{}}
+	fs := &filterSystemBase{
+		filterMgr:   newFilterManager(),
+		notifier:    newChangeNotifier(),
+		quotas:      newFilterQuotas("eth", nil),
+		shutdownCtx: shutdownCtx,
+	}
+
+	client := &mockHashPollingClient{}
+	w := newHashWorker[string]("eth", "test", filterTypeBlock, 10, client, fs.notifier, shutdownCtx)
+	fs.hashWorkers.Store("test/block", w)
+
+	quota := &vfclient.FilterQuota{Owner: "ip:127.0.0.1"}
+	fid, err := fs.quotas.admit(quota, func() (rpc.ID, error) {
+		require.NoError(t, w.accept("0xa"))
+		fs.filterMgr.add(&mockHashFilter{filterBase: filterBase{id: "0xa", typ: filterTypeBlock}, worker: w})
+		return "0xa", nil
+	})
+	require.NoError(t, err)
+
+	uninstall := func(id rpc.ID, local bool) (bool, error) {
+		if vf, ok := fs.filterMgr.delete(id); ok {
+			fs.quotas.release(id)
+			return vf.uninstall()
+		}
+
+		return false, nil
+	}
+	api := newAdminApi(fs, uninstall)
+
+	filters := api.ListFilters()
+	require.Len(t, filters, 1)
+	assert.Equal(t, fid, filters[0].ID)
+	assert.Equal(t, "block", filters[0].Type)
+	assert.Equal(t, "ip:127.0.0.1", filters[0].Owner)
+
+	// cursor lags behind the polled hashes
+	client.pending = []string{"h1", "h2"}
+	require.NoError(t, w.pollOnce())
+
+	workers := api.ListWorkers()
+	require.Len(t, workers, 1)
+	assert.Equal(t, "test/block", workers[0].Key)
+	assert.Equal(t, rpc.ID("0x1"), workers[0].ProxyFilter)
+	assert.Equal(t, uint64(2), workers[0].Lags[fid])
+
+	// proxy filter re-installed once reset
+	require.NoError(t, api.ResetWorker("test/block"))
+	assert.Equal(t, rpc.ID("0x2"), api.ListWorkers()[0].ProxyFilter)
+	assert.ErrorIs(t, api.ResetWorker("unknown"), errWorkerNotFound)
+
+	_, err = api.DumpFilterChain("test")
+	assert.ErrorIs(t, err, errWorkerNotFound)
+
+	ok, err := api.UninstallFilter(fid)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, api.ListFilters())
+}
@@ -1,87 +1,77 @@
 package virtualfilter
 
 import (
-	"github.com/Conflux-Chain/confura/util"
-	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
-	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"time"
+
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
 	"github.com/Conflux-Chain/go-conflux-sdk/types"
 	w3rpc "github.com/openweb3/go-rpc-provider"
-	"github.com/sirupsen/logrus"
 )
 
 // core space filter API
 
 type cfxFilterApi struct {
-	fs        *cfxFilterSystem   // filter system
-	fnClients util.ConcurrentMap // full node clients: node name => sdk client
+	fs *cfxFilterSystem // filter system
 }
 
 func newCfxFilterApi(sys *cfxFilterSystem) *cfxFilterApi {
 	return &cfxFilterApi{fs: sys}
 }
 
-func (api *cfxFilterApi) NewBlockFilter(nodeUrl string) (w3rpc.ID, error) {
-	client, err := api.loadOrGetFnClient(nodeUrl)
+// NewBlockFilter creates the filter, and the optional `quota` is used for admission control of the owner.
+func (api *cfxFilterApi) NewBlockFilter(nodeUrl string, quota *vfclient.FilterQuota) (w3rpc.ID, error) {
+	client, err := api.fs.loadOrGetFnClient(nodeUrl)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	return api.fs.newBlockFilter(client)
+	return api.fs.quotas.admit(quota, func() (w3rpc.ID, error) {
+		return api.fs.newBlockFilter(client)
+	})
 }
 
-func (api *cfxFilterApi) NewPendingTransactionFilter(nodeUrl string) (w3rpc.ID, error) {
-	client, err := api.loadOrGetFnClient(nodeUrl)
+// NewPendingTransactionFilter creates the filter, and the optional `quota` is used for admission control of the owner.
+func (api *cfxFilterApi) NewPendingTransactionFilter(nodeUrl string, quota *vfclient.FilterQuota) (w3rpc.ID, error) {
+	client, err := api.fs.loadOrGetFnClient(nodeUrl)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	return api.fs.newPendingTransactionFilter(client)
+	return api.fs.quotas.admit(quota, func() (w3rpc.ID, error) {
+		return api.fs.newPendingTransactionFilter(client)
+	})
 }
 
-func (api *cfxFilterApi) UninstallFilter(id w3rpc.ID) (bool, error) {
-	return api.fs.uninstallFilter(id)
+// UninstallFilter uninstalls the filter, and the optional `local` flag indicates the request is
+// forwarded by the other service instance and must not be forwarded any more.
+func (api *cfxFilterApi) UninstallFilter(id w3rpc.ID, local *bool) (bool, error) {
+	return api.fs.uninstallFilter(id, local != nil && *local)
 }
 
-func (api *cfxFilterApi) NewFilter(nodeUrl string, crit types.LogFilter) (w3rpc.ID, error) {
-	client, err := api.loadOrGetFnClient(nodeUrl)
+// NewFilter creates the log filter, and the optional `quota` is used for admission control of the owner.
+func (api *cfxFilterApi) NewFilter(nodeUrl string, crit types.LogFilter, quota *vfclient.FilterQuota) (w3rpc.ID, error) {
+	client, err := api.fs.loadOrGetFnClient(nodeUrl)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	return api.fs.newFilter(client, crit)
+	return api.fs.quotas.admit(quota, func() (w3rpc.ID, error) {
+		return api.fs.newFilter(client, crit)
+	})
 }
 
 func (api *cfxFilterApi) GetLogFilter(fid w3rpc.ID) (*types.LogFilter, error) {
-	vf, ok := api.fs.getFilter(fid)
-	if !ok || vf.ftype() != filterTypeLog {
-		return nil, errFilterNotFound
-	}
-
-	cfxf := vf.(*cfxLogFilter)
-	return &cfxf.crit, nil
+	return api.fs.getLogFilter(fid)
 }
 
-func (api *cfxFilterApi) GetFilterChanges(id w3rpc.ID) (*types.CfxFilterChanges, error) {
-	return api.fs.getFilterChanges(id)
-}
-
-func (api *cfxFilterApi) loadOrGetFnClient(nodeUrl string) (*sdk.Client, error) {
-	nodeName := rpcutil.Url2NodeName(nodeUrl)
-	client, _, err := api.fnClients.LoadOrStoreFnErr(nodeName, func(interface{}) (interface{}, error) {
-		client, err := rpcutil.NewCfxClient(nodeUrl, rpcutil.WithClientHookMetrics(true))
-		if err != nil {
-			logrus.WithField("fnNodeUrl", nodeUrl).
-				WithError(err).
-				Error("Failed to new cfx client for virtual filter")
-			return nil, err
-		}
-
-		return client, nil
-	})
-
-	if err != nil {
-		return nil, err
+// GetFilterChanges returns the filter changes, and the optional `local` flag indicates the request
+// is forwarded by the other service instance and must not be forwarded any more. The optional
+// `waitMillis` is the max duration in milliseconds to wait until any change arrives if no change yet.
+func (api *cfxFilterApi) GetFilterChanges(id w3rpc.ID, local *bool, waitMillis *uint64) (*types.CfxFilterChanges, error) {
+	var wait time.Duration
+	if waitMillis != nil {
+		wait = time.Duration(*waitMillis) * time.Millisecond
 	}
 
-	return client.(*sdk.Client), nil
+	return api.fs.getFilterChanges(id, local != nil && *local, wait)
 }
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"math"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/sink"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
 	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
@@ -37,62 +39,76 @@ func newCfxFilter(fid rpc.ID, typ filterType, client *sdk.Client) *cfxFilter {
 	}
 }
 
-// implements `virtualFilter` interface
-
-func (f *cfxFilter) fetch() (filterChanges, error) {
-	return f.client.Filter().GetFilterChanges(f.id)
+func (f *cfxFilter) nodeName() string {
+	return rpcutil.Url2NodeName(f.client.GetNodeURL())
 }
 
-func (f *cfxFilter) uninstall() (bool, error) {
-	metricVirtualFilterSession("cfx", f, -1)
-	return f.client.Filter().UninstallFilter(f.id)
+// cfxHashFilter core space virtual block or pending txn filter, which fetches changes from the
+// shared hash worker of full node instead of a dedicated full node filter.
+type cfxHashFilter struct {
+	*cfxFilter
+	worker *hashWorker[types.Hash]
 }
 
-func (f *cfxFilter) nodeName() string {
-	return rpcutil.Url2NodeName(f.client.GetNodeURL())
-}
+func newCfxHashFilter(
+	worker *hashWorker[types.Hash], client *sdk.Client, fid rpc.ID, typ filterType,
+) (*cfxHashFilter, error) {
+	f := &cfxHashFilter{
+		worker:    worker,
+		cfxFilter: newCfxFilter(fid, typ, client),
+	}
 
-func newCfxBlockFilter(client *sdk.Client) (*cfxFilter, error) {
-	fid, err := client.Filter().NewBlockFilter()
-	if err != nil {
+	if err := worker.accept(fid); err != nil {
 		return nil, err
 	}
 
-	f := newCfxFilter(*fid, filterTypeBlock, client)
 	metricVirtualFilterSession("cfx", f, 1)
 	return f, nil
 }
 
-func newCfxPendingTxnFilter(client *sdk.Client) (*cfxFilter, error) {
-	fid, err := client.Filter().NewPendingTransactionFilter()
+func (f *cfxHashFilter) fetch() (filterChanges, error) {
+	hashes, err := f.worker.fetch(f.id)
 	if err != nil {
 		return nil, err
 	}
 
-	f := newCfxFilter(*fid, filterTypePendingTxn, client)
-	metricVirtualFilterSession("cfx", f, 1)
-	return f, nil
+	return &types.CfxFilterChanges{Type: "hash", Hashes: hashes}, nil
+}
+
+func (f *cfxHashFilter) uninstall() (bool, error) {
+	metricVirtualFilterSession("cfx", f, -1)
+	return f.worker.reject(f.id), nil
 }
 
 type cfxLogFilter struct {
 	*cfxFilter
 
+	mu       sync.Mutex
 	logStore *mysql.VirtualFilterLogStore
 	worker   *cfxFilterWorker
 	crit     types.LogFilter
+
+	// epoch number up to which changes have been delivered
+	delivered uint64
+	// whether to backfill changes since the delivered epoch upon fetch, which are missed while
+	// the filter is not served in memory (eg., service restarted or rebalanced)
+	resuming bool
+	// epoch number up to which changes have been backfilled
+	backfilled uint64
 }
 
 func newCfxLogFilter(
 	vfls *mysql.VirtualFilterLogStore,
 	worker *cfxFilterWorker,
 	client *sdk.Client,
+	fid rpc.ID,
 	crit types.LogFilter,
 ) (*cfxLogFilter, error) {
 	lf := &cfxLogFilter{
 		logStore:  vfls,
 		worker:    worker,
 		crit:      crit,
-		cfxFilter: newCfxFilter(rpc.NewID(), filterTypeLog, client),
+		cfxFilter: newCfxFilter(fid, filterTypeLog, client),
 	}
 
 	if err := worker.accept(lf); err != nil {
@@ -108,7 +124,21 @@ func (f *cfxLogFilter) uninstall() (bool, error) {
 	return f.worker.reject(f)
 }
 
+func (f *cfxLogFilter) criteria() interface{} {
+	return &f.crit
+}
+
+func (f *cfxLogFilter) deliveredTo() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delivered
+}
+
 func (f *cfxLogFilter) fetch() (filterChanges, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	// get change epochs from filter worker since last polling
 	pchanges, err := f.worker.fetchPollingChanges(f.id)
 	if err != nil {
@@ -161,6 +191,20 @@ func (f *cfxLogFilter) fetch() (filterChanges, error) {
 	}
 
 	changeLogs := make([]*types.SubscriptionLog, 0)
+	delivered, backfilled := f.delivered, f.backfilled
+
+	if f.resuming { // backfill changes missed before restored, which covers the polled changes
+		logs, latestEpoch, err := f.backfill()
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to backfill filter changes")
+		}
+
+		for i := range logs {
+			changeLogs = append(changeLogs, &types.SubscriptionLog{Log: &logs[i]})
+		}
+
+		delivered, backfilled = latestEpoch, latestEpoch
+	}
 
 	// locate last re-orged index
 	var idx int
@@ -172,6 +216,7 @@ func (f *cfxLogFilter) fetch() (filterChanges, error) {
 
 	if idx > 0 { // append chain-reorg log
 		revertTo := pchanges.epochs[idx-1].revertedTo
+		delivered = revertTo
 		reorg := &types.ChainReorg{
 			RevertTo: (*hexutil.Big)(new(big.Int).SetUint64(revertTo)),
 		}
@@ -180,6 +225,12 @@ func (f *cfxLogFilter) fetch() (filterChanges, error) {
 
 	for ; idx < len(pchanges.epochs); idx++ { // append normal event logs
 		fe := pchanges.epochs[idx]
+		if fe.epochNum <= backfilled { // already backfilled
+			continue
+		}
+
+		delivered = fe.epochNum
+
 		logs := fe.logs
 		if len(logs) == 0 { // load from store logs
 			logs = blockLogs[fe.blockHash.String()]
@@ -193,9 +244,193 @@ func (f *cfxLogFilter) fetch() (filterChanges, error) {
 		}
 	}
 
+	f.delivered, f.backfilled, f.resuming = delivered, backfilled, false
+
 	fc := &types.CfxFilterChanges{
 		Type: "log", Logs: changeLogs,
 	}
 
 	return fc, nil
 }
+
+// backfill fetches event logs from full node since the delivered epoch up to the latest epoch.
+func (f *cfxLogFilter) backfill() ([]types.Log, uint64, error) {
+	latestEpoch, err := f.client.GetEpochNumber(types.EpochLatestState)
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get latest epoch number")
+	}
+
+	latestEpochNo := latestEpoch.ToInt().Uint64()
+	fromEpoch, toEpoch := f.delivered+1, latestEpochNo
+
+	crit := f.crit
+	if crit.FromEpoch != nil {
+		if epoch, ok := crit.FromEpoch.ToInt(); ok {
+			fromEpoch = util.MaxUint64(fromEpoch, epoch.Uint64())
+		}
+	}
+
+	if crit.ToEpoch != nil {
+		if epoch, ok := crit.ToEpoch.ToInt(); ok {
+			toEpoch = util.MinUint64(toEpoch, epoch.Uint64())
+		}
+	}
+
+	// only epoch range filter is supported to backfill
+	if crit.FromBlock != nil || crit.ToBlock != nil || len(crit.BlockHashes) > 0 || fromEpoch > toEpoch {
+		return nil, latestEpochNo, nil
+	}
+
+	crit.FromEpoch, crit.ToEpoch = types.NewEpochNumberUint64(fromEpoch), types.NewEpochNumberUint64(toEpoch)
+
+	logs, err := f.client.GetLogs(crit)
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get logs")
+	}
+
+	return logs, latestEpochNo, nil
+}
+
+// cfxStreamLogFilter core space log filter driven by the commit stream of syncer.
+type cfxStreamLogFilter struct {
+	streamLogFilter
+	client *sdk.Client
+	crit   types.LogFilter
+}
+
+func newCfxStreamLogFilter(
+	fs *filterSystemBase, client *sdk.Client, fid rpc.ID, crit types.LogFilter, delivered uint64,
+) *cfxStreamLogFilter {
+	f := &cfxStreamLogFilter{
+		streamLogFilter: newStreamLogFilter(fs, fid, delivered),
+		client:          client,
+		crit:            crit,
+	}
+
+	metricVirtualFilterSession("cfx", f, 1)
+	return f
+}
+
+func (f *cfxStreamLogFilter) nodeName() string {
+	return rpcutil.Url2NodeName(f.client.GetNodeURL())
+}
+
+func (f *cfxStreamLogFilter) criteria() interface{} {
+	return &f.crit
+}
+
+func (f *cfxStreamLogFilter) uninstall() (bool, error) {
+	metricVirtualFilterSession("cfx", f, -1)
+	return true, nil
+}
+
+func (f *cfxStreamLogFilter) fetch() (filterChanges, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sc, unverified, err := f.changes()
+	if err != nil {
+		return nil, err
+	}
+
+	changeLogs := make([]*types.SubscriptionLog, 0)
+
+	if sc.reorged { // append chain-reorg log
+		reorg := &types.ChainReorg{
+			RevertTo: (*hexutil.Big)(new(big.Int).SetUint64(sc.revertTo)),
+		}
+		changeLogs = append(changeLogs, &types.SubscriptionLog{ChainReorg: reorg})
+	}
+
+	appendLogs := func(logs []types.Log) {
+		for i := range logs {
+			changeLogs = append(changeLogs, &types.SubscriptionLog{Log: &logs[i]})
+		}
+	}
+
+	delivered := sc.revertTo
+
+	fromEpoch, toEpoch, ok, err := f.fallbackRange(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok { // load from db store if falling behind cache
+		var logs []sink.LogData
+
+		logs, unverified, err = f.loadStore(sc, fromEpoch, toEpoch, unverified, f.loadStoreLogs)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to load filter changes from db store")
+		}
+
+		appendLogs(convertStreamCfxLogs(logs))
+		delivered = toEpoch
+	}
+
+	if delivered+1 >= sc.oldest {
+		for _, e := range sc.epochs {
+			appendLogs(filterCfxLogs(convertStreamCfxLogs(e.logs), &f.crit))
+			delivered = e.number
+		}
+	}
+
+	f.deliver(delivered, sc.revision, unverified)
+
+	return &types.CfxFilterChanges{Type: "log", Logs: changeLogs}, nil
+}
+
+// loadStoreLogs loads event logs from db store within the specified epoch range.
+func (f *cfxStreamLogFilter) loadStoreLogs(fromEpoch, toEpoch uint64) ([]sink.LogData, error) {
+	crit := &f.crit
+	if crit.FromEpoch != nil {
+		if epoch, ok := crit.FromEpoch.ToInt(); ok {
+			fromEpoch = util.MaxUint64(fromEpoch, epoch.Uint64())
+		}
+	}
+
+	if crit.ToEpoch != nil {
+		if epoch, ok := crit.ToEpoch.ToInt(); ok {
+			toEpoch = util.MinUint64(toEpoch, epoch.Uint64())
+		}
+	}
+
+	// only epoch range filter is supported to load from db store
+	if crit.FromBlock != nil || crit.ToBlock != nil || len(crit.BlockHashes) > 0 || fromEpoch > toEpoch {
+		return nil, nil
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), store.TimeoutGetLogs)
+	defer cancel()
+
+	startTime := time.Now()
+	defer metrics.Registry.VirtualFilter.QueryFilterChanges("cfx", f.nodeName(), "mysql").UpdateSince(startTime)
+
+	sfilter := store.ParseCfxLogFilter(fromEpoch, toEpoch, crit)
+	slogs, err := f.db.GetLogs(timeoutCtx, sfilter)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertStoreLogs(slogs), nil
+}
+
+// convertStreamCfxLogs converts event logs of the commit stream to core space event logs.
+func convertStreamCfxLogs(logs []sink.LogData) []types.Log {
+	cfxLogs := make([]types.Log, 0, len(logs))
+	for i := range logs {
+		cfxLogs = append(cfxLogs, logs[i].Log)
+	}
+
+	return cfxLogs
+}
+
+// countCfxStreamLogs counts the event logs of the sealed epoch matched by the stream log filter,
+// which are not delivered yet.
+func countCfxStreamLogs(vf virtualFilter, epoch *streamEpoch) int {
+	sf, ok := vf.(*cfxStreamLogFilter)
+	if !ok || sf.deliveredTo() >= epoch.number {
+		return 0
+	}
+
+	return len(filterCfxLogs(convertStreamCfxLogs(epoch.logs), &sf.crit))
+}
@@ -2,6 +2,7 @@ package virtualfilter
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	cmdutil "github.com/Conflux-Chain/confura/cmd/util"
@@ -19,41 +20,153 @@ import (
 // core space virtual filter system
 type cfxFilterSystem struct {
 	*filterSystemBase
-	conf *cfxConfig
+	conf      *cfxConfig
+	fnClients util.ConcurrentMap // full node clients: node name => sdk client
 }
 
 func newCfxFilterSystem(
 	conf *cfxConfig,
-	vfls *mysql.VirtualFilterLogStore,
+	db *mysql.MysqlStore,
 	shutdownCtx cmdutil.GracefulShutdownContext,
 ) *cfxFilterSystem {
 	return &cfxFilterSystem{
-		conf:             conf,
-		filterSystemBase: newFilterSystemBase(conf.TTL, vfls, shutdownCtx),
+		conf: conf,
+		filterSystemBase: newFilterSystemBase(
+			"cfx", conf.TTL, &conf.Registry, &conf.Stream, "sync.sink", countCfxStreamLogs, db, shutdownCtx,
+		),
 	}
 }
 
 func (fs *cfxFilterSystem) newBlockFilter(client *sdk.Client) (rpc.ID, error) {
-	f, err := newCfxBlockFilter(client)
+	return fs.newHashFilter(client, filterTypeBlock)
+}
+
+func (fs *cfxFilterSystem) newPendingTransactionFilter(client *sdk.Client) (rpc.ID, error) {
+	return fs.newHashFilter(client, filterTypePendingTxn)
+}
+
+// newHashFilter creates virtual block or pending txn filter delegated to the shared hash worker.
+func (fs *cfxFilterSystem) newHashFilter(client *sdk.Client, typ filterType) (rpc.ID, error) {
+	f, err := newCfxHashFilter(fs.loadOrNewHashWorker(client, typ), client, fs.newFilterID(), typ)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	fs.filterMgr.add(f)
+	rec := &filterRecord{ID: f.fid(), Type: typ, NodeUrl: client.GetNodeURL()}
+	if err := fs.addFilter(f, rec); err != nil {
+		f.uninstall()
+		return nilRpcId, err
+	}
+
 	return f.fid(), nil
 }
 
-func (fs *cfxFilterSystem) newPendingTransactionFilter(client *sdk.Client) (rpc.ID, error) {
-	f, err := newCfxPendingTxnFilter(client)
+func (fs *cfxFilterSystem) newFilter(client *sdk.Client, crit types.LogFilter) (rpc.ID, error) {
+	if fs.stream != nil {
+		return fs.newStreamFilter(client, crit)
+	}
+
+	if fs.registry == nil {
+		f, err := newCfxLogFilter(fs.logStore, fs.loadOrNewWorker(client), client, rpc.NewID(), crit)
+		if err != nil {
+			return nilRpcId, err
+		}
+
+		fs.filterMgr.add(f)
+		return f.fid(), nil
+	}
+
+	// register the log filter with the latest epoch as delivered cursor, which will be restored
+	// by the owner service instance upon polling.
+	latestEpoch, err := client.GetEpochNumber(types.EpochLatestState)
+	if err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to get latest epoch number")
+	}
+
+	jcrit, err := json.Marshal(crit)
+	if err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to marshal filter criteria")
+	}
+
+	rec := &filterRecord{
+		ID:      rpc.NewID(),
+		Type:    filterTypeLog,
+		NodeUrl: client.GetNodeURL(),
+		Crit:    jcrit,
+		Cursor:  latestEpoch.ToInt().Uint64(),
+	}
+
+	if err := fs.registry.put(rec); err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to register virtual filter")
+	}
+
+	return rec.ID, nil
+}
+
+// newStreamFilter creates log filter driven by the commit stream of syncer, with the latest epoch
+// of the stream as delivered cursor.
+func (fs *cfxFilterSystem) newStreamFilter(client *sdk.Client, crit types.LogFilter) (rpc.ID, error) {
+	cursor, err := fs.streamCursor()
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	fs.filterMgr.add(f)
-	return f.fid(), nil
+	if fs.registry == nil {
+		f := newCfxStreamLogFilter(fs.filterSystemBase, client, rpc.NewID(), crit, cursor)
+		fs.filterMgr.add(f)
+		return f.fid(), nil
+	}
+
+	jcrit, err := json.Marshal(crit)
+	if err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to marshal filter criteria")
+	}
+
+	rec := &filterRecord{
+		ID:      rpc.NewID(),
+		Type:    filterTypeLog,
+		NodeUrl: client.GetNodeURL(),
+		Crit:    jcrit,
+		Cursor:  cursor,
+	}
+
+	if err := fs.registry.put(rec); err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to register virtual filter")
+	}
+
+	return rec.ID, nil
 }
 
-func (fs *cfxFilterSystem) newFilter(client *sdk.Client, crit types.LogFilter) (rpc.ID, error) {
+// restoreFilter restores the virtual filter from the registry record.
+func (fs *cfxFilterSystem) restoreFilter(rec *filterRecord) (virtualFilter, error) {
+	client, err := fs.loadOrGetFnClient(rec.NodeUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.Type != filterTypeLog {
+		return newCfxHashFilter(fs.loadOrNewHashWorker(client, rec.Type), client, rec.ID, rec.Type)
+	}
+
+	var crit types.LogFilter
+	if err := json.Unmarshal(rec.Crit, &crit); err != nil {
+		return nil, errors.WithMessage(err, "invalid filter criteria")
+	}
+
+	if fs.stream != nil {
+		return newCfxStreamLogFilter(fs.filterSystemBase, client, rec.ID, crit, rec.Cursor), nil
+	}
+
+	f, err := newCfxLogFilter(fs.logStore, fs.loadOrNewWorker(client), client, rec.ID, crit)
+	if err != nil {
+		return nil, err
+	}
+
+	f.delivered, f.resuming = rec.Cursor, true
+	return f, nil
+}
+
+func (fs *cfxFilterSystem) loadOrNewWorker(client *sdk.Client) *cfxFilterWorker {
 	nodeName := rpcutil.Url2NodeName(client.GetNodeURL())
 	worker, _ := fs.workers.LoadOrStoreFn(nodeName, func(k interface{}) interface{} {
 		return newCfxFilterWorker(
@@ -61,36 +174,157 @@ func (fs *cfxFilterSystem) newFilter(client *sdk.Client, crit types.LogFilter) (
 		)
 	})
 
-	f, err := newCfxLogFilter(fs.logStore, worker.(*cfxFilterWorker), client, crit)
+	return worker.(*cfxFilterWorker)
+}
+
+func (fs *cfxFilterSystem) loadOrNewHashWorker(client *sdk.Client, typ filterType) *hashWorker[types.Hash] {
+	key := fmt.Sprintf("%v/%v", rpcutil.Url2NodeName(client.GetNodeURL()), typ)
+	worker, _ := fs.hashWorkers.LoadOrStoreFn(key, func(k interface{}) interface{} {
+		return newCfxHashWorker(typ, fs.conf.MaxFilterHashes, client, fs.notifier, fs.shutdownCtx)
+	})
+
+	return worker.(*hashWorker[types.Hash])
+}
+
+// getFilterChanges returns the filter changes. If the buffered logs exceeded the quota of the filter
+// owner since the last polling, the filter has been evicted and is uninstalled with error.
+func (fs *cfxFilterSystem) getFilterChanges(id rpc.ID, local bool, wait time.Duration) (*types.CfxFilterChanges, error) {
+	if err := fs.checkLogsQuota(id); err != nil {
+		fs.uninstallFilter(id, local)
+		return nil, err
+	}
+
+	fc, err := fs.pollFilterChanges(id, local, wait)
 	if err != nil {
-		return nilRpcId, err
+		return nil, err
 	}
 
-	fs.filterMgr.add(f)
-	return f.fid(), nil
+	fs.quotas.observe(id)
+
+	return fc, nil
 }
 
-func (fs *cfxFilterSystem) getFilterChanges(id rpc.ID) (*types.CfxFilterChanges, error) {
-	vf, ok := fs.filterMgr.get(id)
-	if !ok {
-		return nil, errFilterNotFound
+// pollFilterChanges polls the filter changes, and waits up to the specified duration (capped by
+// config) until any change arrives if no change yet.
+func (fs *cfxFilterSystem) pollFilterChanges(id rpc.ID, local bool, wait time.Duration) (*types.CfxFilterChanges, error) {
+	wait = min(wait, fs.conf.MaxWaitTimeout)
+
+	vf, owner, err := fs.loadFilter(id, local, fs.restoreFilter)
+	if err != nil {
+		return nil, err
 	}
 
-	fc, err := vf.fetch()
+	if len(owner) > 0 { // forward to the owner service instance
+		var fc *types.CfxFilterChanges
+		if wait <= 0 {
+			err := fs.cluster.forward(owner, &fc, "cfx_getFilterChanges", id, true)
+			return fc, err
+		}
+
+		err := fs.cluster.forwardWithTimeout(
+			wait+clusterForwardTimeout, owner, &fc, "cfx_getFilterChanges", id, true, wait.Milliseconds(),
+		)
+		return fc, err
+	}
+
+	fc, err := fs.fetchChanges(vf, wait, func(fc filterChanges) bool {
+		changes := fc.(*types.CfxFilterChanges)
+		return len(changes.Logs) == 0 && len(changes.Hashes) == 0
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	fs.filterMgr.refresh(id)
+	fs.touch(vf)
+
 	return fc.(*types.CfxFilterChanges), nil
 }
 
-func (fs *cfxFilterSystem) uninstallFilter(id rpc.ID) (bool, error) {
+func (fs *cfxFilterSystem) getLogFilter(id rpc.ID) (*types.LogFilter, error) {
+	if vf, ok := fs.getFilter(id); ok {
+		if vf.ftype() != filterTypeLog {
+			return nil, errFilterNotFound
+		}
+
+		if sf, ok := vf.(*cfxStreamLogFilter); ok {
+			return &sf.crit, nil
+		}
+
+		return &vf.(*cfxLogFilter).crit, nil
+	}
+
+	if fs.registry == nil {
+		return nil, errFilterNotFound
+	}
+
+	rec, err := fs.registry.get(id)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get virtual filter from registry")
+	}
+
+	if rec == nil || rec.Type != filterTypeLog {
+		return nil, errFilterNotFound
+	}
+
+	var crit types.LogFilter
+	if err := json.Unmarshal(rec.Crit, &crit); err != nil {
+		return nil, errors.WithMessage(err, "invalid filter criteria")
+	}
+
+	return &crit, nil
+}
+
+func (fs *cfxFilterSystem) uninstallFilter(id rpc.ID, local bool) (bool, error) {
+	fs.quotas.release(id)
+
 	if vf, ok := fs.filterMgr.delete(id); ok {
+		fs.unregister(id)
 		return vf.uninstall()
 	}
 
-	return true, nil
+	if fs.registry == nil {
+		return true, nil
+	}
+
+	rec, err := fs.registry.get(id)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to get virtual filter from registry")
+	}
+
+	if rec == nil {
+		return true, nil
+	}
+
+	if !local && !fs.cluster.isLocal(id) { // forward to the owner service instance
+		var res bool
+		err := fs.cluster.forward(fs.cluster.owner(id), &res, "cfx_uninstallFilter", id, true)
+		return res, err
+	}
+
+	// virtual filter not restored yet
+	return true, fs.unregister(id)
+}
+
+func (fs *cfxFilterSystem) loadOrGetFnClient(nodeUrl string) (*sdk.Client, error) {
+	nodeName := rpcutil.Url2NodeName(nodeUrl)
+	client, _, err := fs.fnClients.LoadOrStoreFnErr(nodeName, func(interface{}) (interface{}, error) {
+		client, err := rpcutil.NewCfxClient(nodeUrl, rpcutil.WithClientHookMetrics(true))
+		if err != nil {
+			logrus.WithField("fnNodeUrl", nodeUrl).
+				WithError(err).
+				Error("Failed to new cfx client for virtual filter")
+			return nil, err
+		}
+
+		return client, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*sdk.Client), nil
 }
 
 // implement `filterWorkerObserver` interface
@@ -102,6 +336,27 @@ func (fs *cfxFilterSystem) onPolled(nodeName string, fid rpc.ID, changes filterC
 		return nil
 	}
 
+	// account the logs accumulated for the virtual filters delegated to the filter worker
+	logs := make([]types.Log, 0, len(fchanges.Logs))
+	for _, v := range fchanges.Logs {
+		if v.Log != nil {
+			logs = append(logs, *v.Log)
+		}
+	}
+
+	count := func(vf virtualFilter) int {
+		if lf, ok := vf.(*cfxLogFilter); ok && lf.worker.nodeName == nodeName {
+			return len(filterCfxLogs(logs, &lf.crit))
+		}
+
+		return 0
+	}
+
+	fs.accumulateLogs(count)
+
+	// wake up long polling requests of the matched virtual filters once changes persisted
+	defer fs.notifyFilters(func(vf virtualFilter) bool { return count(vf) > 0 })
+
 	startTime := time.Now()
 	defer metrics.Registry.VirtualFilter.PersistFilterChanges("cfx", nodeName, "mysql").UpdateSince(startTime)
 
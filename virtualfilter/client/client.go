@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
@@ -17,6 +18,15 @@ const (
 	defaultClientRetryCount     = 0
 	defaultClientRetryInterval  = 1 * time.Second
 	defaultClientRequestTimeout = 3 * time.Second
+
+	// ErrCodeFilterNotFound JSON-RPC error code returned by the virtual filter service if the filter
+	// is not found, e.g., expired or uninstalled.
+	ErrCodeFilterNotFound = -32001
+)
+
+var (
+	// ErrFilterNotFound error returned by the virtual filter service if the filter is not found.
+	ErrFilterNotFound error = &rpc.JsonError{Code: ErrCodeFilterNotFound, Message: "filter not found"}
 )
 
 type clientConfig struct {
@@ -24,6 +34,13 @@ type clientConfig struct {
 	ServiceRpcUrl string
 }
 
+// FilterQuota quota of virtual filters per owner, which is enforced by the virtual filter service.
+type FilterQuota struct {
+	Owner      string `json:"owner"`             // owner identity, e.g., API key or IP address
+	MaxFilters int    `json:"maxFilters"`        // max number of active virtual filters
+	MaxLogs    int    `json:"maxLogs,omitempty"` // max number of logs buffered by log filter
+}
+
 type EthClient struct {
 	// underlying rpc client provider to request virtual filter service
 	p interfaces.Provider
@@ -53,23 +70,35 @@ func MustNewEthClientFromViper() (*EthClient, bool) {
 	return &EthClient{p: p}, true
 }
 
-func (client *EthClient) NewFilter(delFnUrl string, fq *ethtypes.FilterQuery) (val *rpc.ID, err error) {
-	err = client.p.CallContext(context.Background(), &val, "eth_newFilter", delFnUrl, fq)
+func (client *EthClient) NewFilter(
+	delFnUrl string, fq *ethtypes.FilterQuery, quota *FilterQuota,
+) (val *rpc.ID, err error) {
+	err = client.p.CallContext(context.Background(), &val, "eth_newFilter", delFnUrl, fq, quota)
 	return
 }
 
-func (client *EthClient) NewBlockFilter(delFnUrl string) (val *rpc.ID, err error) {
-	err = client.p.CallContext(context.Background(), &val, "eth_newBlockFilter", delFnUrl)
+func (client *EthClient) NewBlockFilter(delFnUrl string, quota *FilterQuota) (val *rpc.ID, err error) {
+	err = client.p.CallContext(context.Background(), &val, "eth_newBlockFilter", delFnUrl, quota)
 	return
 }
 
-func (client *EthClient) NewPendingTransactionFilter(delFnUrl string) (val *rpc.ID, err error) {
-	err = client.p.CallContext(context.Background(), &val, "eth_newPendingTransactionFilter", delFnUrl)
+func (client *EthClient) NewPendingTransactionFilter(delFnUrl string, quota *FilterQuota) (val *rpc.ID, err error) {
+	err = client.p.CallContext(context.Background(), &val, "eth_newPendingTransactionFilter", delFnUrl, quota)
 	return
 }
 
-func (client *EthClient) GetFilterChanges(filterID rpc.ID) (val *ethtypes.FilterChanges, err error) {
-	err = client.p.CallContext(context.Background(), &val, "eth_getFilterChanges", filterID)
+// GetFilterChanges returns the filter changes, and waits up to the specified duration until any
+// change arrives if no change yet.
+func (client *EthClient) GetFilterChanges(filterID rpc.ID, wait time.Duration) (val *ethtypes.FilterChanges, err error) {
+	if wait <= 0 {
+		err = client.p.CallContext(context.Background(), &val, "eth_getFilterChanges", filterID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait+defaultClientRequestTimeout)
+	defer cancel()
+
+	err = client.p.CallContext(ctx, &val, "eth_getFilterChanges", filterID, nil, wait.Milliseconds())
 	return
 }
 
@@ -112,23 +141,35 @@ func MustNewCfxClientFromViper() (*CfxClient, bool) {
 	return &CfxClient{p: p}, true
 }
 
-func (client *CfxClient) NewFilter(delFnUrl string, filterCrit *cfxtypes.LogFilter) (val *rpc.ID, err error) {
-	err = client.p.CallContext(context.Background(), &val, "cfx_newFilter", delFnUrl, filterCrit)
+func (client *CfxClient) NewFilter(
+	delFnUrl string, filterCrit *cfxtypes.LogFilter, quota *FilterQuota,
+) (val *rpc.ID, err error) {
+	err = client.p.CallContext(context.Background(), &val, "cfx_newFilter", delFnUrl, filterCrit, quota)
 	return
 }
 
-func (client *CfxClient) NewBlockFilter(delFnUrl string) (val *rpc.ID, err error) {
-	err = client.p.CallContext(context.Background(), &val, "cfx_newBlockFilter", delFnUrl)
+func (client *CfxClient) NewBlockFilter(delFnUrl string, quota *FilterQuota) (val *rpc.ID, err error) {
+	err = client.p.CallContext(context.Background(), &val, "cfx_newBlockFilter", delFnUrl, quota)
 	return
 }
 
-func (client *CfxClient) NewPendingTransactionFilter(delFnUrl string) (val *rpc.ID, err error) {
-	err = client.p.CallContext(context.Background(), &val, "cfx_newPendingTransactionFilter", delFnUrl)
+func (client *CfxClient) NewPendingTransactionFilter(delFnUrl string, quota *FilterQuota) (val *rpc.ID, err error) {
+	err = client.p.CallContext(context.Background(), &val, "cfx_newPendingTransactionFilter", delFnUrl, quota)
 	return
 }
 
-func (client *CfxClient) GetFilterChanges(filterID rpc.ID) (val *cfxtypes.CfxFilterChanges, err error) {
-	err = client.p.CallContext(context.Background(), &val, "cfx_getFilterChanges", filterID)
+// GetFilterChanges returns the filter changes, and waits up to the specified duration until any
+// change arrives if no change yet.
+func (client *CfxClient) GetFilterChanges(filterID rpc.ID, wait time.Duration) (val *cfxtypes.CfxFilterChanges, err error) {
+	if wait <= 0 {
+		err = client.p.CallContext(context.Background(), &val, "cfx_getFilterChanges", filterID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait+defaultClientRequestTimeout)
+	defer cancel()
+
+	err = client.p.CallContext(ctx, &val, "cfx_getFilterChanges", filterID, nil, wait.Milliseconds())
 	return
 }
 
@@ -141,3 +182,10 @@ func (client *CfxClient) UninstallFilter(filterID rpc.ID) (val bool, err error)
 	err = client.p.CallContext(context.Background(), &val, "cfx_uninstallFilter", filterID)
 	return
 }
+
+// IsFilterNotFoundError checks if the error returned by the virtual filter service indicates the
+// filter is not found, e.g., expired or uninstalled.
+func IsFilterNotFoundError(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == ErrCodeFilterNotFound
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testFilterService struct{}
+
+func (testFilterService) GetFilterChanges(id rpc.ID) ([]string, error) {
+	if id == "0x1" {
+		return []string{}, nil
+	}
+
+	return nil, ErrFilterNotFound
+}
+
+func TestIsFilterNotFoundError(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", testFilterService{}))
+	t.Cleanup(server.Stop)
+
+	client := rpc.DialInProc(server)
+	t.Cleanup(client.Close)
+
+	var res []string
+	assert.NoError(t, client.Call(&res, "eth_getFilterChanges", "0x1"))
+
+	// error code retained across the RPC boundary
+	err := client.Call(&res, "eth_getFilterChanges", "0x2")
+	assert.True(t, IsFilterNotFoundError(err))
+
+	assert.True(t, IsFilterNotFoundError(ErrFilterNotFound))
+	assert.False(t, IsFilterNotFoundError(nil))
+	assert.False(t, IsFilterNotFoundError(errors.New("filter not found")))
+	assert.False(t, IsFilterNotFoundError(&rpc.JsonError{Code: -32000, Message: "filter not found"}))
+}
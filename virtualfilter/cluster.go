@@ -0,0 +1,155 @@
+package virtualfilter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/openweb3/go-rpc-provider/interfaces"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// timeout to forward request to the other service instance
+	clusterForwardTimeout = 3 * time.Second
+)
+
+// filterCluster shards virtual filters across the alive virtual filter service instances by
+// rendezvous hashing, so that only the owner instance serves changes for a virtual filter while the
+// others forward requests to the owner. Ownership rebalances once the instance membership changes.
+type filterCluster struct {
+	conf     *registryConfig
+	registry filterRegistry
+
+	mu      sync.RWMutex
+	members []string // urls of alive service instances
+
+	// RPC providers to forward requests: instance url => provider
+	providers util.ConcurrentMap
+}
+
+func newFilterCluster(conf *registryConfig, registry filterRegistry) *filterCluster {
+	if len(conf.ServiceRpcUrl) == 0 {
+		logrus.Fatal("Service RPC url must be specified for virtual filter registry")
+	}
+
+	return &filterCluster{
+		conf:     conf,
+		registry: registry,
+		members:  []string{conf.ServiceRpcUrl},
+	}
+}
+
+// run heartbeats and refreshes the instance membership periodically, and calls `onRebalance`
+// once the membership changed.
+func (c *filterCluster) run(ctx context.Context, onRebalance func()) {
+	ticker := time.NewTicker(c.conf.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		changed, err := c.refresh()
+		if err != nil {
+			logrus.WithError(err).Info("Virtual filter cluster failed to refresh membership")
+		} else if changed {
+			logrus.WithField("members", c.alive()).Info("Virtual filter cluster membership changed")
+			onRebalance()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh heartbeats and reloads the instance membership, and returns true if membership changed.
+func (c *filterCluster) refresh() (bool, error) {
+	if err := c.registry.heartbeat(c.conf.ServiceRpcUrl); err != nil {
+		return false, errors.WithMessage(err, "failed to heartbeat")
+	}
+
+	members, err := c.registry.members(c.conf.MemberTTL)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to load members")
+	}
+
+	if !slices.Contains(members, c.conf.ServiceRpcUrl) {
+		members = append(members, c.conf.ServiceRpcUrl)
+	}
+
+	slices.Sort(members)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slices.Equal(members, c.members) {
+		return false, nil
+	}
+
+	c.members = members
+	return true, nil
+}
+
+func (c *filterCluster) alive() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.members
+}
+
+// owner returns the url of the service instance which owns the virtual filter.
+func (c *filterCluster) owner(id rpc.ID) string {
+	return rendezvousOwner(c.alive(), string(id))
+}
+
+// isLocal returns true if the virtual filter is owned by this service instance.
+func (c *filterCluster) isLocal(id rpc.ID) bool {
+	return c.owner(id) == c.conf.ServiceRpcUrl
+}
+
+// forward forwards RPC request to the specified service instance.
+func (c *filterCluster) forward(url string, result interface{}, method string, args ...interface{}) error {
+	return c.forwardWithTimeout(clusterForwardTimeout, url, result, method, args...)
+}
+
+// forwardWithTimeout forwards RPC request to the specified service instance with specific timeout,
+// e.g., for long polling requests.
+func (c *filterCluster) forwardWithTimeout(
+	timeout time.Duration, url string, result interface{}, method string, args ...interface{},
+) error {
+	p, _, err := c.providers.LoadOrStoreFnErr(url, func(interface{}) (interface{}, error) {
+		return providers.NewProviderWithOption(url, providers.Option{RequestTimeout: clusterForwardTimeout})
+	})
+
+	if err != nil {
+		return errors.WithMessage(err, "failed to create RPC provider")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return p.(interfaces.Provider).CallContext(ctx, result, method, args...)
+}
+
+// rendezvousOwner picks the member with the highest hash weight of the key.
+func rendezvousOwner(members []string, key string) (owner string) {
+	var maxWeight uint64
+
+	for _, m := range members {
+		h := sha256.Sum256([]byte(m + "/" + key))
+
+		if w := binary.BigEndian.Uint64(h[:8]); len(owner) == 0 || w > maxWeight {
+			owner, maxWeight = m, w
+		}
+	}
+
+	return owner
+}
@@ -11,8 +11,24 @@ type ethConfig struct {
 	Endpoint string        `default:":48545"` // server listening endpoint (default: :48545)
 	TTL      time.Duration `default:"1m"`     // how long filters stay active (default: 1min)
 
+	// admin RPC endpoint, which is served apart from the filter API endpoint to be restricted to
+	// the private network, or disabled if empty
+	AdminEndpoint string
+
 	// max number of filter blocks full of event logs to restrict memory usage (default: 100)
 	MaxFullFilterBlocks int `default:"100"`
+
+	// max number of block or pending txn hashes buffered per full node (default: 10000)
+	MaxFilterHashes int `default:"10000"`
+
+	// max duration to wait for filter changes by long polling (default: 30s)
+	MaxWaitTimeout time.Duration `default:"30s"`
+
+	// durable registry to persist virtual filters and shard them across instances
+	Registry registryConfig
+
+	// commit stream of syncer to drive log filters instead of polling full node
+	Stream streamConfig
 }
 
 func mustNewEthConfigFromViper() *ethConfig {
@@ -27,8 +43,24 @@ type cfxConfig struct {
 	Endpoint string        `default:":42537"` // server listening endpoint (default: :42537)
 	TTL      time.Duration `default:"1m"`     // how long filters stay active (default: 1min)
 
+	// admin RPC endpoint, which is served apart from the filter API endpoint to be restricted to
+	// the private network, or disabled if empty
+	AdminEndpoint string
+
 	// max number of filter epochs full of event logs to restrict memory usage (default: 100)
 	MaxFullFilterEpochs int `default:"100"`
+
+	// max number of block or pending txn hashes buffered per full node (default: 10000)
+	MaxFilterHashes int `default:"10000"`
+
+	// max duration to wait for filter changes by long polling (default: 30s)
+	MaxWaitTimeout time.Duration `default:"30s"`
+
+	// durable registry to persist virtual filters and shard them across instances
+	Registry registryConfig
+
+	// commit stream of syncer to drive log filters instead of polling full node
+	Stream streamConfig
 }
 
 func mustNewCfxConfigFromViper() *cfxConfig {
@@ -37,3 +69,27 @@ func mustNewCfxConfigFromViper() *cfxConfig {
 
 	return &conf
 }
+
+// registryConfig represents the configuration of the durable virtual filter registry.
+type registryConfig struct {
+	// backend to persist virtual filters, available options are `mysql` and `redis`, or disabled
+	// if empty so that virtual filters are kept in memory only
+	Backend string
+	// redis url if `redis` backend used
+	RedisUrl string
+	// exposed RPC url of this instance for other instances to forward requests to
+	ServiceRpcUrl string
+	// interval to heartbeat and refresh the service instance membership (default: 5s)
+	HeartbeatInterval time.Duration `default:"5s"`
+	// how long a service instance stays as member without heartbeat (default: 15s)
+	MemberTTL time.Duration `default:"15s"`
+}
+
+// streamConfig represents the configuration to drive log filters by the commit stream of syncer.
+type streamConfig struct {
+	// whether to drive log filters by the change data capture events of syncer, which requires
+	// the change data capture sink of syncer enabled
+	Enabled bool
+	// max number of recent epochs (or blocks) cached from the commit stream (default: 1000)
+	CacheSize int `default:"1000"`
+}
@@ -1,12 +1,11 @@
 package virtualfilter
 
 import (
-	"github.com/Conflux-Chain/confura/node"
-	"github.com/Conflux-Chain/confura/util"
-	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"time"
+
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
 	w3rpc "github.com/openweb3/go-rpc-provider"
 	"github.com/openweb3/web3go/types"
-	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -16,76 +15,67 @@ var (
 // EVM space filter API
 
 type ethFilterApi struct {
-	fs        *ethFilterSystem   // filter system
-	fnClients util.ConcurrentMap // full node clients: node name => sdk client
+	fs *ethFilterSystem // filter system
 }
 
 func newEthFilterApi(sys *ethFilterSystem) *ethFilterApi {
 	return &ethFilterApi{fs: sys}
 }
 
-func (api *ethFilterApi) NewBlockFilter(nodeUrl string) (w3rpc.ID, error) {
-	client, err := api.loadOrGetFnClient(nodeUrl)
+// NewBlockFilter creates the filter, and the optional `quota` is used for admission control of the owner.
+func (api *ethFilterApi) NewBlockFilter(nodeUrl string, quota *vfclient.FilterQuota) (w3rpc.ID, error) {
+	client, err := api.fs.loadOrGetFnClient(nodeUrl)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	return api.fs.newBlockFilter(client)
+	return api.fs.quotas.admit(quota, func() (w3rpc.ID, error) {
+		return api.fs.newBlockFilter(client)
+	})
 }
 
-func (api *ethFilterApi) NewPendingTransactionFilter(nodeUrl string) (w3rpc.ID, error) {
-	client, err := api.loadOrGetFnClient(nodeUrl)
+// NewPendingTransactionFilter creates the filter, and the optional `quota` is used for admission control of the owner.
+func (api *ethFilterApi) NewPendingTransactionFilter(nodeUrl string, quota *vfclient.FilterQuota) (w3rpc.ID, error) {
+	client, err := api.fs.loadOrGetFnClient(nodeUrl)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	return api.fs.newPendingTransactionFilter(client)
+	return api.fs.quotas.admit(quota, func() (w3rpc.ID, error) {
+		return api.fs.newPendingTransactionFilter(client)
+	})
 }
 
-func (api *ethFilterApi) UninstallFilter(id w3rpc.ID) (bool, error) {
-	return api.fs.uninstallFilter(id)
+// UninstallFilter uninstalls the filter, and the optional `local` flag indicates the request is
+// forwarded by the other service instance and must not be forwarded any more.
+func (api *ethFilterApi) UninstallFilter(id w3rpc.ID, local *bool) (bool, error) {
+	return api.fs.uninstallFilter(id, local != nil && *local)
 }
 
-func (api *ethFilterApi) NewFilter(nodeUrl string, crit types.FilterQuery) (w3rpc.ID, error) {
-	client, err := api.loadOrGetFnClient(nodeUrl)
+// NewFilter creates the log filter, and the optional `quota` is used for admission control of the owner.
+func (api *ethFilterApi) NewFilter(nodeUrl string, crit types.FilterQuery, quota *vfclient.FilterQuota) (w3rpc.ID, error) {
+	client, err := api.fs.loadOrGetFnClient(nodeUrl)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	return api.fs.newFilter(client, crit)
+	return api.fs.quotas.admit(quota, func() (w3rpc.ID, error) {
+		return api.fs.newFilter(client, crit)
+	})
 }
 
 func (api *ethFilterApi) GetLogFilter(fid w3rpc.ID) (*types.FilterQuery, error) {
-	vf, ok := api.fs.getFilter(fid)
-	if !ok || vf.ftype() != filterTypeLog {
-		return nil, errFilterNotFound
-	}
-
-	ethf := vf.(*ethLogFilter)
-	return &ethf.crit, nil
+	return api.fs.getLogFilter(fid)
 }
 
-func (api *ethFilterApi) GetFilterChanges(id w3rpc.ID) (*types.FilterChanges, error) {
-	return api.fs.getFilterChanges(id)
-}
-
-func (api *ethFilterApi) loadOrGetFnClient(nodeUrl string) (*node.Web3goClient, error) {
-	nodeName := rpcutil.Url2NodeName(nodeUrl)
-	client, _, err := api.fnClients.LoadOrStoreFnErr(nodeName, func(interface{}) (interface{}, error) {
-		client, err := rpcutil.NewEthClient(nodeUrl, rpcutil.WithClientHookMetrics(true))
-		if err != nil {
-			logrus.WithField("fnNodeUrl", nodeUrl).
-				WithError(err).
-				Error("Failed to new eth client for virtual filter")
-			return nil, err
-		}
-
-		return &node.Web3goClient{Client: client, URL: nodeUrl}, nil
-	})
-
-	if err != nil {
-		return nil, err
+// GetFilterChanges returns the filter changes, and the optional `local` flag indicates the request
+// is forwarded by the other service instance and must not be forwarded any more. The optional
+// `waitMillis` is the max duration in milliseconds to wait until any change arrives if no change yet.
+func (api *ethFilterApi) GetFilterChanges(id w3rpc.ID, local *bool, waitMillis *uint64) (*types.FilterChanges, error) {
+	var wait time.Duration
+	if waitMillis != nil {
+		wait = time.Duration(*waitMillis) * time.Millisecond
 	}
 
-	return client.(*node.Web3goClient), nil
+	return api.fs.getFilterChanges(id, local != nil && *local, wait)
 }
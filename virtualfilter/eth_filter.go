@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/rpc/ethbridge"
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/sink"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/openweb3/go-rpc-provider"
 	"github.com/openweb3/web3go/types"
 	"github.com/pkg/errors"
@@ -34,64 +38,76 @@ func newEthFilter(fid rpc.ID, typ filterType, client *node.Web3goClient) *ethFil
 	}
 }
 
-// implements `virtualFilter` interface
-
-func (f *ethFilter) fetch() (filterChanges, error) {
-	return f.client.Filter.GetFilterChanges(f.id)
+func (f *ethFilter) nodeName() string {
+	return f.client.NodeName()
 }
 
-func (f *ethFilter) uninstall() (bool, error) {
-	metricVirtualFilterSession("eth", f, -1)
-	return f.client.Filter.UninstallFilter(f.id)
+// ethHashFilter evm space virtual block or pending txn filter, which fetches changes from the
+// shared hash worker of full node instead of a dedicated full node filter.
+type ethHashFilter struct {
+	*ethFilter
+	worker *hashWorker[common.Hash]
 }
 
-func (f *ethFilter) nodeName() string {
-	return f.client.NodeName()
-}
+func newEthHashFilter(
+	worker *hashWorker[common.Hash], client *node.Web3goClient, fid rpc.ID, typ filterType,
+) (*ethHashFilter, error) {
+	f := &ethHashFilter{
+		worker:    worker,
+		ethFilter: newEthFilter(fid, typ, client),
+	}
 
-func newEthBlockFilter(client *node.Web3goClient) (*ethFilter, error) {
-	fid, err := client.Filter.NewBlockFilter()
-	if err != nil {
+	if err := worker.accept(fid); err != nil {
 		return nil, err
 	}
 
-	f := newEthFilter(*fid, filterTypeBlock, client)
 	metricVirtualFilterSession("eth", f, 1)
-
 	return f, nil
 }
 
-func newEthPendingTxnFilter(client *node.Web3goClient) (*ethFilter, error) {
-	fid, err := client.Filter.NewPendingTransactionFilter()
+func (f *ethHashFilter) fetch() (filterChanges, error) {
+	hashes, err := f.worker.fetch(f.id)
 	if err != nil {
 		return nil, err
 	}
 
-	f := newEthFilter(*fid, filterTypePendingTxn, client)
-	metricVirtualFilterSession("eth", f, 1)
+	return &types.FilterChanges{Hashes: hashes}, nil
+}
 
-	return f, nil
+func (f *ethHashFilter) uninstall() (bool, error) {
+	metricVirtualFilterSession("eth", f, -1)
+	return f.worker.reject(f.id), nil
 }
 
 type ethLogFilter struct {
 	*ethFilter
 
+	mu       sync.Mutex
 	logStore *mysql.VirtualFilterLogStore
 	worker   *ethFilterWorker
 	crit     types.FilterQuery
+
+	// block number up to which changes have been delivered
+	delivered uint64
+	// whether to backfill changes since the delivered block upon fetch, which are missed while
+	// the filter is not served in memory (eg., service restarted or rebalanced)
+	resuming bool
+	// block number up to which changes have been backfilled
+	backfilled uint64
 }
 
 func newEthLogFilter(
 	vfls *mysql.VirtualFilterLogStore,
 	worker *ethFilterWorker,
 	client *node.Web3goClient,
+	fid rpc.ID,
 	crit types.FilterQuery,
 ) (*ethLogFilter, error) {
 	lf := &ethLogFilter{
 		logStore:  vfls,
 		worker:    worker,
 		crit:      crit,
-		ethFilter: newEthFilter(rpc.NewID(), filterTypeLog, client),
+		ethFilter: newEthFilter(fid, filterTypeLog, client),
 	}
 
 	if err := worker.accept(lf); err != nil {
@@ -107,7 +123,21 @@ func (f *ethLogFilter) uninstall() (bool, error) {
 	return f.worker.reject(f)
 }
 
+func (f *ethLogFilter) criteria() interface{} {
+	return &f.crit
+}
+
+func (f *ethLogFilter) deliveredTo() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delivered
+}
+
 func (f *ethLogFilter) fetch() (filterChanges, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	// get change blocks from filter worker since last polling
 	pchanges, err := f.worker.fetchPollingChanges(f.id)
 	if err != nil {
@@ -160,7 +190,29 @@ func (f *ethLogFilter) fetch() (filterChanges, error) {
 	}
 
 	changeLogs := make([]types.Log, 0)
+	delivered, backfilled := f.delivered, f.backfilled
+
+	if f.resuming { // backfill changes missed before restored, which covers the polled changes
+		logs, latestBn, err := f.backfill()
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to backfill filter changes")
+		}
+
+		changeLogs = append(changeLogs, logs...)
+		delivered, backfilled = latestBn, latestBn
+	}
+
 	for _, fb := range pchanges.blocks {
+		if !fb.reverted && fb.blockNum <= backfilled { // already backfilled
+			continue
+		}
+
+		if fb.reverted {
+			delivered = fb.blockNum - 1
+		} else {
+			delivered = fb.blockNum
+		}
+
 		logs := fb.logs
 		if len(logs) == 0 { // load from store logs
 			logs = blockLogs[fb.blockHash.String()]
@@ -170,5 +222,189 @@ func (f *ethLogFilter) fetch() (filterChanges, error) {
 		changeLogs = append(changeLogs, logs...)
 	}
 
+	f.delivered, f.backfilled, f.resuming = delivered, backfilled, false
+
+	return &types.FilterChanges{Logs: changeLogs}, nil
+}
+
+// backfill fetches event logs from full node since the delivered block up to the latest block.
+func (f *ethLogFilter) backfill() ([]types.Log, uint64, error) {
+	latestBlock, err := f.client.Eth.BlockNumber()
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get latest block number")
+	}
+
+	latestBn := latestBlock.Uint64()
+	fromBn, toBn := f.delivered+1, latestBn
+
+	crit := f.crit
+	if crit.FromBlock != nil && *crit.FromBlock >= 0 {
+		fromBn = util.MaxUint64(fromBn, uint64(*crit.FromBlock))
+	}
+
+	if crit.ToBlock != nil && *crit.ToBlock >= 0 {
+		toBn = util.MinUint64(toBn, uint64(*crit.ToBlock))
+	}
+
+	if crit.BlockHash != nil || fromBn > toBn {
+		return nil, latestBn, nil
+	}
+
+	fromBlock, toBlock := types.BlockNumber(fromBn), types.BlockNumber(toBn)
+	crit.FromBlock, crit.ToBlock = &fromBlock, &toBlock
+
+	logs, err := f.client.Eth.Logs(crit)
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get logs")
+	}
+
+	return logs, latestBn, nil
+}
+
+// ethStreamLogFilter evm space log filter driven by the commit stream of syncer.
+type ethStreamLogFilter struct {
+	streamLogFilter
+	client  *node.Web3goClient
+	crit    types.FilterQuery
+	chainId *uint32 // cached chain id to load event logs from db store
+}
+
+func newEthStreamLogFilter(
+	fs *filterSystemBase, client *node.Web3goClient, fid rpc.ID, crit types.FilterQuery, delivered uint64,
+) *ethStreamLogFilter {
+	f := &ethStreamLogFilter{
+		streamLogFilter: newStreamLogFilter(fs, fid, delivered),
+		client:          client,
+		crit:            crit,
+	}
+
+	metricVirtualFilterSession("eth", f, 1)
+	return f
+}
+
+func (f *ethStreamLogFilter) nodeName() string {
+	return f.client.NodeName()
+}
+
+func (f *ethStreamLogFilter) criteria() interface{} {
+	return &f.crit
+}
+
+func (f *ethStreamLogFilter) uninstall() (bool, error) {
+	metricVirtualFilterSession("eth", f, -1)
+	return true, nil
+}
+
+func (f *ethStreamLogFilter) fetch() (filterChanges, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sc, unverified, err := f.changes()
+	if err != nil {
+		return nil, err
+	}
+
+	changeLogs := make([]types.Log, 0)
+
+	// logs of the reverted blocks are delivered as removed
+	for _, e := range sc.reverted {
+		logs := filterEthLogs(convertStreamEthLogs(e.logs), &f.crit)
+		for i := range logs {
+			logs[i].Removed = true
+		}
+
+		changeLogs = append(changeLogs, logs...)
+	}
+
+	delivered := sc.revertTo
+
+	fromBn, toBn, ok, err := f.fallbackRange(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok { // load from db store if falling behind cache
+		var logs []sink.LogData
+
+		logs, unverified, err = f.loadStore(sc, fromBn, toBn, unverified, f.loadStoreLogs)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to load filter changes from db store")
+		}
+
+		changeLogs = append(changeLogs, convertStreamEthLogs(logs)...)
+		delivered = toBn
+	}
+
+	if delivered+1 >= sc.oldest {
+		for _, e := range sc.epochs {
+			changeLogs = append(changeLogs, filterEthLogs(convertStreamEthLogs(e.logs), &f.crit)...)
+			delivered = e.number
+		}
+	}
+
+	f.deliver(delivered, sc.revision, unverified)
+
 	return &types.FilterChanges{Logs: changeLogs}, nil
 }
+
+// loadStoreLogs loads event logs from db store within the specified block range.
+func (f *ethStreamLogFilter) loadStoreLogs(fromBn, toBn uint64) ([]sink.LogData, error) {
+	crit := &f.crit
+	if crit.FromBlock != nil && *crit.FromBlock >= 0 {
+		fromBn = util.MaxUint64(fromBn, uint64(*crit.FromBlock))
+	}
+
+	if crit.ToBlock != nil && *crit.ToBlock >= 0 {
+		toBn = util.MinUint64(toBn, uint64(*crit.ToBlock))
+	}
+
+	if crit.BlockHash != nil || fromBn > toBn {
+		return nil, nil
+	}
+
+	if f.chainId == nil {
+		chainId, err := f.client.Eth.ChainId()
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get chain id")
+		}
+
+		cid := uint32(*chainId)
+		f.chainId = &cid
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), store.TimeoutGetLogs)
+	defer cancel()
+
+	startTime := time.Now()
+	defer metrics.Registry.VirtualFilter.QueryFilterChanges("eth", f.nodeName(), "mysql").UpdateSince(startTime)
+
+	sfilter := store.ParseEthLogFilter(fromBn, toBn, crit, *f.chainId)
+	slogs, err := f.db.GetLogs(timeoutCtx, sfilter)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertStoreLogs(slogs), nil
+}
+
+// convertStreamEthLogs converts event logs of the commit stream, which are stored in core space
+// format along with the extended fields, to evm space event logs.
+func convertStreamEthLogs(logs []sink.LogData) []types.Log {
+	ethLogs := make([]types.Log, 0, len(logs))
+	for i := range logs {
+		ethLogs = append(ethLogs, *ethbridge.ConvertLog(&logs[i].Log, logs[i].Extra))
+	}
+
+	return ethLogs
+}
+
+// countEthStreamLogs counts the event logs of the sealed epoch matched by the stream log filter,
+// which are not delivered yet.
+func countEthStreamLogs(vf virtualFilter, epoch *streamEpoch) int {
+	sf, ok := vf.(*ethStreamLogFilter)
+	if !ok || sf.deliveredTo() >= epoch.number {
+		return 0
+	}
+
+	return len(filterEthLogs(convertStreamEthLogs(epoch.logs), &sf.crit))
+}
@@ -0,0 +1,147 @@
+package virtualfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStreamStore in-memory db store of pivot hashes and event logs for stream log filters.
+type testStreamStore struct {
+	pivotHashes map[uint64]string
+	logs        []*store.Log
+}
+
+func (s *testStreamStore) MaxEpoch() (maxEpoch uint64, ok bool, err error) {
+	for epoch := range s.pivotHashes {
+		maxEpoch, ok = max(maxEpoch, epoch), true
+	}
+
+	return maxEpoch, ok, nil
+}
+
+func (s *testStreamStore) PivotHash(epoch uint64) (string, bool, error) {
+	pivotHash, ok := s.pivotHashes[epoch]
+	return pivotHash, ok, nil
+}
+
+func (s *testStreamStore) PivotHashes(epochFrom, epochTo uint64) (map[uint64]string, error) {
+	pivotHashes := make(map[uint64]string)
+	for epoch, pivotHash := range s.pivotHashes {
+		if epoch >= epochFrom && epoch <= epochTo {
+			pivotHashes[epoch] = pivotHash
+		}
+	}
+
+	return pivotHashes, nil
+}
+
+func (s *testStreamStore) GetLogs(ctx context.Context, filter store.LogFilter) ([]*store.Log, error) {
+	var logs []*store.Log
+	for _, log := range s.logs {
+		if log.Epoch >= filter.BlockFrom && log.Epoch <= filter.BlockTo {
+			logs = append(logs, log)
+		}
+	}
+
+	return logs, nil
+}
+
+// reorg switches the pivot chain since the specified epoch, with one event log for each epoch.
+func (s *testStreamStore) reorg(from, to uint64, prefix string) {
+	var logs []*store.Log
+	for _, log := range s.logs {
+		if log.Epoch < from {
+			logs = append(logs, log)
+		}
+	}
+
+	for epoch := from; epoch <= to; epoch++ {
+		pivotHash := prefix + string(rune('0'+epoch))
+		log := newTestStreamLog(epoch)
+
+		s.pivotHashes[epoch] = pivotHash
+		logs = append(logs, store.ParseCfxLog(&log.Log, 0, epoch, log.Extra))
+	}
+
+	s.logs = logs
+}
+
+func newTestEthStreamLogFilter(s *logStream, db streamStore) *ethStreamLogFilter {
+	chainId := uint32(71)
+
+	return &ethStreamLogFilter{
+		streamLogFilter: streamLogFilter{stream: s, db: db},
+		client:          &node.Web3goClient{URL: "http://127.0.0.1:8545"},
+		chainId:         &chainId,
+	}
+}
+
+func fetchTestEthLogs(t *testing.T, f *ethStreamLogFilter) []types.Log {
+	changes, err := f.fetch()
+	require.NoError(t, err)
+
+	return changes.(*types.FilterChanges).Logs
+}
+
+func TestEthStreamLogFilterLogExtra(t *testing.T) {
+	s := newLogStream("eth", 10)
+	f := newTestEthStreamLogFilter(s, &testStreamStore{})
+
+	applyTestStreamEvents(s, newTestStreamEvents(t, 1, "0x01", 1))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 2, "0x02", 0))
+
+	logs := fetchTestEthLogs(t, f)
+	require.Len(t, logs, 1)
+	require.NotNil(t, logs[0].LogType)
+	assert.Equal(t, "call", *logs[0].LogType)
+}
+
+func TestEthStreamLogFilterRevertAfterGap(t *testing.T) {
+	db := &testStreamStore{pivotHashes: make(map[uint64]string)}
+	db.reorg(1, 3, "0x0")
+
+	s := newLogStream("eth", 10)
+	f := newTestEthStreamLogFilter(s, db)
+
+	for epoch := uint64(1); epoch <= 3; epoch++ {
+		applyTestStreamEvents(s, newTestStreamEvents(t, epoch, db.pivotHashes[epoch], 1))
+	}
+
+	logs := fetchTestEthLogs(t, f)
+	assert.Len(t, logs, 2)
+	assert.Equal(t, uint64(2), f.deliveredTo())
+
+	// events missed while pivot switched since epoch 2
+	applyTestStreamEvents(s, newTestStreamEvents(t, 10, "0x0a", 0))
+	db.reorg(2, 4, "0x1")
+
+	logs = fetchTestEthLogs(t, f)
+	require.Len(t, logs, 4)
+	assert.True(t, logs[0].Removed)
+	assert.Equal(t, uint64(2), logs[0].BlockNumber)
+	for i, bn := range []uint64{2, 3, 4} { // reloaded from db store since the stream is not ready
+		assert.False(t, logs[i+1].Removed)
+		assert.Equal(t, bn, logs[i+1].BlockNumber)
+		require.NotNil(t, logs[i+1].LogType)
+	}
+	assert.Equal(t, uint64(4), f.deliveredTo())
+
+	// pivot switched again while the stream is not ready
+	db.reorg(4, 4, "0x2")
+
+	logs = fetchTestEthLogs(t, f)
+	require.Len(t, logs, 2)
+	assert.True(t, logs[0].Removed)
+	assert.Equal(t, uint64(4), logs[0].BlockNumber)
+	assert.False(t, logs[1].Removed)
+	assert.Equal(t, uint64(4), logs[1].BlockNumber)
+
+	// nothing changed
+	assert.Empty(t, fetchTestEthLogs(t, f))
+}
@@ -2,6 +2,7 @@ package virtualfilter
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	cmdutil "github.com/Conflux-Chain/confura/cmd/util"
@@ -9,6 +10,8 @@ import (
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/openweb3/go-rpc-provider"
 	"github.com/openweb3/web3go/types"
 	"github.com/pkg/errors"
@@ -18,77 +21,310 @@ import (
 // evm space virtual filter system
 type ethFilterSystem struct {
 	*filterSystemBase
-	conf *ethConfig
+	conf      *ethConfig
+	fnClients util.ConcurrentMap // full node clients: node name => sdk client
 }
 
 func newEthFilterSystem(
 	conf *ethConfig,
-	vfls *mysql.VirtualFilterLogStore,
+	db *mysql.MysqlStore,
 	shutdownCtx cmdutil.GracefulShutdownContext,
 ) *ethFilterSystem {
 	return &ethFilterSystem{
-		conf:             conf,
-		filterSystemBase: newFilterSystemBase(conf.TTL, vfls, shutdownCtx),
+		conf: conf,
+		filterSystemBase: newFilterSystemBase(
+			"eth", conf.TTL, &conf.Registry, &conf.Stream, "sync.eth.sink", countEthStreamLogs, db, shutdownCtx,
+		),
 	}
 }
 
 func (fs *ethFilterSystem) newBlockFilter(client *node.Web3goClient) (rpc.ID, error) {
-	f, err := newEthBlockFilter(client)
+	return fs.newHashFilter(client, filterTypeBlock)
+}
+
+func (fs *ethFilterSystem) newPendingTransactionFilter(client *node.Web3goClient) (rpc.ID, error) {
+	return fs.newHashFilter(client, filterTypePendingTxn)
+}
+
+// newHashFilter creates virtual block or pending txn filter delegated to the shared hash worker.
+func (fs *ethFilterSystem) newHashFilter(client *node.Web3goClient, typ filterType) (rpc.ID, error) {
+	f, err := newEthHashFilter(fs.loadOrNewHashWorker(client, typ), client, fs.newFilterID(), typ)
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	fs.filterMgr.add(f)
+	rec := &filterRecord{ID: f.fid(), Type: typ, NodeUrl: client.URL}
+	if err := fs.addFilter(f, rec); err != nil {
+		f.uninstall()
+		return nilRpcId, err
+	}
+
 	return f.fid(), nil
 }
 
-func (fs *ethFilterSystem) newPendingTransactionFilter(client *node.Web3goClient) (rpc.ID, error) {
-	f, err := newEthPendingTxnFilter(client)
+func (fs *ethFilterSystem) newFilter(client *node.Web3goClient, crit types.FilterQuery) (rpc.ID, error) {
+	if fs.stream != nil {
+		return fs.newStreamFilter(client, crit)
+	}
+
+	if fs.registry == nil {
+		f, err := newEthLogFilter(fs.logStore, fs.loadOrNewWorker(client), client, rpc.NewID(), crit)
+		if err != nil {
+			return nilRpcId, err
+		}
+
+		fs.filterMgr.add(f)
+		return f.fid(), nil
+	}
+
+	// register the log filter with the latest block as delivered cursor, which will be restored
+	// by the owner service instance upon polling.
+	latestBlock, err := client.Eth.BlockNumber()
+	if err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to get latest block number")
+	}
+
+	jcrit, err := json.Marshal(crit)
+	if err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to marshal filter criteria")
+	}
+
+	rec := &filterRecord{
+		ID:      rpc.NewID(),
+		Type:    filterTypeLog,
+		NodeUrl: client.URL,
+		Crit:    jcrit,
+		Cursor:  latestBlock.Uint64(),
+	}
+
+	if err := fs.registry.put(rec); err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to register virtual filter")
+	}
+
+	return rec.ID, nil
+}
+
+// newStreamFilter creates log filter driven by the commit stream of syncer, with the latest block
+// of the stream as delivered cursor.
+func (fs *ethFilterSystem) newStreamFilter(client *node.Web3goClient, crit types.FilterQuery) (rpc.ID, error) {
+	cursor, err := fs.streamCursor()
 	if err != nil {
 		return nilRpcId, err
 	}
 
-	fs.filterMgr.add(f)
-	return f.fid(), nil
+	if fs.registry == nil {
+		f := newEthStreamLogFilter(fs.filterSystemBase, client, rpc.NewID(), crit, cursor)
+		fs.filterMgr.add(f)
+		return f.fid(), nil
+	}
+
+	jcrit, err := json.Marshal(crit)
+	if err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to marshal filter criteria")
+	}
+
+	rec := &filterRecord{
+		ID:      rpc.NewID(),
+		Type:    filterTypeLog,
+		NodeUrl: client.URL,
+		Crit:    jcrit,
+		Cursor:  cursor,
+	}
+
+	if err := fs.registry.put(rec); err != nil {
+		return nilRpcId, errors.WithMessage(err, "failed to register virtual filter")
+	}
+
+	return rec.ID, nil
 }
 
-func (fs *ethFilterSystem) newFilter(client *node.Web3goClient, crit types.FilterQuery) (rpc.ID, error) {
+// restoreFilter restores the virtual filter from the registry record.
+func (fs *ethFilterSystem) restoreFilter(rec *filterRecord) (virtualFilter, error) {
+	client, err := fs.loadOrGetFnClient(rec.NodeUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.Type != filterTypeLog {
+		return newEthHashFilter(fs.loadOrNewHashWorker(client, rec.Type), client, rec.ID, rec.Type)
+	}
+
+	var crit types.FilterQuery
+	if err := json.Unmarshal(rec.Crit, &crit); err != nil {
+		return nil, errors.WithMessage(err, "invalid filter criteria")
+	}
+
+	if fs.stream != nil {
+		return newEthStreamLogFilter(fs.filterSystemBase, client, rec.ID, crit, rec.Cursor), nil
+	}
+
+	f, err := newEthLogFilter(fs.logStore, fs.loadOrNewWorker(client), client, rec.ID, crit)
+	if err != nil {
+		return nil, err
+	}
+
+	f.delivered, f.resuming = rec.Cursor, true
+	return f, nil
+}
+
+func (fs *ethFilterSystem) loadOrNewWorker(client *node.Web3goClient) *ethFilterWorker {
 	worker, _ := fs.workers.LoadOrStoreFn(client.NodeName(), func(k interface{}) interface{} {
 		return newEthFilterWorker(
 			fs.conf.MaxFullFilterBlocks, fs, client, fs.shutdownCtx,
 		)
 	})
 
-	f, err := newEthLogFilter(fs.logStore, worker.(*ethFilterWorker), client, crit)
+	return worker.(*ethFilterWorker)
+}
+
+func (fs *ethFilterSystem) loadOrNewHashWorker(client *node.Web3goClient, typ filterType) *hashWorker[common.Hash] {
+	key := fmt.Sprintf("%v/%v", client.NodeName(), typ)
+	worker, _ := fs.hashWorkers.LoadOrStoreFn(key, func(k interface{}) interface{} {
+		return newEthHashWorker(typ, fs.conf.MaxFilterHashes, client, fs.notifier, fs.shutdownCtx)
+	})
+
+	return worker.(*hashWorker[common.Hash])
+}
+
+// getFilterChanges returns the filter changes. If the buffered logs exceeded the quota of the filter
+// owner since the last polling, the filter has been evicted and is uninstalled with error.
+func (fs *ethFilterSystem) getFilterChanges(id rpc.ID, local bool, wait time.Duration) (*types.FilterChanges, error) {
+	if err := fs.checkLogsQuota(id); err != nil {
+		fs.uninstallFilter(id, local)
+		return nil, err
+	}
+
+	fc, err := fs.pollFilterChanges(id, local, wait)
 	if err != nil {
-		return nilRpcId, err
+		return nil, err
 	}
 
-	fs.filterMgr.add(f)
-	return f.fid(), nil
+	fs.quotas.observe(id)
+
+	return fc, nil
 }
 
-func (fs *ethFilterSystem) getFilterChanges(id rpc.ID) (*types.FilterChanges, error) {
-	vf, ok := fs.filterMgr.get(id)
-	if !ok {
-		return nil, errFilterNotFound
+// pollFilterChanges polls the filter changes, and waits up to the specified duration (capped by
+// config) until any change arrives if no change yet.
+func (fs *ethFilterSystem) pollFilterChanges(id rpc.ID, local bool, wait time.Duration) (*types.FilterChanges, error) {
+	wait = min(wait, fs.conf.MaxWaitTimeout)
+
+	vf, owner, err := fs.loadFilter(id, local, fs.restoreFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(owner) > 0 { // forward to the owner service instance
+		var fc *types.FilterChanges
+		if wait <= 0 {
+			err := fs.cluster.forward(owner, &fc, "eth_getFilterChanges", id, true)
+			return fc, err
+		}
+
+		err := fs.cluster.forwardWithTimeout(
+			wait+clusterForwardTimeout, owner, &fc, "eth_getFilterChanges", id, true, wait.Milliseconds(),
+		)
+		return fc, err
 	}
 
-	fc, err := vf.fetch()
+	fc, err := fs.fetchChanges(vf, wait, func(fc filterChanges) bool {
+		changes := fc.(*types.FilterChanges)
+		return len(changes.Logs) == 0 && len(changes.Hashes) == 0
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	fs.filterMgr.refresh(id)
+	fs.touch(vf)
+
 	return fc.(*types.FilterChanges), nil
 }
 
-func (fs *ethFilterSystem) uninstallFilter(id rpc.ID) (bool, error) {
+func (fs *ethFilterSystem) getLogFilter(id rpc.ID) (*types.FilterQuery, error) {
+	if vf, ok := fs.getFilter(id); ok {
+		if vf.ftype() != filterTypeLog {
+			return nil, errFilterNotFound
+		}
+
+		if sf, ok := vf.(*ethStreamLogFilter); ok {
+			return &sf.crit, nil
+		}
+
+		return &vf.(*ethLogFilter).crit, nil
+	}
+
+	if fs.registry == nil {
+		return nil, errFilterNotFound
+	}
+
+	rec, err := fs.registry.get(id)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get virtual filter from registry")
+	}
+
+	if rec == nil || rec.Type != filterTypeLog {
+		return nil, errFilterNotFound
+	}
+
+	var crit types.FilterQuery
+	if err := json.Unmarshal(rec.Crit, &crit); err != nil {
+		return nil, errors.WithMessage(err, "invalid filter criteria")
+	}
+
+	return &crit, nil
+}
+
+func (fs *ethFilterSystem) uninstallFilter(id rpc.ID, local bool) (bool, error) {
+	fs.quotas.release(id)
+
 	if vf, ok := fs.filterMgr.delete(id); ok {
+		fs.unregister(id)
 		return vf.uninstall()
 	}
 
-	return true, nil
+	if fs.registry == nil {
+		return true, nil
+	}
+
+	rec, err := fs.registry.get(id)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to get virtual filter from registry")
+	}
+
+	if rec == nil {
+		return true, nil
+	}
+
+	if !local && !fs.cluster.isLocal(id) { // forward to the owner service instance
+		var res bool
+		err := fs.cluster.forward(fs.cluster.owner(id), &res, "eth_uninstallFilter", id, true)
+		return res, err
+	}
+
+	// virtual filter not restored yet
+	return true, fs.unregister(id)
+}
+
+func (fs *ethFilterSystem) loadOrGetFnClient(nodeUrl string) (*node.Web3goClient, error) {
+	nodeName := rpcutil.Url2NodeName(nodeUrl)
+	client, _, err := fs.fnClients.LoadOrStoreFnErr(nodeName, func(interface{}) (interface{}, error) {
+		client, err := rpcutil.NewEthClient(nodeUrl, rpcutil.WithClientHookMetrics(true))
+		if err != nil {
+			logrus.WithField("fnNodeUrl", nodeUrl).
+				WithError(err).
+				Error("Failed to new eth client for virtual filter")
+			return nil, err
+		}
+
+		return &node.Web3goClient{Client: client, URL: nodeUrl}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*node.Web3goClient), nil
 }
 
 // implement `filterWorkerObserver` interface
@@ -100,6 +336,20 @@ func (fs *ethFilterSystem) onPolled(nodeName string, fid rpc.ID, changes filterC
 		return nil
 	}
 
+	// account the logs accumulated for the virtual filters delegated to the filter worker
+	count := func(vf virtualFilter) int {
+		if lf, ok := vf.(*ethLogFilter); ok && lf.worker.nodeName == nodeName {
+			return len(filterEthLogs(fchanges.Logs, &lf.crit))
+		}
+
+		return 0
+	}
+
+	fs.accumulateLogs(count)
+
+	// wake up long polling requests of the matched virtual filters once changes persisted
+	defer fs.notifyFilters(func(vf virtualFilter) bool { return count(vf) > 0 })
+
 	startTime := time.Now()
 	defer metrics.Registry.VirtualFilter.PersistFilterChanges("eth", nodeName, "mysql").UpdateSince(startTime)
 
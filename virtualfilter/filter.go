@@ -1,11 +1,11 @@
 package virtualfilter
 
 import (
-	"errors"
 	"strings"
 	"sync"
 	"time"
 
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
 	"github.com/openweb3/go-rpc-provider"
 )
 
@@ -20,8 +20,21 @@ const (
 	filterTypeLastIndex
 )
 
+func (t filterType) String() string {
+	switch t {
+	case filterTypeLog:
+		return "log"
+	case filterTypeBlock:
+		return "block"
+	case filterTypePendingTxn:
+		return "pendingTxn"
+	default:
+		return "unknown"
+	}
+}
+
 var (
-	errFilterNotFound = errors.New("filter not found")
+	errFilterNotFound = vfclient.ErrFilterNotFound
 )
 
 type filterChanges interface{}
@@ -31,11 +44,18 @@ type virtualFilter interface {
 	ftype() filterType              // filter type
 	nodeName() string               // delegate full node name
 	refresh()                       // refresh last polling time
+	polledAt() time.Time            // last polling time
 	expired(ttl time.Duration) bool // if this filter is expired with the provided TTL
 	fetch() (filterChanges, error)  // fetch filter changes since last polling
 	uninstall() (bool, error)       // uninstall filter
 }
 
+// durableFilter virtual filter of which the delivered cursor is persisted in registry, so that
+// it could be resumed after service restarted or rebalanced.
+type durableFilter interface {
+	deliveredTo() uint64 // block (or epoch) number up to which changes have been delivered
+}
+
 type filterBase struct {
 	id              rpc.ID     // filter ID
 	typ             filterType // filter type
@@ -54,6 +74,10 @@ func (f *filterBase) refresh() {
 	f.lastPollingTime = time.Now()
 }
 
+func (f *filterBase) polledAt() time.Time {
+	return f.lastPollingTime
+}
+
 func (f *filterBase) ftype() filterType {
 	return f.typ
 }
@@ -88,6 +112,16 @@ func (m *filterManager) get(id rpc.ID) (virtualFilter, bool) {
 	return v, ok
 }
 
+// rangeFilters calls the function for each virtual filter while holding the lock.
+func (m *filterManager) rangeFilters(fn func(f virtualFilter)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range m.filters {
+		fn(f)
+	}
+}
+
 func (m *filterManager) add(filter virtualFilter) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -108,12 +142,17 @@ func (m *filterManager) delete(id rpc.ID) (virtualFilter, bool) {
 }
 
 func (m *filterManager) expire(ttl time.Duration) map[rpc.ID]virtualFilter {
+	return m.evict(func(f virtualFilter) bool { return f.expired(ttl) })
+}
+
+// evict deletes and returns the virtual filters matched by the predicate.
+func (m *filterManager) evict(pred func(f virtualFilter) bool) map[rpc.ID]virtualFilter {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	res := make(map[rpc.ID]virtualFilter)
 	for id, f := range m.filters {
-		if !f.expired(ttl) {
+		if !pred(f) {
 			continue
 		}
 
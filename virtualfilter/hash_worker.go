@@ -0,0 +1,414 @@
+package virtualfilter
+
+import (
+	"sync"
+	"time"
+
+	cmdutil "github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// hashPollingClient client for hash worker to poll block or pending txn hashes from full node
+type hashPollingClient[T any] interface {
+	// install new block or pending txn filter
+	install() (rpc.ID, error)
+	// fetch hashes from filter with specific id
+	fetch(fid rpc.ID) ([]T, error)
+	// uninstall filter with specific id
+	uninstall(fid rpc.ID) (bool, error)
+}
+
+// hashWorker polls block or pending txn hashes by one shared proxy filter on full node, and buffers
+// the polled hashes for the delegate virtual filters to fetch changes with their own cursors. The
+// proxy filter is re-installed transparently if lost, eg., due to full node restart.
+type hashWorker[T any] struct {
+	mu          sync.Mutex
+	shutdownCtx cmdutil.GracefulShutdownContext
+
+	space    string     // network space
+	nodeName string     // full node name
+	typ      filterType // block or pending txn filter
+	capacity int        // max number of buffered hashes
+
+	client   hashPollingClient[T]
+	notifier *changeNotifier // notifier of new polled hashes
+
+	fid             rpc.ID            // shared proxy filter id, or nil if not installed
+	polling         bool              // whether the polling goroutine is running
+	lastPollingTime time.Time         // last polling time
+	hashes          []T               // buffered hashes
+	offset          uint64            // sequence number of the first buffered hash
+	cursors         map[rpc.ID]uint64 // virtual filter id => sequence number of next hash to deliver
+}
+
+func newHashWorker[T any](
+	space, nodeName string,
+	typ filterType,
+	capacity int,
+	client hashPollingClient[T],
+	notifier *changeNotifier,
+	shutdownCtx cmdutil.GracefulShutdownContext,
+) *hashWorker[T] {
+	return &hashWorker[T]{
+		space:       space,
+		nodeName:    nodeName,
+		typ:         typ,
+		capacity:    capacity,
+		client:      client,
+		notifier:    notifier,
+		fid:         nilRpcId,
+		cursors:     make(map[rpc.ID]uint64),
+		shutdownCtx: shutdownCtx,
+	}
+}
+
+// accept accepts delegate for virtual filter, which fetches hashes polled since now.
+func (w *hashWorker[T]) accept(id rpc.ID) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shutdownCtx.Ctx.Err() != nil { // worker already shutdown
+		return errFilterWorkerShutdown
+	}
+
+	// install shared proxy filter if not done yet
+	if w.fid == nilRpcId {
+		if err := w.install(); err != nil {
+			return err
+		}
+	}
+
+	// the polling goroutine may be still running, e.g., failed to re-install proxy filter on reset
+	if !w.polling {
+		w.polling = true
+		go w.poll()
+	}
+
+	w.cursors[id] = w.offset + uint64(len(w.hashes))
+	return nil
+}
+
+// reject rejects delegate for virtual filter
+func (w *hashWorker[T]) reject(id rpc.ID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.cursors[id]; ok {
+		delete(w.cursors, id)
+		return true
+	}
+
+	return false
+}
+
+// fetch returns the hashes polled since last fetch of the virtual filter.
+func (w *hashWorker[T]) fetch(id rpc.ID) ([]T, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cursor, ok := w.cursors[id]
+	if !ok {
+		return nil, errFilterNotFound
+	}
+
+	// hashes evicted due to buffer overflow are missed
+	start := max(cursor, w.offset) - w.offset
+	hashes := append([]T{}, w.hashes[start:]...)
+
+	w.cursors[id] = w.offset + uint64(len(w.hashes))
+	w.compact()
+
+	return hashes, nil
+}
+
+// info returns the shared proxy filter info, including the cursor lag of delegate virtual filters.
+func (w *hashWorker[T]) info() workerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wi := workerInfo{
+		Node:            w.nodeName,
+		Type:            w.typ.String(),
+		ProxyFilter:     w.fid,
+		LastPollingTime: w.lastPollingTime,
+		Head:            w.offset + uint64(len(w.hashes)),
+		Lags:            make(map[rpc.ID]uint64),
+	}
+
+	for fid, cursor := range w.cursors {
+		wi.Lags[fid] = wi.Head - min(cursor, wi.Head)
+	}
+
+	return wi
+}
+
+// reset forces to re-install the shared proxy filter, and the delegate virtual filters continue
+// with hashes polled by the new proxy filter. Note the running polling goroutine is reused, which
+// retries to install the proxy filter if failed to re-install here.
+func (w *hashWorker[T]) reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.polling {
+		return errWorkerSessionNotEstablished
+	}
+
+	w.close(true)
+	return w.install()
+}
+
+// install installs the shared proxy filter on full node.
+func (w *hashWorker[T]) install() error {
+	fid, err := w.client.install()
+	if err != nil {
+		return errors.WithMessage(err, "failed to install proxy filter")
+	}
+
+	w.fid, w.lastPollingTime = fid, time.Now()
+	return nil
+}
+
+// poll consistantly polls hashes from full node until no virtual filter delegated.
+func (w *hashWorker[T]) poll() {
+	ticker := time.NewTicker(pollingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if w.gc() { // garbage collected?
+				return
+			}
+
+			start := time.Now()
+			err := w.pollOnce()
+			metrics.Registry.VirtualFilter.PollHashesOnce(w.space, w.typ.String(), w.nodeName, err).UpdateSince(start)
+
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"fid":      w.fid,
+					"nodeName": w.nodeName,
+					"type":     w.typ,
+				}).WithError(err).Info("Hash worker failed to poll filter changes")
+			}
+		case <-w.shutdownCtx.Ctx.Done():
+			w.mu.Lock()
+			w.close(true)
+			w.polling = false
+			w.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (w *hashWorker[T]) pollOnce() error {
+	w.mu.Lock()
+	fid := w.fid
+
+	// proxy filter not installed, e.g., failed to re-install on reset
+	if fid == nilRpcId {
+		defer w.mu.Unlock()
+		return errors.WithMessage(w.install(), "failed to re-install proxy filter")
+	}
+
+	w.mu.Unlock()
+
+	hashes, err := w.client.fetch(fid)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err != nil {
+		// re-install the shared proxy filter if not found (eg., full node restarted) or
+		// not polled for a long time
+		if isFilterNotFoundError(err) {
+			return errors.WithMessage(w.install(), "failed to re-install proxy filter")
+		}
+
+		if time.Since(w.lastPollingTime) >= maxPollingDelayDuration {
+			w.client.uninstall(fid)
+			return errors.WithMessage(w.install(), "failed to re-install proxy filter")
+		}
+
+		return err
+	}
+
+	w.hashes = append(w.hashes, hashes...)
+	w.lastPollingTime = time.Now()
+
+	if len(hashes) > 0 {
+		w.notifier.notify(w.delegates()...)
+	}
+
+	// evict the oldest hashes due to buffer overflow
+	if overflow := len(w.hashes) - w.capacity; overflow > 0 {
+		w.hashes = append([]T{}, w.hashes[overflow:]...)
+		w.offset += uint64(overflow)
+	}
+
+	w.compact()
+	return nil
+}
+
+// delegates returns ids of the delegated virtual filters.
+func (w *hashWorker[T]) delegates() []rpc.ID {
+	ids := make([]rpc.ID, 0, len(w.cursors))
+	for id := range w.cursors {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// compact evicts the buffered hashes which have been delivered to all virtual filters.
+func (w *hashWorker[T]) compact() {
+	minCursor := w.offset + uint64(len(w.hashes))
+	for _, cursor := range w.cursors {
+		minCursor = min(minCursor, cursor)
+	}
+
+	if minCursor > w.offset {
+		w.hashes = append([]T{}, w.hashes[minCursor-w.offset:]...)
+		w.offset = minCursor
+	}
+}
+
+// gc closes the shared proxy filter if no virtual filter delegated.
+func (w *hashWorker[T]) gc() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.cursors) > 0 {
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"fid":      w.fid,
+		"nodeName": w.nodeName,
+		"type":     w.typ,
+	}).Info("Hash worker closed proxy filter due to idle")
+
+	w.close(true)
+	w.polling = false
+
+	return true
+}
+
+// close uninstalls the shared proxy filter and resets the buffered hashes.
+func (w *hashWorker[T]) close(lockfree ...bool) {
+	if len(lockfree) == 0 || !lockfree[0] {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+	}
+
+	if w.fid != nilRpcId {
+		w.client.uninstall(w.fid)
+	}
+
+	w.offset += uint64(len(w.hashes))
+	w.fid, w.hashes = nilRpcId, nil
+}
+
+// evm space block or pending txn hash polling client
+type ethHashPollingClient struct {
+	client *node.Web3goClient
+	typ    filterType
+}
+
+func newEthHashWorker(
+	typ filterType,
+	capacity int,
+	client *node.Web3goClient,
+	notifier *changeNotifier,
+	shutdownCtx cmdutil.GracefulShutdownContext,
+) *hashWorker[common.Hash] {
+	pc := &ethHashPollingClient{client: client, typ: typ}
+	return newHashWorker[common.Hash]("eth", client.NodeName(), typ, capacity, pc, notifier, shutdownCtx)
+}
+
+func (c *ethHashPollingClient) install() (rpc.ID, error) {
+	var fid *rpc.ID
+	var err error
+
+	if c.typ == filterTypeBlock {
+		fid, err = c.client.Filter.NewBlockFilter()
+	} else {
+		fid, err = c.client.Filter.NewPendingTransactionFilter()
+	}
+
+	if err != nil {
+		return nilRpcId, err
+	}
+
+	return *fid, nil
+}
+
+func (c *ethHashPollingClient) fetch(fid rpc.ID) ([]common.Hash, error) {
+	fc, err := c.client.Filter.GetFilterChanges(fid)
+	if err != nil {
+		return nil, err
+	}
+
+	return fc.Hashes, nil
+}
+
+func (c *ethHashPollingClient) uninstall(fid rpc.ID) (bool, error) {
+	return c.client.Filter.UninstallFilter(fid)
+}
+
+// core space block or pending txn hash polling client
+type cfxHashPollingClient struct {
+	client *sdk.Client
+	typ    filterType
+}
+
+func newCfxHashWorker(
+	typ filterType,
+	capacity int,
+	client *sdk.Client,
+	notifier *changeNotifier,
+	shutdownCtx cmdutil.GracefulShutdownContext,
+) *hashWorker[cfxtypes.Hash] {
+	pc := &cfxHashPollingClient{client: client, typ: typ}
+	nodeName := rpcutil.Url2NodeName(client.GetNodeURL())
+
+	return newHashWorker[cfxtypes.Hash]("cfx", nodeName, typ, capacity, pc, notifier, shutdownCtx)
+}
+
+func (c *cfxHashPollingClient) install() (rpc.ID, error) {
+	var fid *rpc.ID
+	var err error
+
+	if c.typ == filterTypeBlock {
+		fid, err = c.client.Filter().NewBlockFilter()
+	} else {
+		fid, err = c.client.Filter().NewPendingTransactionFilter()
+	}
+
+	if err != nil {
+		return nilRpcId, err
+	}
+
+	return *fid, nil
+}
+
+func (c *cfxHashPollingClient) fetch(fid rpc.ID) ([]cfxtypes.Hash, error) {
+	fc, err := c.client.Filter().GetFilterChanges(fid)
+	if err != nil {
+		return nil, err
+	}
+
+	return fc.Hashes, nil
+}
+
+func (c *cfxHashPollingClient) uninstall(fid rpc.ID) (bool, error) {
+	return c.client.Filter().UninstallFilter(fid)
+}
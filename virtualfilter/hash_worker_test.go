@@ -0,0 +1,240 @@
+package virtualfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cmdutil "github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockHashPollingClient struct {
+	installed int
+	fid       rpc.ID
+	pending   []string
+	lost      bool  // proxy filter lost due to full node restart
+	failure   error // error to install proxy filter
+}
+
+func (c *mockHashPollingClient) install() (rpc.ID, error) {
+	if c.failure != nil {
+		return nilRpcId, c.failure
+	}
+
+	c.installed++
+	c.fid, c.lost = rpc.ID(fmt.Sprintf("0x%x", c.installed)), false
+	return c.fid, nil
+}
+
+func (c *mockHashPollingClient) fetch(fid rpc.ID) ([]string, error) {
+	if c.lost || fid != c.fid {
+		return nil, errFilterNotFound
+	}
+
+	hashes := c.pending
+	c.pending = nil
+	return hashes, nil
+}
+
+func (c *mockHashPollingClient) uninstall(fid rpc.ID) (bool, error) {
+	return true, nil
+}
+
+func TestHashWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &mockHashPollingClient{}
+	shutdownCtx := cmdutil.GracefulShutdownContext{Ctx: ctx, Wg: &sync.WaitGroup{}}
+	w := newHashWorker[string]("eth", "test", filterTypeBlock, 3, client, newChangeNotifier(), shutdownCtx)
+
+	// one shared proxy filter for all virtual filters
+	require.NoError(t, w.accept("0xa"))
+	require.NoError(t, w.accept("0xb"))
+	assert.Equal(t, 1, client.installed)
+
+	client.pending = []string{"h1", "h2"}
+	require.NoError(t, w.pollOnce())
+
+	require.NoError(t, w.accept("0xc"))
+
+	// virtual filters fetch with their own cursors
+	hashes, err := w.fetch("0xa")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h1", "h2"}, hashes)
+
+	hashes, err = w.fetch("0xc")
+	require.NoError(t, err)
+	assert.Empty(t, hashes)
+
+	// proxy filter re-installed transparently
+	client.lost = true
+	assert.NoError(t, w.pollOnce())
+	assert.Equal(t, 2, client.installed)
+
+	client.pending = []string{"h3", "h4", "h5"}
+	require.NoError(t, w.pollOnce())
+
+	hashes, err = w.fetch("0xa")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h3", "h4", "h5"}, hashes)
+
+	// hashes evicted due to buffer overflow
+	hashes, err = w.fetch("0xb")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h3", "h4", "h5"}, hashes)
+
+	// delivered hashes compacted
+	hashes, err = w.fetch("0xc")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h3", "h4", "h5"}, hashes)
+	assert.Empty(t, w.hashes)
+
+	assert.True(t, w.reject("0xa"))
+	assert.False(t, w.reject("0xa"))
+
+	_, err = w.fetch("0xa")
+	assert.ErrorIs(t, err, errFilterNotFound)
+}
+
+func TestHashWorkerResetFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &mockHashPollingClient{}
+	shutdownCtx := cmdutil.GracefulShutdownContext{Ctx: ctx, Wg: &sync.WaitGroup{}}
+	w := newHashWorker[string]("eth", "test", filterTypeBlock, 3, client, newChangeNotifier(), shutdownCtx)
+
+	assert.ErrorIs(t, w.reset(), errWorkerSessionNotEstablished)
+
+	require.NoError(t, w.accept("0xa"))
+	assert.Equal(t, 1, client.installed)
+
+	// failed to re-install proxy filter, but the polling goroutine is still running
+	w.mu.Lock()
+	client.failure = errors.New("install failure")
+	w.mu.Unlock()
+
+	assert.Error(t, w.reset())
+
+	w.mu.Lock()
+	assert.Equal(t, nilRpcId, w.fid)
+	assert.True(t, w.polling)
+	w.mu.Unlock()
+
+	assert.Error(t, w.accept("0xb"))
+
+	// the running polling goroutine retries to install the proxy filter
+	w.mu.Lock()
+	client.failure = nil
+	w.mu.Unlock()
+
+	require.NoError(t, w.pollOnce())
+
+	w.mu.Lock()
+	assert.Equal(t, rpc.ID("0x2"), w.fid)
+	w.mu.Unlock()
+
+	// proxy filter installed already without another polling goroutine started
+	require.NoError(t, w.accept("0xb"))
+	assert.Equal(t, 2, client.installed)
+
+	// polling goroutine stopped once garbage collected, and restarted on demand
+	assert.True(t, w.reject("0xa"))
+	assert.True(t, w.reject("0xb"))
+	assert.True(t, w.gc())
+	assert.False(t, w.polling)
+
+	require.NoError(t, w.accept("0xc"))
+	assert.True(t, w.polling)
+	assert.Equal(t, 3, client.installed)
+}
+
+// mockHashFilter virtual filter which fetches changes from the hash worker
+type mockHashFilter struct {
+	filterBase
+	worker *hashWorker[string]
+}
+
+func (f *mockHashFilter) nodeName() string         { return "test" }
+func (f *mockHashFilter) uninstall() (bool, error) { return f.worker.reject(f.id), nil }
+
+func (f *mockHashFilter) fetch() (filterChanges, error) {
+	return f.worker.fetch(f.id)
+}
+
+func TestFetchChangesLongPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &mockHashPollingClient{}
+	shutdownCtx := cmdutil.GracefulShutdownContext{Ctx: ctx, Wg: &sync.WaitGroup{}}
+	fs := &filterSystemBase{notifier: newChangeNotifier(), shutdownCtx: shutdownCtx}
+
+	w := newHashWorker[string]("eth", "test", filterTypeBlock, 10, client, fs.notifier, shutdownCtx)
+	require.NoError(t, w.accept("0xa"))
+
+	vf := &mockHashFilter{filterBase: filterBase{id: "0xa", typ: filterTypeBlock}, worker: w}
+	empty := func(fc filterChanges) bool { return len(fc.([]string)) == 0 }
+
+	// returns immediately without waiting
+	fc, err := fs.fetchChanges(vf, 0, empty)
+	require.NoError(t, err)
+	assert.Empty(t, fc)
+
+	// times out if no change arrives
+	start := time.Now()
+	fc, err = fs.fetchChanges(vf, 50*time.Millisecond, empty)
+	require.NoError(t, err)
+	assert.Empty(t, fc)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// woken up once new changes polled
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		client.pending = []string{"h1"}
+		w.pollOnce()
+	}()
+
+	start = time.Now()
+	fc, err = fs.fetchChanges(vf, 10*time.Second, empty)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h1"}, fc)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestChangeNotifierPerFilter(t *testing.T) {
+	fs := &filterSystemBase{notifier: newChangeNotifier(), filterMgr: newFilterManager()}
+	fs.filterMgr.add(&mockHashFilter{filterBase: filterBase{id: "0xa", typ: filterTypeBlock}})
+	fs.filterMgr.add(&mockHashFilter{filterBase: filterBase{id: "0xb", typ: filterTypeLog}})
+
+	chA, chB, chC := fs.notifier.listen("0xa"), fs.notifier.listen("0xb"), fs.notifier.listen("0xc")
+	assert.ElementsMatch(t, []rpc.ID{"0xa", "0xb", "0xc"}, fs.notifier.waiting())
+
+	// only wake up the matched virtual filters, or removed already
+	fs.notifyFilters(func(vf virtualFilter) bool { return vf.ftype() == filterTypeLog })
+
+	assert.False(t, isNotified(chA))
+	assert.True(t, isNotified(chB))
+	assert.True(t, isNotified(chC))
+	assert.Equal(t, []rpc.ID{"0xa"}, fs.notifier.waiting())
+
+	fs.notifier.notify("0xa")
+	assert.True(t, isNotified(chA))
+	assert.Empty(t, fs.notifier.waiting())
+}
+
+func isNotified(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
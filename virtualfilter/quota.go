@@ -0,0 +1,253 @@
+package virtualfilter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	errFilterQuotaExceeded = errors.New("too many active filters")
+)
+
+// quotaEntry quota accounting of an admitted virtual filter.
+type quotaEntry struct {
+	owner           string    // owner identity
+	maxLogs         int       // max number of buffered logs, or unlimited if zero
+	lastPollingTime time.Time // last polling time
+	pendingLogs     int       // number of logs accumulated since the last polling
+	exceeded        bool      // whether the accumulated logs exceeded the quota
+}
+
+// filterQuotas accounts active virtual filters per owner for admission control.
+//
+// If the durable registry enabled, quota state is kept along with the virtual filter records, so
+// that active virtual filters are counted cluster-wide regardless of which service instance the
+// virtual filter is created by. Otherwise, it is accounted by the service instance in memory. Note
+// admission is not atomic across service instances, so the owner might slightly exceed the quota
+// under concurrent creation.
+//
+// Besides, the number of logs accumulated for each virtual filter since the last polling is also
+// accounted, and the virtual filter is evicted as soon as the logs exceed the quota of the owner.
+type filterQuotas struct {
+	space    string
+	registry filterRegistry // durable registry, nil if disabled
+
+	mu sync.Mutex
+	// owner => number of active filters, which are accounted by this service instance only if
+	// registry enabled
+	counts  map[string]int
+	entries map[rpc.ID]*quotaEntry // filter id => quota entry
+}
+
+func newFilterQuotas(space string, registry filterRegistry) *filterQuotas {
+	return &filterQuotas{
+		space:    space,
+		registry: registry,
+		counts:   make(map[string]int),
+		entries:  make(map[rpc.ID]*quotaEntry),
+	}
+}
+
+// admit creates virtual filter by the `create` function if the quota of the owner not exceeded.
+func (q *filterQuotas) admit(quota *vfclient.FilterQuota, create func() (rpc.ID, error)) (rpc.ID, error) {
+	if quota == nil || len(quota.Owner) == 0 {
+		return create()
+	}
+
+	if err := q.reserve(quota); err != nil {
+		return nilRpcId, err
+	}
+
+	id, err := create()
+	if err != nil {
+		q.cancel(quota.Owner)
+		return nilRpcId, err
+	}
+
+	if q.registry != nil {
+		if err := q.registry.setQuota(id, quota.Owner, quota.MaxLogs); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"fid": id, "owner": quota.Owner,
+			}).WithError(err).Info("Filter quotas failed to save quota of virtual filter into registry")
+		}
+	}
+
+	q.track(id, quota.Owner, quota.MaxLogs)
+	return id, nil
+}
+
+// reserve reserves an active virtual filter for the owner in advance of creation.
+func (q *filterQuotas) reserve(quota *vfclient.FilterQuota) error {
+	if q.registry != nil {
+		if quota.MaxFilters <= 0 {
+			return nil
+		}
+
+		count, err := q.registry.count(quota.Owner)
+		if err != nil {
+			return errors.WithMessage(err, "failed to count active filters from registry")
+		}
+
+		return q.check(quota, count)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.check(quota, q.counts[quota.Owner]); err != nil {
+		return err
+	}
+
+	q.inc(quota.Owner, 1)
+	return nil
+}
+
+func (q *filterQuotas) check(quota *vfclient.FilterQuota, count int) error {
+	if quota.MaxFilters > 0 && count >= quota.MaxFilters {
+		metrics.Registry.VirtualFilter.QuotaRejections(q.space).Mark(1)
+
+		return errors.WithMessagef(
+			errFilterQuotaExceeded, "up to %v active filters allowed, please uninstall unused filters", quota.MaxFilters,
+		)
+	}
+
+	return nil
+}
+
+// cancel cancels the reservation if failed to create virtual filter.
+func (q *filterQuotas) cancel(owner string) {
+	if q.registry != nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inc(owner, -1)
+}
+
+// track tracks the quota of the virtual filter admitted, or restored from the registry.
+func (q *filterQuotas) track(id rpc.ID, owner string, maxLogs int) {
+	if len(owner) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.entries[id]; !ok && q.registry != nil { // otherwise, accounted upon reservation
+		q.inc(owner, 1)
+	}
+
+	q.entries[id] = &quotaEntry{owner: owner, maxLogs: maxLogs, lastPollingTime: time.Now()}
+}
+
+// observe refreshes the last polling time of the virtual filter, and resets the accumulated logs
+// which have been delivered.
+func (q *filterQuotas) observe(id rpc.ID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[id]; ok {
+		e.lastPollingTime = time.Now()
+		e.pendingLogs = 0
+	}
+}
+
+// limited returns the virtual filters limited by the max number of buffered logs, which are not
+// exceeded yet.
+func (q *filterQuotas) limited() []rpc.ID {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var ids []rpc.ID
+	for id, e := range q.entries {
+		if e.maxLogs > 0 && !e.exceeded {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// accumulate accounts the logs accumulated for the virtual filter since the last polling, and
+// returns true if the quota exceeded for the first time, in which case the virtual filter should
+// be evicted right away.
+func (q *filterQuotas) accumulate(id rpc.ID, numLogs int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.entries[id]
+	if !ok || e.maxLogs <= 0 || e.exceeded {
+		return false
+	}
+
+	e.pendingLogs += numLogs
+	e.exceeded = e.pendingLogs > e.maxLogs
+
+	return e.exceeded
+}
+
+// exceeded returns the max number of buffered logs if exceeded since the last polling.
+func (q *filterQuotas) exceeded(id rpc.ID) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[id]; ok && e.exceeded {
+		return e.maxLogs, true
+	}
+
+	return 0, false
+}
+
+// owner returns the owner identity of the virtual filter, or empty if not admitted with quota.
+func (q *filterQuotas) owner(id rpc.ID) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[id]; ok {
+		return e.owner
+	}
+
+	return ""
+}
+
+// release releases the quota of the uninstalled virtual filter.
+func (q *filterQuotas) release(id rpc.ID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[id]; ok {
+		delete(q.entries, id)
+		q.inc(e.owner, -1)
+	}
+}
+
+// expire releases the quota of the virtual filters not polled within the ttl.
+func (q *filterQuotas) expire(ttl time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id, e := range q.entries {
+		if time.Since(e.lastPollingTime) >= ttl {
+			delete(q.entries, id)
+			q.inc(e.owner, -1)
+		}
+	}
+}
+
+// inc updates the number of active filters of the owner accounted in memory.
+func (q *filterQuotas) inc(owner string, delta int) {
+	q.counts[owner] += delta
+	metrics.Registry.VirtualFilter.QuotaFilters(q.space, owner).Update(int64(max(q.counts[owner], 0)))
+
+	if q.counts[owner] <= 0 {
+		delete(q.counts, owner)
+	}
+}
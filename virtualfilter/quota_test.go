@@ -0,0 +1,147 @@
+package virtualfilter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterQuotas(t *testing.T) {
+	q := newFilterQuotas("eth", nil)
+	quota := &vfclient.FilterQuota{Owner: "ip:127.0.0.1", MaxFilters: 2, MaxLogs: 10}
+	create := func() (rpc.ID, error) { return rpc.NewID(), nil }
+
+	// unlimited without quota
+	for i := 0; i < 3; i++ {
+		_, err := q.admit(nil, create)
+		require.NoError(t, err)
+	}
+
+	id1, err := q.admit(quota, create)
+	require.NoError(t, err)
+	assert.Equal(t, quota.Owner, q.owner(id1))
+
+	// quota released if failed to create
+	_, err = q.admit(quota, func() (rpc.ID, error) { return nilRpcId, errors.New("boom") })
+	assert.Error(t, err)
+
+	id2, err := q.admit(quota, create)
+	require.NoError(t, err)
+
+	_, err = q.admit(quota, create)
+	assert.ErrorIs(t, err, errFilterQuotaExceeded)
+
+	// other owners not affected
+	_, err = q.admit(&vfclient.FilterQuota{Owner: "key:abc", MaxFilters: 1}, create)
+	assert.NoError(t, err)
+
+	// quota released once uninstalled
+	q.release(id1)
+	assert.Empty(t, q.owner(id1))
+
+	id3, err := q.admit(quota, create)
+	require.NoError(t, err)
+
+	// quota released once expired
+	time.Sleep(20 * time.Millisecond)
+	q.observe(id3)
+	q.expire(10 * time.Millisecond)
+
+	assert.Empty(t, q.owner(id2))
+	assert.Equal(t, quota.Owner, q.owner(id3))
+	assert.Equal(t, 1, q.counts[quota.Owner])
+}
+
+func TestFilterQuotasExceeded(t *testing.T) {
+	q := newFilterQuotas("eth", nil)
+	quota := &vfclient.FilterQuota{Owner: "ip:127.0.0.1", MaxLogs: 10}
+
+	id, err := q.admit(quota, func() (rpc.ID, error) { return rpc.NewID(), nil })
+	require.NoError(t, err)
+	assert.Equal(t, []rpc.ID{id}, q.limited())
+
+	assert.False(t, q.accumulate(id, 6))
+
+	// accumulated logs reset once polled
+	q.observe(id)
+	assert.False(t, q.accumulate(id, 6))
+
+	// exceeded once accumulated
+	assert.True(t, q.accumulate(id, 5))
+	assert.False(t, q.accumulate(id, 1))
+	assert.Empty(t, q.limited())
+
+	maxLogs, exceeded := q.exceeded(id)
+	assert.True(t, exceeded)
+	assert.Equal(t, 10, maxLogs)
+}
+
+func TestFilterSystemEvictLogsExceeded(t *testing.T) {
+	fs := &filterSystemBase{filterMgr: newFilterManager(), quotas: newFilterQuotas("eth", nil)}
+
+	s := newLogStream("eth", 10)
+	s.onSealed = func(epoch *streamEpoch) {
+		fs.accumulateLogs(func(vf virtualFilter) int { return countEthStreamLogs(vf, epoch) })
+	}
+
+	f := newTestEthStreamLogFilter(s, &testStreamStore{})
+	f.id = rpc.NewID()
+
+	fs.filterMgr.add(f)
+	fs.quotas.track(f.id, "ip:127.0.0.1", 2)
+
+	applyTestStreamEvents(s, newTestStreamEvents(t, 1, "0x01", 2))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 2, "0x02", 1))
+	assert.NoError(t, fs.checkLogsQuota(f.id))
+
+	// evicted as soon as the logs accumulated exceed the quota
+	applyTestStreamEvents(s, newTestStreamEvents(t, 3, "0x03", 0))
+
+	_, ok := fs.getFilter(f.id)
+	assert.False(t, ok)
+	assert.ErrorContains(t, fs.checkLogsQuota(f.id), "exceeded the quota of 2 buffered logs")
+}
+
+func TestFilterQuotasClusterWide(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	registry := newRedisFilterRegistry("eth", rdb, time.Minute)
+	create := func() (rpc.ID, error) {
+		rec := &filterRecord{ID: rpc.NewID(), Type: filterTypeBlock}
+		return rec.ID, registry.put(rec)
+	}
+
+	// quotas of different service instances sharing the same registry
+	q1, q2 := newFilterQuotas("eth", registry), newFilterQuotas("eth", registry)
+	quota := &vfclient.FilterQuota{Owner: "ip:127.0.0.1", MaxFilters: 2, MaxLogs: 10}
+
+	id1, err := q1.admit(quota, create)
+	require.NoError(t, err)
+
+	_, err = q2.admit(quota, create)
+	require.NoError(t, err)
+
+	_, err = q2.admit(quota, create)
+	assert.ErrorIs(t, err, errFilterQuotaExceeded)
+
+	// quota restored along with the virtual filter record
+	rec, err := registry.get(id1)
+	require.NoError(t, err)
+	assert.Equal(t, quota.Owner, rec.Owner)
+	assert.Equal(t, quota.MaxLogs, rec.MaxLogs)
+
+	// quota released once the virtual filter unregistered by any instance
+	require.NoError(t, registry.delete(id1))
+
+	_, err = q1.admit(quota, create)
+	assert.NoError(t, err)
+}
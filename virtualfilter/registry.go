@@ -0,0 +1,297 @@
+package virtualfilter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/store/redis"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// virtual filter registry backends
+	registryBackendMysql = "mysql"
+	registryBackendRedis = "redis"
+
+	// timeout to access the registry backend
+	registryTimeout = 3 * time.Second
+)
+
+// filterRecord durable metadata of a virtual filter.
+type filterRecord struct {
+	ID      rpc.ID          `json:"id"`
+	Type    filterType      `json:"type"`
+	NodeUrl string          `json:"nodeUrl"`          // delegate full node url
+	Crit    json.RawMessage `json:"crit,omitempty"`   // log filter criteria
+	Cursor  uint64          `json:"cursor,omitempty"` // block (or epoch) number delivered up to
+	// quota of the owner which the virtual filter is admitted for, so that the quota is accounted
+	// cluster-wide regardless of which service instance the virtual filter is served by
+	Owner   string `json:"owner,omitempty"`
+	MaxLogs int    `json:"maxLogs,omitempty"`
+}
+
+// filterRegistry persists virtual filters and service instance membership, so that virtual filters
+// survive restart and could be sharded across service instances.
+type filterRegistry interface {
+	// put creates or overwrites the virtual filter record
+	put(rec *filterRecord) error
+	// get returns the virtual filter record, or nil if not found
+	get(id rpc.ID) (*filterRecord, error)
+	// delete deletes the virtual filter record
+	delete(id rpc.ID) error
+	// touch refreshes the last polling time and cursor of the virtual filter
+	touch(id rpc.ID, cursor uint64) error
+	// setQuota updates the owner quota of the virtual filter
+	setQuota(id rpc.ID, owner string, maxLogs int) error
+	// count returns the number of virtual filters of the owner
+	count(owner string) (int, error)
+	// expire deletes the virtual filters not polled within the ttl
+	expire(ttl time.Duration) error
+	// heartbeat registers or refreshes the service instance membership
+	heartbeat(url string) error
+	// members returns the urls of alive service instances
+	members(ttl time.Duration) ([]string, error)
+}
+
+func mustNewFilterRegistry(
+	space string, conf *registryConfig, ttl time.Duration, vfs *mysql.VirtualFilterStore,
+) filterRegistry {
+	switch conf.Backend {
+	case registryBackendMysql:
+		return &mysqlFilterRegistry{vfs: vfs}
+	case registryBackendRedis:
+		return newRedisFilterRegistry(space, redis.MustNewRedisClient(conf.RedisUrl), ttl)
+	default:
+		logrus.WithField("backend", conf.Backend).Fatal("Invalid virtual filter registry backend")
+		return nil
+	}
+}
+
+// mysqlFilterRegistry virtual filter registry backed by mysql.
+type mysqlFilterRegistry struct {
+	vfs *mysql.VirtualFilterStore
+}
+
+func (r *mysqlFilterRegistry) put(rec *filterRecord) error {
+	return r.vfs.SaveVirtualFilter(&mysql.VirtualFilter{
+		ID:       string(rec.ID),
+		Type:     uint8(rec.Type),
+		NodeUrl:  rec.NodeUrl,
+		Crit:     string(rec.Crit),
+		Cursor:   rec.Cursor,
+		Owner:    rec.Owner,
+		MaxLogs:  rec.MaxLogs,
+		PolledAt: time.Now(),
+	})
+}
+
+func (r *mysqlFilterRegistry) get(id rpc.ID) (*filterRecord, error) {
+	vf, err := r.vfs.GetVirtualFilter(string(id))
+	if err != nil || vf == nil {
+		return nil, err
+	}
+
+	rec := &filterRecord{
+		ID:      rpc.ID(vf.ID),
+		Type:    filterType(vf.Type),
+		NodeUrl: vf.NodeUrl,
+		Cursor:  vf.Cursor,
+		Owner:   vf.Owner,
+		MaxLogs: vf.MaxLogs,
+	}
+
+	if len(vf.Crit) > 0 {
+		rec.Crit = json.RawMessage(vf.Crit)
+	}
+
+	return rec, nil
+}
+
+func (r *mysqlFilterRegistry) delete(id rpc.ID) error {
+	_, err := r.vfs.DeleteVirtualFilter(string(id))
+	return err
+}
+
+func (r *mysqlFilterRegistry) touch(id rpc.ID, cursor uint64) error {
+	return r.vfs.TouchVirtualFilter(string(id), cursor)
+}
+
+func (r *mysqlFilterRegistry) setQuota(id rpc.ID, owner string, maxLogs int) error {
+	return r.vfs.SetVirtualFilterQuota(string(id), owner, maxLogs)
+}
+
+func (r *mysqlFilterRegistry) count(owner string) (int, error) {
+	count, err := r.vfs.CountVirtualFiltersByOwner(owner)
+	return int(count), err
+}
+
+func (r *mysqlFilterRegistry) expire(ttl time.Duration) error {
+	_, err := r.vfs.ExpireVirtualFilters(time.Now().Add(-ttl))
+	return err
+}
+
+func (r *mysqlFilterRegistry) heartbeat(url string) error {
+	return r.vfs.HeartbeatVirtualFilterMember(url)
+}
+
+func (r *mysqlFilterRegistry) members(ttl time.Duration) ([]string, error) {
+	return r.vfs.GetVirtualFilterMembers(time.Now().Add(-ttl))
+}
+
+// redisFilterRegistry virtual filter registry backed by redis, of which virtual filter records
+// expire with the TTL of the key. Virtual filters of each owner are indexed by sorted set with the
+// expiration time as score, so that expired ones could be pruned before counting.
+type redisFilterRegistry struct {
+	rdb   *goredis.Client
+	space string
+	ttl   time.Duration // virtual filter ttl
+}
+
+func newRedisFilterRegistry(space string, rdb *goredis.Client, ttl time.Duration) *redisFilterRegistry {
+	return &redisFilterRegistry{rdb: rdb, space: space, ttl: ttl}
+}
+
+func (r *redisFilterRegistry) filterKey(id rpc.ID) string {
+	return fmt.Sprintf("vf:%v:filter:%v", r.space, id)
+}
+
+func (r *redisFilterRegistry) ownerKey(owner string) string {
+	return fmt.Sprintf("vf:%v:owner:%v", r.space, owner)
+}
+
+func (r *redisFilterRegistry) membersKey() string {
+	return fmt.Sprintf("vf:%v:members", r.space)
+}
+
+func (r *redisFilterRegistry) put(rec *filterRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal filter record")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	if err := r.rdb.Set(ctx, r.filterKey(rec.ID), data, r.ttl).Err(); err != nil || len(rec.Owner) == 0 {
+		return err
+	}
+
+	// index by owner until the virtual filter record expires
+	ownerKey := r.ownerKey(rec.Owner)
+	_, err = r.rdb.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.ZAdd(ctx, ownerKey, &goredis.Z{
+			Score: float64(time.Now().Add(r.ttl).UnixMilli()), Member: string(rec.ID),
+		})
+		pipe.Expire(ctx, ownerKey, r.ttl)
+		return nil
+	})
+
+	return err
+}
+
+func (r *redisFilterRegistry) get(id rpc.ID) (*filterRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	data, err := r.rdb.Get(ctx, r.filterKey(id)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rec filterRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, errors.WithMessage(err, "failed to unmarshal filter record")
+	}
+
+	return &rec, nil
+}
+
+func (r *redisFilterRegistry) delete(id rpc.ID) error {
+	rec, err := r.get(id)
+	if err != nil || rec == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	if len(rec.Owner) > 0 {
+		if err := r.rdb.ZRem(ctx, r.ownerKey(rec.Owner), string(id)).Err(); err != nil {
+			return err
+		}
+	}
+
+	return r.rdb.Del(ctx, r.filterKey(id)).Err()
+}
+
+func (r *redisFilterRegistry) touch(id rpc.ID, cursor uint64) error {
+	rec, err := r.get(id)
+	if err != nil || rec == nil {
+		return err
+	}
+
+	rec.Cursor = cursor
+	return r.put(rec)
+}
+
+func (r *redisFilterRegistry) setQuota(id rpc.ID, owner string, maxLogs int) error {
+	rec, err := r.get(id)
+	if err != nil || rec == nil {
+		return err
+	}
+
+	rec.Owner, rec.MaxLogs = owner, maxLogs
+	return r.put(rec)
+}
+
+func (r *redisFilterRegistry) count(owner string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	// prune expired virtual filters of the owner
+	ownerKey := r.ownerKey(owner)
+	deadline := time.Now().UnixMilli()
+	if err := r.rdb.ZRemRangeByScore(ctx, ownerKey, "-inf", fmt.Sprintf("(%v", deadline)).Err(); err != nil {
+		return 0, err
+	}
+
+	count, err := r.rdb.ZCard(ctx, ownerKey).Result()
+	return int(count), err
+}
+
+func (r *redisFilterRegistry) expire(ttl time.Duration) error {
+	// virtual filter records expire along with the key
+	return nil
+}
+
+func (r *redisFilterRegistry) heartbeat(url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	return r.rdb.ZAdd(ctx, r.membersKey(), &goredis.Z{
+		Score: float64(time.Now().UnixMilli()), Member: url,
+	}).Err()
+}
+
+func (r *redisFilterRegistry) members(ttl time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	// prune members without heartbeat within the ttl
+	deadline := time.Now().Add(-ttl).UnixMilli()
+	if err := r.rdb.ZRemRangeByScore(ctx, r.membersKey(), "-inf", fmt.Sprintf("(%v", deadline)).Err(); err != nil {
+		return nil, err
+	}
+
+	return r.rdb.ZRange(ctx, r.membersKey(), 0, -1).Result()
+}
@@ -0,0 +1,96 @@
+package virtualfilter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisFilterRegistry(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	registry := newRedisFilterRegistry("eth", rdb, time.Minute)
+
+	rec := &filterRecord{
+		ID:      rpc.NewID(),
+		Type:    filterTypeLog,
+		NodeUrl: "http://127.0.0.1:8545",
+		Crit:    []byte(`{"address":["0x0000000000000000000000000000000000000001"]}`),
+		Cursor:  100,
+	}
+	require.NoError(t, registry.put(rec))
+
+	loaded, err := registry.get(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, rec, loaded)
+
+	// touch updates cursor and refreshes ttl
+	mr.FastForward(30 * time.Second)
+	require.NoError(t, registry.touch(rec.ID, 105))
+	mr.FastForward(45 * time.Second)
+
+	loaded, err = registry.get(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(105), loaded.Cursor)
+
+	// expired without polling
+	mr.FastForward(time.Minute)
+	loaded, err = registry.get(rec.ID)
+	require.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	// deleted
+	require.NoError(t, registry.put(rec))
+	require.NoError(t, registry.delete(rec.ID))
+	loaded, err = registry.get(rec.ID)
+	require.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	// membership
+	require.NoError(t, registry.heartbeat("http://vf1"))
+	require.NoError(t, registry.heartbeat("http://vf2"))
+
+	members, err := registry.members(time.Minute)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"http://vf1", "http://vf2"}, members)
+
+	members, err = registry.members(-time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, members)
+}
+
+func TestRendezvousOwner(t *testing.T) {
+	members := []string{"http://vf1", "http://vf2", "http://vf3"}
+
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("0x%x", i)
+		owners[key] = rendezvousOwner(members, key)
+		counts[owners[key]]++
+	}
+
+	// sharded evenly across members
+	for _, m := range members {
+		assert.InDelta(t, 1000, counts[m], 200, m)
+	}
+
+	// only filters owned by the removed member are rebalanced
+	for key, owner := range owners {
+		newOwner := rendezvousOwner(members[:2], key)
+		if owner != members[2] {
+			assert.Equal(t, owner, newOwner)
+		} else {
+			assert.NotEqual(t, members[2], newOwner)
+		}
+	}
+}
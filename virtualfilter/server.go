@@ -4,34 +4,54 @@ import (
 	"github.com/Conflux-Chain/confura/cmd/util"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util/rpc"
+	w3rpc "github.com/openweb3/go-rpc-provider"
 )
 
 // MustNewEvmSpaceServerFromViper creates evm space virtual filters RPC server from viper settings
 func MustNewEvmSpaceServerFromViper(
 	shutdownContext util.GracefulShutdownContext,
-	vfls *mysql.VirtualFilterLogStore,
+	db *mysql.MysqlStore,
 ) (*rpc.Server, string) {
 	conf := mustNewEthConfigFromViper()
-	fs := newEthFilterSystem(conf, vfls, shutdownContext)
+	fs := newEthFilterSystem(conf, db, shutdownContext)
 
 	srv := rpc.MustNewServer("eth_vfilter", map[string]interface{}{
 		"eth": newEthFilterApi(fs),
 	})
 
+	if len(conf.AdminEndpoint) > 0 {
+		mustServeAdmin("eth_vfilter_admin", conf.AdminEndpoint, fs.filterSystemBase, fs.uninstallFilter)
+	}
+
 	return srv, conf.Endpoint
 }
 
 // MustNewCoreSpaceServerFromViper creates core space virtual filters RPC server from viper settings
 func MustNewCoreSpaceServerFromViper(
 	shutdownContext util.GracefulShutdownContext,
-	vfls *mysql.VirtualFilterLogStore,
+	db *mysql.MysqlStore,
 ) (*rpc.Server, string) {
 	conf := mustNewCfxConfigFromViper()
-	fs := newCfxFilterSystem(conf, vfls, shutdownContext)
+	fs := newCfxFilterSystem(conf, db, shutdownContext)
 
 	srv := rpc.MustNewServer("cfx_vfilter", map[string]interface{}{
 		"cfx": newCfxFilterApi(fs),
 	})
 
+	if len(conf.AdminEndpoint) > 0 {
+		mustServeAdmin("cfx_vfilter_admin", conf.AdminEndpoint, fs.filterSystemBase, fs.uninstallFilter)
+	}
+
 	return srv, conf.Endpoint
 }
+
+// mustServeAdmin serves the admin RPC API on the separate endpoint until graceful shutdown.
+func mustServeAdmin(
+	name, endpoint string, fs *filterSystemBase, uninstall func(id w3rpc.ID, local bool) (bool, error),
+) {
+	srv := rpc.MustNewServer(name, map[string]interface{}{
+		"admin": newAdminApi(fs, uninstall),
+	})
+
+	go srv.MustServeGraceful(fs.shutdownCtx.Ctx, fs.shutdownCtx.Wg, endpoint, rpc.ProtocolHttp)
+}
@@ -0,0 +1,545 @@
+package virtualfilter
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/sync/sink"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// max number of recent reverts retained to detect chain reorg for log filters
+	maxStreamReverts = 128
+	// max number of epochs (or blocks) loaded from db store at a time for log filters falling
+	// behind the near-head cache
+	maxStreamFallbackEpochs = 1000
+	// interval to re-subscribe the commit stream on failure
+	streamRetryInterval = 5 * time.Second
+)
+
+// streamEpoch epoch (or block for evm space) committed by syncer along with event logs.
+type streamEpoch struct {
+	number    uint64
+	pivotHash string
+	logs      []sink.LogData
+	// applied event ids for deduplication, or nil if sealed once events of the next epoch received
+	ids map[string]struct{}
+}
+
+// streamRevert epochs (or blocks for evm space) reverted by syncer due to chain reorg.
+type streamRevert struct {
+	revision uint64         // revision of the stream after reverted
+	from     uint64         // epoch from which (inclusive) reverted
+	epochs   []*streamEpoch // reverted epochs within the near-head cache
+	// whether events are missed instead, so that the cached epochs are not necessarily reverted
+	// but shall be verified against db store
+	gap bool
+}
+
+// streamChanges changes of the commit stream since the delivered epoch of a log filter.
+type streamChanges struct {
+	reorged  bool           // whether any delivered epoch is reverted
+	revertTo uint64         // delivered epoch after reverted, or the delivered epoch if not reorged
+	reverted []*streamEpoch // delivered epochs which are reverted
+	// delivered epochs cached before events missed, which shall be verified against db store
+	unverified []*streamEpoch
+	ready      bool           // whether any committed epoch is cached
+	oldest     uint64         // oldest epoch within the near-head cache if ready
+	epochs     []*streamEpoch // committed epochs since the delivered epoch
+	revision   uint64         // latest revision of the stream
+}
+
+// logStream near-head cache of epochs (or blocks for evm space) committed by syncer, which is
+// driven by the change data capture events of the syncer. Chain reorg is detected by the revert
+// events, so that log filters are consistent with the indexed event logs.
+type logStream struct {
+	space    string
+	capacity int // max number of cached epochs
+	// callback once an epoch sealed, which is invoked out of the lock
+	onSealed func(epoch *streamEpoch)
+	// callback once any epoch reverted, which is invoked out of the lock
+	onReverted func()
+
+	mu       sync.RWMutex
+	epochs   []*streamEpoch  // cached epochs in ascending order
+	reverts  []*streamRevert // recent reverts in order
+	revision uint64          // incremented once reverted
+}
+
+func newLogStream(space string, capacity int) *logStream {
+	return &logStream{space: space, capacity: max(capacity, 2)}
+}
+
+// run subscribes the commit stream until the context is done, and re-subscribes on failure.
+func (s *logStream) run(ctx context.Context, sub sink.Subscriber) {
+	defer sub.Close()
+
+	for {
+		err := sub.Subscribe(ctx, s.space, s.onEvent)
+		if ctx.Err() != nil {
+			return
+		}
+
+		logrus.WithField("space", s.space).WithError(err).Info("Log stream failed to subscribe commit stream")
+
+		// events may be missed during re-subscription
+		s.mu.Lock()
+		revision := s.revision
+		s.discontinue()
+		reverted := s.revision > revision
+		s.mu.Unlock()
+
+		if reverted && s.onReverted != nil {
+			s.onReverted()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(streamRetryInterval):
+		}
+	}
+}
+
+// discontinue clears the cached epochs once events missed, and log filters will load event logs
+// from db store instead. Since revert events may be missed as well, the cached epochs are retained
+// for log filters to verify the delivered epochs against db store.
+func (s *logStream) discontinue() {
+	if sealed := s.sealed(); len(sealed) > 0 {
+		s.addRevert(&streamRevert{from: sealed[0].number, epochs: slices.Clone(sealed), gap: true})
+	}
+
+	s.epochs = nil
+}
+
+// onEvent applies the change data event into the near-head cache.
+func (s *logStream) onEvent(event *sink.Event) {
+	sealed, reverted := s.apply(event)
+
+	if reverted && s.onReverted != nil {
+		s.onReverted()
+	}
+
+	if sealed != nil && s.onSealed != nil {
+		s.onSealed(sealed)
+	}
+}
+
+// apply applies the change data event into the near-head cache, and returns the epoch sealed by
+// the event if any, and whether any epoch reverted.
+func (s *logStream) apply(event *sink.Event) (sealed *streamEpoch, reverted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer func(revision uint64) {
+		reverted = s.revision > revision
+	}(s.revision)
+
+	switch event.Type {
+	case sink.EventRevert:
+		s.revert(event.Epoch)
+		return nil, false
+	case sink.EventBlock, sink.EventTransaction, sink.EventLog:
+	default:
+		return nil, false
+	}
+
+	if n := len(s.epochs); n > 0 && s.epochs[n-1].ids != nil {
+		// the latest epoch is sealed once the event of the next epoch accepted
+		defer func(latest *streamEpoch) {
+			if latest.ids == nil {
+				sealed = latest
+			}
+		}(s.epochs[n-1])
+	}
+
+	if !s.accept(event) {
+		return nil, false
+	}
+
+	latest := s.epochs[len(s.epochs)-1]
+	if _, ok := latest.ids[event.ID]; ok { // duplicate event
+		return nil, false
+	}
+
+	latest.ids[event.ID] = struct{}{}
+
+	if event.Type != sink.EventLog {
+		return nil, false
+	}
+
+	var log sink.LogData
+	if err := json.Unmarshal(event.Data, &log); err != nil {
+		logrus.WithField("event", event.ID).WithError(err).Error("Log stream failed to unmarshal event log")
+		return nil, false
+	}
+
+	latest.logs = append(latest.logs, log)
+	return nil, false
+}
+
+// accept locates the latest epoch for the event, or returns false if the event is stale.
+func (s *logStream) accept(event *sink.Event) bool {
+	if len(s.epochs) > 0 {
+		latest := s.epochs[len(s.epochs)-1]
+
+		if event.Epoch == latest.number && event.PivotHash == latest.pivotHash {
+			return latest.ids != nil
+		}
+
+		if event.Epoch <= latest.number {
+			idx := sort.Search(len(s.epochs), func(i int) bool { return s.epochs[i].number >= event.Epoch })
+			if idx < len(s.epochs) && s.epochs[idx].pivotHash == event.PivotHash {
+				return false // re-delivered event of the committed epoch
+			}
+
+			if idx == 0 && s.epochs[0].number > event.Epoch {
+				return false // event prior to the near-head cache
+			}
+
+			// pivot switched without revert event received
+			s.revert(event.Epoch)
+		} else if event.Epoch > latest.number+1 {
+			s.discontinue()
+		} else {
+			latest.ids = nil
+		}
+	}
+
+	s.epochs = append(s.epochs, &streamEpoch{
+		number: event.Epoch, pivotHash: event.PivotHash, ids: make(map[string]struct{}),
+	})
+
+	if len(s.epochs) > s.capacity {
+		s.epochs = s.epochs[len(s.epochs)-s.capacity:]
+	}
+
+	return true
+}
+
+// revert reverts the cached epochs since the specified epoch (inclusive). Note the revert is also
+// recorded if no epoch cached, since log filters may have delivered the epochs from db store.
+func (s *logStream) revert(from uint64) {
+	if len(s.epochs) > 0 && s.epochs[len(s.epochs)-1].number < from {
+		return
+	}
+
+	idx := sort.Search(len(s.epochs), func(i int) bool { return s.epochs[i].number >= from })
+
+	s.addRevert(&streamRevert{from: from, epochs: slices.Clone(s.epochs[idx:])})
+	s.epochs = s.epochs[:idx]
+}
+
+// addRevert records the revert with a new revision of the stream.
+func (s *logStream) addRevert(r *streamRevert) {
+	s.revision++
+	r.revision = s.revision
+
+	s.reverts = append(s.reverts, r)
+	if len(s.reverts) > maxStreamReverts {
+		s.reverts = s.reverts[len(s.reverts)-maxStreamReverts:]
+	}
+}
+
+// sealed returns the cached epochs of which all events have been received. Note the latest epoch
+// is held back until events of the next epoch received, since its events may be received partially.
+func (s *logStream) sealed() []*streamEpoch {
+	if n := len(s.epochs); n > 0 && s.epochs[n-1].ids != nil {
+		return s.epochs[:n-1]
+	}
+
+	return s.epochs
+}
+
+// head returns the latest sealed epoch.
+func (s *logStream) head() (uint64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sealed := s.sealed()
+	if len(sealed) == 0 {
+		return 0, false
+	}
+
+	return sealed[len(sealed)-1].number, true
+}
+
+func (s *logStream) currentRevision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.revision
+}
+
+// changes returns the changes since the delivered epoch and the revision of last delivery.
+func (s *logStream) changes(delivered, revision uint64) *streamChanges {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := &streamChanges{revision: s.revision}
+
+	for _, r := range s.reverts {
+		if r.revision <= revision || r.from > delivered {
+			continue
+		}
+
+		idx := sort.Search(len(r.epochs), func(i int) bool { return r.epochs[i].number > delivered })
+
+		if r.gap {
+			res.unverified = mergeStreamEpochs(res.unverified, r.epochs[:idx])
+			continue
+		}
+
+		res.reverted = mergeStreamEpochs(res.reverted, r.epochs[:idx])
+		res.reorged, delivered = true, r.from-1
+	}
+
+	res.revertTo = delivered
+
+	sealed := s.sealed()
+	if len(sealed) == 0 {
+		return res
+	}
+
+	res.ready, res.oldest = true, sealed[0].number
+
+	idx := sort.Search(len(sealed), func(i int) bool { return sealed[i].number > delivered })
+	res.epochs = slices.Clone(sealed[idx:])
+
+	return res
+}
+
+// streamStore db store of the indexed chain data by syncer, which is used by log filters driven by
+// the commit stream to load event logs and verify the delivered epochs.
+type streamStore interface {
+	MaxEpoch() (uint64, bool, error)
+	PivotHash(epoch uint64) (string, bool, error)
+	PivotHashes(epochFrom, epochTo uint64) (map[uint64]string, error)
+	GetLogs(ctx context.Context, filter store.LogFilter) ([]*store.Log, error)
+}
+
+// streamLogFilter base struct for log filter driven by the commit stream of syncer, which polls no
+// filter changes from full node but the near-head cache, or db store if falling behind the cache.
+type streamLogFilter struct {
+	filterBase
+
+	stream *logStream
+	db     streamStore
+
+	mu sync.Mutex
+	// epoch (or block) number up to which changes have been delivered
+	delivered uint64
+	// revision of the stream upon last delivery to detect chain reorg
+	revision uint64
+	// delivered epochs in ascending order, which are not covered by revert events of the stream
+	// but verified against db store, e.g., epochs cached before events missed, or loaded from db
+	// store while the stream is not ready.
+	unverified []*streamEpoch
+}
+
+func newStreamLogFilter(fs *filterSystemBase, fid rpc.ID, delivered uint64) streamLogFilter {
+	return streamLogFilter{
+		filterBase: filterBase{
+			id:              fid,
+			typ:             filterTypeLog,
+			lastPollingTime: time.Now(),
+		},
+		stream:    fs.stream,
+		db:        fs.db,
+		delivered: delivered,
+		revision:  fs.stream.currentRevision(),
+	}
+}
+
+func (f *streamLogFilter) deliveredTo() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delivered
+}
+
+// changes returns the changes of the commit stream since last delivery, along with the delivered
+// epochs to verify on next delivery. Any unverified epoch of which pivot switched in db store is
+// reverted as well, so that event logs of the epochs reorged while events missed are retracted.
+func (f *streamLogFilter) changes() (*streamChanges, []*streamEpoch, error) {
+	sc := f.stream.changes(f.delivered, f.revision)
+
+	reorged, reverted := sc.reorged, sc.reverted
+	unverified := mergeStreamEpochs(f.unverified, sc.unverified)
+
+	for {
+		// unverified epochs reverted by the stream
+		idx := sort.Search(len(unverified), func(i int) bool { return unverified[i].number > sc.revertTo })
+		reverted = mergeStreamEpochs(reverted, unverified[idx:])
+		unverified = unverified[:idx]
+
+		idx, err := f.verify(unverified)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "failed to verify delivered epochs")
+		}
+
+		if idx == len(unverified) {
+			break
+		}
+
+		// revert since the pivot switched epoch, and reload changes from the epoch before
+		next := f.stream.changes(unverified[idx].number-1, sc.revision)
+
+		reverted = mergeStreamEpochs(reverted, unverified[idx:])
+		reverted = mergeStreamEpochs(reverted, next.reverted)
+		unverified = mergeStreamEpochs(unverified[:idx], next.unverified)
+		reorged, sc = true, next
+	}
+
+	sc.reorged, sc.reverted = reorged, reverted
+	return sc, unverified, nil
+}
+
+// verify verifies the pivot hashes of the epochs against db store, and returns the index of the
+// first pivot switched epoch, or the number of epochs if none switched. Note all the epochs prior
+// to an epoch of which pivot hash matched are on the pivot chain too.
+func (f *streamLogFilter) verify(epochs []*streamEpoch) (int, error) {
+	for i := len(epochs) - 1; i >= 0; i-- {
+		pivotHash, ok, err := f.db.PivotHash(epochs[i].number)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok && pivotHash == epochs[i].pivotHash {
+			return i + 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// fallbackRange returns the epoch range to load event logs from db store for epochs not cached,
+// or false if the near-head cache covers all changes. If the stream is not ready, event logs are
+// loaded from db store up to the latest epoch of db store.
+func (f *streamLogFilter) fallbackRange(sc *streamChanges) (uint64, uint64, bool, error) {
+	from, to := sc.revertTo+1, sc.oldest-1
+
+	if !sc.ready {
+		maxEpoch, ok, err := f.db.MaxEpoch()
+		if err != nil {
+			return 0, 0, false, errors.WithMessage(err, "failed to get max epoch from db store")
+		}
+
+		if !ok || from > maxEpoch {
+			return 0, 0, false, nil
+		}
+
+		to = maxEpoch
+	} else if from >= sc.oldest {
+		return 0, 0, false, nil
+	}
+
+	if to-from >= maxStreamFallbackEpochs { // load in batches
+		to = from + maxStreamFallbackEpochs - 1
+	}
+
+	return from, to, true, nil
+}
+
+// loadStore loads event logs from db store within the specified epoch range by the loader. If the
+// stream is not ready, the loaded epochs are tracked as unverified since no revert event could be
+// applied, and note the pivot hashes are loaded ahead of event logs so that event logs of any epoch
+// reorged in between would be reverted upon verification.
+func (f *streamLogFilter) loadStore(
+	sc *streamChanges,
+	from, to uint64,
+	unverified []*streamEpoch,
+	loader func(from, to uint64) ([]sink.LogData, error),
+) ([]sink.LogData, []*streamEpoch, error) {
+	if sc.ready {
+		logs, err := loader(from, to)
+		return logs, unverified, err
+	}
+
+	// only the recent epochs within the capacity of the near-head cache are tracked
+	if to-from >= uint64(f.stream.capacity) {
+		from = to - uint64(f.stream.capacity) + 1
+	}
+
+	pivotHashes, err := f.db.PivotHashes(from, to)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to get pivot hashes from db store")
+	}
+
+	logs, err := loader(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	epochs := make([]*streamEpoch, 0, len(pivotHashes))
+	for epoch := from; epoch <= to; epoch++ {
+		if pivotHash, ok := pivotHashes[epoch]; ok {
+			epochs = append(epochs, &streamEpoch{number: epoch, pivotHash: pivotHash})
+		}
+	}
+
+	for _, log := range logs {
+		epoch := log.EpochNumber.ToInt().Uint64()
+
+		idx := sort.Search(len(epochs), func(i int) bool { return epochs[i].number >= epoch })
+		if idx < len(epochs) && epochs[idx].number == epoch {
+			epochs[idx].logs = append(epochs[idx].logs, log)
+		}
+	}
+
+	return logs, mergeStreamEpochs(unverified, epochs), nil
+}
+
+// deliver updates the delivery state of the log filter, where the unverified epochs out of the
+// capacity of the near-head cache are no longer retained.
+func (f *streamLogFilter) deliver(delivered, revision uint64, unverified []*streamEpoch) {
+	idx := sort.Search(len(unverified), func(i int) bool {
+		return unverified[i].number+uint64(f.stream.capacity) > delivered
+	})
+
+	f.delivered, f.revision, f.unverified = delivered, revision, unverified[idx:]
+}
+
+// mergeStreamEpochs merges the epochs in ascending order, where the former takes precedence over
+// the latter for epochs of the same number.
+func mergeStreamEpochs(epochs, others []*streamEpoch) []*streamEpoch {
+	if len(others) == 0 {
+		return epochs
+	}
+
+	merged := make([]*streamEpoch, 0, len(epochs)+len(others))
+	for i, j := 0, 0; i < len(epochs) || j < len(others); {
+		switch {
+		case j == len(others) || (i < len(epochs) && epochs[i].number < others[j].number):
+			merged = append(merged, epochs[i])
+			i++
+		case i == len(epochs) || others[j].number < epochs[i].number:
+			merged = append(merged, others[j])
+			j++
+		default: // same epoch number
+			merged = append(merged, epochs[i])
+			i, j = i+1, j+1
+		}
+	}
+
+	return merged
+}
+
+// convertStoreLogs converts event logs of db store to the payload format of the commit stream.
+func convertStoreLogs(slogs []*store.Log) []sink.LogData {
+	logs := make([]sink.LogData, 0, len(slogs))
+	for _, v := range slogs {
+		log, logExt := v.ToCfxLog()
+		logs = append(logs, sink.LogData{Log: *log, Extra: logExt})
+	}
+
+	return logs
+}
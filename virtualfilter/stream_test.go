@@ -0,0 +1,157 @@
+package virtualfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/sync/sink"
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStreamLog(epoch uint64) sink.LogData {
+	logType := "call"
+
+	return sink.LogData{
+		Log: cfxtypes.Log{
+			EpochNumber:      cfxtypes.NewBigInt(epoch),
+			TransactionIndex: cfxtypes.NewBigInt(0),
+			LogIndex:         cfxtypes.NewBigInt(0),
+		},
+		Extra: &store.LogExtra{LogType: &logType},
+	}
+}
+
+func newTestStreamEvents(t *testing.T, epoch uint64, pivotHash string, numLogs int) []*sink.Event {
+	newEvent := func(et sink.EventType, data []byte, idx int) *sink.Event {
+		return &sink.Event{
+			ID:        fmt.Sprintf("cfx:%v:%v:%v", epoch, pivotHash, idx),
+			Space:     "cfx",
+			Type:      et,
+			Epoch:     epoch,
+			PivotHash: pivotHash,
+			Data:      data,
+		}
+	}
+
+	events := []*sink.Event{newEvent(sink.EventBlock, nil, 0)}
+	for i := 0; i < numLogs; i++ {
+		data, err := json.Marshal(newTestStreamLog(epoch))
+		require.NoError(t, err)
+
+		events = append(events, newEvent(sink.EventLog, data, i+1))
+	}
+
+	return events
+}
+
+func applyTestStreamEvents(s *logStream, events []*sink.Event) {
+	for _, e := range events {
+		s.onEvent(e)
+	}
+}
+
+func TestLogStreamChanges(t *testing.T) {
+	s := newLogStream("cfx", 10)
+
+	e1 := newTestStreamEvents(t, 1, "0x01", 1)
+	applyTestStreamEvents(s, e1)
+
+	// the latest epoch is held back
+	_, ok := s.head()
+	assert.False(t, ok)
+
+	applyTestStreamEvents(s, newTestStreamEvents(t, 2, "0x02", 2))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 3, "0x03", 0))
+
+	// re-delivered events are deduplicated
+	applyTestStreamEvents(s, e1)
+
+	head, ok := s.head()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), head)
+
+	sc := s.changes(0, 0)
+	assert.False(t, sc.reorged)
+	assert.True(t, sc.ready)
+	assert.Equal(t, uint64(1), sc.oldest)
+	require.Len(t, sc.epochs, 2)
+	assert.Len(t, sc.epochs[0].logs, 1)
+	assert.Len(t, sc.epochs[1].logs, 2)
+
+	sc = s.changes(1, 0)
+	require.Len(t, sc.epochs, 1)
+	assert.Equal(t, uint64(2), sc.epochs[0].number)
+
+	// revert and switch to new pivot chain
+	s.onEvent(sink.NewRevertEvent("cfx", 2, "0x02"))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 2, "0x12", 1))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 3, "0x13", 0))
+
+	// delivered epoch reverted
+	sc = s.changes(2, 0)
+	assert.True(t, sc.reorged)
+	assert.Equal(t, uint64(1), sc.revertTo)
+	require.Len(t, sc.reverted, 1)
+	assert.Equal(t, "0x02", sc.reverted[0].pivotHash)
+	require.Len(t, sc.epochs, 1)
+	assert.Equal(t, "0x12", sc.epochs[0].pivotHash)
+	assert.Equal(t, uint64(1), sc.revision)
+
+	// reverted epochs not delivered yet
+	sc = s.changes(1, 0)
+	assert.False(t, sc.reorged)
+	assert.Len(t, sc.epochs, 1)
+
+	// revert already delivered
+	sc = s.changes(2, 1)
+	assert.False(t, sc.reorged)
+	assert.Empty(t, sc.epochs)
+
+	// events missed
+	applyTestStreamEvents(s, newTestStreamEvents(t, 10, "0x0a", 0))
+	_, ok = s.head()
+	assert.False(t, ok)
+
+	applyTestStreamEvents(s, newTestStreamEvents(t, 11, "0x0b", 0))
+	sc = s.changes(2, 1)
+	assert.True(t, sc.ready)
+	assert.Equal(t, uint64(10), sc.oldest)
+}
+
+func TestLogStreamChangesAfterGap(t *testing.T) {
+	s := newLogStream("cfx", 10)
+
+	applyTestStreamEvents(s, newTestStreamEvents(t, 1, "0x01", 1))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 2, "0x02", 1))
+	applyTestStreamEvents(s, newTestStreamEvents(t, 3, "0x03", 1))
+
+	// events missed, and the sealed epochs are retained to verify
+	applyTestStreamEvents(s, newTestStreamEvents(t, 10, "0x0a", 0))
+
+	sc := s.changes(2, 0)
+	assert.False(t, sc.reorged)
+	assert.False(t, sc.ready)
+	require.Len(t, sc.unverified, 2)
+	assert.Equal(t, "0x01", sc.unverified[0].pivotHash)
+	assert.Equal(t, "0x02", sc.unverified[1].pivotHash)
+
+	// gap already delivered
+	sc = s.changes(2, sc.revision)
+	assert.Empty(t, sc.unverified)
+
+	// revert recorded though no epoch cached
+	s.mu.Lock()
+	s.discontinue()
+	s.mu.Unlock()
+
+	s.onEvent(sink.NewRevertEvent("cfx", 8, "0x08"))
+
+	sc = s.changes(9, 1)
+	assert.True(t, sc.reorged)
+	assert.Equal(t, uint64(7), sc.revertTo)
+	assert.Empty(t, sc.reverted)
+}
@@ -1,14 +1,16 @@
 package virtualfilter
 
 import (
+	"sync"
 	"time"
 
 	cmdutil "github.com/Conflux-Chain/confura/cmd/util"
 	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/sync/sink"
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/confura/util/metrics"
-	gethmetrics "github.com/ethereum/go-ethereum/metrics"
 	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,41 +18,318 @@ const (
 	// filter change polling settings
 	pollingInterval         = 1 * time.Second
 	maxPollingDelayDuration = 1 * time.Minute
+
+	// max attempts to generate virtual filter id owned by this service instance
+	maxLocalFilterIDAttempts = 16
 )
 
 // filterSystemBase base struct for virtual filter system, which creates filter worker to
 // establish proxy log filter to full node, and consistantly polls event logs from the full node
 // to persist data in db/cache store for high performance and stable log filter data retrieval service.
 type filterSystemBase struct {
-	filterMgr *filterManager     // virtual filter manager
-	workers   util.ConcurrentMap // filter workers
+	filterMgr   *filterManager     // virtual filter manager
+	workers     util.ConcurrentMap // log filter workers
+	hashWorkers util.ConcurrentMap // block and pending txn filter workers
 
 	// log store to persist changed logs for more reliability
 	logStore *mysql.VirtualFilterLogStore
 
+	// db store of the indexed chain data by syncer
+	db *mysql.MysqlStore
+	// near-head cache of the commit stream to drive log filters, nil if disabled
+	stream *logStream
+
+	// notifier to wake up long polling requests once new filter changes arrive
+	notifier *changeNotifier
+
+	// quota accounting of virtual filters per owner
+	quotas *filterQuotas
+
+	// durable registry of virtual filters, nil if disabled
+	registry filterRegistry
+	// cluster to shard log filters across service instances, nil if registry disabled
+	cluster *filterCluster
+	// mutex to restore virtual filter from registry
+	restoreMu sync.Mutex
+
 	// graceful shutdown context
 	shutdownCtx cmdutil.GracefulShutdownContext
 }
 
 func newFilterSystemBase(
+	space string,
 	ttl time.Duration,
-	vfls *mysql.VirtualFilterLogStore,
+	regConf *registryConfig,
+	streamConf *streamConfig,
+	sinkViperKey string,
+	countStreamLogs func(vf virtualFilter, epoch *streamEpoch) int,
+	db *mysql.MysqlStore,
 	shutdownCtx cmdutil.GracefulShutdownContext,
 ) *filterSystemBase {
 	fs := &filterSystemBase{
-		logStore:    vfls,
+		logStore:    db.VirtualFilterLogStore,
+		db:          db,
 		shutdownCtx: shutdownCtx,
 		filterMgr:   newFilterManager(),
+		notifier:    newChangeNotifier(),
 	}
 
+	if streamConf.Enabled {
+		sub := sink.MustNewSubscriberFromViper(sinkViperKey)
+		if sub == nil {
+			logrus.WithField("sink", sinkViperKey).Fatal("Change data capture sink of syncer must be enabled for log stream")
+		}
+
+		fs.stream = newLogStream(space, streamConf.CacheSize)
+		fs.stream.onSealed = func(epoch *streamEpoch) {
+			fs.accumulateLogs(func(vf virtualFilter) int { return countStreamLogs(vf, epoch) })
+
+			// only wake up the log filters matched with the sealed epoch
+			fs.notifyFilters(func(vf virtualFilter) bool { return countStreamLogs(vf, epoch) > 0 })
+		}
+		fs.stream.onReverted = func() {
+			fs.notifyFilters(func(vf virtualFilter) bool { return vf.ftype() == filterTypeLog })
+		}
+
+		go fs.stream.run(shutdownCtx.Ctx, sub)
+	}
+
+	if len(regConf.Backend) > 0 {
+		fs.registry = mustNewFilterRegistry(space, regConf, ttl, db.VirtualFilterStore)
+		fs.cluster = newFilterCluster(regConf, fs.registry)
+
+		go fs.cluster.run(shutdownCtx.Ctx, fs.rebalance)
+	}
+
+	fs.quotas = newFilterQuotas(space, fs.registry)
+
 	go fs.timeoutLoop(ttl)
 	return fs
 }
 
+// streamCursor returns the latest epoch (or block) of the commit stream as the delivered cursor of
+// new log filter, or the max epoch of db store if the stream is not ready yet.
+func (fs *filterSystemBase) streamCursor() (uint64, error) {
+	if head, ok := fs.stream.head(); ok {
+		return head, nil
+	}
+
+	maxEpoch, _, err := fs.db.MaxEpoch()
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to get max epoch from db store")
+	}
+
+	return maxEpoch, nil
+}
+
 func (fs *filterSystemBase) getFilter(id rpc.ID) (virtualFilter, bool) {
 	return fs.filterMgr.get(id)
 }
 
+// newFilterID generates virtual filter id, which is owned by this service instance if possible
+// so that the virtual filter could be served right away without being restored by the owner.
+func (fs *filterSystemBase) newFilterID() rpc.ID {
+	id := rpc.NewID()
+	if fs.cluster == nil {
+		return id
+	}
+
+	for i := 0; i < maxLocalFilterIDAttempts && !fs.cluster.isLocal(id); i++ {
+		id = rpc.NewID()
+	}
+
+	return id
+}
+
+// addFilter adds the virtual filter into memory, and also into the durable registry if enabled.
+// The virtual filter is released if owned by the other service instance, which will be restored
+// by the owner upon polling.
+func (fs *filterSystemBase) addFilter(f virtualFilter, rec *filterRecord) error {
+	if fs.registry != nil {
+		if err := fs.registry.put(rec); err != nil {
+			return errors.WithMessage(err, "failed to register virtual filter")
+		}
+
+		if !fs.cluster.isLocal(f.fid()) {
+			f.uninstall()
+			return nil
+		}
+	}
+
+	fs.filterMgr.add(f)
+	return nil
+}
+
+// loadFilter loads the virtual filter from memory, or restores it from the durable registry if
+// enabled. If the virtual filter is owned by the other service instance, the owner url is returned
+// so that the request could be forwarded unless the request is already forwarded (local).
+func (fs *filterSystemBase) loadFilter(
+	id rpc.ID, local bool, restore func(rec *filterRecord) (virtualFilter, error),
+) (virtualFilter, string, error) {
+	if vf, ok := fs.filterMgr.get(id); ok {
+		return vf, "", nil
+	}
+
+	if fs.registry == nil {
+		return nil, "", errFilterNotFound
+	}
+
+	rec, err := fs.registry.get(id)
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "failed to get virtual filter from registry")
+	}
+
+	if rec == nil {
+		return nil, "", errFilterNotFound
+	}
+
+	if !local && !fs.cluster.isLocal(id) {
+		return nil, fs.cluster.owner(id), nil
+	}
+
+	fs.restoreMu.Lock()
+	defer fs.restoreMu.Unlock()
+
+	if vf, ok := fs.filterMgr.get(id); ok { // already restored
+		return vf, "", nil
+	}
+
+	vf, err := restore(rec)
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "failed to restore virtual filter")
+	}
+
+	fs.filterMgr.add(vf)
+	fs.quotas.track(id, rec.Owner, rec.MaxLogs)
+
+	return vf, "", nil
+}
+
+// fetchChanges fetches changes of the virtual filter, and waits up to the timeout until any change
+// arrives if no change yet, so as to cut empty polling requests.
+func (fs *filterSystemBase) fetchChanges(
+	vf virtualFilter, wait time.Duration, empty func(fc filterChanges) bool,
+) (filterChanges, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		// listen before fetch in case of any change missed in between
+		notified := fs.notifier.listen(vf.fid())
+
+		fc, err := vf.fetch()
+		if err != nil || wait <= 0 || !empty(fc) {
+			return fc, err
+		}
+
+		select {
+		case <-notified:
+		case <-timer.C:
+			return fc, nil
+		case <-fs.shutdownCtx.Ctx.Done():
+			return fc, nil
+		}
+	}
+}
+
+// notifyFilters wakes up the long polling requests waiting for the virtual filters matched, or removed
+// already. Note only the virtual filters being waited for are matched, so as to reduce overhead.
+func (fs *filterSystemBase) notifyFilters(match func(vf virtualFilter) bool) {
+	var ids []rpc.ID
+	for _, id := range fs.notifier.waiting() {
+		if vf, ok := fs.filterMgr.get(id); !ok || match(vf) {
+			ids = append(ids, id)
+		}
+	}
+
+	fs.notifier.notify(ids...)
+}
+
+// checkLogsQuota checks if the buffered logs of the virtual filter exceeded the quota of the filter
+// owner since the last polling, in which case the virtual filter should be uninstalled.
+func (fs *filterSystemBase) checkLogsQuota(id rpc.ID) error {
+	if maxLogs, exceeded := fs.quotas.exceeded(id); exceeded {
+		return errors.Errorf(
+			"filter changes exceeded the quota of %v buffered logs, please poll more frequently or narrow the criteria",
+			maxLogs,
+		)
+	}
+
+	return nil
+}
+
+// accumulateLogs accounts the logs accumulated for the virtual filters limited by the quota of
+// buffered logs, where the number of logs matched by each virtual filter is counted by `count`,
+// and evicts the virtual filters of which the quota exceeded right away so as to stop buffering.
+// Note the virtual filter record is retained until the next polling, which fails with the quota
+// exceeded error.
+func (fs *filterSystemBase) accumulateLogs(count func(vf virtualFilter) int) {
+	for _, id := range fs.quotas.limited() {
+		vf, ok := fs.filterMgr.get(id)
+		if !ok {
+			continue
+		}
+
+		if n := count(vf); n == 0 || !fs.quotas.accumulate(id, n) {
+			continue
+		}
+
+		if _, ok := fs.filterMgr.delete(id); !ok {
+			continue
+		}
+
+		if _, err := vf.uninstall(); err != nil {
+			logrus.WithField("fid", id).WithError(err).Info("Filter system failed to evict quota exceeded virtual filter")
+		}
+	}
+}
+
+// touch refreshes the last polling time and delivered cursor of the virtual filter in registry.
+func (fs *filterSystemBase) touch(vf virtualFilter) {
+	if fs.registry == nil {
+		return
+	}
+
+	var cursor uint64
+	if df, ok := vf.(durableFilter); ok {
+		cursor = df.deliveredTo()
+	}
+
+	if err := fs.registry.touch(vf.fid(), cursor); err != nil {
+		logrus.WithField("fid", vf.fid()).WithError(err).Info("Filter system failed to touch virtual filter")
+	}
+}
+
+// unregister deletes the virtual filter from registry.
+func (fs *filterSystemBase) unregister(id rpc.ID) error {
+	if fs.registry == nil {
+		return nil
+	}
+
+	err := fs.registry.delete(id)
+	if err != nil {
+		logrus.WithField("fid", id).WithError(err).Info("Filter system failed to unregister virtual filter")
+	}
+
+	return err
+}
+
+// rebalance releases the virtual filters owned by the other service instances after membership
+// changed, which will be restored by the new owners with the delivered cursor from registry.
+func (fs *filterSystemBase) rebalance() {
+	released := fs.filterMgr.evict(func(f virtualFilter) bool {
+		return !fs.cluster.isLocal(f.fid())
+	})
+
+	for _, vf := range released {
+		vf.uninstall()
+	}
+
+	if len(released) > 0 {
+		logrus.WithField("count", len(released)).Info("Filter system released virtual filters for rebalance")
+	}
+}
+
 // timeoutLoop runs at the interval set by 'ttl' and deletes expired virtual filters
 func (fs *filterSystemBase) timeoutLoop(ttl time.Duration) {
 	ticker := time.NewTicker(ttl / 2)
@@ -60,6 +339,16 @@ func (fs *filterSystemBase) timeoutLoop(ttl time.Duration) {
 		expfs := fs.filterMgr.expire(ttl)
 		for _, vf := range expfs {
 			vf.uninstall()
+			fs.unregister(vf.fid())
+			fs.notifier.notify(vf.fid())
+		}
+
+		fs.quotas.expire(ttl)
+
+		if fs.registry != nil {
+			if err := fs.registry.expire(ttl); err != nil {
+				logrus.WithError(err).Info("Filter system failed to expire virtual filters in registry")
+			}
 		}
 	}
 }
@@ -93,18 +382,59 @@ func (fs *filterSystemBase) onClosed(nodeName string, fid rpc.ID) error {
 }
 
 func metricVirtualFilterSession(space string, f virtualFilter, delta int64) {
-	var gauge gethmetrics.Gauge
-
 	switch f.ftype() {
-	case filterTypeBlock:
-		gauge = metrics.Registry.VirtualFilter.Sessions(space, "block", f.nodeName())
-	case filterTypePendingTxn:
-		gauge = metrics.Registry.VirtualFilter.Sessions(space, "pendingTxn", f.nodeName())
-	case filterTypeLog:
-		gauge = metrics.Registry.VirtualFilter.Sessions(space, "log", f.nodeName())
-	default:
-		return
+	case filterTypeBlock, filterTypePendingTxn, filterTypeLog:
+		metrics.Registry.VirtualFilter.Sessions(space, f.ftype().String(), f.nodeName()).Inc(delta)
+	}
+}
+
+// changeNotifier notifies the long polling requests of virtual filters once new filter changes
+// arrive, which only wakes up the requests waiting for the changed virtual filters rather than all.
+type changeNotifier struct {
+	mu      sync.Mutex
+	waiters map[rpc.ID]chan struct{} // virtual filter id => channel closed upon the next notification
+}
+
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{waiters: make(map[rpc.ID]chan struct{})}
+}
+
+// listen returns the channel which is closed upon the next notification of the virtual filter.
+func (n *changeNotifier) listen(id rpc.ID) <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ch, ok := n.waiters[id]
+	if !ok {
+		ch = make(chan struct{})
+		n.waiters[id] = ch
+	}
+
+	return ch
+}
+
+// waiting returns the virtual filters being waited for by long polling requests.
+func (n *changeNotifier) waiting() []rpc.ID {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ids := make([]rpc.ID, 0, len(n.waiters))
+	for id := range n.waiters {
+		ids = append(ids, id)
 	}
 
-	gauge.Inc(delta)
+	return ids
+}
+
+// notify wakes up the long polling requests waiting for the virtual filters.
+func (n *changeNotifier) notify(ids ...rpc.ID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, id := range ids {
+		if ch, ok := n.waiters[id]; ok {
+			close(ch)
+			delete(n.waiters, id)
+		}
+	}
 }
@@ -22,7 +22,8 @@ import (
 var (
 	nilPollingSession = pollingSession{fid: nilRpcId}
 
-	errFilterWorkerShutdown = errors.New("filter worker already shutdown")
+	errFilterWorkerShutdown        = errors.New("filter worker already shutdown")
+	errWorkerSessionNotEstablished = errors.New("worker session not established")
 )
 
 // pollingSession session context data for the filer worker during polling
@@ -232,6 +233,87 @@ func (w *filterWorker) dumpFilterChain() {
 	}
 }
 
+// dumpChain returns the nodes along the filter chain of the polling session.
+func (w *filterWorker) dumpChain() ([]filterNodeInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.session.fchain == nil {
+		return nil, errWorkerSessionNotEstablished
+	}
+
+	var nodes []filterNodeInfo
+	err := w.session.fchain.traverse(nilFilterCursor, func(node *filterNode, forkPoint bool) bool {
+		if node != nil {
+			nodes = append(nodes, filterNodeInfo{
+				Height:    node.cursor().height,
+				Hash:      node.cursor().hash,
+				Reorged:   node.reorged(),
+				ForkPoint: forkPoint,
+			})
+		}
+
+		return true
+	})
+
+	return nodes, err
+}
+
+// info returns the polling session info, including the cursor lag of delegate virtual filters.
+func (w *filterWorker) info() workerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wi := workerInfo{
+		Node:            w.nodeName,
+		Type:            filterTypeLog.String(),
+		ProxyFilter:     w.session.fid,
+		LastPollingTime: w.session.lastPollingTime,
+		Lags:            make(map[rpc.ID]uint64),
+	}
+
+	if w.session.fchain != nil {
+		wi.Head = w.session.fchain.snapshotLatestCursor().height
+	}
+
+	for fid, cursor := range w.session.fcursors {
+		wi.Lags[fid] = wi.Head - min(cursor.height, wi.Head)
+	}
+
+	return wi
+}
+
+// reset forces to re-establish the polling session, and the delegate virtual filters continue from
+// the latest cursor of the new session, so changes in between might be missed.
+func (w *filterWorker) reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.session.fid == nilRpcId {
+		return errWorkerSessionNotEstablished
+	}
+
+	session, err := w.client.establish()
+	if err != nil {
+		return errors.WithMessage(err, "failed to establish polling session")
+	}
+
+	for fid := range w.session.fcursors {
+		session.fcursors[fid] = session.fchain.snapshotLatestCursor()
+	}
+
+	oldfid := w.session.fid
+	w.session = session
+	w.client.uninstall(oldfid)
+
+	if w.observer != nil {
+		w.observer.onClosed(w.nodeName, oldfid)
+		w.observer.onEstablished(w.nodeName, session.fid)
+	}
+
+	return nil
+}
+
 func (w *filterWorker) merge(fchanges filterChanges) error {
 	startTime := time.Now()
 	defer metrics.Registry.VirtualFilter.PersistFilterChanges(w.space, w.nodeName, "memory").UpdateSince(startTime)